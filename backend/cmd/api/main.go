@@ -15,13 +15,16 @@ import (
 	"kelo-backend/pkg/logger"
 	"kelo-backend/pkg/liquidity"
 	"kelo-backend/pkg/merchant"
+	"kelo-backend/pkg/metrics"
 	"kelo-backend/api/handlers"
 	"kelo-backend/pkg/admin"
 	"kelo-backend/pkg/bnpl"
 	"kelo-backend/pkg/order"
 	"kelo-backend/pkg/product"
+	"kelo-backend/pkg/provisioning"
 	"kelo-backend/pkg/relayer"
 	"kelo-backend/pkg/staking"
+	"kelo-backend/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -38,6 +41,17 @@ func main() {
 	// Initialize logger
 	logger.Init(cfg.LogLevel)
 
+	// Trace requests through to the services, external APIs and chains they call
+	shutdownTracing, err := tracing.Init(context.Background(), "kelo-api", cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
+	// Time Supabase queries; this must happen before the first query
+	if err := metrics.InstrumentSupabase(cfg.SupabaseURL); err != nil {
+		log.Fatal().Err(err).Msg("Failed to instrument Supabase client")
+	}
+
 	// Initialize Supabase client
 	supabaseClient, err := supabase.NewClient(cfg.SupabaseURL, cfg.SupabaseServiceRoleKey, nil)
 	if err != nil {
@@ -52,9 +66,17 @@ func main() {
 
 	// Initialize services
 	creditScoreService := creditscore.NewCreditScoreService(supabaseClient, blockchainClients, cfg)
-	relayerService, err := relayer.NewTrustedRelayer(cfg, blockchainClients)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to initialize relayer service")
+
+	// The relayer normally runs as cmd/relayer. Running it here as well would send every
+	// message twice, so the API only runs it when asked to.
+	var relayerService *relayer.TrustedRelayer
+	if cfg.RunRelayer {
+		relayerService, err = relayer.NewTrustedRelayer(cfg, blockchainClients, supabaseClient)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize relayer service")
+		}
+	} else {
+		log.Info().Msg("Relayer disabled in the API; run cmd/relayer or set RUN_RELAYER=true")
 	}
 
 	// Initialize services
@@ -65,11 +87,17 @@ func main() {
 	bnplService := bnpl.NewService()
 	repaymentService := bnpl.NewRepaymentService(supabaseClient, blockchainClients)
 	stakingService := staking.NewService()
-	adminService := admin.NewService(supabaseClient)
+	eclTables := provisioning.DefaultTables()
+	if cfg.ECLTablesPath != "" {
+		eclTables, err = provisioning.LoadTables(cfg.ECLTablesPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load provisioning tables")
+		}
+	}
+	adminService := admin.NewService(supabaseClient, provisioning.NewCalculator(eclTables))
 
 	// Initialize handlers
 	creditScoreHandler := creditscore.NewCreditScoreHandler(creditScoreService)
-	relayerHandler := relayer.NewHandler(relayerService)
 	productHandler := product.NewHandler(productService)
 	merchantHandler := merchant.NewHandler(merchantService)
 	orderHandler := order.NewHandler(orderService)
@@ -86,14 +114,19 @@ func main() {
 
 	router := gin.New()
 	router.Use(gin.Recovery())
+	router.Use(tracing.GinMiddleware("kelo-backend/cmd/api"))
+	router.Use(logger.RequestIDMiddleware())
 	router.Use(logger.GinMiddleware())
+	router.Use(metrics.GinMiddleware())
 	router.Use(corsMiddleware())
 
 	// Setup routes
 	v1 := router.Group("/v1")
 	{
 		creditScoreHandler.RegisterRoutes(v1)
-		relayerHandler.RegisterRoutes(v1)
+		if relayerService != nil {
+			relayer.NewHandler(relayerService).RegisterRoutes(v1)
+		}
 		productHandler.RegisterRoutes(v1)
 		merchantHandler.RegisterRoutes(v1)
 		orderHandler.RegisterRoutes(v1)
@@ -127,6 +160,23 @@ func main() {
 		}
 	}()
 
+	// Serve metrics on their own port so that they are not exposed with the API
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.MetricsPort),
+		Handler:      metricsMux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	go func() {
+		log.Info().Msgf("Starting metrics server on port %d", cfg.MetricsPort)
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("Failed to start metrics server")
+		}
+	}()
+
 	// Start background services
 	go startBackgroundServices(relayerService, creditScoreService)
 
@@ -144,6 +194,17 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Metrics server forced to shutdown")
+	}
+	if relayerService != nil {
+		if err := relayerService.Stop(); err != nil {
+			log.Error().Err(err).Msg("Error stopping relayer service")
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
 
 	log.Info().Msg("Server exited")
 }
@@ -152,7 +213,8 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-control, X-Requested-With, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -177,11 +239,13 @@ func startBackgroundServices(relayerService *relayer.TrustedRelayer, creditScore
 	}()
 
 	// Start relayer service
-	go func() {
-		if err := relayerService.Start(); err != nil {
-			log.Error().Err(err).Msg("Failed to start relayer service")
-		}
-	}()
+	if relayerService != nil {
+		go func() {
+			if err := relayerService.Start(); err != nil {
+				log.Error().Err(err).Msg("Failed to start relayer service")
+			}
+		}()
+	}
 
 	log.Info().Msg("Background services started")
 }
\ No newline at end of file
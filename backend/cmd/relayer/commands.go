@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+
+	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/relayer"
+
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
+)
+
+// status prints the status reported by the running relayer's /healthz and returns the
+// exit code: 1 if the relayer is unhealthy or cannot be reached
+func status(addr string) int {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("http://" + addr + "/healthz")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach relayer at %s: %v\n", addr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var relayerStatus relayer.RelayerStatus
+	if err := json.NewDecoder(resp.Body).Decode(&relayerStatus); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read relayer status: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Status:\t%s\n", relayerStatus.Status)
+	fmt.Fprintf(w, "Address:\t%s\n", relayerStatus.Address)
+	if metrics := relayerStatus.Metrics; metrics != nil {
+		fmt.Fprintf(w, "Messages:\t%d processed, %d sent, %d confirmed, %d failed\n",
+			metrics.MessagesProcessed, metrics.MessagesSent, metrics.MessagesConfirmed, metrics.MessagesFailed)
+	}
+
+	fmt.Fprintln(w, "\nHealth checks:")
+	for name, check := range relayerStatus.HealthChecks {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", name, check.Status, check.Error)
+	}
+	for _, breaker := range relayerStatus.CircuitBreakers {
+		if breaker["state"] != "CLOSED" {
+			fmt.Fprintf(w, "Circuit %v:\t%v\n", breaker["name"], breaker["state"])
+		}
+	}
+	for _, usage := range relayerStatus.Limits {
+		if usage.Paused != nil {
+			fmt.Fprintf(w, "Paused %s:\t%s until %s\n", usage.ChainID, usage.Paused.Limit, usage.Paused.RetryAt.Format(time.RFC3339))
+		}
+	}
+	for _, alert := range relayerStatus.Alerts {
+		fmt.Fprintf(w, "Alert:\t%s\t%s\t%s\n", alert.Severity, alert.Title, alert.Description)
+	}
+	w.Flush()
+
+	if relayerStatus.Status == relayer.HealthStatusUnhealthy {
+		return 1
+	}
+	return 0
+}
+
+// replay puts a dead-lettered message back in the outbox for the running relayer to
+// send. The replay is audit-logged as the operating system user.
+func replay(cfg *config.Config, id string) int {
+	supabaseClient, err := supabase.NewClient(cfg.SupabaseURL, cfg.SupabaseServiceRoleKey, nil)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize Supabase client")
+	}
+
+	actor := "cli"
+	if current, err := user.Current(); err == nil {
+		actor = "cli:" + current.Username
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	deadLetter, err := relayer.NewOfflineRelayer(cfg, supabaseClient).ReplayDeadLetter(ctx, actor, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to replay dead letter %s: %v\n", id, err)
+		return 1
+	}
+
+	fmt.Printf("Replayed dead letter %s: message %s is queued for %s\n", deadLetter.ID, deadLetter.MessageID, deadLetter.ChainID)
+	return 0
+}
@@ -4,23 +4,46 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"kelo-backend/pkg/blockchain"
 	"kelo-backend/pkg/config"
 	"kelo-backend/pkg/logger"
 	"kelo-backend/pkg/relayer"
+	"kelo-backend/pkg/tracing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
 )
 
+const usage = `Usage: relayer [flags] [command]
+
+Commands:
+  run          Run the relayer (default)
+  status       Print the status of the running relayer
+  replay <id>  Replay a dead-lettered message
+
+Flags:
+`
+
 func main() {
 	// Parse command line flags
 	var (
-		version = flag.Bool("version", false, "Show version information")
+		version    = flag.Bool("version", false, "Show version information")
+		configPath = flag.String("config", "", "Relayer config file (defaults to RELAYER_CONFIG_PATH)")
+		addr       = flag.String("addr", "", "Health address of the running relayer for status (defaults to RELAYER_METRICS_HOST:RELAYER_METRICS_PORT)")
 	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *version {
@@ -28,8 +51,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	command := "run"
+	args := flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadRelayer(*configPath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
@@ -37,6 +66,28 @@ func main() {
 	// Initialize logger
 	logger.Init(cfg.LogLevel)
 
+	switch command {
+	case "run":
+		run(cfg)
+	case "status":
+		if *addr == "" {
+			*addr = healthAddr(cfg, true)
+		}
+		os.Exit(status(*addr))
+	case "replay":
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(replay(cfg, args[0]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// run runs the relayer with its admin and health servers until it is signalled to stop
+func run(cfg *config.Config) {
 	log.Info().
 		Str("environment", cfg.Environment).
 		Str("version", "1.0.0").
@@ -46,14 +97,26 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Continue the traces of the requests behind the messages the relayer delivers
+	shutdownTracing, err := tracing.Init(ctx, "kelo-relayer", cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
 	// Initialize blockchain clients
 	bc, err := blockchain.NewClients(cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize blockchain clients")
 	}
 
+	// Initialize Supabase client
+	supabaseClient, err := supabase.NewClient(cfg.SupabaseURL, cfg.SupabaseServiceRoleKey, nil)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize Supabase client")
+	}
+
 	// Initialize trusted relayer
-	relayer, err := relayer.NewTrustedRelayer(cfg, bc)
+	trustedRelayer, err := relayer.NewTrustedRelayer(cfg, bc, supabaseClient)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize trusted relayer")
 	}
@@ -63,10 +126,35 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start the relayer service
-	if err := relayer.Start(); err != nil {
+	if err := trustedRelayer.Start(); err != nil {
 		log.Fatal().Err(err).Msg("Failed to start trusted relayer")
 	}
 
+	// Serve the admin control plane: pause, resume and drain chains, change their
+	// settings and dry-run messages. Every route requires the admin role.
+	if cfg.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(tracing.GinMiddleware("kelo-backend/cmd/relayer"))
+	router.Use(logger.RequestIDMiddleware())
+	router.Use(logger.GinMiddleware())
+	relayer.NewHandler(trustedRelayer).RegisterRoutes(router)
+
+	// The admin routes require the admin role, but /healthz has no auth and returns
+	// the full relayer status, so it only listens on RELAYER_METRICS_HOST.
+	adminServer := newServer(fmt.Sprintf(":%d", cfg.RelayerAdminPort), router)
+	healthServer := newServer(healthAddr(cfg, false), relayer.NewHealthHandler(trustedRelayer))
+	for name, srv := range map[string]*http.Server{"admin": adminServer, "health": healthServer} {
+		go func(name string, srv *http.Server) {
+			log.Info().Msgf("Starting relayer %s server on %s", name, srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msgf("Failed to start relayer %s server", name)
+			}
+		}(name, srv)
+	}
+
 	// Wait for shutdown signal
 	select {
 	case <-sigChan:
@@ -77,10 +165,44 @@ func main() {
 
 	// Graceful shutdown
 	log.Info().Msg("Shutting down trusted relayer...")
-	
-	if err := relayer.Stop(); err != nil {
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+	if err := adminServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Relayer admin server forced to shutdown")
+	}
+
+	if err := trustedRelayer.Stop(); err != nil {
 		log.Error().Err(err).Msg("Error during shutdown")
 	} else {
 		log.Info().Msg("Trusted relayer stopped successfully")
 	}
-}
\ No newline at end of file
+
+	// Keep answering probes until the relayer has stopped
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Relayer health server forced to shutdown")
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
+}
+
+// healthAddr is the address of the health server. A client dials localhost when the
+// server listens on every interface.
+func healthAddr(cfg *config.Config, dial bool) string {
+	host := cfg.RelayerMetricsHost
+	if dial && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.RelayerMetricsPort))
+}
+
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+}
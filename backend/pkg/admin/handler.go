@@ -35,6 +35,12 @@ func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 
 		// Platform Analytics
 		admin.GET("/analytics", h.GetPlatformAnalytics)
+
+		// Loan-loss Provisioning
+		admin.GET("/analytics/ecl", h.GetECLReport)
+		admin.POST("/analytics/provisions", h.PostProvisions)
+		admin.GET("/analytics/par", h.GetPortfolioAtRisk)
+		admin.GET("/analytics/vintages", h.GetVintageRollRates)
 	}
 }
 
@@ -180,3 +186,43 @@ func (h *Handler) GetPlatformAnalytics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, analytics)
 }
+
+func (h *Handler) GetECLReport(c *gin.Context) {
+	report, err := h.service.GetECLReport(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate expected credit loss"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) PostProvisions(c *gin.Context) {
+	report, err := h.service.PostProvisions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post loan provisions"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+func (h *Handler) GetPortfolioAtRisk(c *gin.Context) {
+	report, err := h.service.GetPortfolioAtRisk(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate portfolio at risk"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) GetVintageRollRates(c *gin.Context) {
+	vintages, err := h.service.GetVintageRollRates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate vintage roll rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, vintages)
+}
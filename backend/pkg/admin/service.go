@@ -4,17 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"kelo-backend/pkg/metrics"
 	"kelo-backend/pkg/models"
+	"kelo-backend/pkg/provisioning"
+	"time"
 
+	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
 )
 
+// settledLoanStatuses are loan statuses that no longer carry credit risk.
+var settledLoanStatuses = map[string]bool{
+	"paid_off": true,
+	"PAID":     true,
+}
+
 type Service struct {
-	db *supabase.Client
+	db         *supabase.Client
+	calculator *provisioning.Calculator
 }
 
-func NewService(db *supabase.Client) *Service {
-	return &Service{db: db}
+func NewService(db *supabase.Client, calculator *provisioning.Calculator) *Service {
+	if calculator == nil {
+		calculator = provisioning.NewCalculator(nil)
+	}
+	return &Service{db: db, calculator: calculator}
 }
 
 // GetUsers retrieves a paginated list of users.
@@ -145,3 +159,181 @@ func (s *Service) GetPlatformAnalytics(ctx context.Context) (*models.PlatformAna
 
 	return &analytics[0], nil
 }
+
+// GetECLReport calculates expected credit losses for the outstanding loan book.
+func (s *Service) GetECLReport(ctx context.Context) (*provisioning.ECLReport, error) {
+	exposures, err := s.loadExposures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.calculator.Calculate(exposures, time.Now().UTC())
+	recordDelinquency(report.Provisions)
+	return report, nil
+}
+
+// recordDelinquency updates the delinquent loan counts exported as metrics.
+func recordDelinquency(provisions []provisioning.Provision) {
+	counts := make(map[string]int, len(provisioning.Buckets))
+	for _, bucket := range provisioning.Buckets[1:] {
+		counts[string(bucket)] = 0
+	}
+	for _, provision := range provisions {
+		if provision.Bucket != provisioning.BucketCurrent {
+			counts[string(provision.Bucket)]++
+		}
+	}
+	metrics.SetDelinquentLoans(counts)
+}
+
+// PostProvisions calculates expected credit losses and records one provision per loan
+// in the loan_provisions table.
+func (s *Service) PostProvisions(ctx context.Context) (*provisioning.ECLReport, error) {
+	report, err := s.GetECLReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(report.Provisions) == 0 {
+		return report, nil
+	}
+
+	_, _, err = s.db.From("loan_provisions").Insert(report.Provisions, false, "", "", "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to post loan provisions: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetPortfolioAtRisk calculates PAR1, PAR30 and PAR90 for the outstanding loan book.
+func (s *Service) GetPortfolioAtRisk(ctx context.Context) (*provisioning.PARReport, error) {
+	report, err := s.GetECLReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return provisioning.PortfolioAtRisk(report.Provisions, report.AsOf), nil
+}
+
+// GetVintageRollRates compares the current loan book against the most recently posted
+// provisions and reports how each origination cohort moved between DPD buckets.
+func (s *Service) GetVintageRollRates(ctx context.Context) ([]provisioning.VintageRollRate, error) {
+	report, err := s.GetECLReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.getLatestPostedProvisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return provisioning.VintageRollRates(previous, report.Provisions), nil
+}
+
+// getLatestPostedProvisions returns the provisions from the most recent posting run.
+func (s *Service) getLatestPostedProvisions(ctx context.Context) ([]provisioning.Provision, error) {
+	var runs []struct {
+		AsOf string `json:"as_of"`
+	}
+
+	jsonString, _, err := s.db.From("loan_provisions").Select("as_of", "exact", false).Order("as_of", &postgrest.OrderOpts{Ascending: false}).Limit(1, "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest provisioning run: %w", err)
+	}
+	if err := json.Unmarshal([]byte(jsonString), &runs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal latest provisioning run: %w", err)
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+
+	var provisions []provisioning.Provision
+	jsonString, _, err = s.db.From("loan_provisions").Select("*", "exact", false).Eq("as_of", runs[0].AsOf).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posted provisions: %w", err)
+	}
+	if err := json.Unmarshal([]byte(jsonString), &provisions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal posted provisions: %w", err)
+	}
+
+	return provisions, nil
+}
+
+// loadExposures builds the provisioning inputs from outstanding loans, their repayments
+// and each borrower's latest credit score.
+func (s *Service) loadExposures(ctx context.Context) ([]provisioning.Exposure, error) {
+	var loans []models.Loan
+	jsonString, _, err := s.db.From("loans").Select("*", "exact", false).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans: %w", err)
+	}
+	if err := json.Unmarshal([]byte(jsonString), &loans); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loans: %w", err)
+	}
+
+	var loanIDs, userIDs []string
+	seenUsers := make(map[string]bool)
+	outstanding := loans[:0]
+	for _, loan := range loans {
+		if settledLoanStatuses[loan.Status] {
+			continue
+		}
+		outstanding = append(outstanding, loan)
+		loanIDs = append(loanIDs, loan.ID)
+		if !seenUsers[loan.UserID] {
+			seenUsers[loan.UserID] = true
+			userIDs = append(userIDs, loan.UserID)
+		}
+	}
+
+	if len(outstanding) == 0 {
+		return []provisioning.Exposure{}, nil
+	}
+
+	var repayments []models.Repayment
+	jsonString, _, err = s.db.From("repayments").Select("loan_id, amount", "exact", false).In("loan_id", loanIDs).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repayments: %w", err)
+	}
+	if err := json.Unmarshal([]byte(jsonString), &repayments); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal repayments: %w", err)
+	}
+
+	repaid := make(map[string]float64)
+	for _, repayment := range repayments {
+		repaid[repayment.LoanID] += repayment.Amount
+	}
+
+	var scores []models.CreditScore
+	jsonString, _, err = s.db.From("credit_scores").Select("user_id, score, created_at", "exact", false).In("user_id", userIDs).Order("created_at", &postgrest.OrderOpts{Ascending: false}).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit scores: %w", err)
+	}
+	if err := json.Unmarshal([]byte(jsonString), &scores); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credit scores: %w", err)
+	}
+
+	// Scores are ordered newest first, so the first one seen per user is the latest.
+	latestScores := make(map[string]int)
+	for _, score := range scores {
+		if _, ok := latestScores[score.UserID]; !ok {
+			latestScores[score.UserID] = score.Score
+		}
+	}
+
+	exposures := make([]provisioning.Exposure, 0, len(outstanding))
+	for _, loan := range outstanding {
+		exposures = append(exposures, provisioning.Exposure{
+			LoanID:       loan.ID,
+			UserID:       loan.UserID,
+			Outstanding:  loan.PrincipalAmount - repaid[loan.ID],
+			DueDate:      loan.DueDate,
+			OriginatedAt: loan.CreatedAt,
+			CreditScore:  latestScores[loan.UserID],
+		})
+	}
+
+	return exposures, nil
+}
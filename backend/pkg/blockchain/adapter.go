@@ -0,0 +1,281 @@
+package blockchain
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"kelo-backend/pkg/config"
+)
+
+var (
+	// ErrUnknownChain is returned when no adapter is registered for a chain.
+	ErrUnknownChain = errors.New("unknown chain")
+	// ErrTxNotFound is returned by TransactionReceipt while a transaction is not yet included.
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrNotSupported is returned for operations a chain does not support.
+	ErrNotSupported = errors.New("operation not supported on this chain")
+	// ErrTxExpired is returned for a transaction that expired before it was included
+	// and never will be.
+	ErrTxExpired = errors.New("transaction expired")
+)
+
+// ChainAdapter is the chain-agnostic interface the relayer uses to talk to a liquidity
+// chain. Amounts are in the chain's smallest unit (wei, lamports, octas).
+type ChainAdapter interface {
+	// ChainID returns the registry key of the chain, e.g. "ethereum".
+	ChainID() string
+	// Spec returns the configuration the adapter was built from.
+	Spec() config.ChainSpec
+
+	Balance(ctx context.Context, account string) (*big.Int, error)
+	// Nonce returns the next nonce (sequence number on Aptos) for an account.
+	Nonce(ctx context.Context, account string) (uint64, error)
+	EstimateFee(ctx context.Context) (*FeeEstimate, error)
+
+	// BuildTransaction creates an unsigned transaction. Missing nonce and fees are
+	// fetched from the chain.
+	BuildTransaction(ctx context.Context, req *TxRequest) (*Transaction, error)
+	// SignTransaction signs with an ed25519.PrivateKey on Solana and Aptos, and with an
+	// *ecdsa.PrivateKey or EVMSigner on EVM chains.
+	SignTransaction(ctx context.Context, tx *Transaction, key crypto.PrivateKey) (*Transaction, error)
+	// SendTransaction submits a signed transaction and returns its hash.
+	SendTransaction(ctx context.Context, tx *Transaction) (string, error)
+	// TransactionReceipt returns ErrTxNotFound until the transaction is included.
+	TransactionReceipt(ctx context.Context, hash string) (*Receipt, error)
+
+	SubscribeLogs(ctx context.Context, filter LogFilter, sink chan<- ChainLog) (Subscription, error)
+}
+
+// TxExpirer is implemented by adapters of chains whose transactions expire, so a
+// transaction that was dropped can be told apart from one that is still pending.
+type TxExpirer interface {
+	// TransactionExpired reports whether a transaction with the given Expiry can no
+	// longer be included.
+	TransactionExpired(ctx context.Context, expiry uint64) (bool, error)
+}
+
+// ContractCaller is implemented by adapters that can execute read-only contract calls,
+// such as fee quotes, against the latest state.
+type ContractCaller interface {
+	CallContract(ctx context.Context, req *TxRequest) ([]byte, error)
+}
+
+// FeeEstimate holds fee parameters in the chain's smallest unit. EVM chains fill
+// all three; other chains only set GasPrice.
+type FeeEstimate struct {
+	GasPrice  *big.Int `json:"gas_price"`
+	GasTipCap *big.Int `json:"gas_tip_cap,omitempty"`
+	GasFeeCap *big.Int `json:"gas_fee_cap,omitempty"`
+}
+
+// TxRequest describes a transaction to build.
+type TxRequest struct {
+	From     string
+	To       string
+	Value    *big.Int
+	Data     []byte
+	Nonce    *uint64
+	GasLimit uint64
+	Fee      *FeeEstimate
+	// Call carries the payload for non-EVM chains: []SolanaInstruction on Solana and
+	// AptosEntryFunction on Aptos.
+	Call interface{}
+}
+
+// Transaction is a built, and once signed, submittable transaction.
+type Transaction struct {
+	ChainID string
+	Nonce   uint64
+	// Hash and Raw are set once the transaction is signed.
+	Hash string
+	Raw  []byte
+	// Native is the chain-specific transaction, e.g. *types.Transaction on EVM chains.
+	Native interface{}
+	// Expiry is when the transaction can no longer be included: the last valid block
+	// height on Solana and the expiration timestamp in seconds on Aptos. Zero means it
+	// does not expire.
+	Expiry uint64
+}
+
+// Receipt is the inclusion status of a transaction.
+type Receipt struct {
+	TxHash        string `json:"tx_hash"`
+	BlockNumber   uint64 `json:"block_number"`
+	BlockHash     string `json:"block_hash,omitempty"`
+	Success       bool   `json:"success"`
+	Confirmations uint64 `json:"confirmations"`
+	// Final is true once the chain's configured confirmations have been reached.
+	Final bool `json:"final"`
+	// Fee is what the sender paid for the transaction in the chain's smallest native
+	// unit, or nil if the chain does not report it.
+	Fee *big.Int `json:"fee,omitempty"`
+	// Logs are the contract logs the transaction emitted, on chains that report them.
+	Logs []ChainLog `json:"logs,omitempty"`
+}
+
+// LogFilter selects contract logs. An empty address list matches the chain's
+// configured contract.
+type LogFilter struct {
+	Addresses []string
+	Topics    [][]string
+	FromBlock uint64
+}
+
+// ChainLog is a contract log emitted on a chain.
+type ChainLog struct {
+	ChainID     string   `json:"chain_id"`
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        []byte   `json:"data"`
+	BlockNumber uint64   `json:"block_number"`
+	BlockHash   string   `json:"block_hash"`
+	TxHash      string   `json:"tx_hash"`
+	Index       uint     `json:"index"`
+}
+
+// Subscription is an active log subscription.
+type Subscription interface {
+	Unsubscribe()
+	Err() <-chan error
+}
+
+// AdapterFactory builds an adapter for a chain spec.
+type AdapterFactory func(spec config.ChainSpec) (ChainAdapter, error)
+
+var adapterFactories = map[string]AdapterFactory{
+	config.ChainTypeEVM:    DialEVMAdapter,
+	config.ChainTypeSolana: func(spec config.ChainSpec) (ChainAdapter, error) { return NewSolanaAdapter(spec), nil },
+	config.ChainTypeAptos:  func(spec config.ChainSpec) (ChainAdapter, error) { return NewAptosAdapter(spec), nil },
+}
+
+// NewAdapter builds the adapter for a chain spec based on its type.
+func NewAdapter(spec config.ChainSpec) (ChainAdapter, error) {
+	factory, ok := adapterFactories[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain type %q for %s", spec.Type, spec.Key)
+	}
+	return factory(spec)
+}
+
+// Registry holds the chain adapters keyed by chain ID.
+type Registry struct {
+	mu       sync.RWMutex
+	adapters map[string]ChainAdapter
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{adapters: make(map[string]ChainAdapter)}
+}
+
+// NewRegistryFromConfig builds an adapter for every enabled chain spec.
+func NewRegistryFromConfig(specs []config.ChainSpec) (*Registry, error) {
+	registry := NewRegistry()
+	for _, spec := range specs {
+		if !spec.Enabled {
+			continue
+		}
+		adapter, err := NewAdapter(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", spec.Name, err)
+		}
+		if err := registry.Register(adapter); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds an adapter to the registry.
+func (r *Registry) Register(adapter ChainAdapter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.adapters[adapter.ChainID()]; exists {
+		return fmt.Errorf("chain %s is already registered", adapter.ChainID())
+	}
+	r.adapters[adapter.ChainID()] = adapter
+	return nil
+}
+
+// Adapter returns the adapter for a chain. The chain can be given by key or, for
+// EVM chains, by numeric chain ID.
+func (r *Registry) Adapter(chainID string) (ChainAdapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if adapter, ok := r.adapters[chainID]; ok {
+		return adapter, nil
+	}
+	for _, adapter := range r.adapters {
+		if id := adapter.Spec().EVMChainID; id != 0 && strconv.FormatUint(id, 10) == chainID {
+			return adapter, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownChain, chainID)
+}
+
+// Chains returns the registered chain IDs in sorted order.
+func (r *Registry) Chains() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chains := make([]string, 0, len(r.adapters))
+	for chainID := range r.adapters {
+		chains = append(chains, chainID)
+	}
+	sort.Strings(chains)
+	return chains
+}
+
+// WaitForFinality polls a transaction receipt until it is final. It returns an error
+// if the transaction reverted, and ErrTxExpired once a transaction with a non-zero
+// expiry can no longer be included.
+func WaitForFinality(ctx context.Context, adapter ChainAdapter, hash string, expiry uint64, pollInterval time.Duration) (*Receipt, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := TransactionExpired(ctx, adapter, expiry)
+		if err != nil {
+			return nil, err
+		}
+		receipt, err := adapter.TransactionReceipt(ctx, hash)
+		switch {
+		case errors.Is(err, ErrTxNotFound):
+			if expired {
+				return nil, fmt.Errorf("%s transaction %s: %w", adapter.ChainID(), hash, ErrTxExpired)
+			}
+		case err != nil:
+			return nil, err
+		case !receipt.Success:
+			return receipt, fmt.Errorf("%s transaction %s failed", adapter.ChainID(), hash)
+		case receipt.Final:
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TransactionExpired reports whether a transaction with the given expiry can no longer
+// be included. Check it before looking the transaction up, so that one included just
+// before it expired is still found.
+func TransactionExpired(ctx context.Context, adapter ChainAdapter, expiry uint64) (bool, error) {
+	expirer, ok := adapter.(TxExpirer)
+	if !ok || expiry == 0 {
+		return false, nil
+	}
+	return expirer.TransactionExpired(ctx, expiry)
+}
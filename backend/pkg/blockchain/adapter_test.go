@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Adapter(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453})))
+	require.NoError(t, registry.Register(NewFakeAdapter(config.ChainSpec{Key: "aptos", Type: config.ChainTypeAptos})))
+
+	adapter, err := registry.Adapter("base")
+	require.NoError(t, err)
+	assert.Equal(t, "base", adapter.ChainID())
+
+	adapter, err = registry.Adapter("8453")
+	require.NoError(t, err)
+	assert.Equal(t, "base", adapter.ChainID())
+
+	_, err = registry.Adapter("polygon")
+	assert.True(t, errors.Is(err, ErrUnknownChain))
+
+	assert.Error(t, registry.Register(NewFakeAdapter(config.ChainSpec{Key: "base"})))
+	assert.Equal(t, []string{"aptos", "base"}, registry.Chains())
+}
+
+func TestNewRegistryFromConfig_SkipsDisabledChains(t *testing.T) {
+	registry, err := NewRegistryFromConfig([]config.ChainSpec{
+		{Key: "solana", Type: config.ChainTypeSolana, RPCURL: "http://localhost:8899", Enabled: true},
+		{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, Enabled: false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"solana"}, registry.Chains())
+
+	_, err = NewRegistryFromConfig([]config.ChainSpec{{Key: "near", Type: "near", Enabled: true}})
+	assert.Error(t, err)
+}
+
+func TestFakeAdapter_SubmitAndConfirm(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, Confirmations: 3})
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	tx, err := adapter.BuildTransaction(ctx, &TxRequest{From: from, To: "0xpool", Data: []byte{1}})
+	require.NoError(t, err)
+	signed, err := adapter.SignTransaction(ctx, tx, key)
+	require.NoError(t, err)
+	hash, err := adapter.SendTransaction(ctx, signed)
+	require.NoError(t, err)
+	assert.Equal(t, signed.Hash, hash)
+
+	nonce, err := adapter.Nonce(ctx, from)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+
+	receipt, err := adapter.TransactionReceipt(ctx, hash)
+	require.NoError(t, err)
+	assert.True(t, receipt.Success)
+	assert.False(t, receipt.Final)
+
+	adapter.Mine(2)
+	receipt, err = WaitForFinality(ctx, adapter, hash, 0, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), receipt.Confirmations)
+
+	_, err = adapter.TransactionReceipt(ctx, "0xunknown")
+	assert.True(t, errors.Is(err, ErrTxNotFound))
+}
+
+func TestWaitForFinality_Reverted(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewFakeAdapter(config.ChainSpec{Key: "base"})
+	adapter.Revert = true
+
+	tx, err := adapter.BuildTransaction(ctx, &TxRequest{From: "0xrelayer", To: "0xpool"})
+	require.NoError(t, err)
+	signed, err := adapter.SignTransaction(ctx, tx, nil)
+	require.NoError(t, err)
+	hash, err := adapter.SendTransaction(ctx, signed)
+	require.NoError(t, err)
+
+	_, err = WaitForFinality(ctx, adapter, hash, 0, time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestWaitForFinality_Expired(t *testing.T) {
+	ctx := context.Background()
+	adapter := NewFakeAdapter(config.ChainSpec{Key: "solana", Type: config.ChainTypeSolana})
+	adapter.Hold = true
+	adapter.ExpiresAfter = 2
+
+	tx, err := adapter.BuildTransaction(ctx, &TxRequest{From: "relayer", To: "pool"})
+	require.NoError(t, err)
+	signed, err := adapter.SignTransaction(ctx, tx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), signed.Expiry)
+	hash, err := adapter.SendTransaction(ctx, signed)
+	require.NoError(t, err)
+
+	// The transaction is never included, so waiting ends once it expires
+	adapter.DropHeld()
+	adapter.Mine(3)
+	_, err = WaitForFinality(ctx, adapter, hash, signed.Expiry, time.Millisecond)
+	assert.ErrorIs(t, err, ErrTxExpired)
+}
+
+func TestEVMAdapter_BuildAndSign(t *testing.T) {
+	adapter := NewEVMAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453}, nil)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	nonce := uint64(4)
+	tx, err := adapter.BuildTransaction(context.Background(), &TxRequest{
+		To:       "0x0987654321098765432109876543210987654321",
+		Nonce:    &nonce,
+		GasLimit: 100000,
+		Fee:      &FeeEstimate{GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)},
+	})
+	require.NoError(t, err)
+
+	signed, err := adapter.SignTransaction(context.Background(), tx, key)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), signed.Nonce)
+	assert.NotEmpty(t, signed.Hash)
+	assert.NotEmpty(t, signed.Raw)
+}
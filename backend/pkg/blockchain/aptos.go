@@ -0,0 +1,458 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AptosAddress is a 32-byte Aptos account address.
+type AptosAddress [32]byte
+
+// AptosAddressFromHex parses a 0x-prefixed hex address, left-padding short forms like 0x1.
+func AptosAddressFromHex(s string) (AptosAddress, error) {
+	var address AptosAddress
+	s = strings.TrimPrefix(strings.ToLower(s), "0x")
+	if len(s) == 0 || len(s) > 64 {
+		return address, fmt.Errorf("invalid Aptos address length: %d", len(s))
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return address, fmt.Errorf("invalid Aptos address: %w", err)
+	}
+	copy(address[32-len(decoded):], decoded)
+	return address, nil
+}
+
+// String returns the full 0x-prefixed hex form of the address.
+func (a AptosAddress) String() string {
+	return "0x" + hex.EncodeToString(a[:])
+}
+
+// ParseAptosPrivateKey parses a hex ed25519 private key seed, with or without the 0x or
+// ed25519-priv-0x prefix.
+func ParseAptosPrivateKey(s string) (ed25519.PrivateKey, error) {
+	s = strings.TrimPrefix(s, "ed25519-priv-")
+	seed, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Aptos private key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid Aptos private key length: %d", len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// AptosAddressFromPrivateKey returns the account address of a single-key ed25519 account.
+func AptosAddressFromPrivateKey(key ed25519.PrivateKey) AptosAddress {
+	// The authentication key is sha3-256(public key || 0x00 single-signer scheme).
+	return AptosAddress(sha3.Sum256(append(append([]byte{}, key.Public().(ed25519.PublicKey)...), 0x00)))
+}
+
+// AptosEntryFunction is an entry-function transaction payload.
+type AptosEntryFunction struct {
+	Module   AptosAddress
+	Name     string
+	Function string
+	TypeArgs []string
+	Args     [][]byte
+}
+
+// AptosRawTransaction is an unsigned Aptos transaction.
+type AptosRawTransaction struct {
+	Sender                  AptosAddress
+	SequenceNumber          uint64
+	Payload                 AptosEntryFunction
+	MaxGasAmount            uint64
+	GasUnitPrice            uint64
+	ExpirationTimestampSecs uint64
+	ChainID                 uint8
+}
+
+// BCS enum variants used when serialising transactions.
+const (
+	aptosPayloadEntryFunction = 2
+	aptosTypeTagStruct        = 7
+	aptosAuthenticatorEd25519 = 0
+)
+
+// bcs serialises the raw transaction in Binary Canonical Serialization.
+func (tx *AptosRawTransaction) bcs() ([]byte, error) {
+	var b []byte
+	b = append(b, tx.Sender[:]...)
+	b = binary.LittleEndian.AppendUint64(b, tx.SequenceNumber)
+
+	b = appendULEB128(b, aptosPayloadEntryFunction)
+	b = append(b, tx.Payload.Module[:]...)
+	b = appendBCSString(b, tx.Payload.Name)
+	b = appendBCSString(b, tx.Payload.Function)
+	b = appendULEB128(b, uint64(len(tx.Payload.TypeArgs)))
+	for _, typeArg := range tx.Payload.TypeArgs {
+		var err error
+		if b, err = appendAptosStructTag(b, typeArg); err != nil {
+			return nil, err
+		}
+	}
+	b = appendULEB128(b, uint64(len(tx.Payload.Args)))
+	for _, arg := range tx.Payload.Args {
+		b = appendULEB128(b, uint64(len(arg)))
+		b = append(b, arg...)
+	}
+
+	b = binary.LittleEndian.AppendUint64(b, tx.MaxGasAmount)
+	b = binary.LittleEndian.AppendUint64(b, tx.GasUnitPrice)
+	b = binary.LittleEndian.AppendUint64(b, tx.ExpirationTimestampSecs)
+	b = append(b, tx.ChainID)
+	return b, nil
+}
+
+// SigningMessage returns the bytes an ed25519 signer signs for this transaction.
+func (tx *AptosRawTransaction) SigningMessage() ([]byte, error) {
+	raw, err := tx.bcs()
+	if err != nil {
+		return nil, err
+	}
+	prefix := sha3.Sum256([]byte("APTOS::RawTransaction"))
+	return append(prefix[:], raw...), nil
+}
+
+// Sign signs the transaction and returns the BCS-encoded SignedTransaction.
+func (tx *AptosRawTransaction) Sign(key ed25519.PrivateKey) ([]byte, error) {
+	raw, err := tx.bcs()
+	if err != nil {
+		return nil, err
+	}
+	message, err := tx.SigningMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	signed := append([]byte{}, raw...)
+	signed = appendULEB128(signed, aptosAuthenticatorEd25519)
+	pub := key.Public().(ed25519.PublicKey)
+	signed = appendULEB128(signed, uint64(len(pub)))
+	signed = append(signed, pub...)
+	signature := ed25519.Sign(key, message)
+	signed = appendULEB128(signed, uint64(len(signature)))
+	signed = append(signed, signature...)
+	return signed, nil
+}
+
+// AptosTransactionHash returns the hash of a BCS-encoded SignedTransaction, which is
+// sha3-256 over the hashed "APTOS::Transaction" prefix, the UserTransaction variant
+// and the signed transaction.
+func AptosTransactionHash(signed []byte) string {
+	prefix := sha3.Sum256([]byte("APTOS::Transaction"))
+	message := append(append(prefix[:], 0x00), signed...)
+	hash := sha3.Sum256(message)
+	return "0x" + hex.EncodeToString(hash[:])
+}
+
+// appendAptosStructTag encodes a non-generic struct type such as 0x1::aptos_coin::AptosCoin.
+func appendAptosStructTag(b []byte, typeTag string) ([]byte, error) {
+	parts := strings.Split(typeTag, "::")
+	if len(parts) != 3 || strings.ContainsAny(typeTag, "<>") {
+		return nil, fmt.Errorf("unsupported Aptos type tag: %s", typeTag)
+	}
+	address, err := AptosAddressFromHex(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	b = appendULEB128(b, aptosTypeTagStruct)
+	b = append(b, address[:]...)
+	b = appendBCSString(b, parts[1])
+	b = appendBCSString(b, parts[2])
+	b = appendULEB128(b, 0) // no type parameters
+	return b, nil
+}
+
+func appendBCSString(b []byte, s string) []byte {
+	b = appendULEB128(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendULEB128(b []byte, v uint64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// AptosClient represents an Aptos blockchain client using the fullnode REST API,
+// e.g. https://fullnode.mainnet.aptoslabs.com/v1
+type AptosClient struct {
+	rpcURL       string
+	httpClient   *http.Client
+	pollInterval time.Duration
+	maxGasAmount uint64
+	txTimeout    time.Duration
+}
+
+// NewAptosClient creates a new Aptos client
+func NewAptosClient(rpcURL string) *AptosClient {
+	return &AptosClient{
+		rpcURL:       strings.TrimRight(rpcURL, "/"),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		pollInterval: defaultPollInterval,
+		maxGasAmount: 10000,
+		txTimeout:    60 * time.Second,
+	}
+}
+
+// aptosAPIError is the error body returned by the fullnode REST API.
+type aptosAPIError struct {
+	Message   string `json:"message"`
+	ErrorCode string `json:"error_code"`
+}
+
+// errAptosNotFound is returned by get when the resource does not exist.
+var errAptosNotFound = fmt.Errorf("not found")
+
+func (c *AptosClient) do(ctx context.Context, method, path, contentType string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.rpcURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Aptos request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Aptos %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Aptos response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errAptosNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr aptosAPIError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("aptos %s returned status %d: %s (%s)", path, resp.StatusCode, apiErr.Message, apiErr.ErrorCode)
+		}
+		return fmt.Errorf("aptos %s returned status %d: %s", path, resp.StatusCode, string(data))
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to decode Aptos response: %w", err)
+	}
+	return nil
+}
+
+// GetBalance returns the APT balance of an Aptos account in octas
+func (c *AptosClient) GetBalance(ctx context.Context, account string) (uint64, error) {
+	var resource struct {
+		Data struct {
+			Coin struct {
+				Value string `json:"value"`
+			} `json:"coin"`
+		} `json:"data"`
+	}
+	path := "/accounts/" + account + "/resource/" + url.PathEscape("0x1::coin::CoinStore<0x1::aptos_coin::AptosCoin>")
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &resource); err != nil {
+		if err == errAptosNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseUint(resource.Data.Coin.Value, 10, 64)
+}
+
+// GetSequenceNumber returns the next sequence number for an account.
+func (c *AptosClient) GetSequenceNumber(ctx context.Context, account string) (uint64, error) {
+	var info struct {
+		SequenceNumber string `json:"sequence_number"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/accounts/"+account, "", nil, &info); err != nil {
+		return 0, fmt.Errorf("failed to get Aptos account: %w", err)
+	}
+	return strconv.ParseUint(info.SequenceNumber, 10, 64)
+}
+
+// GetChainID returns the chain ID of the network the node is serving.
+func (c *AptosClient) GetChainID(ctx context.Context) (uint8, error) {
+	var ledger struct {
+		ChainID uint8 `json:"chain_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "", "", nil, &ledger); err != nil {
+		return 0, fmt.Errorf("failed to get Aptos ledger info: %w", err)
+	}
+	return ledger.ChainID, nil
+}
+
+// GetLedgerTimestamp returns the timestamp of the latest committed ledger version.
+func (c *AptosClient) GetLedgerTimestamp(ctx context.Context) (time.Time, error) {
+	var ledger struct {
+		LedgerTimestamp string `json:"ledger_timestamp"`
+	}
+	if err := c.do(ctx, http.MethodGet, "", "", nil, &ledger); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get Aptos ledger info: %w", err)
+	}
+	micros, err := strconv.ParseInt(ledger.LedgerTimestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid Aptos ledger timestamp %q: %w", ledger.LedgerTimestamp, err)
+	}
+	return time.UnixMicro(micros), nil
+}
+
+// EstimateGasPrice returns the node's suggested gas unit price.
+func (c *AptosClient) EstimateGasPrice(ctx context.Context) (uint64, error) {
+	var estimate struct {
+		GasEstimate uint64 `json:"gas_estimate"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/estimate_gas_price", "", nil, &estimate); err != nil {
+		return 0, fmt.Errorf("failed to estimate Aptos gas price: %w", err)
+	}
+	return estimate.GasEstimate, nil
+}
+
+// SendTransaction submits a BCS-encoded signed transaction and returns its hash
+func (c *AptosClient) SendTransaction(ctx context.Context, tx []byte) (string, error) {
+	var pending struct {
+		Hash string `json:"hash"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/transactions", "application/x.aptos.signed_transaction+bcs", tx, &pending); err != nil {
+		return "", fmt.Errorf("failed to submit Aptos transaction: %w", err)
+	}
+	return pending.Hash, nil
+}
+
+// AptosTransactionStatus is the status of a submitted transaction.
+type AptosTransactionStatus struct {
+	Type     string `json:"type"`
+	Hash     string `json:"hash"`
+	Version  string `json:"version"`
+	Success  bool   `json:"success"`
+	VMStatus string `json:"vm_status"`
+	// GasUsed and GasUnitPrice are set once the transaction is committed
+	GasUsed      string `json:"gas_used,omitempty"`
+	GasUnitPrice string `json:"gas_unit_price,omitempty"`
+}
+
+// Pending reports whether the transaction is still in the mempool.
+func (s *AptosTransactionStatus) Pending() bool {
+	return s.Type == "pending_transaction"
+}
+
+// GetTransaction returns a transaction by hash, or nil if the node has not seen it.
+func (c *AptosClient) GetTransaction(ctx context.Context, hash string) (*AptosTransactionStatus, error) {
+	var tx AptosTransactionStatus
+	err := c.do(ctx, http.MethodGet, "/transactions/by_hash/"+hash, "", nil, &tx)
+	if err == errAptosNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// WaitForTransaction polls until the transaction is committed, returning an error if the
+// Move VM rejected it.
+func (c *AptosClient) WaitForTransaction(ctx context.Context, hash string) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		tx, err := c.GetTransaction(ctx, hash)
+		if err != nil {
+			return err
+		}
+		if tx != nil && !tx.Pending() {
+			if !tx.Success {
+				return fmt.Errorf("aptos transaction %s failed: %s", hash, tx.VMStatus)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// AptosDisburseRequest describes a disbursement from the Kelo pool module.
+type AptosDisburseRequest struct {
+	ModuleAddress AptosAddress
+	CoinType      string
+	Recipient     AptosAddress
+	Amount        uint64
+}
+
+// NewAptosDisburseFunction returns the KeloLiquidityPool::disburse<CoinType>(to, amount) payload.
+func NewAptosDisburseFunction(req AptosDisburseRequest) AptosEntryFunction {
+	return AptosEntryFunction{
+		Module:   req.ModuleAddress,
+		Name:     "KeloLiquidityPool",
+		Function: "disburse",
+		TypeArgs: []string{req.CoinType},
+		Args: [][]byte{
+			req.Recipient[:],
+			binary.LittleEndian.AppendUint64(nil, req.Amount),
+		},
+	}
+}
+
+// Disburse builds, signs and submits KeloLiquidityPool::disburse<CoinType>(to, amount).
+func (c *AptosClient) Disburse(ctx context.Context, req AptosDisburseRequest, signer ed25519.PrivateKey) (string, error) {
+	sender := AptosAddressFromPrivateKey(signer)
+
+	sequenceNumber, err := c.GetSequenceNumber(ctx, sender.String())
+	if err != nil {
+		return "", err
+	}
+	chainID, err := c.GetChainID(ctx)
+	if err != nil {
+		return "", err
+	}
+	gasPrice, err := c.EstimateGasPrice(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tx := &AptosRawTransaction{
+		Sender:                  sender,
+		SequenceNumber:          sequenceNumber,
+		Payload:                 NewAptosDisburseFunction(req),
+		MaxGasAmount:            c.maxGasAmount,
+		GasUnitPrice:            gasPrice,
+		ExpirationTimestampSecs: uint64(time.Now().Add(c.txTimeout).Unix()),
+		ChainID:                 chainID,
+	}
+
+	signed, err := tx.Sign(signer)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign Aptos transaction: %w", err)
+	}
+
+	return c.SendTransaction(ctx, signed)
+}
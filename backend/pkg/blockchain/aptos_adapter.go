@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"kelo-backend/pkg/config"
+)
+
+// AptosAdapter is the ChainAdapter for Aptos.
+type AptosAdapter struct {
+	spec   config.ChainSpec
+	client *AptosClient
+}
+
+// NewAptosAdapter creates an Aptos adapter for the chain spec
+func NewAptosAdapter(spec config.ChainSpec) *AptosAdapter {
+	return &AptosAdapter{spec: spec, client: NewAptosClient(spec.RPCURL)}
+}
+
+// ChainID returns the registry key of the chain
+func (a *AptosAdapter) ChainID() string {
+	return a.spec.Key
+}
+
+// Spec returns the chain configuration
+func (a *AptosAdapter) Spec() config.ChainSpec {
+	return a.spec
+}
+
+// Client returns the underlying Aptos REST client.
+func (a *AptosAdapter) Client() *AptosClient {
+	return a.client
+}
+
+// Balance returns the APT balance of an account in octas
+func (a *AptosAdapter) Balance(ctx context.Context, account string) (*big.Int, error) {
+	balance, err := a.client.GetBalance(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(balance), nil
+}
+
+// Nonce returns the account's next sequence number
+func (a *AptosAdapter) Nonce(ctx context.Context, account string) (uint64, error) {
+	return a.client.GetSequenceNumber(ctx, account)
+}
+
+// EstimateFee returns the suggested gas unit price in octas.
+func (a *AptosAdapter) EstimateFee(ctx context.Context) (*FeeEstimate, error) {
+	gasPrice, err := a.client.EstimateGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &FeeEstimate{GasPrice: new(big.Int).SetUint64(gasPrice)}, nil
+}
+
+// BuildTransaction builds an unsigned transaction from req.Call, which must be an
+// AptosEntryFunction.
+func (a *AptosAdapter) BuildTransaction(ctx context.Context, req *TxRequest) (*Transaction, error) {
+	payload, ok := req.Call.(AptosEntryFunction)
+	if !ok {
+		return nil, fmt.Errorf("aptos transactions require an entry function")
+	}
+	sender, err := AptosAddressFromHex(req.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Aptos sender: %w", err)
+	}
+
+	var sequenceNumber uint64
+	if req.Nonce != nil {
+		sequenceNumber = *req.Nonce
+	} else if sequenceNumber, err = a.Nonce(ctx, sender.String()); err != nil {
+		return nil, err
+	}
+
+	fee := req.Fee
+	if fee == nil {
+		if fee, err = a.EstimateFee(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	chainID, err := a.client.GetChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	maxGas := req.GasLimit
+	if maxGas == 0 {
+		maxGas = a.client.maxGasAmount
+	}
+
+	raw := &AptosRawTransaction{
+		Sender:                  sender,
+		SequenceNumber:          sequenceNumber,
+		Payload:                 payload,
+		MaxGasAmount:            maxGas,
+		GasUnitPrice:            fee.GasPrice.Uint64(),
+		ExpirationTimestampSecs: uint64(time.Now().Add(a.client.txTimeout).Unix()),
+		ChainID:                 chainID,
+	}
+
+	return &Transaction{ChainID: a.spec.Key, Nonce: sequenceNumber, Native: raw, Expiry: raw.ExpirationTimestampSecs}, nil
+}
+
+// SignTransaction signs the transaction with the sender's ed25519 key.
+func (a *AptosAdapter) SignTransaction(ctx context.Context, tx *Transaction, key crypto.PrivateKey) (*Transaction, error) {
+	raw, ok := tx.Native.(*AptosRawTransaction)
+	if !ok {
+		return nil, fmt.Errorf("not an Aptos transaction")
+	}
+	signer, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("aptos transactions require an ed25519 key")
+	}
+
+	signed, err := raw.Sign(signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign Aptos transaction: %w", err)
+	}
+
+	return &Transaction{
+		ChainID: a.spec.Key,
+		Nonce:   raw.SequenceNumber,
+		Hash:    AptosTransactionHash(signed),
+		Raw:     signed,
+		Native:  raw,
+		Expiry:  raw.ExpirationTimestampSecs,
+	}, nil
+}
+
+// SendTransaction submits a signed transaction and returns its hash
+func (a *AptosAdapter) SendTransaction(ctx context.Context, tx *Transaction) (string, error) {
+	if len(tx.Raw) == 0 {
+		return "", fmt.Errorf("aptos transaction is not signed")
+	}
+	return a.client.SendTransaction(ctx, tx.Raw)
+}
+
+// TransactionReceipt returns the status of a transaction. Aptos has instant finality,
+// so a committed transaction is final.
+func (a *AptosAdapter) TransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	tx, err := a.client.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil || tx.Pending() {
+		return nil, ErrTxNotFound
+	}
+
+	version, _ := strconv.ParseUint(tx.Version, 10, 64)
+	return &Receipt{
+		TxHash:        hash,
+		BlockNumber:   version,
+		Success:       tx.Success,
+		Confirmations: 1,
+		Final:         true,
+		Fee:           aptosFee(tx),
+	}, nil
+}
+
+// TransactionExpired reports whether the ledger has reached a transaction's expiration
+// timestamp. The chain rejects transactions from then on.
+func (a *AptosAdapter) TransactionExpired(ctx context.Context, expiry uint64) (bool, error) {
+	now, err := a.client.GetLedgerTimestamp(ctx)
+	if err != nil {
+		return false, err
+	}
+	return uint64(now.Unix()) >= expiry, nil
+}
+
+// SubscribeLogs is not supported; Aptos emits Move events rather than EVM logs.
+func (a *AptosAdapter) SubscribeLogs(ctx context.Context, filter LogFilter, sink chan<- ChainLog) (Subscription, error) {
+	return nil, ErrNotSupported
+}
+
+// aptosFee returns the octas a committed transaction paid for gas
+func aptosFee(tx *AptosTransactionStatus) *big.Int {
+	used, err := strconv.ParseUint(tx.GasUsed, 10, 64)
+	if err != nil {
+		return nil
+	}
+	price, err := strconv.ParseUint(tx.GasUnitPrice, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(used), new(big.Int).SetUint64(price))
+}
@@ -0,0 +1,174 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAptosAddressFromHex(t *testing.T) {
+	address, err := AptosAddressFromHex("0x1")
+	require.NoError(t, err)
+	assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000001", address.String())
+
+	_, err = AptosAddressFromHex("0xzz")
+	assert.Error(t, err)
+}
+
+func TestAptosRawTransaction_BCS(t *testing.T) {
+	module, _ := AptosAddressFromHex("0xcafe")
+	tx := &AptosRawTransaction{
+		SequenceNumber: 7,
+		Payload: AptosEntryFunction{
+			Module:   module,
+			Name:     "KeloLiquidityPool",
+			Function: "disburse",
+			TypeArgs: []string{"0x1::aptos_coin::AptosCoin"},
+			Args:     [][]byte{make([]byte, 32), binary.LittleEndian.AppendUint64(nil, 100)},
+		},
+		MaxGasAmount:            10000,
+		GasUnitPrice:            100,
+		ExpirationTimestampSecs: 1700000000,
+		ChainID:                 2,
+	}
+
+	raw, err := tx.bcs()
+	require.NoError(t, err)
+
+	// sender(32) + sequence number(8), then the EntryFunction payload variant.
+	assert.Equal(t, uint64(7), binary.LittleEndian.Uint64(raw[32:40]))
+	assert.Equal(t, byte(aptosPayloadEntryFunction), raw[40])
+	assert.Equal(t, module[:], raw[41:73])
+	assert.Equal(t, byte(len("KeloLiquidityPool")), raw[73])
+	// Trailer: max gas, gas price, expiration, chain ID.
+	assert.Equal(t, byte(2), raw[len(raw)-1])
+	assert.Equal(t, uint64(1700000000), binary.LittleEndian.Uint64(raw[len(raw)-9:len(raw)-1]))
+
+	tx.Payload.TypeArgs = []string{"0x1::coin::CoinStore<0x1::aptos_coin::AptosCoin>"}
+	_, err = tx.bcs()
+	assert.Error(t, err)
+}
+
+// fakeAptosNode serves just enough of the Aptos fullnode REST API for the disburse flow.
+type fakeAptosNode struct {
+	t         *testing.T
+	submitted [][]byte
+	polls     int
+}
+
+func (f *fakeAptosNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"chain_id": 2, "ledger_version": "100", "ledger_timestamp": "1700000000000000"})
+	case path == "/estimate_gas_price":
+		json.NewEncoder(w).Encode(map[string]interface{}{"gas_estimate": 100})
+	case strings.HasPrefix(path, "/accounts/") && strings.Contains(path, "/resource/"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"type": "0x1::coin::CoinStore<0x1::aptos_coin::AptosCoin>", "data": map[string]interface{}{"coin": map[string]interface{}{"value": "123456"}}})
+	case strings.HasPrefix(path, "/accounts/"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"sequence_number": "3", "authentication_key": "0x0"})
+	case path == "/transactions" && r.Method == http.MethodPost:
+		assert.Equal(f.t, "application/x.aptos.signed_transaction+bcs", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		f.submitted = append(f.submitted, body)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"hash": "0xabc"})
+	case path == "/transactions/by_hash/0xabc":
+		f.polls++
+		if f.polls == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": "not found", "error_code": "transaction_not_found"})
+			return
+		}
+		if f.polls == 2 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"type": "pending_transaction", "hash": "0xabc"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"type": "user_transaction", "hash": "0xabc", "success": true, "vm_status": "Executed successfully"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAptosClient_Disburse(t *testing.T) {
+	fake := &fakeAptosNode{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewAptosClient(server.URL + "/v1/")
+	client.pollInterval = time.Millisecond
+
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	module, _ := AptosAddressFromHex("0xcafe")
+	recipient, _ := AptosAddressFromHex("0xbeef")
+
+	hash, err := client.Disburse(context.Background(), AptosDisburseRequest{
+		ModuleAddress: module,
+		CoinType:      "0x1::aptos_coin::AptosCoin",
+		Recipient:     recipient,
+		Amount:        2500,
+	}, signer)
+	require.NoError(t, err)
+	assert.Equal(t, "0xabc", hash)
+	require.NoError(t, client.WaitForTransaction(context.Background(), hash))
+	assert.Equal(t, 3, fake.polls)
+
+	// SignedTransaction = raw transaction || Ed25519 authenticator(pubkey, signature).
+	require.Len(t, fake.submitted, 1)
+	signed := fake.submitted[0]
+	signature := signed[len(signed)-64:]
+	pub := signed[len(signed)-64-1-32 : len(signed)-64-1]
+	raw := signed[:len(signed)-64-1-32-1-1]
+
+	sender := AptosAddressFromPrivateKey(signer)
+	assert.Equal(t, sender[:], raw[:32])
+	assert.Equal(t, uint64(3), binary.LittleEndian.Uint64(raw[32:40]))
+	assert.Equal(t, byte(2), raw[len(raw)-1])
+
+	prefix := sha3.Sum256([]byte("APTOS::RawTransaction"))
+	assert.True(t, ed25519.Verify(ed25519.PublicKey(pub), append(prefix[:], raw...), signature))
+
+	balance, err := client.GetBalance(context.Background(), sender.String())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(123456), balance)
+}
+
+func TestAptosAdapter_TransactionExpired(t *testing.T) {
+	server := httptest.NewServer(&fakeAptosNode{t: t})
+	defer server.Close()
+	adapter := NewAptosAdapter(config.ChainSpec{Key: "aptos", Type: config.ChainTypeAptos, RPCURL: server.URL + "/v1"})
+
+	expired, err := adapter.TransactionExpired(context.Background(), 1700000000)
+	require.NoError(t, err)
+	assert.True(t, expired)
+	expired, err = adapter.TransactionExpired(context.Background(), 1700000060)
+	require.NoError(t, err)
+	assert.False(t, expired)
+}
+
+func TestAptosClient_WaitForTransactionFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"type": "user_transaction", "success": false, "vm_status": "Move abort: E_INSUFFICIENT_BALANCE"})
+	}))
+	defer server.Close()
+
+	client := NewAptosClient(server.URL)
+	client.pollInterval = time.Millisecond
+	err := client.WaitForTransaction(context.Background(), "0xdead")
+	assert.ErrorContains(t, err, "E_INSUFFICIENT_BALANCE")
+}
@@ -9,115 +9,32 @@ import (
 
         "kelo-backend/pkg/config"
 
-        "github.com/ethereum/go-ethereum"
         "github.com/ethereum/go-ethereum/accounts/abi/bind"
-        "github.com/ethereum/go-ethereum/common"
         "github.com/ethereum/go-ethereum/core/types"
-        "github.com/ethereum/go-ethereum/ethclient"
         "github.com/rs/zerolog/log"
 )
 
 // Clients holds all blockchain client instances
 type Clients struct {
-        config          *config.Config
-        ethereumClient  *ethclient.Client
-        baseClient      *ethclient.Client
-        arbitrumClient  *ethclient.Client
-        avalancheClient *ethclient.Client
-        celoClient      *ethclient.Client
-        polygonClient   *ethclient.Client
-        kavaClient      *ethclient.Client
-        solanaClient    *SolanaClient
-        aptosClient     *AptosClient
-        hederaClient    *HederaClient
+        config       *config.Config
+        registry     *Registry
+        hederaClient *HederaClient
 }
 
 // NewClients creates and initializes all blockchain clients
 func NewClients(cfg *config.Config) (*Clients, error) {
-        clients := &Clients{
-                config: cfg,
-        }
-
-        // Initialize Ethereum client
-        if cfg.EthereumRPC != "" {
-                ethClient, err := ethclient.Dial(cfg.EthereumRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Ethereum: %w", err)
-                }
-                clients.ethereumClient = ethClient
-                log.Info().Msg("Connected to Ethereum network")
-        }
-
-        // Initialize Base client
-        if cfg.BaseRPC != "" {
-                baseClient, err := ethclient.Dial(cfg.BaseRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Base: %w", err)
-                }
-                clients.baseClient = baseClient
-                log.Info().Msg("Connected to Base network")
-        }
-
-        // Initialize Arbitrum client
-        if cfg.ArbitrumRPC != "" {
-                arbitrumClient, err := ethclient.Dial(cfg.ArbitrumRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Arbitrum: %w", err)
-                }
-                clients.arbitrumClient = arbitrumClient
-                log.Info().Msg("Connected to Arbitrum network")
-        }
-
-        // Initialize Avalanche client
-        if cfg.AvalancheRPC != "" {
-                avalancheClient, err := ethclient.Dial(cfg.AvalancheRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Avalanche: %w", err)
-                }
-                clients.avalancheClient = avalancheClient
-                log.Info().Msg("Connected to Avalanche network")
-        }
-
-        // Initialize Celo client
-        if cfg.CeloRPC != "" {
-                celoClient, err := ethclient.Dial(cfg.CeloRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Celo: %w", err)
-                }
-                clients.celoClient = celoClient
-                log.Info().Msg("Connected to Celo network")
-        }
-
-        // Initialize Polygon client
-        if cfg.PolygonRPC != "" {
-                polygonClient, err := ethclient.Dial(cfg.PolygonRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Polygon: %w", err)
-                }
-                clients.polygonClient = polygonClient
-                log.Info().Msg("Connected to Polygon network")
+        // Initialize an adapter for every enabled liquidity chain
+        registry, err := NewRegistryFromConfig(cfg.Chains)
+        if err != nil {
+                return nil, err
         }
-
-        // Initialize Kava client
-        if cfg.KavaRPC != "" {
-                kavaClient, err := ethclient.Dial(cfg.KavaRPC)
-                if err != nil {
-                        return nil, fmt.Errorf("failed to connect to Kava: %w", err)
-                }
-                clients.kavaClient = kavaClient
-                log.Info().Msg("Connected to Kava network")
+        for _, chainID := range registry.Chains() {
+                log.Info().Str("chain_id", chainID).Msg("Connected to liquidity chain")
         }
 
-        // Initialize Solana client
-        if cfg.SolanaRPC != "" {
-                clients.solanaClient = NewSolanaClient(cfg.SolanaRPC)
-                log.Info().Msg("Connected to Solana network")
-        }
-
-        // Initialize Aptos client
-        if cfg.AptosRPC != "" {
-                clients.aptosClient = NewAptosClient(cfg.AptosRPC)
-                log.Info().Msg("Connected to Aptos network")
+        clients := &Clients{
+                config:   cfg,
+                registry: registry,
         }
 
         // Initialize Hedera client
@@ -127,49 +44,23 @@ func NewClients(cfg *config.Config) (*Clients, error) {
         return clients, nil
 }
 
-// GetEthereumClient returns the Ethereum client
-func (c *Clients) GetEthereumClient() *ethclient.Client {
-        return c.ethereumClient
-}
-
-// GetBaseClient returns the Base client
-func (c *Clients) GetBaseClient() *ethclient.Client {
-        return c.baseClient
-}
-
-// GetArbitrumClient returns the Arbitrum client
-func (c *Clients) GetArbitrumClient() *ethclient.Client {
-        return c.arbitrumClient
-}
-
-// GetAvalancheClient returns the Avalanche client
-func (c *Clients) GetAvalancheClient() *ethclient.Client {
-        return c.avalancheClient
-}
-
-// GetCeloClient returns the Celo client
-func (c *Clients) GetCeloClient() *ethclient.Client {
-        return c.celoClient
-}
-
-// GetPolygonClient returns the Polygon client
-func (c *Clients) GetPolygonClient() *ethclient.Client {
-        return c.polygonClient
-}
-
-// GetKavaClient returns the Kava client
-func (c *Clients) GetKavaClient() *ethclient.Client {
-        return c.kavaClient
+// NewClientsWithRegistry creates clients over an existing adapter registry
+func NewClientsWithRegistry(cfg *config.Config, registry *Registry, hederaClient *HederaClient) *Clients {
+        return &Clients{
+                config:       cfg,
+                registry:     registry,
+                hederaClient: hederaClient,
+        }
 }
 
-// GetSolanaClient returns the Solana client
-func (c *Clients) GetSolanaClient() *SolanaClient {
-        return c.solanaClient
+// Registry returns the liquidity chain adapters
+func (c *Clients) Registry() *Registry {
+        return c.registry
 }
 
-// GetAptosClient returns the Aptos client
-func (c *Clients) GetAptosClient() *AptosClient {
-        return c.aptosClient
+// Adapter returns the adapter for a liquidity chain
+func (c *Clients) Adapter(chainID string) (ChainAdapter, error) {
+        return c.registry.Adapter(strings.ToLower(chainID))
 }
 
 // GetHederaClient returns the Hedera client
@@ -177,81 +68,26 @@ func (c *Clients) GetHederaClient() *HederaClient {
         return c.hederaClient
 }
 
-// WaitForTransaction waits for a transaction to be confirmed on any EVM chain
-func (c *Clients) WaitForTransaction(ctx context.Context, chainID string, txHash common.Hash) error {
-	var client *ethclient.Client
-
-	switch strings.ToLower(chainID) {
-	case "ethereum", "1", "11155111":
-		client = c.ethereumClient
-	case "base", "8453", "84531":
-		client = c.baseClient
-	case "arbitrum", "42161", "421614":
-		client = c.arbitrumClient
-	case "avalanche", "43114", "43113":
-		client = c.avalancheClient
-	case "celo", "42220", "44787":
-		client = c.celoClient
-	case "polygon", "137", "80001":
-		client = c.polygonClient
-	case "kava", "2222", "2221":
-		client = c.kavaClient
-	default:
-		return fmt.Errorf("unsupported chain ID: %s", chainID)
-	}
-
-	if client == nil {
-		return fmt.Errorf("client not available for chain ID: %s", chainID)
-	}
-
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+// WaitForTransaction waits for a transaction to be confirmed on any liquidity chain.
+// expiry is the transaction's Expiry; with zero it waits until ctx is done.
+func (c *Clients) WaitForTransaction(ctx context.Context, chainID string, txHash string, expiry uint64) error {
+        adapter, err := c.Adapter(chainID)
+        if err != nil {
+                return err
+        }
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			_, err := client.TransactionReceipt(ctx, txHash)
-			if err == nil {
-				return nil // Success
-			}
-			if err == ethereum.NotFound {
-				continue // Transaction not yet mined
-			}
-			return err // Other error
-		}
-	}
+        _, err = WaitForFinality(ctx, adapter, txHash, expiry, defaultPollInterval)
+        return err
 }
 
-// GetBalance returns the balance of an address on any EVM chain
-func (c *Clients) GetBalance(ctx context.Context, chainID string, address common.Address) (*big.Int, error) {
-        var client *ethclient.Client
-
-        switch strings.ToLower(chainID) {
-        case "ethereum", "1", "11155111":
-                client = c.ethereumClient
-        case "base", "8453", "84531":
-                client = c.baseClient
-        case "arbitrum", "42161", "421614":
-                client = c.arbitrumClient
-        case "avalanche", "43114", "43113":
-                client = c.avalancheClient
-        case "celo", "42220", "44787":
-                client = c.celoClient
-        case "polygon", "137", "80001":
-                client = c.polygonClient
-        case "kava", "2222", "2221":
-                client = c.kavaClient
-        default:
-                return nil, fmt.Errorf("unsupported chain ID: %s", chainID)
+// GetBalance returns the native balance of an address on any liquidity chain
+func (c *Clients) GetBalance(ctx context.Context, chainID string, address string) (*big.Int, error) {
+        adapter, err := c.Adapter(chainID)
+        if err != nil {
+                return nil, err
         }
 
-        if client == nil {
-                return nil, fmt.Errorf("client not available for chain ID: %s", chainID)
-        }
-
-        return client.BalanceAt(ctx, address, nil)
+        return adapter.Balance(ctx, address)
 }
 
 // SendTransaction sends a transaction on Ethereum/Base
@@ -261,59 +97,6 @@ func (c *Clients) SendTransaction(ctx context.Context, chainID string, tx *bind.
         return nil, fmt.Errorf("not implemented")
 }
 
-// SolanaClient represents a Solana blockchain client
-type SolanaClient struct {
-        rpcURL string
-}
-
-// NewSolanaClient creates a new Solana client
-func NewSolanaClient(rpcURL string) *SolanaClient {
-        return &SolanaClient{
-                rpcURL: rpcURL,
-        }
-}
-
-// GetBalance returns the balance of a Solana account
-func (c *SolanaClient) GetBalance(ctx context.Context, account string) (uint64, error) {
-        // This is a placeholder implementation
-        // In a real implementation, you would use the Solana RPC client
-        return 0, fmt.Errorf("not implemented")
-}
-
-// SendTransaction sends a transaction on Solana
-func (c *SolanaClient) SendTransaction(ctx context.Context, tx []byte) (string, error) {
-        // This is a placeholder implementation
-        // In a real implementation, you would send the transaction to Solana
-        return "", fmt.Errorf("not implemented")
-}
-
-// AptosClient represents an Aptos blockchain client
-type AptosClient struct {
-        rpcURL string
-}
-
-// NewAptosClient creates a new Aptos client
-func NewAptosClient(rpcURL string) *AptosClient {
-        return &AptosClient{
-                rpcURL: rpcURL,
-        }
-}
-
-// GetBalance returns the balance of an Aptos account
-func (c *AptosClient) GetBalance(ctx context.Context, account string) (uint64, error) {
-        // This is a placeholder implementation
-        // In a real implementation, you would use the Aptos REST client
-        return 0, fmt.Errorf("not implemented")
-}
-
-// SendTransaction sends a transaction on Aptos
-func (c *AptosClient) SendTransaction(ctx context.Context, tx []byte) (string, error) {
-        // This is a placeholder implementation
-        // In a real implementation, you would send the transaction to Aptos
-        return "", fmt.Errorf("not implemented")
-}
-
-
 // GetTokenInfo returns information about a token on any supported chain
 func (c *Clients) GetTokenInfo(ctx context.Context, chainID string, tokenAddress string) (map[string]interface{}, error) {
         // This is a placeholder implementation
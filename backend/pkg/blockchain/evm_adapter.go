@@ -0,0 +1,417 @@
+package blockchain
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// EVMBackend is the subset of ethclient.Client the EVM adapter needs. The go-ethereum
+// simulated backend satisfies it once BlockNumber is added, as the e2e tests do.
+type EVMBackend interface {
+	bind.ContractBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// EVMAdapter is the ChainAdapter for EVM chains.
+type EVMAdapter struct {
+	spec         config.ChainSpec
+	backend      EVMBackend
+	chainID      *big.Int
+	pollInterval time.Duration
+}
+
+// DialEVMAdapter connects to the chain's RPC endpoint and creates an EVM adapter.
+func DialEVMAdapter(spec config.ChainSpec) (ChainAdapter, error) {
+	client, err := ethclient.Dial(spec.RPCURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewEVMAdapter(spec, client), nil
+}
+
+// NewEVMAdapter creates an EVM adapter over an existing backend
+func NewEVMAdapter(spec config.ChainSpec, backend EVMBackend) *EVMAdapter {
+	return &EVMAdapter{
+		spec:         spec,
+		backend:      backend,
+		chainID:      new(big.Int).SetUint64(spec.EVMChainID),
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// ChainID returns the registry key of the chain
+func (a *EVMAdapter) ChainID() string {
+	return a.spec.Key
+}
+
+// Spec returns the chain configuration
+func (a *EVMAdapter) Spec() config.ChainSpec {
+	return a.spec
+}
+
+// Backend returns the underlying EVM client, for contract bindings.
+func (a *EVMAdapter) Backend() EVMBackend {
+	return a.backend
+}
+
+// Balance returns the native balance of an account in wei
+func (a *EVMAdapter) Balance(ctx context.Context, account string) (*big.Int, error) {
+	return a.backend.BalanceAt(ctx, common.HexToAddress(account), nil)
+}
+
+// Nonce returns the pending nonce of an account
+func (a *EVMAdapter) Nonce(ctx context.Context, account string) (uint64, error) {
+	return a.backend.PendingNonceAt(ctx, common.HexToAddress(account))
+}
+
+// EstimateFee returns the suggested legacy gas price and EIP-1559 fee caps. The fee
+// cap allows the base fee to double before the transaction is priced out.
+func (a *EVMAdapter) EstimateFee(ctx context.Context) (*FeeEstimate, error) {
+	gasPrice, err := a.backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+
+	fee := &FeeEstimate{GasPrice: gasPrice}
+
+	header, err := a.backend.HeaderByNumber(ctx, nil)
+	if err != nil || header.BaseFee == nil {
+		return fee, nil // pre-London chain, legacy pricing only
+	}
+	tip, err := a.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return fee, nil
+	}
+	fee.GasTipCap = tip
+	fee.GasFeeCap = new(big.Int).Add(tip, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+	return fee, nil
+}
+
+// BuildTransaction builds an unsigned dynamic-fee transaction, or a legacy one when
+// the fee has no EIP-1559 caps.
+func (a *EVMAdapter) BuildTransaction(ctx context.Context, req *TxRequest) (*Transaction, error) {
+	if req.To == "" {
+		return nil, fmt.Errorf("transaction has no recipient")
+	}
+	to := common.HexToAddress(req.To)
+
+	var nonce uint64
+	if req.Nonce != nil {
+		nonce = *req.Nonce
+	} else {
+		var err error
+		if nonce, err = a.Nonce(ctx, req.From); err != nil {
+			return nil, fmt.Errorf("failed to get nonce: %w", err)
+		}
+	}
+
+	fee := req.Fee
+	if fee == nil {
+		var err error
+		if fee, err = a.EstimateFee(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	value := req.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	gasLimit := req.GasLimit
+	if gasLimit == 0 {
+		estimated, err := a.backend.EstimateGas(ctx, ethereum.CallMsg{
+			From:  common.HexToAddress(req.From),
+			To:    &to,
+			Value: value,
+			Data:  req.Data,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate gas: %w", err)
+		}
+		gasLimit = estimated
+	}
+
+	var tx *types.Transaction
+	if fee.GasFeeCap != nil && fee.GasTipCap != nil {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   a.chainID,
+			Nonce:     nonce,
+			GasTipCap: fee.GasTipCap,
+			GasFeeCap: fee.GasFeeCap,
+			Gas:       gasLimit,
+			To:        &to,
+			Value:     value,
+			Data:      req.Data,
+		})
+	} else {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: fee.GasPrice,
+			Gas:      gasLimit,
+			To:       &to,
+			Value:    value,
+			Data:     req.Data,
+		})
+	}
+
+	return &Transaction{ChainID: a.spec.Key, Nonce: nonce, Native: tx}, nil
+}
+
+// EVMSigner signs EVM transactions for an account whose key need not be held by the
+// process, such as a keystore or a remote signer.
+type EVMSigner interface {
+	Address() common.Address
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// SignTransaction signs the transaction with an ECDSA key or an EVMSigner.
+func (a *EVMAdapter) SignTransaction(ctx context.Context, tx *Transaction, key crypto.PrivateKey) (*Transaction, error) {
+	native, ok := tx.Native.(*types.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("not an EVM transaction")
+	}
+
+	var signed *types.Transaction
+	var err error
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		signed, err = types.SignTx(native, types.LatestSignerForChainID(a.chainID), key)
+	case EVMSigner:
+		signed, err = key.SignTx(ctx, native, a.chainID)
+	default:
+		return nil, fmt.Errorf("EVM transactions require an ECDSA key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	return &Transaction{
+		ChainID: a.spec.Key,
+		Nonce:   signed.Nonce(),
+		Hash:    signed.Hash().Hex(),
+		Raw:     raw,
+		Native:  signed,
+	}, nil
+}
+
+// SendTransaction submits a signed transaction
+func (a *EVMAdapter) SendTransaction(ctx context.Context, tx *Transaction) (string, error) {
+	native, ok := tx.Native.(*types.Transaction)
+	if !ok {
+		return "", fmt.Errorf("not an EVM transaction")
+	}
+	if err := a.backend.SendTransaction(ctx, native); err != nil {
+		return "", err
+	}
+	return native.Hash().Hex(), nil
+}
+
+// CallContract executes a read-only call against the latest block
+func (a *EVMAdapter) CallContract(ctx context.Context, req *TxRequest) ([]byte, error) {
+	if req.To == "" {
+		return nil, fmt.Errorf("call has no recipient")
+	}
+	to := common.HexToAddress(req.To)
+	return a.backend.CallContract(ctx, ethereum.CallMsg{
+		From:  common.HexToAddress(req.From),
+		To:    &to,
+		Value: req.Value,
+		Data:  req.Data,
+	}, nil)
+}
+
+// TransactionReceipt returns the receipt of a mined transaction with its current
+// confirmation count.
+func (a *EVMAdapter) TransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	receipt, err := a.backend.TransactionReceipt(ctx, common.HexToHash(hash))
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, ErrTxNotFound
+		}
+		return nil, err
+	}
+
+	head, err := a.backend.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	var confirmations uint64
+	if block := receipt.BlockNumber.Uint64(); head >= block {
+		confirmations = head - block + 1
+	}
+
+	logs := make([]ChainLog, 0, len(receipt.Logs))
+	for _, l := range receipt.Logs {
+		logs = append(logs, toChainLog(a.spec.Key, *l))
+	}
+
+	return &Receipt{
+		TxHash:        hash,
+		BlockNumber:   receipt.BlockNumber.Uint64(),
+		BlockHash:     receipt.BlockHash.Hex(),
+		Success:       receipt.Status == types.ReceiptStatusSuccessful,
+		Confirmations: confirmations,
+		Final:         confirmations >= a.spec.Confirmations,
+		Fee:           receiptFee(receipt),
+		Logs:          logs,
+	}, nil
+}
+
+// SubscribeLogs streams matching logs into sink. It uses a push subscription when the
+// endpoint supports it and falls back to polling otherwise.
+func (a *EVMAdapter) SubscribeLogs(ctx context.Context, filter LogFilter, sink chan<- ChainLog) (Subscription, error) {
+	query := a.filterQuery(filter)
+
+	logs := make(chan types.Log)
+	if sub, err := a.backend.SubscribeFilterLogs(ctx, query, logs); err == nil {
+		return forwardLogs(ctx, sub, logs, sink, a.spec.Key), nil
+	}
+
+	return a.pollLogs(ctx, query, sink), nil
+}
+
+func (a *EVMAdapter) filterQuery(filter LogFilter) ethereum.FilterQuery {
+	addresses := filter.Addresses
+	if len(addresses) == 0 && a.spec.ContractAddress != "" {
+		addresses = []string{a.spec.ContractAddress}
+	}
+
+	query := ethereum.FilterQuery{}
+	for _, address := range addresses {
+		query.Addresses = append(query.Addresses, common.HexToAddress(address))
+	}
+	for _, position := range filter.Topics {
+		var topics []common.Hash
+		for _, topic := range position {
+			topics = append(topics, common.HexToHash(topic))
+		}
+		query.Topics = append(query.Topics, topics)
+	}
+	if filter.FromBlock > 0 {
+		query.FromBlock = new(big.Int).SetUint64(filter.FromBlock)
+	}
+	return query
+}
+
+// logSubscription is a Subscription driven by a goroutine.
+type logSubscription struct {
+	cancel context.CancelFunc
+	errc   chan error
+}
+
+func (s *logSubscription) Unsubscribe() { s.cancel() }
+
+func (s *logSubscription) Err() <-chan error { return s.errc }
+
+func forwardLogs(ctx context.Context, sub ethereum.Subscription, logs <-chan types.Log, sink chan<- ChainLog, chainID string) Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &logSubscription{cancel: cancel, errc: make(chan error, 1)}
+
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-sub.Err():
+				s.errc <- err
+				return
+			case l := <-logs:
+				select {
+				case sink <- toChainLog(chainID, l):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+func (a *EVMAdapter) pollLogs(ctx context.Context, query ethereum.FilterQuery, sink chan<- ChainLog) Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &logSubscription{cancel: cancel, errc: make(chan error, 1)}
+
+	go func() {
+		ticker := time.NewTicker(a.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			head, err := a.backend.BlockNumber(ctx)
+			if err == nil && (query.FromBlock == nil || query.FromBlock.Uint64() <= head) {
+				q := query
+				q.ToBlock = new(big.Int).SetUint64(head)
+				logs, err := a.backend.FilterLogs(ctx, q)
+				if err != nil {
+					if ctx.Err() == nil {
+						s.errc <- fmt.Errorf("failed to filter logs: %w", err)
+					}
+					return
+				}
+				for _, l := range logs {
+					select {
+					case sink <- toChainLog(a.spec.Key, l):
+					case <-ctx.Done():
+						return
+					}
+				}
+				query.FromBlock = new(big.Int).SetUint64(head + 1)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+func toChainLog(chainID string, l types.Log) ChainLog {
+	topics := make([]string, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i] = topic.Hex()
+	}
+	return ChainLog{
+		ChainID:     chainID,
+		Address:     l.Address.Hex(),
+		Topics:      topics,
+		Data:        l.Data,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		Index:       l.Index,
+	}
+}
+
+// receiptFee returns the gas the transaction paid for, if the node reports its price
+func receiptFee(receipt *types.Receipt) *big.Int {
+	if receipt.EffectiveGasPrice == nil {
+		return nil
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+}
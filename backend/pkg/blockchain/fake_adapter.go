@@ -0,0 +1,383 @@
+package blockchain
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"kelo-backend/pkg/config"
+)
+
+// FakeAdapter is an in-memory ChainAdapter for tests. Sent transactions are mined
+// into the next block immediately; Mine advances the head to add confirmations.
+type FakeAdapter struct {
+	mu       sync.Mutex
+	spec     config.ChainSpec
+	balances map[string]*big.Int
+	nonces   map[string]uint64          // next nonce with no transaction, per sender
+	used     map[string]map[uint64]bool // nonces taken by sent transactions, per sender
+	fee      *FeeEstimate
+	head     uint64
+	fork     uint64 // bumped by every reorg, so re-mined blocks get new hashes
+	receipts map[string]*Receipt
+	sent     []*Transaction
+	mempool  []*Transaction
+	subs     []*fakeSubscription
+
+	// SendErr, when set, is returned by SendTransaction.
+	SendErr error
+	// Revert marks every subsequently mined transaction as failed.
+	Revert bool
+	// Hold keeps sent transactions in the mempool until Mine is called. A transaction
+	// with the same sender and nonce as a held one replaces it.
+	Hold bool
+	// CallFunc, when set, answers CallContract.
+	CallFunc func(req *TxRequest) ([]byte, error)
+	// LogsFunc, when set, gives the logs of each mined transaction's receipt.
+	LogsFunc func(tx *Transaction) []ChainLog
+	// ExpiresAfter, when set, gives built transactions an Expiry that many blocks past
+	// the head, and TransactionExpired compares it with the head.
+	ExpiresAfter uint64
+}
+
+// NewFakeAdapter creates a fake adapter for the chain spec
+func NewFakeAdapter(spec config.ChainSpec) *FakeAdapter {
+	if spec.Confirmations == 0 {
+		spec.Confirmations = 1
+	}
+	return &FakeAdapter{
+		spec:     spec,
+		balances: make(map[string]*big.Int),
+		nonces:   make(map[string]uint64),
+		used:     make(map[string]map[uint64]bool),
+		fee:      &FeeEstimate{GasPrice: big.NewInt(1000000000), GasTipCap: big.NewInt(1000000000), GasFeeCap: big.NewInt(2000000000)},
+		receipts: make(map[string]*Receipt),
+	}
+}
+
+// ChainID returns the registry key of the chain
+func (f *FakeAdapter) ChainID() string {
+	return f.spec.Key
+}
+
+// Spec returns the chain configuration
+func (f *FakeAdapter) Spec() config.ChainSpec {
+	return f.spec
+}
+
+// SetBalance sets the balance returned for an account.
+func (f *FakeAdapter) SetBalance(account string, balance *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[account] = balance
+}
+
+// SetNonce sets the account's next nonce, as if it had sent transactions elsewhere.
+func (f *FakeAdapter) SetNonce(account string, nonce uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nonces[account] = nonce
+}
+
+// SetFee sets the fee returned by EstimateFee.
+func (f *FakeAdapter) SetFee(fee *FeeEstimate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fee = fee
+}
+
+// Mine advances the chain head by n blocks, including held transactions in the first.
+func (f *FakeAdapter) Mine(n uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	if len(f.mempool) > 0 {
+		f.head++
+		for _, tx := range f.mempool {
+			f.receipts[tx.Hash] = f.newReceipt(tx)
+		}
+		f.mempool = nil
+		n--
+	}
+	f.head += n
+}
+
+// DropHeld discards the held transactions without mining them, as a node does with
+// transactions that expire, and frees their nonces.
+func (f *FakeAdapter) DropHeld() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tx := range f.mempool {
+		f.releaseNonce(tx.Hash)
+	}
+	f.mempool = nil
+}
+
+// Reorg replaces the last depth blocks with a fork of the same height. Transactions mined
+// in them are re-mined into the first block of the fork when reinclude is set; otherwise
+// they are dropped from the chain and the mempool, and their nonces can be used again.
+func (f *FakeAdapter) Reorg(depth uint64, reinclude bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if depth == 0 || depth > f.head {
+		return
+	}
+
+	f.fork++
+	base := f.head - depth
+	for hash, receipt := range f.receipts {
+		if receipt.BlockNumber <= base {
+			continue
+		}
+		if reinclude {
+			receipt.BlockNumber = base + 1
+			receipt.BlockHash = fakeBlockHash(f.fork, base+1)
+		} else {
+			delete(f.receipts, hash)
+			f.releaseNonce(hash)
+		}
+	}
+}
+
+// releaseNonce frees the nonce of a dropped transaction
+func (f *FakeAdapter) releaseNonce(hash string) {
+	for _, tx := range f.sent {
+		req, _ := tx.Native.(*TxRequest)
+		if tx.Hash != hash || req == nil {
+			continue
+		}
+		delete(f.used[req.From], tx.Nonce)
+		if tx.Nonce < f.nonces[req.From] {
+			f.nonces[req.From] = tx.Nonce
+		}
+	}
+}
+
+// newReceipt records a transaction mined at the head. It pays for its whole gas limit,
+// or 21000 gas without one, at the legacy gas price.
+func (f *FakeAdapter) newReceipt(tx *Transaction) *Receipt {
+	receipt := &Receipt{TxHash: tx.Hash, BlockNumber: f.head, BlockHash: fakeBlockHash(f.fork, f.head), Success: !f.Revert}
+	if f.LogsFunc != nil && receipt.Success {
+		receipt.Logs = f.LogsFunc(tx)
+	}
+	if req, _ := tx.Native.(*TxRequest); req != nil && req.Fee != nil && req.Fee.GasPrice != nil {
+		gas := req.GasLimit
+		if gas == 0 {
+			gas = 21000
+		}
+		receipt.Fee = new(big.Int).Mul(new(big.Int).SetUint64(gas), req.Fee.GasPrice)
+	}
+	return receipt
+}
+
+func fakeBlockHash(fork, number uint64) string {
+	return fmt.Sprintf("0x%032x%032x", fork, number)
+}
+
+// Sent returns the transactions submitted so far.
+func (f *FakeAdapter) Sent() []*Transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*Transaction(nil), f.sent...)
+}
+
+// EmitLog delivers a log to every active subscription.
+func (f *FakeAdapter) EmitLog(l ChainLog) {
+	f.mu.Lock()
+	subs := append([]*fakeSubscription(nil), f.subs...)
+	f.mu.Unlock()
+
+	l.ChainID = f.spec.Key
+	for _, sub := range subs {
+		select {
+		case sub.sink <- l:
+		case <-sub.done:
+		}
+	}
+}
+
+func (f *FakeAdapter) Balance(ctx context.Context, account string) (*big.Int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if balance, ok := f.balances[account]; ok {
+		return new(big.Int).Set(balance), nil
+	}
+	return big.NewInt(0), nil
+}
+
+func (f *FakeAdapter) Nonce(ctx context.Context, account string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nonces[account], nil
+}
+
+func (f *FakeAdapter) EstimateFee(ctx context.Context) (*FeeEstimate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fee, nil
+}
+
+func (f *FakeAdapter) BuildTransaction(ctx context.Context, req *TxRequest) (*Transaction, error) {
+	nonce, _ := f.Nonce(ctx, req.From)
+	if req.Nonce != nil {
+		nonce = *req.Nonce
+	}
+	built := *req
+	if built.Fee == nil {
+		built.Fee, _ = f.EstimateFee(ctx)
+	}
+	tx := &Transaction{ChainID: f.spec.Key, Nonce: nonce, Native: &built}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ExpiresAfter > 0 {
+		tx.Expiry = f.head + f.ExpiresAfter
+	}
+	return tx, nil
+}
+
+// SignTransaction derives a deterministic hash from the request; the key is not used.
+func (f *FakeAdapter) SignTransaction(ctx context.Context, tx *Transaction, key crypto.PrivateKey) (*Transaction, error) {
+	req, ok := tx.Native.(*TxRequest)
+	if !ok {
+		return nil, fmt.Errorf("not a fake transaction")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(f.spec.Key))
+	h.Write([]byte(req.From))
+	h.Write([]byte(req.To))
+	h.Write(binary.BigEndian.AppendUint64(nil, tx.Nonce))
+	h.Write(req.Data)
+	if req.Fee != nil {
+		for _, fee := range []*big.Int{req.Fee.GasPrice, req.Fee.GasTipCap, req.Fee.GasFeeCap} {
+			if fee != nil {
+				h.Write(fee.Bytes())
+			}
+		}
+	}
+	raw := h.Sum(nil)
+
+	signed := *tx
+	signed.Hash = "0x" + hex.EncodeToString(raw)
+	signed.Raw = raw
+	return &signed, nil
+}
+
+// SendTransaction records the transaction and mines it into the next block. Like a
+// node, it rejects a nonce the sender has already used unless it replaces a held
+// transaction, and the pending nonce only advances past contiguous nonces.
+func (f *FakeAdapter) SendTransaction(ctx context.Context, tx *Transaction) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.SendErr != nil {
+		return "", f.SendErr
+	}
+	if tx.Hash == "" {
+		return "", fmt.Errorf("transaction is not signed")
+	}
+
+	req, _ := tx.Native.(*TxRequest)
+	if req != nil {
+		if (tx.Nonce < f.nonces[req.From] || f.used[req.From][tx.Nonce]) && !f.isHeld(req.From, tx.Nonce) {
+			return "", fmt.Errorf("nonce too low: next nonce %d, tx nonce %d", f.nonces[req.From], tx.Nonce)
+		}
+		if f.used[req.From] == nil {
+			f.used[req.From] = make(map[uint64]bool)
+		}
+		f.used[req.From][tx.Nonce] = true
+		for f.used[req.From][f.nonces[req.From]] {
+			f.nonces[req.From]++
+		}
+	}
+	f.sent = append(f.sent, tx)
+
+	if f.Hold {
+		pool := f.mempool[:0]
+		for _, held := range f.mempool {
+			if heldReq, _ := held.Native.(*TxRequest); req == nil || heldReq == nil || heldReq.From != req.From || held.Nonce != tx.Nonce {
+				pool = append(pool, held)
+			}
+		}
+		f.mempool = append(pool, tx)
+		return tx.Hash, nil
+	}
+
+	f.head++
+	f.receipts[tx.Hash] = f.newReceipt(tx)
+	return tx.Hash, nil
+}
+
+// isHeld reports whether a held transaction from the sender has the nonce.
+func (f *FakeAdapter) isHeld(from string, nonce uint64) bool {
+	for _, held := range f.mempool {
+		if heldReq, _ := held.Native.(*TxRequest); heldReq != nil && heldReq.From == from && held.Nonce == nonce {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeAdapter) CallContract(ctx context.Context, req *TxRequest) ([]byte, error) {
+	if f.CallFunc == nil {
+		return nil, ErrNotSupported
+	}
+	return f.CallFunc(req)
+}
+
+func (f *FakeAdapter) TransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	receipt, ok := f.receipts[hash]
+	if !ok {
+		return nil, ErrTxNotFound
+	}
+	r := *receipt
+	r.Confirmations = f.head - r.BlockNumber + 1
+	r.Final = r.Confirmations >= f.spec.Confirmations
+	return &r, nil
+}
+
+// TransactionExpired reports whether the head is past the expiry.
+func (f *FakeAdapter) TransactionExpired(ctx context.Context, expiry uint64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head > expiry, nil
+}
+
+func (f *FakeAdapter) SubscribeLogs(ctx context.Context, filter LogFilter, sink chan<- ChainLog) (Subscription, error) {
+	sub := &fakeSubscription{sink: sink, done: make(chan struct{}), errc: make(chan error)}
+
+	f.mu.Lock()
+	f.subs = append(f.subs, sub)
+	f.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.Unsubscribe()
+		case <-sub.done:
+		}
+	}()
+	return sub, nil
+}
+
+type fakeSubscription struct {
+	sink chan<- ChainLog
+	done chan struct{}
+	errc chan error
+	once sync.Once
+}
+
+func (s *fakeSubscription) Unsubscribe() { s.once.Do(func() { close(s.done) }) }
+
+func (s *fakeSubscription) Err() <-chan error { return s.errc }
@@ -5,16 +5,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/logger"
+	"kelo-backend/pkg/tracing"
+	"math"
+	"math/big"
 	"os"
 
 	"github.com/hiero-ledger/hiero-sdk-go/v2"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// hederaTracer returns the tracer of the Hedera client's spans from the current tracer
+// provider
+func hederaTracer() trace.Tracer {
+	return otel.Tracer("kelo-backend/pkg/blockchain/hedera")
+}
+
 // HederaClient represents a Hedera blockchain client
 type HederaClient struct {
-	client          *hedera.Client
-	loanUpdateTopic string
+	client            *hedera.Client
+	loanUpdateTopic   string
+	loanAssetDecimals int
 }
 
 // NewHederaClient creates a new Hedera client
@@ -52,14 +66,22 @@ func NewHederaClient(cfg *config.Config) *HederaClient {
 	client.SetOperator(operatorID, operatorKey)
 
 	return &HederaClient{
-		client:          client,
-		loanUpdateTopic: cfg.HederaLoanUpdateTopicID,
+		client:            client,
+		loanUpdateTopic:   cfg.HederaLoanUpdateTopicID,
+		loanAssetDecimals: cfg.LoanAssetDecimals,
 	}
 }
 
 // MintLoanAgreementNFT mints a new HTS NFT to represent a loan agreement.
-func (c *HederaClient) MintLoanAgreementNFT(ctx context.Context, loanID string, metadata []byte) (string, int64, error) {
-	log.Info().Str("loanId", loanID).Msg("Minting new loan agreement NFT on Hedera")
+func (c *HederaClient) MintLoanAgreementNFT(ctx context.Context, loanID string, metadata []byte) (_ string, _ int64, err error) {
+	_, span := hederaTracer().Start(ctx, "HederaClient.MintLoanAgreementNFT", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("loan_id", loanID)))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	logger.FromContext(ctx).Info().Str("loanId", loanID).Msg("Minting new loan agreement NFT on Hedera")
 
 	op := c.client.GetOperator()
 
@@ -89,7 +111,7 @@ func (c *HederaClient) MintLoanAgreementNFT(ctx context.Context, loanID string,
 	}
 
 	tokenID := *receipt.TokenID
-	log.Info().Str("tokenId", tokenID.String()).Msg("Successfully created NFT token class")
+	logger.FromContext(ctx).Info().Str("tokenId", tokenID.String()).Msg("Successfully created NFT token class")
 
 	// 2. Mint the actual NFT with metadata
 	mintTx, err := hedera.NewTokenMintTransaction().
@@ -110,7 +132,8 @@ func (c *HederaClient) MintLoanAgreementNFT(ctx context.Context, loanID string,
 	}
 
 	serial := mintReceipt.SerialNumbers[0]
-	log.Info().
+	span.SetAttributes(attribute.String("token_id", tokenID.String()), attribute.Int64("serial_number", serial))
+	logger.FromContext(ctx).Info().
 		Str("tokenId", tokenID.String()).
 		Int64("serialNumber", serial).
 		Msg("Successfully minted NFT")
@@ -119,8 +142,18 @@ func (c *HederaClient) MintLoanAgreementNFT(ctx context.Context, loanID string,
 }
 
 // RecordLoanCreationEvent records the loan creation on the Hedera Consensus Service.
-func (c *HederaClient) RecordLoanCreationEvent(ctx context.Context, topicIDStr string, eventData []byte) error {
-	log.Info().Str("topicId", topicIDStr).Msg("Recording loan creation event on HCS")
+// A JSON object event carries the caller's trace context under "trace_context", so
+// the relayer can continue the trace.
+func (c *HederaClient) RecordLoanCreationEvent(ctx context.Context, topicIDStr string, eventData []byte) (err error) {
+	ctx, span := hederaTracer().Start(ctx, "HederaClient.RecordLoanCreationEvent", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("topic_id", topicIDStr)))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	logger.FromContext(ctx).Info().Str("topicId", topicIDStr).Msg("Recording loan creation event on HCS")
+	eventData = withTraceContext(ctx, eventData)
 
 	topicID, err := hedera.TopicIDFromString(topicIDStr)
 	if err != nil {
@@ -141,13 +174,20 @@ func (c *HederaClient) RecordLoanCreationEvent(ctx context.Context, topicIDStr s
 		return fmt.Errorf("failed to get HCS message submission receipt: %w", err)
 	}
 
-	log.Info().Str("topicId", topicIDStr).Msg("Successfully recorded event on HCS")
+	logger.FromContext(ctx).Info().Str("topicId", topicIDStr).Msg("Successfully recorded event on HCS")
 	return nil
 }
 
 // UpdateLoanNFTStatus updates the metadata of the loan NFT when a repayment is made.
-func (c *HederaClient) UpdateLoanNFTStatus(ctx context.Context, tokenIDStr string, serialNumber int64, newMetadata []byte) error {
-	log.Info().
+func (c *HederaClient) UpdateLoanNFTStatus(ctx context.Context, tokenIDStr string, serialNumber int64, newMetadata []byte) (err error) {
+	ctx, span := hederaTracer().Start(ctx, "HederaClient.UpdateLoanNFTStatus",
+		trace.WithAttributes(attribute.String("token_id", tokenIDStr), attribute.Int64("serial_number", serialNumber)))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	logger.FromContext(ctx).Info().
 		Str("tokenId", tokenIDStr).
 		Int64("serialNumber", serialNumber).
 		Msg("Updating loan NFT status on Hedera")
@@ -167,7 +207,7 @@ func (c *HederaClient) UpdateLoanNFTStatus(ctx context.Context, tokenIDStr strin
 	//
 	// Given the constraints, we will simulate this by submitting a message to the HCS topic instead.
 	// This is a more realistic implementation of status updates.
-	log.Warn().Msg("Token metadata updates for specific serials are not directly supported via TokenUpdateTransaction. Submitting to HCS as a workaround.")
+	logger.FromContext(ctx).Warn().Msg("Token metadata updates for specific serials are not directly supported via TokenUpdateTransaction. Submitting to HCS as a workaround.")
 
 	if c.loanUpdateTopic == "" {
 		return fmt.Errorf("loan update topic ID is not configured")
@@ -184,3 +224,64 @@ func (c *HederaClient) UpdateLoanNFTStatus(ctx context.Context, tokenIDStr strin
 
 	return c.RecordLoanCreationEvent(ctx, c.loanUpdateTopic, updateEvent)
 }
+
+// LoanRepaidMessage builds the loan_repaid message the relayer reads from the loan update
+// topic. tokenID is the loan's LoanAgreementNFT token ID, amounts are in the loan asset's
+// base units and payer is the wallet that repaid.
+func LoanRepaidMessage(tokenID string, amount, totalRepaid *big.Int, payer string) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"event":        "loan_repaid",
+		"token_id":     tokenID,
+		"amount":       amount.String(),
+		"total_repaid": totalRepaid.String(),
+		"payer":        payer,
+	})
+}
+
+// PublishLoanRepaid submits a loan_repaid message to the loan update topic, so the relayer
+// confirms the repayment to the pool that funded the loan. Amounts are in the loan asset.
+func (c *HederaClient) PublishLoanRepaid(ctx context.Context, tokenID string, amount, totalRepaid float64, payer string) error {
+	if c.loanUpdateTopic == "" {
+		return fmt.Errorf("loan update topic ID is not configured")
+	}
+
+	message, err := LoanRepaidMessage(tokenID, baseUnits(amount, c.loanAssetDecimals), baseUnits(totalRepaid, c.loanAssetDecimals), payer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loan repaid event: %w", err)
+	}
+	return c.RecordLoanCreationEvent(ctx, c.loanUpdateTopic, message)
+}
+
+// baseUnits converts an amount of the loan asset to its base units, rounded to the nearest unit
+func baseUnits(amount float64, decimals int) *big.Int {
+	scaled := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(math.Pow10(decimals)))
+	scaled.Add(scaled, big.NewFloat(0.5))
+	units, _ := scaled.Int(nil)
+	return units
+}
+
+// withTraceContext adds the trace context of ctx to a JSON object event. Other events,
+// and events that already carry one, are returned unchanged.
+func withTraceContext(ctx context.Context, eventData []byte) []byte {
+	traceContext := tracing.Inject(ctx)
+	if traceContext == nil {
+		return eventData
+	}
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(eventData, &event); err != nil || event == nil {
+		return eventData
+	}
+	if _, ok := event["trace_context"]; ok {
+		return eventData
+	}
+	encoded, err := json.Marshal(traceContext)
+	if err != nil {
+		return eventData
+	}
+	event["trace_context"] = encoded
+	withContext, err := json.Marshal(event)
+	if err != nil {
+		return eventData
+	}
+	return withContext
+}
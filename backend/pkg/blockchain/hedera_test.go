@@ -0,0 +1,49 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestWithTraceContext(t *testing.T) {
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	event := []byte(`{"event":"loan_repaid","token_id":"7"}`)
+	assert.Equal(t, event, withTraceContext(context.Background(), event))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	var decoded struct {
+		Event        string            `json:"event"`
+		TraceContext map[string]string `json:"trace_context"`
+	}
+	require.NoError(t, json.Unmarshal(withTraceContext(ctx, event), &decoded))
+	assert.Equal(t, "loan_repaid", decoded.Event)
+	assert.Contains(t, decoded.TraceContext["traceparent"], span.SpanContext().TraceID().String())
+
+	// Events that are not JSON objects, or already carry a trace context, are unchanged
+	for _, unchanged := range [][]byte{[]byte("plain text"), []byte(`["a"]`), []byte(`{"trace_context":{}}`)} {
+		assert.Equal(t, unchanged, withTraceContext(ctx, unchanged))
+	}
+}
+
+func TestBaseUnits(t *testing.T) {
+	assert.Equal(t, "250000000", baseUnits(250, 6).String())
+	// Amounts that are not exact in binary round to the nearest unit
+	assert.Equal(t, "100100000", baseUnits(100.1, 6).String())
+	assert.Equal(t, "1", baseUnits(0.000001, 6).String())
+}
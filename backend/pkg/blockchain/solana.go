@@ -0,0 +1,576 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// SolanaPublicKey is an ed25519 public key or program-derived address on Solana.
+type SolanaPublicKey [32]byte
+
+// Well-known Solana programs used by the Kelo pool program.
+var (
+	SolanaSystemProgramID          = MustSolanaPublicKey("11111111111111111111111111111111")
+	SolanaTokenProgramID           = MustSolanaPublicKey("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+	SolanaAssociatedTokenProgramID = MustSolanaPublicKey("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL")
+)
+
+// SolanaPublicKeyFromBase58 parses a base58-encoded public key.
+func SolanaPublicKeyFromBase58(s string) (SolanaPublicKey, error) {
+	var key SolanaPublicKey
+	decoded, err := base58Decode(s)
+	if err != nil {
+		return key, err
+	}
+	if len(decoded) != len(key) {
+		return key, fmt.Errorf("invalid public key length: %d", len(decoded))
+	}
+	copy(key[:], decoded)
+	return key, nil
+}
+
+// MustSolanaPublicKey parses a base58-encoded public key and panics on error.
+func MustSolanaPublicKey(s string) SolanaPublicKey {
+	key, err := SolanaPublicKeyFromBase58(s)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// String returns the base58 encoding of the key.
+func (k SolanaPublicKey) String() string {
+	return base58Encode(k[:])
+}
+
+// ParseSolanaPrivateKey parses a base58-encoded 64-byte keypair as exported by the Solana CLI.
+func ParseSolanaPrivateKey(s string) (ed25519.PrivateKey, error) {
+	decoded, err := base58Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Solana private key: %w", err)
+	}
+	if len(decoded) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Solana private key length: %d", len(decoded))
+	}
+	return ed25519.PrivateKey(decoded), nil
+}
+
+// SolanaPublicKeyFromPrivateKey returns the public key of a Solana keypair.
+func SolanaPublicKeyFromPrivateKey(key ed25519.PrivateKey) SolanaPublicKey {
+	var pub SolanaPublicKey
+	copy(pub[:], key.Public().(ed25519.PublicKey))
+	return pub
+}
+
+// FindProgramAddress derives a program address and its bump seed, searching from bump 255 down.
+func FindProgramAddress(seeds [][]byte, programID SolanaPublicKey) (SolanaPublicKey, uint8, error) {
+	for bump := 255; bump >= 0; bump-- {
+		withBump := append(append([][]byte{}, seeds...), []byte{byte(bump)})
+		address, err := createProgramAddress(withBump, programID)
+		if err == nil {
+			return address, uint8(bump), nil
+		}
+	}
+	return SolanaPublicKey{}, 0, fmt.Errorf("unable to find a viable program address bump seed")
+}
+
+// FindAssociatedTokenAddress returns the associated token account of a wallet for a mint.
+func FindAssociatedTokenAddress(wallet, mint SolanaPublicKey) (SolanaPublicKey, error) {
+	address, _, err := FindProgramAddress([][]byte{wallet[:], SolanaTokenProgramID[:], mint[:]}, SolanaAssociatedTokenProgramID)
+	return address, err
+}
+
+// createProgramAddress hashes the seeds into an address, rejecting addresses that lie on
+// the ed25519 curve since those could have a private key.
+func createProgramAddress(seeds [][]byte, programID SolanaPublicKey) (SolanaPublicKey, error) {
+	h := sha256.New()
+	for _, seed := range seeds {
+		if len(seed) > 32 {
+			return SolanaPublicKey{}, fmt.Errorf("seed exceeds 32 bytes")
+		}
+		h.Write(seed)
+	}
+	h.Write(programID[:])
+	h.Write([]byte("ProgramDerivedAddress"))
+
+	var address SolanaPublicKey
+	copy(address[:], h.Sum(nil))
+	if isOnEd25519Curve(address) {
+		return SolanaPublicKey{}, fmt.Errorf("address is on the ed25519 curve")
+	}
+	return address, nil
+}
+
+var (
+	ed25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	// ed25519D is -121665/121666 mod p.
+	ed25519D = new(big.Int).Mod(
+		new(big.Int).Mul(big.NewInt(-121665), new(big.Int).ModInverse(big.NewInt(121666), ed25519P)),
+		ed25519P,
+	)
+)
+
+// isOnEd25519Curve reports whether the bytes decompress to a point on the ed25519 curve,
+// i.e. whether (y^2 - 1) / (d*y^2 + 1) has a square root mod p.
+func isOnEd25519Curve(key SolanaPublicKey) bool {
+	le := key
+	le[31] &= 0x7f
+	be := make([]byte, 32)
+	for i := range le {
+		be[31-i] = le[i]
+	}
+	y := new(big.Int).Mod(new(big.Int).SetBytes(be), ed25519P)
+
+	y2 := new(big.Int).Mul(y, y)
+	u := new(big.Int).Sub(y2, big.NewInt(1))
+	u.Mod(u, ed25519P)
+	v := new(big.Int).Mul(ed25519D, y2)
+	v.Add(v, big.NewInt(1))
+	v.Mod(v, ed25519P)
+
+	x2 := new(big.Int).Mul(u, new(big.Int).ModInverse(v, ed25519P))
+	x2.Mod(x2, ed25519P)
+	if x2.Sign() == 0 {
+		return true
+	}
+
+	exp := new(big.Int).Rsh(new(big.Int).Sub(ed25519P, big.NewInt(1)), 1)
+	return new(big.Int).Exp(x2, exp, ed25519P).Cmp(big.NewInt(1)) == 0
+}
+
+// SolanaAccountMeta describes an account referenced by an instruction.
+type SolanaAccountMeta struct {
+	PublicKey  SolanaPublicKey
+	IsSigner   bool
+	IsWritable bool
+}
+
+// SolanaInstruction is a single program instruction.
+type SolanaInstruction struct {
+	ProgramID SolanaPublicKey
+	Accounts  []SolanaAccountMeta
+	Data      []byte
+}
+
+// keloDisburseInstruction is the Borsh variant index of KeloInstruction::Disburse.
+const keloDisburseInstruction = 3
+
+// SolanaDisburseAccounts are the accounts the Kelo pool program's disburse instruction reads.
+type SolanaDisburseAccounts struct {
+	Relayer        SolanaPublicKey
+	MerchantToken  SolanaPublicKey
+	PoolToken      SolanaPublicKey
+	PoolAuthority  SolanaPublicKey
+	PoolState      SolanaPublicKey
+	TokenProgramID SolanaPublicKey
+	PoolProgramID  SolanaPublicKey
+	Amount         uint64
+}
+
+// NewSolanaDisburseInstruction builds KeloInstruction::Disburse { amount }.
+func NewSolanaDisburseInstruction(accounts SolanaDisburseAccounts) SolanaInstruction {
+	data := make([]byte, 9)
+	data[0] = keloDisburseInstruction
+	binary.LittleEndian.PutUint64(data[1:], accounts.Amount)
+
+	return SolanaInstruction{
+		ProgramID: accounts.PoolProgramID,
+		Accounts: []SolanaAccountMeta{
+			{PublicKey: accounts.Relayer, IsSigner: true, IsWritable: false},
+			{PublicKey: accounts.MerchantToken, IsWritable: true},
+			{PublicKey: accounts.PoolToken, IsWritable: true},
+			{PublicKey: accounts.PoolAuthority},
+			{PublicKey: accounts.PoolState},
+			{PublicKey: accounts.TokenProgramID},
+		},
+		Data: data,
+	}
+}
+
+// NewCreateAssociatedTokenAccountIdempotentInstruction creates a wallet's associated token
+// account if it does not exist yet.
+func NewCreateAssociatedTokenAccountIdempotentInstruction(payer, wallet, mint SolanaPublicKey) (SolanaInstruction, error) {
+	ata, err := FindAssociatedTokenAddress(wallet, mint)
+	if err != nil {
+		return SolanaInstruction{}, err
+	}
+	return SolanaInstruction{
+		ProgramID: SolanaAssociatedTokenProgramID,
+		Accounts: []SolanaAccountMeta{
+			{PublicKey: payer, IsSigner: true, IsWritable: true},
+			{PublicKey: ata, IsWritable: true},
+			{PublicKey: wallet},
+			{PublicKey: mint},
+			{PublicKey: SolanaSystemProgramID},
+			{PublicKey: SolanaTokenProgramID},
+		},
+		Data: []byte{1},
+	}, nil
+}
+
+// BuildSolanaTransaction compiles the instructions into a legacy transaction message paid
+// for and signed by signer. It returns the wire-format transaction and its signature, which
+// is also the transaction ID.
+func BuildSolanaTransaction(signer ed25519.PrivateKey, instructions []SolanaInstruction, recentBlockhash SolanaPublicKey) ([]byte, string, error) {
+	payer := SolanaPublicKeyFromPrivateKey(signer)
+
+	type keyFlags struct {
+		signer   bool
+		writable bool
+	}
+	flags := map[SolanaPublicKey]*keyFlags{payer: {signer: true, writable: true}}
+	order := []SolanaPublicKey{payer}
+	addKey := func(key SolanaPublicKey, signer, writable bool) {
+		f, ok := flags[key]
+		if !ok {
+			f = &keyFlags{}
+			flags[key] = f
+			order = append(order, key)
+		}
+		f.signer = f.signer || signer
+		f.writable = f.writable || writable
+	}
+	for _, ix := range instructions {
+		for _, account := range ix.Accounts {
+			addKey(account.PublicKey, account.IsSigner, account.IsWritable)
+		}
+		addKey(ix.ProgramID, false, false)
+	}
+
+	// Accounts are ordered: writable signers, read-only signers, writable non-signers,
+	// read-only non-signers. The fee payer is always first.
+	var keys []SolanaPublicKey
+	for _, group := range []keyFlags{{true, true}, {true, false}, {false, true}, {false, false}} {
+		for _, key := range order {
+			if *flags[key] == group {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var numSigners, numReadonlySigners, numReadonlyUnsigned byte
+	index := make(map[SolanaPublicKey]byte, len(keys))
+	for i, key := range keys {
+		index[key] = byte(i)
+		f := flags[key]
+		switch {
+		case f.signer && !f.writable:
+			numSigners++
+			numReadonlySigners++
+		case f.signer:
+			numSigners++
+		case !f.writable:
+			numReadonlyUnsigned++
+		}
+	}
+	if numSigners != 1 {
+		return nil, "", fmt.Errorf("only single-signer transactions are supported, got %d signers", numSigners)
+	}
+
+	message := []byte{numSigners, numReadonlySigners, numReadonlyUnsigned}
+	message = appendCompactU16(message, len(keys))
+	for _, key := range keys {
+		message = append(message, key[:]...)
+	}
+	message = append(message, recentBlockhash[:]...)
+	message = appendCompactU16(message, len(instructions))
+	for _, ix := range instructions {
+		message = append(message, index[ix.ProgramID])
+		message = appendCompactU16(message, len(ix.Accounts))
+		for _, account := range ix.Accounts {
+			message = append(message, index[account.PublicKey])
+		}
+		message = appendCompactU16(message, len(ix.Data))
+		message = append(message, ix.Data...)
+	}
+
+	signature := ed25519.Sign(signer, message)
+	tx := appendCompactU16(nil, 1)
+	tx = append(tx, signature...)
+	tx = append(tx, message...)
+
+	return tx, base58Encode(signature), nil
+}
+
+// appendCompactU16 appends Solana's "shortvec" length encoding.
+func appendCompactU16(b []byte, n int) []byte {
+	for {
+		elem := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, elem)
+		}
+		b = append(b, elem|0x80)
+	}
+}
+
+// SolanaClient represents a Solana blockchain client
+type SolanaClient struct {
+	rpcURL       string
+	httpClient   *http.Client
+	pollInterval time.Duration
+}
+
+// NewSolanaClient creates a new Solana client
+func NewSolanaClient(rpcURL string) *SolanaClient {
+	return &SolanaClient{
+		rpcURL:       rpcURL,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// GetBalance returns the balance of a Solana account in lamports
+func (c *SolanaClient) GetBalance(ctx context.Context, account string) (uint64, error) {
+	var result struct {
+		Value uint64 `json:"value"`
+	}
+	err := callJSONRPC(ctx, c.httpClient, c.rpcURL, "getBalance", []interface{}{account, map[string]string{"commitment": "finalized"}}, &result)
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+// GetLatestBlockhash returns a recent blockhash to build transactions against and the
+// last block height at which a transaction using it can be included.
+func (c *SolanaClient) GetLatestBlockhash(ctx context.Context) (SolanaPublicKey, uint64, error) {
+	var result struct {
+		Value struct {
+			Blockhash            string `json:"blockhash"`
+			LastValidBlockHeight uint64 `json:"lastValidBlockHeight"`
+		} `json:"value"`
+	}
+	err := callJSONRPC(ctx, c.httpClient, c.rpcURL, "getLatestBlockhash", []interface{}{map[string]string{"commitment": "finalized"}}, &result)
+	if err != nil {
+		return SolanaPublicKey{}, 0, err
+	}
+	blockhash, err := SolanaPublicKeyFromBase58(result.Value.Blockhash)
+	if err != nil {
+		return SolanaPublicKey{}, 0, err
+	}
+	return blockhash, result.Value.LastValidBlockHeight, nil
+}
+
+// GetBlockHeight returns the height of the latest finalized block.
+func (c *SolanaClient) GetBlockHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+	err := callJSONRPC(ctx, c.httpClient, c.rpcURL, "getBlockHeight", []interface{}{map[string]string{"commitment": "finalized"}}, &height)
+	if err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// SendTransaction sends a signed wire-format transaction and returns its signature
+func (c *SolanaClient) SendTransaction(ctx context.Context, tx []byte) (string, error) {
+	var signature string
+	params := []interface{}{
+		base64.StdEncoding.EncodeToString(tx),
+		map[string]string{"encoding": "base64", "preflightCommitment": "confirmed"},
+	}
+	if err := callJSONRPC(ctx, c.httpClient, c.rpcURL, "sendTransaction", params, &signature); err != nil {
+		return "", err
+	}
+	return signature, nil
+}
+
+// SolanaSignatureStatus is the status of a submitted transaction.
+type SolanaSignatureStatus struct {
+	Slot               uint64      `json:"slot"`
+	Confirmations      *uint64     `json:"confirmations"`
+	Err                interface{} `json:"err"`
+	ConfirmationStatus string      `json:"confirmationStatus"`
+}
+
+// GetSignatureStatus returns the status of a transaction, or nil if the cluster has not seen it.
+func (c *SolanaClient) GetSignatureStatus(ctx context.Context, signature string) (*SolanaSignatureStatus, error) {
+	var result struct {
+		Value []*SolanaSignatureStatus `json:"value"`
+	}
+	params := []interface{}{[]string{signature}, map[string]bool{"searchTransactionHistory": true}}
+	if err := callJSONRPC(ctx, c.httpClient, c.rpcURL, "getSignatureStatuses", params, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Value) == 0 {
+		return nil, nil
+	}
+	return result.Value[0], nil
+}
+
+// WaitForFinality polls until the transaction is finalized or fails.
+func (c *SolanaClient) WaitForFinality(ctx context.Context, signature string) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.GetSignatureStatus(ctx, signature)
+		if err != nil {
+			return err
+		}
+		if status != nil {
+			if status.Err != nil {
+				return fmt.Errorf("solana transaction %s failed: %v", signature, status.Err)
+			}
+			if status.ConfirmationStatus == "finalized" {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SolanaDisburseRequest describes a disbursement from the Kelo pool program.
+type SolanaDisburseRequest struct {
+	ProgramID SolanaPublicKey
+	Mint      SolanaPublicKey
+	Merchant  SolanaPublicKey
+	Amount    uint64
+}
+
+// NewSolanaDisburseInstructions builds the instructions for a disbursement signed by
+// relayer. The pool holds its tokens in the pool authority's associated token account,
+// and the merchant's associated token account is created first if it does not exist.
+func NewSolanaDisburseInstructions(relayer SolanaPublicKey, req SolanaDisburseRequest) ([]SolanaInstruction, error) {
+	poolAuthority, _, err := FindProgramAddress([][]byte{[]byte("kelo_pool")}, req.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive pool authority: %w", err)
+	}
+	poolState, _, err := FindProgramAddress([][]byte{[]byte("pool_state")}, req.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive pool state: %w", err)
+	}
+	poolToken, err := FindAssociatedTokenAddress(poolAuthority, req.Mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive pool token account: %w", err)
+	}
+	merchantToken, err := FindAssociatedTokenAddress(req.Merchant, req.Mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive merchant token account: %w", err)
+	}
+
+	createATA, err := NewCreateAssociatedTokenAccountIdempotentInstruction(relayer, req.Merchant, req.Mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build create token account instruction: %w", err)
+	}
+	disburse := NewSolanaDisburseInstruction(SolanaDisburseAccounts{
+		Relayer:        relayer,
+		MerchantToken:  merchantToken,
+		PoolToken:      poolToken,
+		PoolAuthority:  poolAuthority,
+		PoolState:      poolState,
+		TokenProgramID: SolanaTokenProgramID,
+		PoolProgramID:  req.ProgramID,
+		Amount:         req.Amount,
+	})
+
+	return []SolanaInstruction{createATA, disburse}, nil
+}
+
+// Disburse builds, signs and submits a disburse instruction against the Kelo pool program.
+func (c *SolanaClient) Disburse(ctx context.Context, req SolanaDisburseRequest, signer ed25519.PrivateKey) (string, error) {
+	instructions, err := NewSolanaDisburseInstructions(SolanaPublicKeyFromPrivateKey(signer), req)
+	if err != nil {
+		return "", err
+	}
+
+	blockhash, _, err := c.GetLatestBlockhash(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get latest blockhash: %w", err)
+	}
+
+	tx, _, err := BuildSolanaTransaction(signer, instructions, blockhash)
+	if err != nil {
+		return "", fmt.Errorf("failed to build disburse transaction: %w", err)
+	}
+
+	return c.SendTransaction(ctx, tx)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() [256]int {
+	var index [256]int
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		index[base58Alphabet[i]] = i
+	}
+	return index
+}()
+
+// base58Encode encodes bytes with the Bitcoin base58 alphabet used by Solana.
+func base58Encode(b []byte) string {
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// Repeated division of the big-endian number by 58, most significant digit last.
+	digits := make([]byte, 0, len(b)*138/100+1)
+	for _, v := range b[zeros:] {
+		carry := int(v)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = '1'
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out)
+}
+
+// base58Decode decodes a base58 string.
+func base58Decode(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	bytesLE := make([]byte, 0, len(s))
+	for i := zeros; i < len(s); i++ {
+		carry := base58Index[s[i]]
+		if carry < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", s[i])
+		}
+		for j := range bytesLE {
+			carry += int(bytesLE[j]) * 58
+			bytesLE[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			bytesLE = append(bytesLE, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	out := make([]byte, zeros+len(bytesLE))
+	for i, b := range bytesLE {
+		out[len(out)-1-i] = b
+	}
+	return out, nil
+}
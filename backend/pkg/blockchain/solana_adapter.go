@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+
+	"kelo-backend/pkg/config"
+)
+
+// solanaLamportsPerSignature is the base fee Solana charges per transaction signature.
+const solanaLamportsPerSignature = 5000
+
+// solanaRootedConfirmations is the confirmation depth after which a slot is rooted.
+const solanaRootedConfirmations = 32
+
+// solanaUnsignedTx is a Solana transaction awaiting its fee payer's signature.
+type solanaUnsignedTx struct {
+	instructions         []SolanaInstruction
+	blockhash            SolanaPublicKey
+	lastValidBlockHeight uint64
+}
+
+// SolanaAdapter is the ChainAdapter for Solana.
+type SolanaAdapter struct {
+	spec   config.ChainSpec
+	client *SolanaClient
+}
+
+// NewSolanaAdapter creates a Solana adapter for the chain spec
+func NewSolanaAdapter(spec config.ChainSpec) *SolanaAdapter {
+	return &SolanaAdapter{spec: spec, client: NewSolanaClient(spec.RPCURL)}
+}
+
+// ChainID returns the registry key of the chain
+func (a *SolanaAdapter) ChainID() string {
+	return a.spec.Key
+}
+
+// Spec returns the chain configuration
+func (a *SolanaAdapter) Spec() config.ChainSpec {
+	return a.spec
+}
+
+// Client returns the underlying Solana RPC client.
+func (a *SolanaAdapter) Client() *SolanaClient {
+	return a.client
+}
+
+// Balance returns the balance of an account in lamports
+func (a *SolanaAdapter) Balance(ctx context.Context, account string) (*big.Int, error) {
+	balance, err := a.client.GetBalance(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(balance), nil
+}
+
+// Nonce always returns zero; Solana transactions are ordered by recent blockhash
+// rather than account nonces.
+func (a *SolanaAdapter) Nonce(ctx context.Context, account string) (uint64, error) {
+	return 0, nil
+}
+
+// EstimateFee returns the base fee of a single-signature transaction in lamports.
+func (a *SolanaAdapter) EstimateFee(ctx context.Context) (*FeeEstimate, error) {
+	return &FeeEstimate{GasPrice: big.NewInt(solanaLamportsPerSignature)}, nil
+}
+
+// BuildTransaction builds an unsigned transaction from req.Call, which must be a
+// []SolanaInstruction.
+func (a *SolanaAdapter) BuildTransaction(ctx context.Context, req *TxRequest) (*Transaction, error) {
+	instructions, ok := req.Call.([]SolanaInstruction)
+	if !ok || len(instructions) == 0 {
+		return nil, fmt.Errorf("solana transactions require instructions")
+	}
+
+	blockhash, lastValidBlockHeight, err := a.client.GetLatestBlockhash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest blockhash: %w", err)
+	}
+
+	return &Transaction{
+		ChainID: a.spec.Key,
+		Native:  &solanaUnsignedTx{instructions: instructions, blockhash: blockhash, lastValidBlockHeight: lastValidBlockHeight},
+		Expiry:  lastValidBlockHeight,
+	}, nil
+}
+
+// SignTransaction signs the transaction with the fee payer's ed25519 key.
+func (a *SolanaAdapter) SignTransaction(ctx context.Context, tx *Transaction, key crypto.PrivateKey) (*Transaction, error) {
+	unsigned, ok := tx.Native.(*solanaUnsignedTx)
+	if !ok {
+		return nil, fmt.Errorf("not an unsigned Solana transaction")
+	}
+	signer, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("solana transactions require an ed25519 key")
+	}
+
+	raw, signature, err := BuildSolanaTransaction(signer, unsigned.instructions, unsigned.blockhash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{ChainID: a.spec.Key, Hash: signature, Raw: raw, Native: unsigned, Expiry: unsigned.lastValidBlockHeight}, nil
+}
+
+// SendTransaction submits a signed transaction and returns its signature
+func (a *SolanaAdapter) SendTransaction(ctx context.Context, tx *Transaction) (string, error) {
+	if len(tx.Raw) == 0 {
+		return "", fmt.Errorf("solana transaction is not signed")
+	}
+	return a.client.SendTransaction(ctx, tx.Raw)
+}
+
+// TransactionReceipt returns the status of a transaction. It is final once finalized.
+func (a *SolanaAdapter) TransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	status, err := a.client.GetSignatureStatus(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, ErrTxNotFound
+	}
+
+	receipt := &Receipt{
+		TxHash:      hash,
+		BlockNumber: status.Slot,
+		Success:     status.Err == nil,
+		Final:       status.ConfirmationStatus == "finalized",
+	}
+	if status.Confirmations != nil {
+		receipt.Confirmations = *status.Confirmations
+	} else {
+		receipt.Confirmations = solanaRootedConfirmations // null means the slot is rooted
+	}
+	return receipt, nil
+}
+
+// TransactionExpired reports whether the finalized chain is past a transaction's last
+// valid block height, after which no fork can include it.
+func (a *SolanaAdapter) TransactionExpired(ctx context.Context, expiry uint64) (bool, error) {
+	height, err := a.client.GetBlockHeight(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get block height: %w", err)
+	}
+	return height > expiry, nil
+}
+
+// SubscribeLogs is not supported over the Solana HTTP RPC.
+func (a *SolanaAdapter) SubscribeLogs(ctx context.Context, filter LogFilter, sink chan<- ChainLog) (Subscription, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase58RoundTrip(t *testing.T) {
+	assert.Equal(t, "11111111111111111111111111111111", SolanaSystemProgramID.String())
+	assert.Equal(t, SolanaPublicKey{}, SolanaSystemProgramID)
+
+	for _, input := range [][]byte{{}, {0}, {0, 0, 1}, []byte("hello world"), SolanaTokenProgramID[:]} {
+		decoded, err := base58Decode(base58Encode(input))
+		require.NoError(t, err)
+		assert.Equal(t, input, decoded)
+	}
+	assert.Equal(t, "StV1DL6CwTryKyV", base58Encode([]byte("hello world")))
+
+	_, err := base58Decode("0OIl")
+	assert.Error(t, err)
+}
+
+func TestFindProgramAddress(t *testing.T) {
+	// Real ed25519 public keys are on the curve, derived addresses never are.
+	for i := 0; i < 20; i++ {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		var key SolanaPublicKey
+		copy(key[:], pub)
+		assert.True(t, isOnEd25519Curve(key))
+	}
+
+	programID := MustSolanaPublicKey("KeLo111111111111111111111111111111111111111")
+	address, bump, err := FindProgramAddress([][]byte{[]byte("kelo_pool")}, programID)
+	require.NoError(t, err)
+	assert.False(t, isOnEd25519Curve(address))
+
+	again, err := createProgramAddress([][]byte{[]byte("kelo_pool"), {bump}}, programID)
+	require.NoError(t, err)
+	assert.Equal(t, address, again)
+}
+
+// fakeSolanaRPC serves just enough of the Solana JSON-RPC API for the disburse flow.
+type fakeSolanaRPC struct {
+	t         *testing.T
+	blockhash SolanaPublicKey
+	sent      [][]byte
+	polls     int
+}
+
+func (f *fakeSolanaRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+
+	var result interface{}
+	switch req.Method {
+	case "getLatestBlockhash":
+		result = map[string]interface{}{"value": map[string]interface{}{"blockhash": f.blockhash.String(), "lastValidBlockHeight": 100}}
+	case "sendTransaction":
+		var encoded string
+		require.NoError(f.t, json.Unmarshal(req.Params[0], &encoded))
+		tx, err := base64.StdEncoding.DecodeString(encoded)
+		require.NoError(f.t, err)
+		f.sent = append(f.sent, tx)
+		result = base58Encode(tx[1:65])
+	case "getSignatureStatuses":
+		f.polls++
+		status := "confirmed"
+		if f.polls > 1 {
+			status = "finalized"
+		}
+		result = map[string]interface{}{"value": []interface{}{map[string]interface{}{"slot": 42, "err": nil, "confirmationStatus": status}}}
+	case "getBalance":
+		result = map[string]interface{}{"value": 5000}
+	case "getBlockHeight":
+		result = 150
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": result})
+}
+
+func TestSolanaClient_Disburse(t *testing.T) {
+	fake := &fakeSolanaRPC{t: t, blockhash: MustSolanaPublicKey("EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N")}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewSolanaClient(server.URL)
+	client.pollInterval = time.Millisecond
+
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	merchantPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var merchant SolanaPublicKey
+	copy(merchant[:], merchantPub)
+
+	req := SolanaDisburseRequest{
+		ProgramID: MustSolanaPublicKey("KeLo111111111111111111111111111111111111111"),
+		Mint:      MustSolanaPublicKey("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"),
+		Merchant:  merchant,
+		Amount:    1_500_000,
+	}
+
+	signature, err := client.Disburse(context.Background(), req, signer)
+	require.NoError(t, err)
+	require.Len(t, fake.sent, 1)
+	require.NoError(t, client.WaitForFinality(context.Background(), signature))
+
+	// One signature, then a message signed by the relayer.
+	tx := fake.sent[0]
+	assert.Equal(t, byte(1), tx[0])
+	message := tx[65:]
+	assert.True(t, ed25519.Verify(signer.Public().(ed25519.PublicKey), message, tx[1:65]))
+	assert.Equal(t, base58Encode(tx[1:65]), signature)
+
+	// Header: one writable signer, no read-only signers.
+	assert.Equal(t, byte(1), message[0])
+	assert.Equal(t, byte(0), message[1])
+	numKeys := int(message[3])
+	relayer := SolanaPublicKeyFromPrivateKey(signer)
+	assert.Equal(t, relayer[:], message[4:36])
+	blockhashAt := 4 + 32*numKeys
+	assert.Equal(t, fake.blockhash[:], message[blockhashAt:blockhashAt+32])
+
+	// The disburse instruction data is the last 9 bytes: variant 3 then the u64 amount.
+	data := message[len(message)-9:]
+	assert.Equal(t, byte(keloDisburseInstruction), data[0])
+	assert.Equal(t, uint64(1_500_000), binary.LittleEndian.Uint64(data[1:]))
+
+	balance, err := client.GetBalance(context.Background(), relayer.String())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5000), balance)
+}
+
+func TestSolanaAdapter_TransactionExpiry(t *testing.T) {
+	fake := &fakeSolanaRPC{t: t, blockhash: MustSolanaPublicKey("EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N")}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	adapter := NewSolanaAdapter(config.ChainSpec{Key: "solana", Type: config.ChainTypeSolana, RPCURL: server.URL})
+
+	program := MustSolanaPublicKey("KeLo111111111111111111111111111111111111111")
+	tx, err := adapter.BuildTransaction(context.Background(), &TxRequest{Call: []SolanaInstruction{{ProgramID: program}}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), tx.Expiry)
+
+	// The finalized chain is at height 150
+	expired, err := adapter.TransactionExpired(context.Background(), tx.Expiry)
+	require.NoError(t, err)
+	assert.True(t, expired)
+	expired, err = adapter.TransactionExpired(context.Background(), 150)
+	require.NoError(t, err)
+	assert.False(t, expired)
+}
+
+func TestSolanaClient_WaitForFinalityFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": map[string]interface{}{
+			"value": []interface{}{map[string]interface{}{"slot": 1, "err": map[string]interface{}{"InstructionError": []interface{}{1, "InvalidAccountData"}}, "confirmationStatus": "confirmed"}},
+		}})
+	}))
+	defer server.Close()
+
+	client := NewSolanaClient(server.URL)
+	client.pollInterval = time.Millisecond
+	assert.Error(t, client.WaitForFinality(context.Background(), "sig"))
+}
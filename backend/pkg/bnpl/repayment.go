@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/logger"
+	"kelo-backend/pkg/metrics"
 	"kelo-backend/pkg/models"
 	"time"
 
-	"github.com/rs/zerolog/log"
 	"github.com/supabase-community/supabase-go"
 )
 
@@ -28,7 +29,7 @@ func NewRepaymentService(db *supabase.Client, bcClients *blockchain.Clients) *Re
 
 // ProcessRepayment handles a user's loan repayment.
 func (s *RepaymentService) ProcessRepayment(ctx context.Context, userID, loanID string, amount float64) error {
-	log.Info().
+	logger.FromContext(ctx).Info().
 		Str("userId", userID).
 		Str("loanId", loanID).
 		Float64("amount", amount).
@@ -75,6 +76,7 @@ func (s *RepaymentService) ProcessRepayment(ctx context.Context, userID, loanID
 	if err != nil {
 		return fmt.Errorf("failed to update loan status: %w", err)
 	}
+	metrics.RepaymentRecorded(amount, loanStatus)
 
 	// 4. Update the on-chain representation (Hedera NFT)
 	hederaClient := s.bcClients.GetHederaClient()
@@ -90,7 +92,7 @@ func (s *RepaymentService) ProcessRepayment(ctx context.Context, userID, loanID
 			"status":             loanStatus,
 		})
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to create new NFT metadata")
+			logger.FromContext(ctx).Error().Err(err).Msg("Failed to create new NFT metadata")
 			// We continue even if metadata fails, as the DB update is the source of truth
 		} else {
 			// A serial number of 1 is assumed for this single-mint token.
@@ -98,11 +100,47 @@ func (s *RepaymentService) ProcessRepayment(ctx context.Context, userID, loanID
 			if err != nil {
 				// Log the error but don't fail the transaction.
 				// On-chain updates can be retried or reconciled later.
-				log.Error().Err(err).Msg("Failed to update on-chain loan NFT status")
+				logger.FromContext(ctx).Error().Err(err).Msg("Failed to update on-chain loan NFT status")
 			}
 		}
+
+		// 5. Tell the relayer, which confirms the repayment to the pool that funded the loan
+		if err := s.publishLoanRepaid(ctx, hederaClient, &loan, amount); err != nil {
+			logger.FromContext(ctx).Error().Err(err).Msg("Failed to publish loan repayment")
+		}
 	}
 
-	log.Info().Str("loanId", loanID).Msg("Successfully processed repayment")
+	logger.FromContext(ctx).Info().Str("loanId", loanID).Msg("Successfully processed repayment")
 	return nil
 }
+
+// publishLoanRepaid publishes the repayment on the loan update topic with the loan's
+// total repaid so far and the borrower's wallet as the payer.
+func (s *RepaymentService) publishLoanRepaid(ctx context.Context, hederaClient *blockchain.HederaClient, loan *models.Loan, amount float64) error {
+	var repayments []models.Repayment
+	data, _, err := s.db.From("repayments").Select("amount", "", false).Eq("loan_id", loan.ID).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve repayments: %w", err)
+	}
+	if err := json.Unmarshal(data, &repayments); err != nil {
+		return fmt.Errorf("failed to unmarshal repayments: %w", err)
+	}
+	var totalRepaid float64
+	for _, repayment := range repayments {
+		totalRepaid += repayment.Amount
+	}
+
+	var profile models.Profile
+	data, _, err = s.db.From("profiles").Select("wallet_address", "", false).Eq("id", loan.UserID).Single().Execute()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve borrower profile: %w", err)
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return fmt.Errorf("failed to unmarshal borrower profile: %w", err)
+	}
+	if profile.WalletAddress == "" {
+		return fmt.Errorf("borrower %s has no wallet address", loan.UserID)
+	}
+
+	return hederaClient.PublishLoanRepaid(ctx, loan.OnchainID, amount, totalRepaid, profile.WalletAddress)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"time"
 
+	"kelo-backend/pkg/logger"
+	"kelo-backend/pkg/metrics"
+
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 )
 
 // Service handles business logic for BNPL
@@ -27,12 +29,14 @@ type Loan struct {
 
 // ApplyForLoan simulates a loan application
 func (s *Service) ApplyForLoan(ctx context.Context, amount float64) (*Loan, error) {
-	log.Info().Float64("amount", amount).Msg("Simulating loan application")
+	logger.FromContext(ctx).Info().Float64("amount", amount).Msg("Simulating loan application")
 
 	// In a real implementation, you would save the loan to the database
-	return &Loan{
+	loan := &Loan{
 		ID:        uuid.New().String(),
 		Amount:    amount,
 		CreatedAt: time.Now(),
-	}, nil
+	}
+	metrics.LoanOriginated(amount)
+	return loan, nil
 }
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Chain types supported by the blockchain adapters.
+const (
+	ChainTypeEVM    = "evm"
+	ChainTypeSolana = "solana"
+	ChainTypeAptos  = "aptos"
+)
+
+// ChainSpec describes a liquidity chain. Adding a chain only requires a new entry
+// in the chains config file; the blockchain registry builds an adapter per spec.
+type ChainSpec struct {
+	// Key identifies the chain throughout the backend, e.g. "ethereum".
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// Type selects the adapter implementation: evm, solana or aptos.
+	Type   string `json:"type"`
+	RPCURL string `json:"rpc_url"`
+	// EVMChainID is the EIP-155 chain ID; only used by EVM chains.
+	EVMChainID uint64 `json:"evm_chain_id,omitempty"`
+	// ContractAddress is the liquidity pool contract, Solana program ID or Aptos module address.
+	ContractAddress string `json:"contract_address"`
+	// TokenAddress is the loan asset's ERC-20 address; required for EVM disbursements.
+	TokenAddress string `json:"token_address,omitempty"`
+	// LayerZeroEID is the 32-bit LayerZero V2 endpoint ID, e.g. 30101 for Ethereum.
+	LayerZeroEID uint32 `json:"layerzero_eid,omitempty"`
+	// LayerZeroReceiver is the contract that receives LayerZero messages on this chain.
+	LayerZeroReceiver string `json:"layerzero_receiver,omitempty"`
+	Confirmations     uint64 `json:"confirmations"`
+	GasLimit          uint64 `json:"gas_limit,omitempty"`
+	GasPriceWei       uint64 `json:"gas_price_wei,omitempty"`
+	// NativePriceUSD is the USD price of the chain's native coin. Allocation compares gas
+	// costs across chains in USD; chains without a price are only compared with chains
+	// of the same type.
+	NativePriceUSD float64 `json:"native_price_usd,omitempty"`
+	Enabled        bool    `json:"enabled"`
+	// Limits caps what the relayer submits for the chain.
+	Limits ChainLimits `json:"limits,omitempty"`
+}
+
+// ChainLimits caps the relayer's submissions for a chain so that a bug or a flood of
+// approvals cannot drain its pools or burn its gas. A zero limit is not enforced.
+type ChainLimits struct {
+	// MaxMessagesPerMinute caps the messages sent to the chain.
+	MaxMessagesPerMinute int `json:"max_messages_per_minute,omitempty"`
+	// MaxDisbursedPerHour and MaxDisbursedPerDay cap the loan asset disbursed from the
+	// chain's pools, in whole tokens.
+	MaxDisbursedPerHour float64 `json:"max_disbursed_per_hour,omitempty"`
+	MaxDisbursedPerDay  float64 `json:"max_disbursed_per_day,omitempty"`
+	// MaxGasSpendPerDay caps the fees paid by relayer transactions on the chain, in its
+	// native coin, e.g. ETH.
+	MaxGasSpendPerDay float64 `json:"max_gas_spend_per_day,omitempty"`
+}
+
+// WithDefaults returns the limits with each unset limit taken from defaults
+func (l ChainLimits) WithDefaults(defaults ChainLimits) ChainLimits {
+	if l.MaxMessagesPerMinute == 0 {
+		l.MaxMessagesPerMinute = defaults.MaxMessagesPerMinute
+	}
+	if l.MaxDisbursedPerHour == 0 {
+		l.MaxDisbursedPerHour = defaults.MaxDisbursedPerHour
+	}
+	if l.MaxDisbursedPerDay == 0 {
+		l.MaxDisbursedPerDay = defaults.MaxDisbursedPerDay
+	}
+	if l.MaxGasSpendPerDay == 0 {
+		l.MaxGasSpendPerDay = defaults.MaxGasSpendPerDay
+	}
+	return l
+}
+
+// LoadChains reads chain specs from a JSON file containing an array of ChainSpec.
+func LoadChains(path string) ([]ChainSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chains config: %w", err)
+	}
+
+	var chains []ChainSpec
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, fmt.Errorf("failed to parse chains config: %w", err)
+	}
+	if err := normalizeChains(chains); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// normalizeChains validates chain specs and fills in their defaults.
+func normalizeChains(chains []ChainSpec) error {
+	seen := make(map[string]bool, len(chains))
+	for i := range chains {
+		chain := &chains[i]
+		chain.Key = strings.ToLower(strings.TrimSpace(chain.Key))
+		if chain.Key == "" {
+			return fmt.Errorf("chain %d has no key", i)
+		}
+		if seen[chain.Key] {
+			return fmt.Errorf("duplicate chain %s", chain.Key)
+		}
+		seen[chain.Key] = true
+
+		switch chain.Type {
+		case ChainTypeEVM:
+			if chain.EVMChainID == 0 {
+				return fmt.Errorf("chain %s: evm_chain_id is required", chain.Key)
+			}
+		case ChainTypeSolana, ChainTypeAptos:
+		default:
+			return fmt.Errorf("chain %s: unsupported type %q", chain.Key, chain.Type)
+		}
+		if chain.Name == "" {
+			chain.Name = chain.Key
+		}
+		if chain.Confirmations == 0 {
+			chain.Confirmations = 1
+		}
+		limits := chain.Limits
+		if limits.MaxMessagesPerMinute < 0 || limits.MaxDisbursedPerHour < 0 || limits.MaxDisbursedPerDay < 0 || limits.MaxGasSpendPerDay < 0 {
+			return fmt.Errorf("chain %s: limits must not be negative", chain.Key)
+		}
+	}
+
+	return nil
+}
+
+// defaultChains builds the chain list from the per-chain environment variables,
+// used when no chains config file is set.
+func defaultChains(cfg *Config) []ChainSpec {
+	evm := func(key, name, rpc, pool string, chainID uint64, eid uint32, confirmations, gasPriceWei uint64) ChainSpec {
+		return ChainSpec{
+			Key:             key,
+			Name:            name,
+			Type:            ChainTypeEVM,
+			RPCURL:          rpc,
+			EVMChainID:      chainID,
+			ContractAddress: pool,
+			LayerZeroEID:    eid,
+			Confirmations:   confirmations,
+			GasLimit:        500000,
+			GasPriceWei:     gasPriceWei,
+			Enabled:         rpc != "",
+		}
+	}
+
+	return []ChainSpec{
+		evm("ethereum", "Ethereum", cfg.EthereumRPC, cfg.EthereumLiquidityPool, 1, 30101, 12, 20000000000),
+		evm("base", "Base", cfg.BaseRPC, cfg.BaseLiquidityPool, 8453, 30184, 5, 1000000000),
+		evm("arbitrum", "Arbitrum", cfg.ArbitrumRPC, cfg.ArbitrumLiquidityPool, 42161, 30110, 10, 100000000),
+		evm("avalanche", "Avalanche", cfg.AvalancheRPC, cfg.AvalancheLiquidityPool, 43114, 30106, 6, 25000000000),
+		evm("celo", "Celo", cfg.CeloRPC, cfg.CeloLiquidityPool, 42220, 30125, 6, 5000000000),
+		evm("polygon", "Polygon", cfg.PolygonRPC, cfg.PolygonLiquidityPool, 137, 30109, 64, 50000000000),
+		evm("kava", "Kava", cfg.KavaRPC, cfg.KavaLiquidityPool, 2222, 30177, 6, 1000000000),
+		{
+			Key:             "solana",
+			Name:            "Solana",
+			Type:            ChainTypeSolana,
+			RPCURL:          cfg.SolanaRPC,
+			ContractAddress: cfg.SolanaProgramID,
+			Confirmations:   1,
+			Enabled:         cfg.SolanaRPC != "" && cfg.SolanaProgramID != "",
+		},
+		{
+			Key:             "aptos",
+			Name:            "Aptos",
+			Type:            ChainTypeAptos,
+			RPCURL:          cfg.AptosRPC,
+			ContractAddress: cfg.AptosModuleAddress,
+			Confirmations:   1,
+			Enabled:         cfg.AptosRPC != "" && cfg.AptosModuleAddress != "",
+		},
+	}
+}
@@ -12,6 +12,12 @@ import (
 
 type Config struct {
         Port                   int
+        // MetricsPort serves the API server's /metrics, apart from the public API
+        MetricsPort            int
+        // TracingExporter is where spans go: none, otlp or stdout (see pkg/tracing)
+        TracingExporter        string
+        // TracingSampleRatio is the share of new traces that are recorded
+        TracingSampleRatio     float64
         Environment            string
         LogLevel               string
         SupabaseURL            string
@@ -20,8 +26,10 @@ type Config struct {
         HederaNetwork          string
         HederaContractAddress  string
         HederaLoanUpdateTopicID string
+        HederaMirrorNodeURL    string
         LayerZeroEndpoint      string
         LayerZeroAPIKey        string
+        LayerZeroSourceChain   string
         EthereumRPC            string
         EthereumLiquidityPool  string
         BaseRPC                string
@@ -37,15 +45,62 @@ type Config struct {
         KavaRPC                string
         KavaLiquidityPool      string
         SolanaRPC              string
+        SolanaProgramID        string
+        SolanaTokenMint        string
+        SolanaTokenDecimals    int
+        SolanaRelayerKey       string
         AptosRPC               string
+        AptosModuleAddress     string
+        AptosCoinType          string
+        AptosCoinDecimals      int
+        AptosRelayerKey        string
         MpesaAPIKey            string
         MpesaSecret            string
         RedisURL               string
         RelayerPrivateKey      string
+        RelayerSigner          string
+        RelayerKeystorePath    string
+        RelayerKeystorePasswordFile string
+        RelayerRemoteSignerURL string
+        RelayerRemoteSignerAPI string
+        RelayerAddress         string
+        RelayerSignerSetVersion int
+        // RelayerAdminPort serves the relayer's admin control plane in cmd/relayer
+        RelayerAdminPort       int
+        // RelayerMetricsPort serves /healthz and /metrics in cmd/relayer
+        RelayerMetricsPort     int
+        // RelayerMetricsHost is the interface /healthz and /metrics listen on. It is
+        // localhost by default, because /healthz has no auth and returns the full status.
+        RelayerMetricsHost     string
+        // RelayerConfigPath is the relayer config file (see relayer.example.json)
+        RelayerConfigPath      string
+        // RelayerAlertsPath is the relayer's alerting file (see alerts.example.json)
+        RelayerAlertsPath      string
+        // RunRelayer runs the relayer inside the API process instead of cmd/relayer
+        RunRelayer             bool
         MaxRetries             int
+        ECLTablesPath          string
+        LoanAsset              string
+        LoanAssetDecimals      int
+        ChainsConfigPath       string
+        Chains                 []ChainSpec
+        // ChainLimits applies to every chain that does not set its own limits
+        ChainLimits            ChainLimits
 }
 
+// Load loads the configuration from the environment and, if RELAYER_CONFIG_PATH is
+// set, the relayer config file.
 func Load() (*Config, error) {
+        return load("")
+}
+
+// LoadRelayer loads the configuration like Load, with the relayer config file at path
+// in place of RELAYER_CONFIG_PATH.
+func LoadRelayer(path string) (*Config, error) {
+        return load(path)
+}
+
+func load(relayerConfigPath string) (*Config, error) {
         // Load .env file from the current directory
         err := godotenv.Load()
         if err != nil {
@@ -69,6 +124,9 @@ func Load() (*Config, error) {
         // Read configuration
         cfg := &Config{
                 Port:                   getEnvAsInt("PORT", 8080),
+                MetricsPort:            getEnvAsInt("METRICS_PORT", 9091),
+                TracingExporter:        getEnv("TRACING_EXPORTER", "none"),
+                TracingSampleRatio:     getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
                 Environment:            getEnv("ENVIRONMENT", "development"),
                 LogLevel:               getEnv("LOG_LEVEL", "info"),
                 SupabaseURL:            getEnv("SUPABASE_URL", ""),
@@ -77,8 +135,10 @@ func Load() (*Config, error) {
                 HederaNetwork:          getEnv("HEDERA_NETWORK", "testnet"),
                 HederaContractAddress:  getEnv("HEDERA_CONTRACT_ADDRESS", ""),
                 HederaLoanUpdateTopicID: getEnv("HEDERA_LOAN_UPDATE_TOPIC_ID", ""),
+                HederaMirrorNodeURL:    getEnv("HEDERA_MIRROR_NODE_URL", ""),
                 LayerZeroEndpoint:      getEnv("LAYERZERO_ENDPOINT", ""),
                 LayerZeroAPIKey:        getEnv("LAYERZERO_API_KEY", ""),
+                LayerZeroSourceChain:   getEnv("LAYERZERO_SOURCE_CHAIN", "ethereum"),
                 EthereumRPC:            getEnv("ETHEREUM_RPC", ""),
                 EthereumLiquidityPool:  getEnv("ETHEREUM_LIQUIDITY_POOL", ""),
                 BaseRPC:                getEnv("BASE_RPC", ""),
@@ -94,12 +154,76 @@ func Load() (*Config, error) {
                 KavaRPC:                getEnv("KAVA_RPC", ""),
                 KavaLiquidityPool:      getEnv("KAVA_LIQUIDITY_POOL", ""),
                 SolanaRPC:              getEnv("SOLANA_RPC", ""),
+                SolanaProgramID:        getEnv("SOLANA_PROGRAM_ID", ""),
+                SolanaTokenMint:        getEnv("SOLANA_TOKEN_MINT", ""),
+                SolanaTokenDecimals:    getEnvAsInt("SOLANA_TOKEN_DECIMALS", 6),
+                SolanaRelayerKey:       getEnv("SOLANA_RELAYER_KEY", ""),
                 AptosRPC:               getEnv("APTOS_RPC", ""),
+                AptosModuleAddress:     getEnv("APTOS_MODULE_ADDRESS", ""),
+                AptosCoinType:          getEnv("APTOS_COIN_TYPE", "0xf22bede237a07e121b56d91a491eb7bcdfd1f5907926a9e58338f964a01b17fa::asset::USDC"),
+                AptosCoinDecimals:      getEnvAsInt("APTOS_COIN_DECIMALS", 6),
+                AptosRelayerKey:        getEnv("APTOS_RELAYER_KEY", ""),
                 MpesaAPIKey:            getEnv("MPESA_API_KEY", ""),
                 MpesaSecret:            getEnv("MPESA_SECRET", ""),
                 RedisURL:               getEnv("REDIS_URL", ""),
                 RelayerPrivateKey:      getEnv("RELAYER_PRIVATE_KEY", ""),
+                RelayerSigner:          getEnv("RELAYER_SIGNER", "local"),
+                RelayerKeystorePath:    getEnv("RELAYER_KEYSTORE_PATH", ""),
+                RelayerKeystorePasswordFile: getEnv("RELAYER_KEYSTORE_PASSWORD_FILE", ""),
+                RelayerRemoteSignerURL: getEnv("RELAYER_REMOTE_SIGNER_URL", ""),
+                RelayerRemoteSignerAPI: getEnv("RELAYER_REMOTE_SIGNER_API", "web3signer"),
+                RelayerAddress:         getEnv("RELAYER_ADDRESS", ""),
+                RelayerSignerSetVersion: getEnvAsInt("RELAYER_SIGNER_SET_VERSION", 1),
+                RelayerAdminPort:       getEnvAsInt("RELAYER_ADMIN_PORT", 8081),
+                RelayerMetricsPort:     getEnvAsInt("RELAYER_METRICS_PORT", 9090),
+                RelayerMetricsHost:     getEnv("RELAYER_METRICS_HOST", "localhost"),
+                RelayerConfigPath:      getEnv("RELAYER_CONFIG_PATH", ""),
+                RelayerAlertsPath:      getEnv("RELAYER_ALERTS_PATH", ""),
+                RunRelayer:             getEnvAsBool("RUN_RELAYER", false),
                 MaxRetries:             getEnvAsInt("MAX_RETRIES", 3),
+                ECLTablesPath:          getEnv("ECL_TABLES_PATH", ""),
+                LoanAsset:              getEnv("LOAN_ASSET", "USDC"),
+                LoanAssetDecimals:      getEnvAsInt("LOAN_ASSET_DECIMALS", 6),
+                ChainsConfigPath:       getEnv("CHAINS_CONFIG_PATH", ""),
+                ChainLimits: ChainLimits{
+                        MaxMessagesPerMinute: getEnvAsInt("RELAYER_MAX_MESSAGES_PER_MINUTE", 0),
+                        MaxDisbursedPerHour:  getEnvAsFloat("RELAYER_MAX_DISBURSED_PER_HOUR", 0),
+                        MaxDisbursedPerDay:   getEnvAsFloat("RELAYER_MAX_DISBURSED_PER_DAY", 0),
+                        MaxGasSpendPerDay:    getEnvAsFloat("RELAYER_MAX_GAS_SPEND_PER_DAY", 0),
+                },
+        }
+
+        // Default to the public mirror node of the Hedera network
+        if cfg.HederaMirrorNodeURL == "" {
+                cfg.HederaMirrorNodeURL = fmt.Sprintf("https://%s.mirrornode.hedera.com", cfg.HederaNetwork)
+        }
+
+        // Load liquidity chains from the chains file, falling back to the per-chain env vars
+        if cfg.ChainsConfigPath != "" {
+                chains, err := LoadChains(cfg.ChainsConfigPath)
+                if err != nil {
+                        return nil, err
+                }
+                cfg.Chains = chains
+        } else {
+                cfg.Chains = defaultChains(cfg)
+        }
+
+        // The relayer config file overrides the relayer settings from the environment
+        if relayerConfigPath != "" {
+                cfg.RelayerConfigPath = relayerConfigPath
+        }
+        if cfg.RelayerConfigPath != "" {
+                file, err := LoadRelayerFile(cfg.RelayerConfigPath)
+                if err != nil {
+                        return nil, err
+                }
+                if err := file.apply(cfg); err != nil {
+                        return nil, err
+                }
+        }
+        for i := range cfg.Chains {
+                cfg.Chains[i].Limits = cfg.Chains[i].Limits.WithDefaults(cfg.ChainLimits)
         }
 
         // Validate required configuration
@@ -132,6 +256,15 @@ func getEnvAsInt(key string, defaultValue int) int {
         return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+        if value, exists := os.LookupEnv(key); exists {
+                if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+                        return floatValue
+                }
+        }
+        return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
         if value, exists := os.LookupEnv(key); exists {
                 if boolValue, err := strconv.ParseBool(value); err == nil {
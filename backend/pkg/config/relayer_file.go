@@ -0,0 +1,99 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RelayerFile is the standalone relayer's config file. Settings it leaves out keep the
+// values from the environment.
+type RelayerFile struct {
+	AdminPort            int    `json:"admin_port,omitempty"`
+	MetricsPort          int    `json:"metrics_port,omitempty"`
+	MetricsHost          string `json:"metrics_host,omitempty"`
+	LayerZeroSourceChain string `json:"layerzero_source_chain,omitempty"`
+	MaxRetries           int    `json:"max_retries,omitempty"`
+	// AlertsPath is the relayer's alerting file.
+	AlertsPath string `json:"alerts_path,omitempty"`
+	// Chains replaces the chains from CHAINS_CONFIG_PATH or the per-chain variables.
+	Chains []ChainSpec `json:"chains,omitempty"`
+	// Confirmations overrides the confirmations of chains by key.
+	Confirmations map[string]uint64 `json:"confirmations,omitempty"`
+	// Limits applies to every chain that does not set its own limits.
+	Limits *ChainLimits `json:"limits,omitempty"`
+}
+
+// LoadRelayerFile reads the relayer config file at path.
+func LoadRelayerFile(path string) (*RelayerFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relayer config: %w", err)
+	}
+
+	var file RelayerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse relayer config: %w", err)
+	}
+	if len(file.Chains) > 0 {
+		if err := normalizeChains(file.Chains); err != nil {
+			return nil, fmt.Errorf("relayer config: %w", err)
+		}
+	}
+	if file.AdminPort < 0 || file.MetricsPort < 0 || file.MaxRetries < 0 {
+		return nil, fmt.Errorf("relayer config: ports and max_retries must not be negative")
+	}
+	if limits := file.Limits; limits != nil {
+		if limits.MaxMessagesPerMinute < 0 || limits.MaxDisbursedPerHour < 0 || limits.MaxDisbursedPerDay < 0 || limits.MaxGasSpendPerDay < 0 {
+			return nil, fmt.Errorf("relayer config: limits must not be negative")
+		}
+	}
+	return &file, nil
+}
+
+// apply overrides the configuration with the settings the file sets.
+func (f *RelayerFile) apply(cfg *Config) error {
+	if f.AdminPort != 0 {
+		cfg.RelayerAdminPort = f.AdminPort
+	}
+	if f.MetricsPort != 0 {
+		cfg.RelayerMetricsPort = f.MetricsPort
+	}
+	if f.MetricsHost != "" {
+		cfg.RelayerMetricsHost = f.MetricsHost
+	}
+	if f.LayerZeroSourceChain != "" {
+		cfg.LayerZeroSourceChain = f.LayerZeroSourceChain
+	}
+	if f.MaxRetries != 0 {
+		cfg.MaxRetries = f.MaxRetries
+	}
+	if f.AlertsPath != "" {
+		cfg.RelayerAlertsPath = f.AlertsPath
+	}
+	if len(f.Chains) > 0 {
+		cfg.Chains = f.Chains
+	}
+	if f.Limits != nil {
+		cfg.ChainLimits = *f.Limits
+	}
+
+	for key, confirmations := range f.Confirmations {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if confirmations == 0 {
+			return fmt.Errorf("relayer config: chain %s needs at least one confirmation", key)
+		}
+		found := false
+		for i := range cfg.Chains {
+			if cfg.Chains[i].Key == key {
+				cfg.Chains[i].Confirmations = confirmations
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("relayer config: confirmations for unknown chain %s", key)
+		}
+	}
+	return nil
+}
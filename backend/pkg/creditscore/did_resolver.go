@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"kelo-backend/pkg/logger"
 )
 
 // DIDDocument represents a DID document structure
@@ -337,7 +337,7 @@ func (d *DIDResolver) verifyOnHedera(ctx context.Context, identifier string) (bo
 	}
 	
 	// Simulate successful verification
-	log.Info().Str("identifier", identifier).Msg("Verifying DID on Hedera")
+	logger.FromContext(ctx).Info().Str("identifier", identifier).Msg("Verifying DID on Hedera")
 	
 	// Return true for simulation
 	return true, nil
@@ -522,7 +522,7 @@ func (d *DIDResolver) createOnHedera(ctx context.Context, document *DIDDocument)
 	}
 	
 	// Simulate creating DID
-	log.Info().Str("did", document.ID).Msg("Creating DID on Hedera")
+	logger.FromContext(ctx).Info().Str("did", document.ID).Msg("Creating DID on Hedera")
 	
 	// Generate a random account ID for simulation
 	accountID := fmt.Sprintf("0.0.%d", time.Now().UnixNano()%10000000)
@@ -544,7 +544,7 @@ func (d *DIDResolver) updateOnHedera(ctx context.Context, did string, document *
 	}
 	
 	// Simulate updating DID
-	log.Info().Str("did", did).Msg("Updating DID on Hedera")
+	logger.FromContext(ctx).Info().Str("did", did).Msg("Updating DID on Hedera")
 	
 	return nil
 }
@@ -10,11 +10,15 @@ import (
 
 	"kelo-backend/pkg/blockchain"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/logger"
+	"kelo-backend/pkg/metrics"
 	"kelo-backend/pkg/models"
+	"kelo-backend/pkg/tracing"
 
-	"github.com/rs/zerolog/log"
 	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CreditScoreEngine handles credit scoring calculations and data integration
@@ -219,10 +223,18 @@ func NewCreditScoreEngine(client *supabase.Client, blockchain *blockchain.Client
 }
 
 // CalculateCreditScore calculates a comprehensive credit score for a user
-func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req CreditScoreRequest) (*CreditScoreResponse, error) {
+func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req CreditScoreRequest) (_ *CreditScoreResponse, err error) {
+	ctx, span := otel.Tracer("kelo-backend/pkg/creditscore").Start(ctx, "CreditScoreEngine.CalculateCreditScore")
+	span.SetAttributes(attribute.String("user.id", req.UserID), attribute.Bool("credit_score.force_recalculate", req.ForceRecalculate))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	// Check if we have a recent valid score
 	if !req.ForceRecalculate {
 		if recentScore, err := e.getRecentValidScore(req.UserID); err == nil {
+			span.SetAttributes(attribute.Bool("credit_score.cached", true))
 			return recentScore, nil
 		}
 	}
@@ -247,7 +259,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	// Calculate on-chain history score
 	onChainScore, err := e.calculateOnChainHistoryScore(ctx, &user)
 	if err != nil {
-		log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate on-chain history score")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate on-chain history score")
 	} else {
 		factors.OnChainHistory = onChainScore
 	}
@@ -255,7 +267,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	// Calculate repayment behavior score
 	repaymentScore, err := e.calculateRepaymentBehaviorScore(ctx, &user)
 	if err != nil {
-		log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate repayment behavior score")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate repayment behavior score")
 	} else {
 		factors.RepaymentBehavior = repaymentScore
 	}
@@ -271,7 +283,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	if req.IncludeMpesa {
 		mpesaScore, err := e.calculateMpesaScore(ctx, &user)
 		if err != nil {
-			log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate M-Pesa score")
+			logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate M-Pesa score")
 		} else {
 			externalDataScore += mpesaScore
 			externalDataCount++
@@ -281,7 +293,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	if req.IncludeBank {
 		bankScore, err := e.calculateBankScore(ctx, &user)
 		if err != nil {
-			log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate bank score")
+			logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate bank score")
 		} else {
 			externalDataScore += bankScore
 			externalDataCount++
@@ -291,7 +303,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	if req.IncludeCRB {
 		crbScore, err := e.calculateCRBScore(ctx, &user)
 		if err != nil {
-			log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate CRB score")
+			logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate CRB score")
 		} else {
 			externalDataScore += crbScore
 			externalDataCount++
@@ -301,7 +313,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	if req.IncludePayslip {
 		payslipScore, err := e.calculatePayslipScore(ctx, &user)
 		if err != nil {
-			log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate payslip score")
+			logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate payslip score")
 		} else {
 			externalDataScore += payslipScore
 			externalDataCount++
@@ -315,7 +327,7 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 	// Calculate DID verification score
 	didScore, err := e.calculateDIDVerificationScore(ctx, &user)
 	if err != nil {
-		log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate DID verification score")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to calculate DID verification score")
 	} else {
 		factors.DIDVerification = didScore
 	}
@@ -352,10 +364,12 @@ func (e *CreditScoreEngine) CalculateCreditScore(ctx context.Context, req Credit
 		UpdateReason:    "Regular credit score calculation",
 		Recommendations: e.generateRecommendations(factors, finalScore),
 	}
+	metrics.CreditScoreComputed(response.Rating)
+	span.SetAttributes(attribute.Int("credit_score.score", finalScore), attribute.String("credit_score.rating", response.Rating))
 
 	// Save score to database
 	if err := e.saveCreditScore(&user, response); err != nil {
-		log.Error().Err(err).Str("userID", req.UserID).Msg("Failed to save credit score")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", req.UserID).Msg("Failed to save credit score")
 	}
 
 	return response, nil
@@ -9,6 +9,8 @@ import (
 	"os"
 	"time"
 
+	"kelo-backend/pkg/logger"
+	"kelo-backend/pkg/metrics"
 	"kelo-backend/pkg/utils"
 
 	"github.com/rs/zerolog/log"
@@ -86,7 +88,9 @@ func NewPayslipClient() *PayslipClient {
 }
 
 // GetStatement retrieves M-Pesa statement for a phone number
-func (m *MpesaClient) GetStatement(ctx context.Context, phoneNumber string) (*MpesaStatement, error) {
+func (m *MpesaClient) GetStatement(ctx context.Context, phoneNumber string) (_ *MpesaStatement, err error) {
+	defer func(start time.Time) { metrics.ObserveExternalCall("mpesa", "get_statement", start, err) }(time.Now())
+
 	if err := m.authenticate(ctx); err != nil {
 		return nil, fmt.Errorf("M-Pesa authentication failed: %w", err)
 	}
@@ -107,7 +111,7 @@ func (m *MpesaClient) GetStatement(ctx context.Context, phoneNumber string) (*Mp
 	// 	"Content-Type":  "application/json",
 	// }
 
-	log.Info().Str("phone_number", phoneNumber).Msg("Simulating M-Pesa API call")
+	logger.FromContext(ctx).Info().Str("phone_number", phoneNumber).Msg("Simulating M-Pesa API call")
 	// In a real implementation, you would make a call to the M-Pesa API
 	// respBody, err := utils.MakeRequest(ctx, m.client, "POST", url, headers, request)
 	// For now, we return mock data
@@ -166,7 +170,7 @@ func (b *BankClient) GetBankStatement(ctx context.Context, bankName, accountNumb
 	// 	"Content-Type":  "application/json",
 	// }
 
-	log.Info().Str("bank_name", bankName).Str("account_number", accountNumber).Msg("Simulating Bank API call")
+	logger.FromContext(ctx).Info().Str("bank_name", bankName).Str("account_number", accountNumber).Msg("Simulating Bank API call")
 	// In a real implementation, you would make a call to the bank API
 	// respBody, err := utils.MakeRequest(ctx, b.client, "POST", url, headers, request)
 	// For now, we return mock data
@@ -184,7 +188,9 @@ func (b *BankClient) GetBankStatement(ctx context.Context, bankName, accountNumb
 }
 
 // GetCRBReport retrieves CRB report for a customer
-func (c *CRBClient) GetCRBReport(ctx context.Context, customerID string) (*CRBReport, error) {
+func (c *CRBClient) GetCRBReport(ctx context.Context, customerID string) (_ *CRBReport, err error) {
+	defer func(start time.Time) { metrics.ObserveExternalCall("crb", "get_report", start, err) }(time.Now())
+
 	if c.apiKey == "" {
 		return nil, fmt.Errorf("CRB API key not configured")
 	}
@@ -195,7 +201,7 @@ func (c *CRBClient) GetCRBReport(ctx context.Context, customerID string) (*CRBRe
 	// 	"Content-Type":  "application/json",
 	// }
 
-	log.Info().Str("customer_id", customerID).Msg("Simulating CRB API call")
+	logger.FromContext(ctx).Info().Str("customer_id", customerID).Msg("Simulating CRB API call")
 	// In a real implementation, you would make a call to the CRB API
 	// respBody, err := utils.MakeRequest(ctx, c.client, "GET", url, headers, nil)
 	// For now, we return mock data
@@ -236,7 +242,7 @@ func (p *PayslipClient) GetPayslip(ctx context.Context, employer, employeeID, pe
 	// 	"Content-Type":  "application/json",
 	// }
 
-	log.Info().Str("employer", employer).Str("employee_id", employeeID).Msg("Simulating Payslip API call")
+	logger.FromContext(ctx).Info().Str("employer", employer).Str("employee_id", employeeID).Msg("Simulating Payslip API call")
 	// In a real implementation, you would make a call to the payslip API
 	// respBody, err := utils.MakeRequest(ctx, p.client, "GET", url, headers, nil)
 	// For now, we return mock data
@@ -254,10 +260,11 @@ func (p *PayslipClient) GetPayslip(ctx context.Context, employer, employeeID, pe
 }
 
 // authenticate authenticates with M-Pesa API
-func (m *MpesaClient) authenticate(ctx context.Context) error {
+func (m *MpesaClient) authenticate(ctx context.Context) (err error) {
 	if m.authToken != "" {
 		return nil
 	}
+	defer func(start time.Time) { metrics.ObserveExternalCall("mpesa", "authenticate", start, err) }(time.Now())
 
 	url := fmt.Sprintf("%s/oauth/v1/generate?grant_type=client_credentials", m.baseURL)
 	auth := base64.StdEncoding.EncodeToString([]byte(m.apiKey + ":" + m.secret))
@@ -354,7 +361,7 @@ func (p *PayslipClient) GetEmploymentHistory(ctx context.Context, employer, empl
 		period := time.Now().AddDate(0, -i, 0).Format("2006-01")
 		payslip, err := p.GetPayslip(ctx, employer, employeeID, period)
 		if err != nil {
-			log.Warn().Err(err).Str("period", period).Msg("Failed to get payslip for period")
+			logger.FromContext(ctx).Warn().Err(err).Str("period", period).Msg("Failed to get payslip for period")
 			continue
 		}
 		payslips = append(payslips, *payslip)
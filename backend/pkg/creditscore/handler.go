@@ -5,11 +5,11 @@ import (
 	"strconv"
 	"time"
 
+	"kelo-backend/pkg/logger"
 	"kelo-backend/pkg/middleware"
 	"kelo-backend/pkg/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rs/zerolog/log"
 )
 
 // CreditScoreHandler handles HTTP requests for credit scoring
@@ -63,7 +63,7 @@ func (h *CreditScoreHandler) GetCreditScore(c *gin.Context) {
 
 	response, err := h.service.GetUserCreditScore(c.Request.Context(), userID)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get credit score")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get credit score")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -81,7 +81,7 @@ func (h *CreditScoreHandler) UpdateCreditScore(c *gin.Context) {
 
 	response, err := h.service.UpdateUserCreditScore(c.Request.Context(), userID)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to update credit score")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to update credit score")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -100,7 +100,7 @@ func (h *CreditScoreHandler) GetCreditScoreReport(c *gin.Context) {
 	includeDetailed := c.Query("detailed") == "true"
 	report, err := h.service.GenerateCreditScoreReport(c.Request.Context(), userID, includeDetailed)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to generate credit score report")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to generate credit score report")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -119,7 +119,7 @@ func (h *CreditScoreHandler) GetCreditScoreHistory(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	history, err := h.service.GetCreditScoreHistory(c.Request.Context(), userID, limit)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get credit score history")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get credit score history")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -147,7 +147,7 @@ func (h *CreditScoreHandler) AddExternalDataSource(c *gin.Context) {
 
 	err := h.service.AddExternalDataSource(c.Request.Context(), userID, request.SourceType, request.Identifier)
 	if err != nil {
-		log.Error().Err(err).
+		logger.FromContext(c.Request.Context()).Error().Err(err).
 			Str("userID", userID).
 			Str("sourceType", request.SourceType).
 			Str("identifier", request.Identifier).
@@ -175,7 +175,7 @@ func (h *CreditScoreHandler) GetUserAnalytics(c *gin.Context) {
 
 	report, err := h.service.GenerateCreditScoreReport(c.Request.Context(), userID, true)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get user analytics")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get user analytics")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -205,7 +205,7 @@ func (h *CreditScoreHandler) GetRiskAssessment(c *gin.Context) {
 
 	report, err := h.service.GenerateCreditScoreReport(c.Request.Context(), userID, true)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get risk assessment")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get risk assessment")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -228,7 +228,7 @@ func (h *CreditScoreHandler) GetLoanEligibility(c *gin.Context) {
 
 	report, err := h.service.GenerateCreditScoreReport(c.Request.Context(), userID, true)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get loan eligibility")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get loan eligibility")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -251,7 +251,7 @@ func (h *CreditScoreHandler) GetDIDAnalysis(c *gin.Context) {
 
 	report, err := h.service.GenerateCreditScoreReport(c.Request.Context(), userID, true)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get DID analysis")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get DID analysis")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -288,7 +288,7 @@ func (h *CreditScoreHandler) VerifyDID(c *gin.Context) {
 
 	isVerified, err := h.service.didResolver.VerifyDID(c.Request.Context(), request.DID)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Str("did", request.DID).Msg("Failed to verify DID")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Str("did", request.DID).Msg("Failed to verify DID")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -311,7 +311,7 @@ func (h *CreditScoreHandler) GetHCSBehavior(c *gin.Context) {
 
 	analytics, err := h.service.hcsAnalyzer.AnalyzeUserBehavior(c.Request.Context(), userID)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get HCS behavior analysis")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get HCS behavior analysis")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -330,7 +330,7 @@ func (h *CreditScoreHandler) GetHCSRepayments(c *gin.Context) {
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "90"))
 	repayments, err := h.service.hcsAnalyzer.GetRepaymentHistory(c.Request.Context(), userID, days)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get HCS repayment history")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get HCS repayment history")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -356,7 +356,7 @@ func (h *CreditScoreHandler) GetHCSLoans(c *gin.Context) {
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "180"))
 	loans, err := h.service.hcsAnalyzer.GetLoanHistory(c.Request.Context(), userID, days)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to get HCS loan history")
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("userID", userID).Msg("Failed to get HCS loan history")
 		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -9,8 +9,7 @@ import (
 	"time"
 
 	"kelo-backend/pkg/blockchain"
-
-	"github.com/rs/zerolog/log"
+	"kelo-backend/pkg/logger"
 )
 
 // HCSMessage represents a message from Hedera Consensus Service
@@ -220,7 +219,7 @@ func (h *HCSAnalyzer) GetRepaymentHistory(ctx context.Context, userID string, da
 	for _, msg := range messages {
 		repayment, err := h.parseRepaymentMessage(msg)
 		if err != nil {
-			log.Warn().Err(err).Str("messageID", fmt.Sprintf("%d", msg.SequenceNumber)).Msg("Failed to parse repayment message")
+			logger.FromContext(ctx).Warn().Err(err).Str("messageID", fmt.Sprintf("%d", msg.SequenceNumber)).Msg("Failed to parse repayment message")
 			continue
 		}
 		if repayment != nil {
@@ -256,7 +255,7 @@ func (h *HCSAnalyzer) GetLoanHistory(ctx context.Context, userID string, days in
 	for _, msg := range messages {
 		loan, err := h.parseLoanMessage(msg)
 		if err != nil {
-			log.Warn().Err(err).Str("messageID", fmt.Sprintf("%d", msg.SequenceNumber)).Msg("Failed to parse loan message")
+			logger.FromContext(ctx).Warn().Err(err).Str("messageID", fmt.Sprintf("%d", msg.SequenceNumber)).Msg("Failed to parse loan message")
 			continue
 		}
 		if loan != nil {
@@ -317,7 +316,7 @@ func (h *HCSAnalyzer) queryUserMessages(ctx context.Context, userID string, star
 	for _, topicID := range h.config.TopicIDs {
 		messages, err := h.queryTopicMessages(ctx, topicID, userID, startTime, endTime)
 		if err != nil {
-			log.Warn().Err(err).Str("topicID", topicID).Str("userID", userID).Msg("Failed to query topic messages")
+			logger.FromContext(ctx).Warn().Err(err).Str("topicID", topicID).Str("userID", userID).Msg("Failed to query topic messages")
 			continue
 		}
 		allMessages = append(allMessages, messages...)
@@ -340,7 +339,7 @@ func (h *HCSAnalyzer) queryTopicMessages(ctx context.Context, topicID, userID st
 	}
 
 	// Simulate HCS message query
-	log.Info().
+	logger.FromContext(ctx).Info().
 		Str("topicID", topicID).
 		Str("userID", userID).
 		Time("startTime", startTime).
@@ -8,9 +8,9 @@ import (
 
 	"kelo-backend/pkg/blockchain"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/logger"
 	"kelo-backend/pkg/models"
 
-	"github.com/rs/zerolog/log"
 	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
 )
@@ -234,14 +234,14 @@ func (s *CreditScoreService) GenerateCreditScoreReport(ctx context.Context, user
 	if includeDetailed {
 		// Add detailed analyses
 		if err := s.addDetailedAnalyses(ctx, &user, report); err != nil {
-			log.Error().Err(err).Str("userID", userID).Msg("Failed to add detailed analyses")
+			logger.FromContext(ctx).Error().Err(err).Str("userID", userID).Msg("Failed to add detailed analyses")
 		}
 	}
 
 	// Add risk assessment
 	riskAssessment, err := s.generateRiskAssessment(ctx, &user, report)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to generate risk assessment")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", userID).Msg("Failed to generate risk assessment")
 	} else {
 		report.RiskAssessment = riskAssessment
 	}
@@ -249,7 +249,7 @@ func (s *CreditScoreService) GenerateCreditScoreReport(ctx context.Context, user
 	// Add loan eligibility
 	loanEligibility, err := s.assessLoanEligibility(ctx, &user, report)
 	if err != nil {
-		log.Error().Err(err).Str("userID", userID).Msg("Failed to assess loan eligibility")
+		logger.FromContext(ctx).Error().Err(err).Str("userID", userID).Msg("Failed to assess loan eligibility")
 	} else {
 		report.LoanEligibility = loanEligibility
 	}
@@ -766,7 +766,7 @@ func (s *CreditScoreService) AddExternalDataSource(ctx context.Context, userID,
 		return fmt.Errorf("unsupported data source type: %s", sourceType)
 	}
 
-	log.Info().
+	logger.FromContext(ctx).Info().
 		Str("userID", userID).
 		Str("sourceType", sourceType).
 		Str("identifier", identifier).
@@ -1,8 +1,12 @@
 package liquidity
 
 import (
+	"bytes"
+	"fmt"
 	"kelo-backend/pkg/middleware"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +30,8 @@ func (h *Handler) RegisterRoutes(router gin.IRouter) {
 		poolRoutes.GET("/", h.GetPools)
 		poolRoutes.POST("/deposit", h.Deposit)
 		poolRoutes.POST("/withdraw", h.Withdraw)
+		poolRoutes.GET("/statements", h.GetStatement)
+		poolRoutes.GET("/statements/annual/:year", h.GetAnnualSummary)
 	}
 }
 
@@ -81,4 +87,112 @@ func (h *Handler) Withdraw(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful", "transaction": tx})
-}
\ No newline at end of file
+}
+
+// GetStatement returns the caller's LP statement for a period as JSON, CSV or PDF.
+// The period is given by the from and to query parameters (YYYY-MM-DD, to is exclusive)
+// and defaults to the current calendar month.
+//
+// Statements are built from completed pool transactions and pool_share_prices, and
+// nothing in the backend writes either yet: Deposit and Withdraw are placeholders that
+// record nothing. Until deposits, withdrawals, interest and share prices are recorded,
+// every statement and annual summary is empty.
+func (h *Handler) GetStatement(c *gin.Context) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(statementDateFormat, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(statementDateFormat, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	statement, err := h.service.GetStatement(userID.(string), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("kelo-statement-%s-%s", from.Format(statementDateFormat), to.Format(statementDateFormat))
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, statement)
+	case "csv":
+		var buf bytes.Buffer
+		if err := WriteStatementCSV(&buf, statement); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sendAttachment(c, filename+".csv", "text/csv", buf.Bytes())
+	case "pdf":
+		var buf bytes.Buffer
+		if err := WriteStatementPDF(&buf, statement); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sendAttachment(c, filename+".pdf", "application/pdf", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, expected json, csv or pdf"})
+	}
+}
+
+// GetAnnualSummary returns the caller's tax-year summary as JSON, CSV or PDF. Like
+// statements, it is empty until pool transactions are recorded.
+func (h *Handler) GetAnnualSummary(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil || year < 2000 || year > time.Now().UTC().Year() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax year"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	summary, err := h.service.GetAnnualSummary(userID.(string), year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("kelo-annual-summary-%d", year)
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, summary)
+	case "csv":
+		var buf bytes.Buffer
+		if err := WriteAnnualSummaryCSV(&buf, summary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sendAttachment(c, filename+".csv", "text/csv", buf.Bytes())
+	case "pdf":
+		var buf bytes.Buffer
+		if err := WriteAnnualSummaryPDF(&buf, summary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sendAttachment(c, filename+".pdf", "application/pdf", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, expected json, csv or pdf"})
+	}
+}
+
+// sendAttachment writes a downloadable file response.
+func sendAttachment(c *gin.Context, filename, contentType string, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -34,7 +34,8 @@ func (s *Service) GetPools() ([]models.LiquidityPool, error) {
 }
 
 // Deposit handles a user depositing funds into a pool.
-// This is a placeholder and would involve a complex blockchain transaction.
+// This is a placeholder and would involve a complex blockchain transaction. It records
+// nothing, so the deposit does not appear on the user's statements.
 func (s *Service) Deposit(userID, poolID string, amount float64) (*models.Transaction, error) {
 	// 1. Validate the pool exists
 	// 2. Interact with the smart contract to facilitate the deposit
@@ -52,7 +53,8 @@ func (s *Service) Deposit(userID, poolID string, amount float64) (*models.Transa
 }
 
 // Withdraw handles a user withdrawing funds from a pool.
-// This is also a placeholder for a blockchain transaction.
+// This is also a placeholder for a blockchain transaction, and records nothing for
+// statements either.
 func (s *Service) Withdraw(userID, poolID string, amount float64) (*models.Transaction, error) {
 	// 1. Check user's balance in the pool
 	// 2. Interact with the smart contract
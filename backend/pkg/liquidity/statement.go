@@ -0,0 +1,288 @@
+package liquidity
+
+import (
+	"encoding/json"
+	"fmt"
+	"kelo-backend/pkg/models"
+	"sort"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Pool transaction types that appear on LP statements.
+const (
+	TxTypeDeposit    = "deposit"
+	TxTypeWithdrawal = "withdrawal"
+	TxTypeInterest   = "interest"
+	TxTypeLoss       = "loss"
+)
+
+var statementTxTypes = []string{TxTypeDeposit, TxTypeWithdrawal, TxTypeInterest, TxTypeLoss}
+
+// txStatusCompleted is the status of a pool transaction that has settled. Pending and
+// failed transactions never move a balance and stay off statements.
+const txStatusCompleted = "completed"
+
+// StatementLine is a single pool transaction on a statement.
+type StatementLine struct {
+	Date      time.Time `json:"date"`
+	PoolID    string    `json:"pool_id"`
+	Type      string    `json:"type"`
+	Amount    float64   `json:"amount"`
+	Reference string    `json:"reference,omitempty"`
+}
+
+// SharePricePoint is a pool's share price at a point in time.
+type SharePricePoint struct {
+	Date       time.Time `json:"date"`
+	SharePrice float64   `json:"share_price"`
+}
+
+// PoolStatement summarises a user's position in one pool over a period.
+type PoolStatement struct {
+	PoolID         string            `json:"pool_id"`
+	PoolName       string            `json:"pool_name"`
+	OpeningBalance float64           `json:"opening_balance"`
+	Deposits       float64           `json:"deposits"`
+	Withdrawals    float64           `json:"withdrawals"`
+	InterestEarned float64           `json:"interest_earned"`
+	LossesAbsorbed float64           `json:"losses_absorbed"`
+	ClosingBalance float64           `json:"closing_balance"`
+	CurrentStake   float64           `json:"current_stake"`
+	SharePrices    []SharePricePoint `json:"share_prices"`
+	Lines          []StatementLine   `json:"lines"`
+}
+
+// Statement is an LP's statement across all of their pools for a period.
+// PeriodEnd is exclusive.
+type Statement struct {
+	UserID         string          `json:"user_id"`
+	PeriodStart    time.Time       `json:"period_start"`
+	PeriodEnd      time.Time       `json:"period_end"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	OpeningBalance float64         `json:"opening_balance"`
+	Deposits       float64         `json:"deposits"`
+	Withdrawals    float64         `json:"withdrawals"`
+	InterestEarned float64         `json:"interest_earned"`
+	LossesAbsorbed float64         `json:"losses_absorbed"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Pools          []PoolStatement `json:"pools"`
+}
+
+// AnnualSummary is the tax-year view of an LP's income from pools.
+type AnnualSummary struct {
+	UserID         string          `json:"user_id"`
+	TaxYear        int             `json:"tax_year"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	InterestEarned float64         `json:"interest_earned"`
+	LossesAbsorbed float64         `json:"losses_absorbed"`
+	NetIncome      float64         `json:"net_income"`
+	Deposits       float64         `json:"deposits"`
+	Withdrawals    float64         `json:"withdrawals"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Pools          []PoolStatement `json:"pools"`
+}
+
+// GetStatement builds a user's statement for the period [from, to). It reads completed
+// pool transactions and pool_share_prices, which nothing writes yet, so the statement is
+// empty until deposits, withdrawals, interest and share prices are recorded.
+func (s *Service) GetStatement(userID string, from, to time.Time) (*Statement, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("statement period end must be after its start")
+	}
+
+	var investments []models.UserInvestment
+	data, _, err := s.db.From("user_investments").Select("*", "exact", false).Eq("user_id", userID).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user investments: %w", err)
+	}
+	if err := json.Unmarshal(data, &investments); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user investments: %w", err)
+	}
+
+	// Everything before the period end is needed to work out the opening balance.
+	var transactions []models.Transaction
+	data, _, err = s.db.From("transactions").Select("*", "exact", false).
+		Eq("user_id", userID).
+		Not("pool_id", "is", "null").
+		In("type", statementTxTypes).
+		Eq("status", txStatusCompleted).
+		Lt("created_at", to.UTC().Format(time.RFC3339)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool transactions: %w", err)
+	}
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pool transactions: %w", err)
+	}
+
+	poolIDs := statementPoolIDs(investments, transactions)
+	if len(poolIDs) == 0 {
+		return buildStatement(userID, from, to, nil, investments, transactions, nil), nil
+	}
+
+	var pools []models.LiquidityPool
+	data, _, err = s.db.From("liquidity_pools").Select("*", "exact", false).In("id", poolIDs).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liquidity pools: %w", err)
+	}
+	if err := json.Unmarshal(data, &pools); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal liquidity pools: %w", err)
+	}
+
+	var prices []models.PoolSharePrice
+	data, _, err = s.db.From("pool_share_prices").Select("*", "exact", false).
+		In("pool_id", poolIDs).
+		Gte("recorded_at", from.UTC().Format(time.RFC3339)).
+		Lt("recorded_at", to.UTC().Format(time.RFC3339)).
+		Order("recorded_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool share prices: %w", err)
+	}
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pool share prices: %w", err)
+	}
+
+	return buildStatement(userID, from, to, pools, investments, transactions, prices), nil
+}
+
+// GetAnnualSummary builds a user's summary for a calendar tax year.
+func (s *Service) GetAnnualSummary(userID string, year int) (*AnnualSummary, error) {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	statement, err := s.GetStatement(userID, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnnualSummary{
+		UserID:         userID,
+		TaxYear:        year,
+		GeneratedAt:    statement.GeneratedAt,
+		InterestEarned: statement.InterestEarned,
+		LossesAbsorbed: statement.LossesAbsorbed,
+		NetIncome:      statement.InterestEarned - statement.LossesAbsorbed,
+		Deposits:       statement.Deposits,
+		Withdrawals:    statement.Withdrawals,
+		ClosingBalance: statement.ClosingBalance,
+		Pools:          statement.Pools,
+	}, nil
+}
+
+// statementPoolIDs returns every pool the user currently holds or has transacted with.
+func statementPoolIDs(investments []models.UserInvestment, transactions []models.Transaction) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, investment := range investments {
+		if !seen[investment.PoolID] {
+			seen[investment.PoolID] = true
+			ids = append(ids, investment.PoolID)
+		}
+	}
+	for _, tx := range transactions {
+		if !seen[tx.PoolID] {
+			seen[tx.PoolID] = true
+			ids = append(ids, tx.PoolID)
+		}
+	}
+	return ids
+}
+
+// buildStatement rolls completed transactions up into per-pool balances. Transactions
+// before the period only contribute to the opening balance.
+func buildStatement(userID string, from, to time.Time, pools []models.LiquidityPool, investments []models.UserInvestment, transactions []models.Transaction, prices []models.PoolSharePrice) *Statement {
+	statement := &Statement{
+		UserID:      userID,
+		PeriodStart: from,
+		PeriodEnd:   to,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	byPool := make(map[string]*PoolStatement)
+	poolStatement := func(poolID string) *PoolStatement {
+		ps, ok := byPool[poolID]
+		if !ok {
+			ps = &PoolStatement{PoolID: poolID, SharePrices: []SharePricePoint{}, Lines: []StatementLine{}}
+			byPool[poolID] = ps
+		}
+		return ps
+	}
+
+	for _, pool := range pools {
+		poolStatement(pool.ID).PoolName = pool.Name
+	}
+	for _, investment := range investments {
+		poolStatement(investment.PoolID).CurrentStake = investment.StakedAmount
+	}
+
+	for _, tx := range transactions {
+		if tx.Status != txStatusCompleted || !tx.CreatedAt.Before(to) {
+			continue
+		}
+		ps := poolStatement(tx.PoolID)
+		delta := balanceDelta(tx)
+
+		if tx.CreatedAt.Before(from) {
+			ps.OpeningBalance += delta
+			continue
+		}
+
+		switch tx.Type {
+		case TxTypeDeposit:
+			ps.Deposits += tx.Amount
+		case TxTypeWithdrawal:
+			ps.Withdrawals += tx.Amount
+		case TxTypeInterest:
+			ps.InterestEarned += tx.Amount
+		case TxTypeLoss:
+			ps.LossesAbsorbed += tx.Amount
+		}
+		ps.Lines = append(ps.Lines, StatementLine{
+			Date:      tx.CreatedAt,
+			PoolID:    tx.PoolID,
+			Type:      tx.Type,
+			Amount:    tx.Amount,
+			Reference: tx.TransactionHash,
+		})
+	}
+
+	for _, price := range prices {
+		ps := poolStatement(price.PoolID)
+		ps.SharePrices = append(ps.SharePrices, SharePricePoint{Date: price.RecordedAt, SharePrice: price.SharePrice})
+	}
+
+	for _, ps := range byPool {
+		ps.ClosingBalance = ps.OpeningBalance + ps.Deposits - ps.Withdrawals + ps.InterestEarned - ps.LossesAbsorbed
+
+		statement.OpeningBalance += ps.OpeningBalance
+		statement.Deposits += ps.Deposits
+		statement.Withdrawals += ps.Withdrawals
+		statement.InterestEarned += ps.InterestEarned
+		statement.LossesAbsorbed += ps.LossesAbsorbed
+		statement.ClosingBalance += ps.ClosingBalance
+		statement.Pools = append(statement.Pools, *ps)
+	}
+
+	sort.Slice(statement.Pools, func(i, j int) bool {
+		if statement.Pools[i].PoolName != statement.Pools[j].PoolName {
+			return statement.Pools[i].PoolName < statement.Pools[j].PoolName
+		}
+		return statement.Pools[i].PoolID < statement.Pools[j].PoolID
+	})
+
+	return statement
+}
+
+// balanceDelta returns how a transaction changes the user's position in the pool.
+func balanceDelta(tx models.Transaction) float64 {
+	switch tx.Type {
+	case TxTypeDeposit, TxTypeInterest:
+		return tx.Amount
+	case TxTypeWithdrawal, TxTypeLoss:
+		return -tx.Amount
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,244 @@
+package liquidity
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const statementDateFormat = "2006-01-02"
+
+// WriteStatementCSV writes a statement as CSV: one row per transaction followed by
+// one summary row per pool.
+func WriteStatementCSV(w io.Writer, statement *Statement) error {
+	cw := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"user_id", statement.UserID},
+		{"period_start", statement.PeriodStart.Format(statementDateFormat)},
+		{"period_end", statement.PeriodEnd.Format(statementDateFormat)},
+		{},
+		{"date", "pool_id", "pool_name", "type", "amount", "reference"},
+	}
+	for _, pool := range statement.Pools {
+		for _, line := range pool.Lines {
+			rows = append(rows, []string{
+				line.Date.Format(time.RFC3339),
+				line.PoolID,
+				pool.PoolName,
+				line.Type,
+				formatAmount(line.Amount),
+				line.Reference,
+			})
+		}
+	}
+
+	rows = append(rows, []string{},
+		[]string{"pool_id", "pool_name", "opening_balance", "deposits", "withdrawals", "interest_earned", "losses_absorbed", "closing_balance"})
+	for _, pool := range statement.Pools {
+		rows = append(rows, []string{
+			pool.PoolID,
+			pool.PoolName,
+			formatAmount(pool.OpeningBalance),
+			formatAmount(pool.Deposits),
+			formatAmount(pool.Withdrawals),
+			formatAmount(pool.InterestEarned),
+			formatAmount(pool.LossesAbsorbed),
+			formatAmount(pool.ClosingBalance),
+		})
+	}
+	rows = append(rows, []string{
+		"total",
+		"",
+		formatAmount(statement.OpeningBalance),
+		formatAmount(statement.Deposits),
+		formatAmount(statement.Withdrawals),
+		formatAmount(statement.InterestEarned),
+		formatAmount(statement.LossesAbsorbed),
+		formatAmount(statement.ClosingBalance),
+	})
+
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write statement CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteAnnualSummaryCSV writes an annual summary as CSV with one row per pool.
+func WriteAnnualSummaryCSV(w io.Writer, summary *AnnualSummary) error {
+	cw := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"user_id", summary.UserID},
+		{"tax_year", strconv.Itoa(summary.TaxYear)},
+		{},
+		{"pool_id", "pool_name", "interest_earned", "losses_absorbed", "net_income", "closing_balance"},
+	}
+	for _, pool := range summary.Pools {
+		rows = append(rows, []string{
+			pool.PoolID,
+			pool.PoolName,
+			formatAmount(pool.InterestEarned),
+			formatAmount(pool.LossesAbsorbed),
+			formatAmount(pool.InterestEarned - pool.LossesAbsorbed),
+			formatAmount(pool.ClosingBalance),
+		})
+	}
+	rows = append(rows, []string{
+		"total",
+		"",
+		formatAmount(summary.InterestEarned),
+		formatAmount(summary.LossesAbsorbed),
+		formatAmount(summary.NetIncome),
+		formatAmount(summary.ClosingBalance),
+	})
+
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write annual summary CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteStatementPDF renders a statement as a plain-text PDF.
+func WriteStatementPDF(w io.Writer, statement *Statement) error {
+	lines := []string{
+		"Kelo Liquidity Provider Statement",
+		"",
+		"Investor: " + statement.UserID,
+		fmt.Sprintf("Period: %s to %s", statement.PeriodStart.Format(statementDateFormat), statement.PeriodEnd.Format(statementDateFormat)),
+		"Generated: " + statement.GeneratedAt.Format(time.RFC3339),
+		"",
+		fmt.Sprintf("Opening balance:  %s", formatAmount(statement.OpeningBalance)),
+		fmt.Sprintf("Deposits:         %s", formatAmount(statement.Deposits)),
+		fmt.Sprintf("Withdrawals:      %s", formatAmount(statement.Withdrawals)),
+		fmt.Sprintf("Interest earned:  %s", formatAmount(statement.InterestEarned)),
+		fmt.Sprintf("Losses absorbed:  %s", formatAmount(statement.LossesAbsorbed)),
+		fmt.Sprintf("Closing balance:  %s", formatAmount(statement.ClosingBalance)),
+	}
+
+	for _, pool := range statement.Pools {
+		lines = append(lines, "", fmt.Sprintf("Pool: %s (%s)", pool.PoolName, pool.PoolID),
+			fmt.Sprintf("  Opening %s  Deposits %s  Withdrawals %s", formatAmount(pool.OpeningBalance), formatAmount(pool.Deposits), formatAmount(pool.Withdrawals)),
+			fmt.Sprintf("  Interest %s  Losses %s  Closing %s", formatAmount(pool.InterestEarned), formatAmount(pool.LossesAbsorbed), formatAmount(pool.ClosingBalance)))
+		for _, price := range pool.SharePrices {
+			lines = append(lines, fmt.Sprintf("  Share price %s: %s", price.Date.Format(statementDateFormat), strconv.FormatFloat(price.SharePrice, 'f', 6, 64)))
+		}
+		for _, line := range pool.Lines {
+			lines = append(lines, fmt.Sprintf("  %s  %-10s %14s  %s", line.Date.Format(statementDateFormat), line.Type, formatAmount(line.Amount), line.Reference))
+		}
+	}
+
+	return writeTextPDF(w, lines)
+}
+
+// WriteAnnualSummaryPDF renders an annual summary as a plain-text PDF.
+func WriteAnnualSummaryPDF(w io.Writer, summary *AnnualSummary) error {
+	lines := []string{
+		fmt.Sprintf("Kelo Liquidity Provider Annual Summary %d", summary.TaxYear),
+		"",
+		"Investor: " + summary.UserID,
+		"Generated: " + summary.GeneratedAt.Format(time.RFC3339),
+		"",
+		fmt.Sprintf("Interest earned:  %s", formatAmount(summary.InterestEarned)),
+		fmt.Sprintf("Losses absorbed:  %s", formatAmount(summary.LossesAbsorbed)),
+		fmt.Sprintf("Net income:       %s", formatAmount(summary.NetIncome)),
+		fmt.Sprintf("Year-end balance: %s", formatAmount(summary.ClosingBalance)),
+	}
+	for _, pool := range summary.Pools {
+		lines = append(lines, "", fmt.Sprintf("Pool: %s (%s)", pool.PoolName, pool.PoolID),
+			fmt.Sprintf("  Interest %s  Losses %s  Net %s", formatAmount(pool.InterestEarned), formatAmount(pool.LossesAbsorbed), formatAmount(pool.InterestEarned-pool.LossesAbsorbed)))
+	}
+
+	return writeTextPDF(w, lines)
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// PDF page layout, in points, for a US Letter page in Courier 10pt.
+const (
+	pdfPageWidth    = 612
+	pdfPageHeight   = 792
+	pdfMargin       = 50
+	pdfLineHeight   = 14
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// writeTextPDF writes a minimal PDF 1.4 document with one line of monospaced text per
+// entry, paginating as needed.
+func writeTextPDF(w io.Writer, lines []string) error {
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and a content stream per page.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i, page := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 10 Tf %d TL %d %d Td\n", pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDFText(line))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// escapePDFText escapes a string for use in a PDF literal string and drops characters
+// the standard Courier font cannot show.
+func escapePDFText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
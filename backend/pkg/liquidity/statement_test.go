@@ -0,0 +1,78 @@
+package liquidity
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStatement() *Statement {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	pools := []models.LiquidityPool{{ID: "pool_a", Name: "Stable Pool"}}
+	investments := []models.UserInvestment{{UserID: "user_1", PoolID: "pool_a", StakedAmount: 1240}}
+	transactions := []models.Transaction{
+		{PoolID: "pool_a", Type: TxTypeDeposit, Amount: 1000, Status: txStatusCompleted, CreatedAt: from.AddDate(0, -1, 0)},
+		{PoolID: "pool_a", Type: TxTypeInterest, Amount: 10, Status: txStatusCompleted, CreatedAt: from.AddDate(0, 0, -3)},
+		{PoolID: "pool_a", Type: TxTypeDeposit, Amount: 300, Status: txStatusCompleted, CreatedAt: from.AddDate(0, 0, 5), TransactionHash: "0xabc"},
+		{PoolID: "pool_a", Type: TxTypeInterest, Amount: 15, Status: txStatusCompleted, CreatedAt: from.AddDate(0, 0, 20)},
+		{PoolID: "pool_a", Type: TxTypeLoss, Amount: 5, Status: txStatusCompleted, CreatedAt: from.AddDate(0, 0, 21)},
+		{PoolID: "pool_a", Type: TxTypeWithdrawal, Amount: 80, Status: txStatusCompleted, CreatedAt: from.AddDate(0, 0, 25)},
+		// Neither has settled, so neither is on the statement
+		{PoolID: "pool_a", Type: TxTypeDeposit, Amount: 500, Status: "pending", CreatedAt: from.AddDate(0, 0, 26)},
+		{PoolID: "pool_a", Type: TxTypeWithdrawal, Amount: 200, Status: "failed", CreatedAt: from.AddDate(0, 0, -2)},
+	}
+	prices := []models.PoolSharePrice{{PoolID: "pool_a", SharePrice: 1.012, RecordedAt: from.AddDate(0, 0, 15)}}
+
+	return buildStatement("user_1", from, to, pools, investments, transactions, prices)
+}
+
+func TestBuildStatement(t *testing.T) {
+	statement := testStatement()
+	require.Len(t, statement.Pools, 1)
+
+	pool := statement.Pools[0]
+	assert.Equal(t, "Stable Pool", pool.PoolName)
+	assert.Equal(t, 1010.0, pool.OpeningBalance)
+	assert.Equal(t, 300.0, pool.Deposits)
+	assert.Equal(t, 80.0, pool.Withdrawals)
+	assert.Equal(t, 15.0, pool.InterestEarned)
+	assert.Equal(t, 5.0, pool.LossesAbsorbed)
+	assert.Equal(t, 1240.0, pool.ClosingBalance)
+	assert.Equal(t, pool.CurrentStake, pool.ClosingBalance)
+	assert.Len(t, pool.Lines, 4)
+	assert.Len(t, pool.SharePrices, 1)
+	assert.Equal(t, 1240.0, statement.ClosingBalance)
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteStatementCSV(&buf, testStatement()))
+
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	require.NoError(t, err)
+
+	last := rows[len(rows)-1]
+	assert.Equal(t, "total", last[0])
+	assert.Equal(t, "1240.00", last[len(last)-1])
+}
+
+func TestWriteStatementPDF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteStatementPDF(&buf, testStatement()))
+
+	pdf := buf.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "(Pool: Stable Pool \\(pool_a\\)) Tj")
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in from callers and back out in responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what an incoming request ID may look like; anything else is
+// replaced so that callers cannot inject into the logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware returns a Gin middleware that gives each request an ID, taken
+// from X-Request-ID when the caller sent a valid one, and a logger carrying it in the
+// request context. Services log through FromContext so their lines can be tied to the
+// request.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := c.Request.Context()
+		fields := log.With().Str("request_id", requestID)
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			fields = fields.Str("trace_id", span.TraceID().String())
+		}
+		requestLogger := fields.Logger()
+		c.Request = c.Request.WithContext(requestLogger.WithContext(ctx))
+
+		c.Next()
+	}
+}
+
+// WithUser adds the authenticated user's ID and role to the request's logger
+func WithUser(c *gin.Context, userID, role string) {
+	ctx := c.Request.Context()
+	userLogger := FromContext(ctx).With().Str("user_id", userID).Str("role", role).Logger()
+	c.Request = c.Request.WithContext(userLogger.WithContext(ctx))
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the global logger
+// outside a request
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+			return l
+		}
+	}
+	return &log.Logger
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,13 +21,15 @@ func Init(level string) {
 	// Set global log level
 	zerolog.SetGlobalLevel(logLevel)
 
-	// Configure logger
-	log.Logger = log.With().Timestamp().Logger()
+	// Configure logger; phone and account numbers are masked in every line
+	log.Logger = zerolog.New(NewRedactingWriter(os.Stderr)).With().Timestamp().Logger()
 
 	log.Info().Msgf("Logger initialized with level: %s", level)
 }
 
-// GinMiddleware returns a Gin middleware for logging HTTP requests
+// GinMiddleware returns a Gin middleware for logging HTTP requests. Requests are logged
+// with the request's logger, so RequestIDMiddleware must run first for the lines to
+// carry the request ID.
 func GinMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -49,7 +52,7 @@ func GinMiddleware() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
-		log.Info().
+		FromContext(c.Request.Context()).Info().
 			Str("client_ip", clientIP).
 			Str("method", method).
 			Str("path", path).
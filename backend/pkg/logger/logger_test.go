@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends the global logger's lines to a buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(NewRedactingWriter(&buf))
+	t.Cleanup(func() { log.Logger = previous })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func TestRequestIDMiddleware_CorrelatesLogs(t *testing.T) {
+	buf := captureLogs(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), GinMiddleware())
+	router.GET("/loans", func(c *gin.Context) {
+		WithUser(c, "user-1", "admin")
+		// A service logging with the context it was handed
+		FromContext(c.Request.Context()).Info().Msg("Listing loans")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/loans", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "req-123", rec.Header().Get(RequestIDHeader))
+
+	lines := logLines(t, buf)
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Equal(t, "req-123", line["request_id"])
+		assert.Equal(t, "user-1", line["user_id"])
+		assert.Equal(t, "admin", line["role"])
+	}
+	assert.Equal(t, "HTTP request", lines[1]["message"])
+
+	// Missing or unsafe IDs are replaced
+	for _, incoming := range []string{"", "bad id\n{\"level\":\"error\"}", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/loans", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		requestID := rec.Header().Get(RequestIDHeader)
+		assert.Len(t, requestID, 36)
+		assert.NotEqual(t, incoming, requestID)
+	}
+}
+
+func TestFromContext_FallsBackToGlobalLogger(t *testing.T) {
+	assert.Same(t, &log.Logger, FromContext(context.Background()))
+
+	requestLogger := zerolog.New(nil).With().Str("request_id", "req-1").Logger()
+	assert.Equal(t, requestLogger, *FromContext(requestLogger.WithContext(context.Background())))
+}
+
+func TestRedact(t *testing.T) {
+	buf := captureLogs(t)
+	log.Info().
+		Str("phone_number", "254712345678").
+		Str("account_number", "0123456789012").
+		Int64("bankAccount", 99887766).
+		Str("account_id", "acct-1").
+		Str("tx_hash", "0xab0712345678cd").
+		Err(assert.AnError).
+		Msg("Sending STK push to +254712345678 and 0712345678")
+
+	line := logLines(t, buf)[0]
+	assert.Equal(t, "********5678", line["phone_number"])
+	assert.Equal(t, "*********9012", line["account_number"])
+	assert.Equal(t, "****7766", line["bankAccount"])
+	assert.Equal(t, "acct-1", line["account_id"])
+	assert.Equal(t, "0xab0712345678cd", line["tx_hash"])
+	assert.Equal(t, "Sending STK push to *********5678 and ******5678", line["message"])
+	assert.NotContains(t, buf.String(), "254712345678")
+}
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// piiFieldPattern matches JSON fields holding phone or account numbers, by key
+	piiFieldPattern = regexp.MustCompile(`(?i)("[^"]*(?:phone|msisdn|account_?number|account_?no|bank_?account|iban|card_?number)[^"]*":)("(?:[^"\\]|\\.)*"|\d+)`)
+	// phonePattern matches Kenyan mobile numbers wherever they appear, e.g. in messages
+	// and errors
+	phonePattern = regexp.MustCompile(`\+?\b(?:254|0)[17]\d{8}\b`)
+)
+
+// redactingWriter masks phone and account numbers in log lines before writing them
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter returns a writer that masks phone and account numbers in the
+// JSON log lines written to w, keeping their last four digits
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write(Redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Redact masks the phone and account numbers in a JSON log line
+func Redact(line []byte) []byte {
+	line = piiFieldPattern.ReplaceAllFunc(line, func(field []byte) []byte {
+		match := piiFieldPattern.FindSubmatch(field)
+		value := strings.Trim(string(match[2]), `"`)
+		return append(match[1], `"`+mask(value)+`"`...)
+	})
+	return phonePattern.ReplaceAllFunc(line, func(phone []byte) []byte {
+		return []byte(mask(string(phone)))
+	})
+}
+
+// mask hides all but the last four characters of a value
+func mask(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
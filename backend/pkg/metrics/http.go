@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// unmatchedRoute labels requests that matched no route, so that unknown paths do not
+// each get their own series
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests, by route and status code",
+	}, []string{"method", "route", "status"})
+	httpLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	httpInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests being served, by route",
+	}, []string{"method", "route"})
+)
+
+// GinMiddleware returns a Gin middleware that records the latency, status code and
+// in-flight count of requests by route template, e.g. /v1/loans/:id
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		method := c.Request.Method
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		inFlight := httpInFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(method, route, status).Inc()
+		httpLatency.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
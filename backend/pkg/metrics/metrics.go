@@ -0,0 +1,88 @@
+// Package metrics holds the API server's Prometheus metrics: HTTP requests per route,
+// lending activity, and the latency of external APIs and Supabase queries. The metrics
+// are registered with the default registry and served by Handler on the admin port.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	loansOriginated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kelo_loans_originated_total",
+		Help: "Total number of loans originated",
+	})
+	loanOriginatedAmount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kelo_loans_originated_amount_total",
+		Help: "Total principal of the loans originated",
+	})
+	repayments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kelo_repayments_total",
+		Help: "Total number of loan repayments recorded",
+	}, []string{"loan_status"})
+	repaymentAmount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kelo_repayments_amount_total",
+		Help: "Total amount repaid",
+	})
+	delinquentLoans = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kelo_loans_delinquent",
+		Help: "Outstanding loans by days-past-due bucket at the last ECL calculation",
+	}, []string{"dpd_bucket"})
+	creditScores = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kelo_credit_scores_computed_total",
+		Help: "Total number of credit scores computed, by rating band",
+	}, []string{"band"})
+	externalAPILatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kelo_external_api_duration_seconds",
+		Help:    "Latency of calls to external APIs",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"client", "operation", "outcome"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// LoanOriginated counts an originated loan and its principal
+func LoanOriginated(amount float64) {
+	loansOriginated.Inc()
+	loanOriginatedAmount.Add(amount)
+}
+
+// RepaymentRecorded counts a repayment and the status it left the loan in
+func RepaymentRecorded(amount float64, loanStatus string) {
+	repayments.WithLabelValues(loanStatus).Inc()
+	repaymentAmount.Add(amount)
+}
+
+// SetDelinquentLoans records the number of outstanding loans in each days-past-due
+// bucket
+func SetDelinquentLoans(counts map[string]int) {
+	for bucket, count := range counts {
+		delinquentLoans.WithLabelValues(bucket).Set(float64(count))
+	}
+}
+
+// CreditScoreComputed counts a computed credit score by its rating band
+func CreditScoreComputed(band string) {
+	creditScores.WithLabelValues(band).Inc()
+}
+
+// ObserveExternalCall records the latency of a call to an external API that started at
+// start and ended with err
+func ObserveExternalCall(client, operation string, start time.Time, err error) {
+	externalAPILatency.WithLabelValues(client, operation, outcome(err == nil)).Observe(time.Since(start).Seconds())
+}
+
+func outcome(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "error"
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGinMiddleware_RecordsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/v1/loans/:id", func(c *gin.Context) {
+		assert.Equal(t, 1.0, testutil.ToFloat64(httpInFlight.WithLabelValues(http.MethodGet, "/v1/loans/:id")))
+		c.Status(http.StatusNotFound)
+	})
+
+	// The counters are process-wide, so compare against their values before the requests
+	matched := httpRequests.WithLabelValues(http.MethodGet, "/v1/loans/:id", "404")
+	unmatched := httpRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")
+	matchedBefore, unmatchedBefore := testutil.ToFloat64(matched), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/v1/loans/1", "/v1/loans/2", "/v1/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(matched)-matchedBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(unmatched)-unmatchedBefore)
+	assert.Equal(t, 0.0, testutil.ToFloat64(httpInFlight.WithLabelValues(http.MethodGet, "/v1/loans/:id")))
+}
+
+func TestSupabaseTransport_TimesQueries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/v1/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	req := httptest.NewRequest(http.MethodGet, server.URL, nil)
+	transport := &supabaseTransport{host: req.URL.Host, next: http.DefaultTransport}
+	client := &http.Client{Transport: transport}
+
+	for _, path := range []string{"/rest/v1/loans?id=eq.1", "/rest/v1/missing", "/auth/v1/token"} {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	post, err := http.NewRequest(http.MethodPost, server.URL+"/rest/v1/loans", nil)
+	require.NoError(t, err)
+	post.Header.Set("Prefer", "resolution=merge-duplicates")
+	resp, err := client.Do(post)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The auth request is not a query
+	assert.Equal(t, 3, testutil.CollectAndCount(supabaseLatency))
+	assert.ElementsMatch(t, []string{"select/success/loans", "select/error/missing", "upsert/success/loans"}, series(t, supabaseLatency))
+
+	rpc := httptest.NewRequest(http.MethodPost, "/rest/v1/rpc/score_band", nil)
+	table, operation := queryLabels(rpc)
+	assert.Equal(t, "score_band", table)
+	assert.Equal(t, "rpc", operation)
+}
+
+func TestObserveExternalCall(t *testing.T) {
+	ObserveExternalCall("crb", "get_report", time.Now(), nil)
+	ObserveExternalCall("crb", "get_report", time.Now(), errors.New("timeout"))
+	assert.ElementsMatch(t, []string{"crb/get_report/success", "crb/get_report/error"}, series(t, externalAPILatency))
+}
+
+// series returns the label values of each series of a collector, in label name order
+// and joined by slashes
+func series(t *testing.T, collector prometheus.Collector) []string {
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	close(ch)
+
+	var all []string
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		var values []string
+		for _, label := range m.GetLabel() {
+			values = append(values, label.GetValue())
+		}
+		all = append(all, strings.Join(values, "/"))
+	}
+	return all
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// restPath is the prefix of Supabase's PostgREST API
+const restPath = "/rest/v1/"
+
+var supabaseLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kelo_supabase_query_duration_seconds",
+	Help:    "Latency of Supabase queries, by table and operation",
+	Buckets: prometheus.DefBuckets,
+}, []string{"table", "operation", "outcome"})
+
+// InstrumentSupabase records the latency of every query to the Supabase project at
+// supabaseURL. The Supabase client sends queries through http.DefaultTransport and
+// takes no client of its own, so the default transport is wrapped; call it once at
+// startup, before any query is made.
+func InstrumentSupabase(supabaseURL string) error {
+	u, err := url.Parse(supabaseURL)
+	if err != nil {
+		return err
+	}
+	http.DefaultTransport = &supabaseTransport{host: u.Host, next: http.DefaultTransport}
+	return nil
+}
+
+// supabaseTransport times the PostgREST requests to one host
+type supabaseTransport struct {
+	host string
+	next http.RoundTripper
+}
+
+func (t *supabaseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host || !strings.HasPrefix(req.URL.Path, restPath) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	table, operation := queryLabels(req)
+	ok := err == nil && resp.StatusCode < http.StatusBadRequest
+	supabaseLatency.WithLabelValues(table, operation, outcome(ok)).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// queryLabels returns the table, or function for RPCs, and the operation of a
+// PostgREST request
+func queryLabels(req *http.Request) (string, string) {
+	table := strings.TrimPrefix(req.URL.Path, restPath)
+	if name, ok := strings.CutPrefix(table, "rpc/"); ok {
+		return name, "rpc"
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return table, "select"
+	case http.MethodPost:
+		if strings.Contains(req.Header.Get("Prefer"), "resolution=") {
+			return table, "upsert"
+		}
+		return table, "insert"
+	case http.MethodPatch:
+		return table, "update"
+	case http.MethodDelete:
+		return table, "delete"
+	default:
+		return table, strings.ToLower(req.Method)
+	}
+}
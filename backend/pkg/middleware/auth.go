@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/logger"
 	"net/http"
 	"strings"
 
@@ -92,6 +93,7 @@ func AuthMiddleware(requiredRoles ...string) gin.HandlerFunc {
 		// Set user info in context for downstream handlers
 		c.Set("userID", userID)
 		c.Set("userRole", userRole)
+		logger.WithUser(c, userID, userRole)
 
 		c.Next()
 	}
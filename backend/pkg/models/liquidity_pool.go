@@ -6,10 +6,29 @@ import (
 
 // LiquidityPool represents a liquidity pool in the Kelo system.
 type LiquidityPool struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	TotalStaked float64   `json:"total_staked"`
-	Apy         float64   `json:"apy,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description,omitempty"`
+	TotalStaked    float64   `json:"total_staked"`
+	Apy            float64   `json:"apy,omitempty"`
+	ChainID        string    `json:"chain_id,omitempty"`
+	Asset          string    `json:"asset,omitempty"`
+	TotalBorrowed  float64   `json:"total_borrowed"`
+	UtilizationCap float64   `json:"utilization_cap,omitempty"`
+	Priority       int       `json:"priority"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// UserInvestment corresponds to the 'user_investments' table in Supabase.
+type UserInvestment struct {
+	UserID       string  `json:"user_id"`
+	PoolID       string  `json:"pool_id"`
+	StakedAmount float64 `json:"staked_amount"`
+}
+
+// PoolSharePrice corresponds to the 'pool_share_prices' table in Supabase.
+type PoolSharePrice struct {
+	PoolID     string    `json:"pool_id"`
+	SharePrice float64   `json:"share_price"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
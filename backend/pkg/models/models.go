@@ -30,7 +30,12 @@ type Loan struct {
 	DueDate         time.Time  `json:"due_date"`
 	CreatedAt       time.Time  `json:"created_at,omitempty"`
 	UpdatedAt       time.Time  `json:"updated_at,omitempty"`
-	RepaidAt        *time.Time `json:"repaid_at,omitempty"` // Used for repayment behavior score
+	RepaidAt        *time.Time `json:"repaid_at,omitempty"`       // Used for repayment behavior score
+	OnchainID       string     `json:"onchain_id,omitempty"`      // LoanAgreementNFT token ID on Hedera
+	FundingPoolID   string     `json:"funding_pool_id,omitempty"` // Pool chosen by the relayer's allocation engine
+	FundingChain    string     `json:"funding_chain,omitempty"`
+	AllocatedAt     *time.Time `json:"allocated_at,omitempty"`
+	DisbursedAt     *time.Time `json:"disbursed_at,omitempty"` // Set when the merchant has been paid
 }
 
 // Repayment corresponds to the 'repayments' table in Supabase.
@@ -46,12 +51,14 @@ type Repayment struct {
 // In this context, it is not a direct 1:1 mapping to a table but represents
 // the data needed for credit scoring from a transaction history source.
 type Transaction struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Type      string    `json:"type"`
-	Amount    float64   `json:"amount"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	PoolID          string    `json:"pool_id,omitempty"`
+	Type            string    `json:"type"`
+	Amount          float64   `json:"amount"`
+	Status          string    `json:"status"`
+	TransactionHash string    `json:"transaction_hash,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // CreditScore corresponds to the 'credit_scores' table in Supabase.
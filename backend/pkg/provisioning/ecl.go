@@ -0,0 +1,262 @@
+package provisioning
+
+import (
+	"sort"
+	"time"
+)
+
+// Exposure is an outstanding loan as seen by the provisioning engine.
+type Exposure struct {
+	LoanID       string
+	UserID       string
+	Outstanding  float64
+	DueDate      time.Time
+	OriginatedAt time.Time
+	CreditScore  int
+}
+
+// Provision is the expected credit loss computed for a single loan.
+type Provision struct {
+	ID          string    `json:"id,omitempty"`
+	AsOf        time.Time `json:"as_of"`
+	LoanID      string    `json:"loan_id"`
+	UserID      string    `json:"user_id"`
+	Vintage     string    `json:"vintage"`
+	DaysPastDue int       `json:"days_past_due"`
+	Bucket      DPDBucket `json:"dpd_bucket"`
+	Band        ScoreBand `json:"score_band"`
+	Exposure    float64   `json:"exposure"`
+	PD          float64   `json:"pd"`
+	LGD         float64   `json:"lgd"`
+	ECL         float64   `json:"ecl"`
+}
+
+// BucketSummary aggregates exposure and ECL for one DPD bucket and score band.
+type BucketSummary struct {
+	Bucket   DPDBucket `json:"dpd_bucket"`
+	Band     ScoreBand `json:"score_band"`
+	Loans    int       `json:"loans"`
+	Exposure float64   `json:"exposure"`
+	ECL      float64   `json:"ecl"`
+}
+
+// ECLReport is the expected-credit-loss view of the loan book at a point in time.
+type ECLReport struct {
+	AsOf          time.Time       `json:"as_of"`
+	TotalExposure float64         `json:"total_exposure"`
+	TotalECL      float64         `json:"total_ecl"`
+	CoverageRatio float64         `json:"coverage_ratio"`
+	Buckets       []BucketSummary `json:"buckets"`
+	Provisions    []Provision     `json:"provisions"`
+}
+
+// PARReport holds portfolio-at-risk ratios. PAR1 counts any arrears, PAR30 and PAR90
+// count loans past the 30 and 90 day bucket boundaries.
+type PARReport struct {
+	AsOf             time.Time `json:"as_of"`
+	TotalOutstanding float64   `json:"total_outstanding"`
+	PAR1Amount       float64   `json:"par1_amount"`
+	PAR30Amount      float64   `json:"par30_amount"`
+	PAR90Amount      float64   `json:"par90_amount"`
+	PAR1             float64   `json:"par1"`
+	PAR30            float64   `json:"par30"`
+	PAR90            float64   `json:"par90"`
+}
+
+// VintageRollRate describes how one origination cohort moved between DPD buckets
+// from the previous provisioning run to the current one.
+type VintageRollRate struct {
+	Vintage          string                          `json:"vintage"`
+	Loans            int                             `json:"loans"`
+	Exposure         float64                         `json:"exposure"`
+	ExposureByBucket map[DPDBucket]float64           `json:"exposure_by_bucket"`
+	Transitions      map[DPDBucket]map[DPDBucket]int `json:"transitions"`
+	RollForward      map[DPDBucket]float64           `json:"roll_forward"`
+	CureRate         map[DPDBucket]float64           `json:"cure_rate"`
+}
+
+// Calculator computes expected credit losses from the configured tables.
+type Calculator struct {
+	tables *Tables
+}
+
+// NewCalculator creates a new ECL calculator. Nil tables fall back to DefaultTables.
+func NewCalculator(tables *Tables) *Calculator {
+	if tables == nil {
+		tables = DefaultTables()
+	}
+	return &Calculator{tables: tables}
+}
+
+// Tables returns the assumptions the calculator was configured with.
+func (c *Calculator) Tables() *Tables {
+	return c.tables
+}
+
+// Provision computes the ECL for a single exposure as of the given time.
+func (c *Calculator) Provision(exposure Exposure, asOf time.Time) Provision {
+	daysPastDue := 0
+	if asOf.After(exposure.DueDate) {
+		daysPastDue = int(asOf.Sub(exposure.DueDate).Hours() / 24)
+	}
+
+	bucket := BucketForDPD(daysPastDue)
+	band := BandForScore(exposure.CreditScore)
+	pd := c.tables.pd(bucket, band)
+	lgd := c.tables.lgd(band)
+
+	outstanding := exposure.Outstanding
+	if outstanding < 0 {
+		outstanding = 0
+	}
+
+	return Provision{
+		AsOf:        asOf,
+		LoanID:      exposure.LoanID,
+		UserID:      exposure.UserID,
+		Vintage:     exposure.OriginatedAt.Format("2006-01"),
+		DaysPastDue: daysPastDue,
+		Bucket:      bucket,
+		Band:        band,
+		Exposure:    outstanding,
+		PD:          pd,
+		LGD:         lgd,
+		ECL:         outstanding * pd * lgd,
+	}
+}
+
+// Calculate provisions every exposure and aggregates the result by bucket and band.
+func (c *Calculator) Calculate(exposures []Exposure, asOf time.Time) *ECLReport {
+	report := &ECLReport{
+		AsOf:       asOf,
+		Provisions: make([]Provision, 0, len(exposures)),
+	}
+
+	type cell struct {
+		bucket DPDBucket
+		band   ScoreBand
+	}
+	summaries := make(map[cell]*BucketSummary)
+
+	for _, exposure := range exposures {
+		provision := c.Provision(exposure, asOf)
+		report.Provisions = append(report.Provisions, provision)
+		report.TotalExposure += provision.Exposure
+		report.TotalECL += provision.ECL
+
+		key := cell{provision.Bucket, provision.Band}
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &BucketSummary{Bucket: provision.Bucket, Band: provision.Band}
+			summaries[key] = summary
+		}
+		summary.Loans++
+		summary.Exposure += provision.Exposure
+		summary.ECL += provision.ECL
+	}
+
+	for _, bucket := range Buckets {
+		for _, band := range Bands {
+			if summary, ok := summaries[cell{bucket, band}]; ok {
+				report.Buckets = append(report.Buckets, *summary)
+			}
+		}
+	}
+
+	if report.TotalExposure > 0 {
+		report.CoverageRatio = report.TotalECL / report.TotalExposure
+	}
+
+	return report
+}
+
+// PortfolioAtRisk computes PAR1, PAR30 and PAR90 from a set of provisions.
+func PortfolioAtRisk(provisions []Provision, asOf time.Time) *PARReport {
+	report := &PARReport{AsOf: asOf}
+
+	for _, p := range provisions {
+		report.TotalOutstanding += p.Exposure
+		if p.DaysPastDue >= 1 {
+			report.PAR1Amount += p.Exposure
+		}
+		if p.DaysPastDue > 30 {
+			report.PAR30Amount += p.Exposure
+		}
+		if p.DaysPastDue > 90 {
+			report.PAR90Amount += p.Exposure
+		}
+	}
+
+	if report.TotalOutstanding > 0 {
+		report.PAR1 = report.PAR1Amount / report.TotalOutstanding
+		report.PAR30 = report.PAR30Amount / report.TotalOutstanding
+		report.PAR90 = report.PAR90Amount / report.TotalOutstanding
+	}
+
+	return report
+}
+
+// VintageRollRates compares the current provisions against the previous run, grouped by
+// origination month. Loans that are missing from the previous run only count towards the
+// cohort's exposure; loans that have since closed are not in the current run and are ignored.
+func VintageRollRates(previous, current []Provision) []VintageRollRate {
+	previousBuckets := make(map[string]DPDBucket, len(previous))
+	for _, p := range previous {
+		previousBuckets[p.LoanID] = p.Bucket
+	}
+
+	vintages := make(map[string]*VintageRollRate)
+	for _, p := range current {
+		v, ok := vintages[p.Vintage]
+		if !ok {
+			v = &VintageRollRate{
+				Vintage:          p.Vintage,
+				ExposureByBucket: make(map[DPDBucket]float64),
+				Transitions:      make(map[DPDBucket]map[DPDBucket]int),
+				RollForward:      make(map[DPDBucket]float64),
+				CureRate:         make(map[DPDBucket]float64),
+			}
+			vintages[p.Vintage] = v
+		}
+
+		v.Loans++
+		v.Exposure += p.Exposure
+		v.ExposureByBucket[p.Bucket] += p.Exposure
+
+		from, ok := previousBuckets[p.LoanID]
+		if !ok {
+			continue
+		}
+		if v.Transitions[from] == nil {
+			v.Transitions[from] = make(map[DPDBucket]int)
+		}
+		v.Transitions[from][p.Bucket]++
+	}
+
+	result := make([]VintageRollRate, 0, len(vintages))
+	for _, v := range vintages {
+		for from, to := range v.Transitions {
+			total, worse, better := 0, 0, 0
+			for bucket, count := range to {
+				total += count
+				switch {
+				case bucket.rank() > from.rank():
+					worse += count
+				case bucket.rank() < from.rank():
+					better += count
+				}
+			}
+			if total > 0 {
+				v.RollForward[from] = float64(worse) / float64(total)
+				v.CureRate[from] = float64(better) / float64(total)
+			}
+		}
+		result = append(result, *v)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Vintage < result[j].Vintage
+	})
+
+	return result
+}
@@ -0,0 +1,126 @@
+package provisioning
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketForDPD(t *testing.T) {
+	assert.Equal(t, BucketCurrent, BucketForDPD(0))
+	assert.Equal(t, Bucket1To30, BucketForDPD(1))
+	assert.Equal(t, Bucket1To30, BucketForDPD(30))
+	assert.Equal(t, Bucket31To60, BucketForDPD(31))
+	assert.Equal(t, Bucket61To90, BucketForDPD(90))
+	assert.Equal(t, Bucket90Plus, BucketForDPD(91))
+}
+
+func TestBandForScore(t *testing.T) {
+	assert.Equal(t, BandUnscored, BandForScore(0))
+	assert.Equal(t, BandVeryPoor, BandForScore(550))
+	assert.Equal(t, BandPoor, BandForScore(600))
+	assert.Equal(t, BandFair, BandForScore(680))
+	assert.Equal(t, BandGood, BandForScore(700))
+	assert.Equal(t, BandExcellent, BandForScore(800))
+}
+
+func TestCalculate(t *testing.T) {
+	asOf := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	calculator := NewCalculator(nil)
+
+	exposures := []Exposure{
+		{LoanID: "loan_current", Outstanding: 1000, DueDate: asOf.AddDate(0, 0, 10), OriginatedAt: asOf.AddDate(0, -1, 0), CreditScore: 760},
+		{LoanID: "loan_late", Outstanding: 500, DueDate: asOf.AddDate(0, 0, -45), OriginatedAt: asOf.AddDate(0, -3, 0), CreditScore: 620},
+		{LoanID: "loan_default", Outstanding: 200, DueDate: asOf.AddDate(0, 0, -120), OriginatedAt: asOf.AddDate(0, -6, 0)},
+	}
+
+	report := calculator.Calculate(exposures, asOf)
+	require.Len(t, report.Provisions, 3)
+
+	assert.Equal(t, BucketCurrent, report.Provisions[0].Bucket)
+	assert.Equal(t, BandExcellent, report.Provisions[0].Band)
+	assert.InDelta(t, 1000*0.005*0.65, report.Provisions[0].ECL, 1e-9)
+
+	assert.Equal(t, 45, report.Provisions[1].DaysPastDue)
+	assert.Equal(t, Bucket31To60, report.Provisions[1].Bucket)
+	assert.InDelta(t, 500*0.40*0.80, report.Provisions[1].ECL, 1e-9)
+
+	assert.Equal(t, Bucket90Plus, report.Provisions[2].Bucket)
+	assert.Equal(t, BandUnscored, report.Provisions[2].Band)
+	assert.InDelta(t, 200*1*0.80, report.Provisions[2].ECL, 1e-9)
+
+	assert.InDelta(t, 1700, report.TotalExposure, 1e-9)
+	assert.InDelta(t, 3.25+160+160, report.TotalECL, 1e-9)
+	assert.Len(t, report.Buckets, 3)
+}
+
+func TestPortfolioAtRisk(t *testing.T) {
+	provisions := []Provision{
+		{Exposure: 600, DaysPastDue: 0},
+		{Exposure: 200, DaysPastDue: 5},
+		{Exposure: 100, DaysPastDue: 40},
+		{Exposure: 100, DaysPastDue: 100},
+	}
+
+	report := PortfolioAtRisk(provisions, time.Now())
+	assert.InDelta(t, 0.4, report.PAR1, 1e-9)
+	assert.InDelta(t, 0.2, report.PAR30, 1e-9)
+	assert.InDelta(t, 0.1, report.PAR90, 1e-9)
+}
+
+func TestVintageRollRates(t *testing.T) {
+	previous := []Provision{
+		{LoanID: "a", Vintage: "2024-01", Bucket: BucketCurrent},
+		{LoanID: "b", Vintage: "2024-01", Bucket: BucketCurrent},
+		{LoanID: "c", Vintage: "2024-01", Bucket: Bucket1To30},
+	}
+	current := []Provision{
+		{LoanID: "a", Vintage: "2024-01", Bucket: Bucket1To30, Exposure: 100},
+		{LoanID: "b", Vintage: "2024-01", Bucket: BucketCurrent, Exposure: 100},
+		{LoanID: "c", Vintage: "2024-01", Bucket: BucketCurrent, Exposure: 50},
+		{LoanID: "d", Vintage: "2024-02", Bucket: BucketCurrent, Exposure: 80},
+	}
+
+	rates := VintageRollRates(previous, current)
+	require.Len(t, rates, 2)
+
+	assert.Equal(t, "2024-01", rates[0].Vintage)
+	assert.Equal(t, 3, rates[0].Loans)
+	assert.InDelta(t, 0.5, rates[0].RollForward[BucketCurrent], 1e-9)
+	assert.InDelta(t, 1.0, rates[0].CureRate[Bucket1To30], 1e-9)
+
+	assert.Equal(t, "2024-02", rates[1].Vintage)
+	assert.Empty(t, rates[1].Transitions)
+}
+
+func TestLoadTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tables.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"probability_of_default":{"current":{"Good":0.02}},"loss_given_default":{"Good":0.5}}`), 0o600))
+
+	tables, err := LoadTables(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0.02, tables.pd(BucketCurrent, BandGood))
+	assert.Equal(t, 0.5, tables.lgd(BandGood))
+	assert.Equal(t, DefaultTables().pd(Bucket90Plus, BandFair), tables.pd(Bucket90Plus, BandFair))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"loss_given_default":{"Good":1.5}}`), 0o600))
+	_, err = LoadTables(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"probability_of_default":{"180+":{"Good":1}}}`), 0o600))
+	_, err = LoadTables(path)
+	assert.Error(t, err)
+
+	// A misspelled band is rejected rather than ignored
+	require.NoError(t, os.WriteFile(path, []byte(`{"probability_of_default":{"current":{"Gud":0.02}}}`), 0o600))
+	_, err = LoadTables(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"loss_given_default":{"Gud":0.5}}`), 0o600))
+	_, err = LoadTables(path)
+	assert.Error(t, err)
+}
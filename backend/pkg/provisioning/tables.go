@@ -0,0 +1,173 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DPDBucket groups loans by how many days they are past due.
+type DPDBucket string
+
+const (
+	BucketCurrent DPDBucket = "current"
+	Bucket1To30   DPDBucket = "1-30"
+	Bucket31To60  DPDBucket = "31-60"
+	Bucket61To90  DPDBucket = "61-90"
+	Bucket90Plus  DPDBucket = "90+"
+)
+
+// Buckets lists the DPD buckets from best to worst.
+var Buckets = []DPDBucket{BucketCurrent, Bucket1To30, Bucket31To60, Bucket61To90, Bucket90Plus}
+
+// BucketForDPD returns the bucket a loan falls into for the given days past due.
+func BucketForDPD(daysPastDue int) DPDBucket {
+	switch {
+	case daysPastDue <= 0:
+		return BucketCurrent
+	case daysPastDue <= 30:
+		return Bucket1To30
+	case daysPastDue <= 60:
+		return Bucket31To60
+	case daysPastDue <= 90:
+		return Bucket61To90
+	default:
+		return Bucket90Plus
+	}
+}
+
+// rank returns the position of the bucket in Buckets, used to tell roll-forwards from cures.
+func (b DPDBucket) rank() int {
+	for i, bucket := range Buckets {
+		if bucket == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// ScoreBand is a credit score band, matching the ratings in credit_score_ranges.
+type ScoreBand string
+
+const (
+	BandExcellent ScoreBand = "Excellent"
+	BandGood      ScoreBand = "Good"
+	BandFair      ScoreBand = "Fair"
+	BandPoor      ScoreBand = "Poor"
+	BandVeryPoor  ScoreBand = "Very Poor"
+	BandUnscored  ScoreBand = "Unscored"
+)
+
+// Bands lists the score bands from best to worst.
+var Bands = []ScoreBand{BandExcellent, BandGood, BandFair, BandPoor, BandVeryPoor, BandUnscored}
+
+// BandForScore returns the band for a credit score. A zero score means the borrower has not been scored.
+func BandForScore(score int) ScoreBand {
+	switch {
+	case score <= 0:
+		return BandUnscored
+	case score >= 750:
+		return BandExcellent
+	case score >= 700:
+		return BandGood
+	case score >= 650:
+		return BandFair
+	case score >= 600:
+		return BandPoor
+	default:
+		return BandVeryPoor
+	}
+}
+
+// Tables holds the probability-of-default and loss-given-default assumptions used for ECL.
+type Tables struct {
+	// ProbabilityOfDefault is keyed by DPD bucket, then by score band.
+	ProbabilityOfDefault map[DPDBucket]map[ScoreBand]float64 `json:"probability_of_default"`
+	// LossGivenDefault is keyed by score band.
+	LossGivenDefault map[ScoreBand]float64 `json:"loss_given_default"`
+}
+
+// DefaultTables returns the provisioning assumptions used when no tables file is configured.
+func DefaultTables() *Tables {
+	return &Tables{
+		ProbabilityOfDefault: map[DPDBucket]map[ScoreBand]float64{
+			BucketCurrent: {BandExcellent: 0.005, BandGood: 0.01, BandFair: 0.02, BandPoor: 0.04, BandVeryPoor: 0.08, BandUnscored: 0.05},
+			Bucket1To30:   {BandExcellent: 0.05, BandGood: 0.08, BandFair: 0.12, BandPoor: 0.18, BandVeryPoor: 0.25, BandUnscored: 0.20},
+			Bucket31To60:  {BandExcellent: 0.20, BandGood: 0.25, BandFair: 0.30, BandPoor: 0.40, BandVeryPoor: 0.50, BandUnscored: 0.40},
+			Bucket61To90:  {BandExcellent: 0.40, BandGood: 0.45, BandFair: 0.50, BandPoor: 0.60, BandVeryPoor: 0.70, BandUnscored: 0.60},
+			Bucket90Plus:  {BandExcellent: 1, BandGood: 1, BandFair: 1, BandPoor: 1, BandVeryPoor: 1, BandUnscored: 1},
+		},
+		LossGivenDefault: map[ScoreBand]float64{
+			BandExcellent: 0.65,
+			BandGood:      0.70,
+			BandFair:      0.75,
+			BandPoor:      0.80,
+			BandVeryPoor:  0.85,
+			BandUnscored:  0.80,
+		},
+	}
+}
+
+// LoadTables reads provisioning tables from a JSON file. Missing cells fall back to the defaults.
+func LoadTables(path string) (*Tables, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provisioning tables: %w", err)
+	}
+
+	var loaded Tables
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse provisioning tables: %w", err)
+	}
+
+	tables := DefaultTables()
+	for bucket, bands := range loaded.ProbabilityOfDefault {
+		if _, ok := tables.ProbabilityOfDefault[bucket]; !ok {
+			return nil, fmt.Errorf("unknown DPD bucket in provisioning tables: %s", bucket)
+		}
+		for band, pd := range bands {
+			if _, ok := tables.ProbabilityOfDefault[bucket][band]; !ok {
+				return nil, fmt.Errorf("unknown score band in provisioning tables: %s/%s", bucket, band)
+			}
+			tables.ProbabilityOfDefault[bucket][band] = pd
+		}
+	}
+	for band, lgd := range loaded.LossGivenDefault {
+		if _, ok := tables.LossGivenDefault[band]; !ok {
+			return nil, fmt.Errorf("unknown score band in provisioning tables: %s", band)
+		}
+		tables.LossGivenDefault[band] = lgd
+	}
+
+	if err := tables.Validate(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// Validate checks that every rate in the tables is a probability.
+func (t *Tables) Validate() error {
+	for bucket, bands := range t.ProbabilityOfDefault {
+		for band, pd := range bands {
+			if pd < 0 || pd > 1 {
+				return fmt.Errorf("probability of default for %s/%s must be between 0 and 1, got %v", bucket, band, pd)
+			}
+		}
+	}
+	for band, lgd := range t.LossGivenDefault {
+		if lgd < 0 || lgd > 1 {
+			return fmt.Errorf("loss given default for %s must be between 0 and 1, got %v", band, lgd)
+		}
+	}
+	return nil
+}
+
+// pd returns the probability of default for a bucket and band.
+func (t *Tables) pd(bucket DPDBucket, band ScoreBand) float64 {
+	return t.ProbabilityOfDefault[bucket][band]
+}
+
+// lgd returns the loss given default for a band.
+func (t *Tables) lgd(band ScoreBand) float64 {
+	return t.LossGivenDefault[band]
+}
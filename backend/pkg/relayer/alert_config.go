@@ -0,0 +1,341 @@
+package relayer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Notifier types of the alerting file
+const (
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierTeams   = "teams"
+	NotifierSMTP    = "smtp"
+	NotifierLog     = "log"
+)
+
+// Metrics alert rule conditions can compare
+const (
+	// AlertMetricEvents counts the monitor events that match the condition
+	AlertMetricEvents = "events"
+	// AlertMetricFailureRate is the share of processed messages that failed
+	AlertMetricFailureRate = "failure_rate"
+	// AlertMetricOpenCircuits counts the chains whose circuit breaker is open
+	AlertMetricOpenCircuits = "open_circuits"
+	// AlertMetricPausedChains counts the chains whose sends are paused by a limit
+	AlertMetricPausedChains = "paused_chains"
+	// AlertMetricUnhealthyChecks counts the failing health checks
+	AlertMetricUnhealthyChecks = "unhealthy_checks"
+)
+
+// AlertConfig is the relayer's alerting file: where alerts are sent, how they are
+// routed and silenced, and rules on top of the built-in ones
+type AlertConfig struct {
+	// DedupWindow drops a rule's notification that repeats its last one within the
+	// window, e.g. "15m"
+	DedupWindow string          `json:"dedup_window,omitempty"`
+	Notifiers   []NotifierSpec  `json:"notifiers"`
+	Routes      []AlertRoute    `json:"routes"`
+	Silences    []AlertSilence  `json:"silences,omitempty"`
+	Rules       []AlertRuleSpec `json:"rules,omitempty"`
+}
+
+// NotifierSpec configures a named notifier. URL, headers, template and SMTP
+// credentials may reference environment variables as ${NAME}.
+type NotifierSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+	// Headers and Template configure webhook notifiers
+	Headers  map[string]string `json:"headers,omitempty"`
+	Template string            `json:"template,omitempty"`
+	SMTP     *SMTPConfig       `json:"smtp,omitempty"`
+}
+
+// AlertRuleSpec is an alert rule read from the alerting file. A rule with the name of
+// a built-in rule replaces it.
+type AlertRuleSpec struct {
+	Name      string             `json:"name"`
+	Severity  AlertSeverity      `json:"severity"`
+	Message   string             `json:"message"`
+	Enabled   *bool              `json:"enabled,omitempty"`
+	Condition AlertConditionSpec `json:"condition"`
+}
+
+// AlertConditionSpec compares one of the relayer's metrics with a threshold. The rule
+// fires while "metric op threshold" holds.
+type AlertConditionSpec struct {
+	Metric string `json:"metric"`
+	// Op is one of >, >=, <, <= and ==; it defaults to >
+	Op        string  `json:"op,omitempty"`
+	Threshold float64 `json:"threshold"`
+	// ChainID limits the metric to one chain
+	ChainID string `json:"chain_id,omitempty"`
+	// EventType and EventStatus select the events counted by the events metric
+	EventType   *EventType   `json:"event_type,omitempty"`
+	EventStatus *EventStatus `json:"event_status,omitempty"`
+	// Window limits events and failure_rate to recent events, e.g. "10m", and Last to
+	// the latest events
+	Window string `json:"window,omitempty"`
+	Last   int    `json:"last,omitempty"`
+	// MinEvents is how many messages failure_rate needs before it can fire
+	MinEvents int `json:"min_events,omitempty"`
+}
+
+// LoadAlertConfig reads the alerting file at path
+func LoadAlertConfig(path string) (*AlertConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert config: %w", err)
+	}
+
+	var config AlertConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse alert config: %w", err)
+	}
+	for i := range config.Notifiers {
+		spec := &config.Notifiers[i]
+		spec.URL = os.ExpandEnv(spec.URL)
+		spec.Template = os.ExpandEnv(spec.Template)
+		for key, value := range spec.Headers {
+			spec.Headers[key] = os.ExpandEnv(value)
+		}
+		if spec.SMTP != nil {
+			spec.SMTP.Username = os.ExpandEnv(spec.SMTP.Username)
+			spec.SMTP.Password = os.ExpandEnv(spec.SMTP.Password)
+		}
+	}
+	return &config, nil
+}
+
+// configureAlerts sends alerts to the notifiers of the alerting file and adds its rules
+func (tr *TrustedRelayer) configureAlerts(config *AlertConfig) error {
+	var dedupWindow time.Duration
+	if config.DedupWindow != "" {
+		var err error
+		if dedupWindow, err = time.ParseDuration(config.DedupWindow); err != nil {
+			return fmt.Errorf("invalid alert dedup_window: %w", err)
+		}
+	}
+
+	notifiers := make(map[string]AlertNotifier, len(config.Notifiers))
+	for _, spec := range config.Notifiers {
+		if spec.Name == "" {
+			return fmt.Errorf("alert notifier has no name")
+		}
+		if _, ok := notifiers[spec.Name]; ok {
+			return fmt.Errorf("duplicate alert notifier %s", spec.Name)
+		}
+		notifier, err := newNotifier(spec)
+		if err != nil {
+			return fmt.Errorf("alert notifier %s: %w", spec.Name, err)
+		}
+		notifiers[spec.Name] = notifier
+	}
+	router, err := NewAlertRouter(notifiers, config.Routes, config.Silences, dedupWindow)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]*AlertRule, 0, len(config.Rules))
+	for _, spec := range config.Rules {
+		rule, err := tr.alertRule(spec)
+		if err != nil {
+			return fmt.Errorf("alert rule %s: %w", spec.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	tr.monitor.AddAlertNotifier(router)
+	for _, rule := range rules {
+		tr.monitor.SetAlertRule(rule)
+	}
+	return nil
+}
+
+func newNotifier(spec NotifierSpec) (AlertNotifier, error) {
+	switch spec.Type {
+	case NotifierWebhook:
+		return NewWebhookNotifier(spec.URL, spec.Template, spec.Headers)
+	case NotifierSlack, NotifierTeams:
+		if spec.URL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		if spec.Type == NotifierSlack {
+			return NewSlackNotifier(spec.URL), nil
+		}
+		return NewTeamsNotifier(spec.URL), nil
+	case NotifierSMTP:
+		if spec.SMTP == nil {
+			return nil, fmt.Errorf("smtp settings are required")
+		}
+		return NewEmailNotifier(*spec.SMTP)
+	case NotifierLog:
+		return &LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %q", spec.Type)
+	}
+}
+
+// alertRule builds an alert rule from its spec
+func (tr *TrustedRelayer) alertRule(spec AlertRuleSpec) (*AlertRule, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	condition, err := tr.alertCondition(spec.Condition)
+	if err != nil {
+		return nil, err
+	}
+	message := spec.Message
+	if message == "" {
+		message = fmt.Sprintf("%s %s %g", spec.Condition.Metric, compareOp(spec.Condition.Op), spec.Condition.Threshold)
+	}
+	return &AlertRule{
+		Name:      spec.Name,
+		Severity:  spec.Severity,
+		Message:   message,
+		Enabled:   spec.Enabled == nil || *spec.Enabled,
+		Condition: condition,
+	}, nil
+}
+
+// alertCondition builds the condition that compares the spec's metric with its
+// threshold
+func (tr *TrustedRelayer) alertCondition(spec AlertConditionSpec) (func(*Monitor) bool, error) {
+	compare, err := comparison(spec.Op)
+	if err != nil {
+		return nil, err
+	}
+	var window time.Duration
+	if spec.Window != "" {
+		if window, err = time.ParseDuration(spec.Window); err != nil {
+			return nil, fmt.Errorf("invalid window: %w", err)
+		}
+	}
+
+	var metric func(*Monitor) (float64, bool)
+	switch spec.Metric {
+	case AlertMetricEvents:
+		metric = func(m *Monitor) (float64, bool) {
+			count := 0
+			for _, event := range recentEvents(m, window, spec.Last) {
+				if spec.matches(event) {
+					count++
+				}
+			}
+			return float64(count), true
+		}
+	case AlertMetricFailureRate:
+		metric = func(m *Monitor) (float64, bool) {
+			processed, failed := 0, 0
+			for _, event := range recentEvents(m, window, spec.Last) {
+				if event.Type != EventTypeMessageProcessed || (spec.ChainID != "" && event.ChainID != spec.ChainID) {
+					continue
+				}
+				processed++
+				if event.Status == EventStatusFailure {
+					failed++
+				}
+			}
+			if processed == 0 || processed < spec.MinEvents {
+				return 0, false
+			}
+			return float64(failed) / float64(processed), true
+		}
+	case AlertMetricOpenCircuits:
+		metric = func(m *Monitor) (float64, bool) {
+			return float64(countChains(tr.errorHandler.OpenCircuits(), spec.ChainID)), true
+		}
+	case AlertMetricPausedChains:
+		metric = func(m *Monitor) (float64, bool) {
+			return float64(countChains(tr.limits.PausedChains(), spec.ChainID)), true
+		}
+	case AlertMetricUnhealthyChecks:
+		metric = func(m *Monitor) (float64, bool) {
+			count := 0
+			for _, check := range m.GetHealthChecks() {
+				if check.Status == HealthStatusUnhealthy {
+					count++
+				}
+			}
+			return float64(count), true
+		}
+	default:
+		return nil, fmt.Errorf("unsupported metric %q", spec.Metric)
+	}
+
+	return func(m *Monitor) bool {
+		value, ok := metric(m)
+		return ok && compare(value, spec.Threshold)
+	}, nil
+}
+
+// matches reports whether the events metric counts the event
+func (spec *AlertConditionSpec) matches(event *Event) bool {
+	if spec.EventType != nil && event.Type != *spec.EventType {
+		return false
+	}
+	if spec.EventStatus != nil && event.Status != *spec.EventStatus {
+		return false
+	}
+	return spec.ChainID == "" || event.ChainID == spec.ChainID
+}
+
+// recentEvents returns the monitor's events within the window, or its last events, or
+// all of them
+func recentEvents(m *Monitor, window time.Duration, last int) []*Event {
+	limit := last
+	if limit <= 0 {
+		limit = m.eventBuffer.maxSize
+	}
+	events := m.GetRecentEvents(limit)
+	if window <= 0 {
+		return events
+	}
+	start := time.Now().Add(-window)
+	for i, event := range events {
+		if !event.Timestamp.Before(start) {
+			return events[i:]
+		}
+	}
+	return nil
+}
+
+// countChains counts the chains, or whether chainID is among them
+func countChains(chains []string, chainID string) int {
+	if chainID == "" {
+		return len(chains)
+	}
+	for _, chain := range chains {
+		if chain == chainID {
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareOp(op string) string {
+	if op == "" {
+		return ">"
+	}
+	return op
+}
+
+func comparison(op string) (func(value, threshold float64) bool, error) {
+	switch compareOp(op) {
+	case ">":
+		return func(value, threshold float64) bool { return value > threshold }, nil
+	case ">=":
+		return func(value, threshold float64) bool { return value >= threshold }, nil
+	case "<":
+		return func(value, threshold float64) bool { return value < threshold }, nil
+	case "<=":
+		return func(value, threshold float64) bool { return value <= threshold }, nil
+	case "==":
+		return func(value, threshold float64) bool { return value == threshold }, nil
+	default:
+		return nil, fmt.Errorf("unsupported op %q", op)
+	}
+}
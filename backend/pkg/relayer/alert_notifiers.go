@@ -0,0 +1,228 @@
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"kelo-backend/pkg/utils"
+)
+
+// notifyTimeout bounds a single notification
+const notifyTimeout = 10 * time.Second
+
+// Alert notification states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertNotification is what notifier templates render: the alert and whether it is
+// firing or resolved
+type AlertNotification struct {
+	*Alert
+	Status string `json:"status"`
+}
+
+func newAlertNotification(alert *Alert) *AlertNotification {
+	status := AlertFiring
+	if alert.Resolved {
+		status = AlertResolved
+	}
+	return &AlertNotification{Alert: alert, Status: status}
+}
+
+// summary is the one-line description of the notification used by chat and email
+func (n *AlertNotification) summary() string {
+	return fmt.Sprintf("[%s] %s %s: %s", strings.ToUpper(n.Status), n.Severity, n.Title, n.Description)
+}
+
+// defaultWebhookTemplate posts the notification as JSON
+const defaultWebhookTemplate = `{"status": {{json .Status}}, "alert": {{json .Alert}}}`
+
+// WebhookNotifier posts alerts to a generic webhook. The body is rendered from a
+// text/template over an AlertNotification; the json function encodes a value as JSON.
+type WebhookNotifier struct {
+	url      string
+	headers  map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier. An empty body template posts the
+// notification as JSON.
+func NewWebhookNotifier(url, body string, headers map[string]string) (*WebhookNotifier, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if body == "" {
+		body = defaultWebhookTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": templateJSON}).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+
+	allHeaders := map[string]string{"Content-Type": "application/json"}
+	for key, value := range headers {
+		allHeaders[key] = value
+	}
+	return &WebhookNotifier{
+		url:      url,
+		headers:  allHeaders,
+		template: tmpl,
+		client:   &http.Client{Timeout: notifyTimeout},
+	}, nil
+}
+
+// Notify renders the template and posts it
+func (wn *WebhookNotifier) Notify(alert *Alert) error {
+	var body bytes.Buffer
+	if err := wn.template.Execute(&body, newAlertNotification(alert)); err != nil {
+		return fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return fmt.Errorf("webhook template did not render valid JSON: %s", body.String())
+	}
+	return postAlert(wn.client, wn.url, wn.headers, json.RawMessage(body.Bytes()))
+}
+
+// templateJSON encodes a value as JSON for use inside a JSON template
+func templateJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// ChatNotifier posts alerts to a Slack or Microsoft Teams incoming webhook
+type ChatNotifier struct {
+	url     string
+	payload func(*AlertNotification) interface{}
+	client  *http.Client
+}
+
+// NewSlackNotifier creates a notifier for a Slack incoming webhook, or any chat service
+// that accepts Slack's {"text": ...} payload
+func NewSlackNotifier(url string) *ChatNotifier {
+	return &ChatNotifier{url: url, payload: slackPayload, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// NewTeamsNotifier creates a notifier for a Microsoft Teams incoming webhook
+func NewTeamsNotifier(url string) *ChatNotifier {
+	return &ChatNotifier{url: url, payload: teamsPayload, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// Notify posts the alert to the chat webhook
+func (cn *ChatNotifier) Notify(alert *Alert) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	return postAlert(cn.client, cn.url, headers, cn.payload(newAlertNotification(alert)))
+}
+
+func slackPayload(n *AlertNotification) interface{} {
+	icon := ":rotating_light:"
+	if n.Status == AlertResolved {
+		icon = ":white_check_mark:"
+	}
+	return map[string]string{"text": icon + " " + n.summary()}
+}
+
+func teamsPayload(n *AlertNotification) interface{} {
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    n.summary(),
+		"themeColor": severityColor(n),
+		"title":      fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status), n.Title),
+		"text":       fmt.Sprintf("%s\n\nSeverity: %s. Raised at %s.", n.Description, n.Severity, n.Timestamp.UTC().Format(time.RFC3339)),
+	}
+}
+
+// severityColor is the card colour of a notification
+func severityColor(n *AlertNotification) string {
+	if n.Status == AlertResolved {
+		return "2EB67D"
+	}
+	switch n.Severity {
+	case SeverityCritical:
+		return "B00020"
+	case SeverityHigh:
+		return "E01E5A"
+	case SeverityMedium:
+		return "ECB22E"
+	default:
+		return "36C5F0"
+	}
+}
+
+// postAlert posts a notification body to a webhook
+func postAlert(client *http.Client, url string, headers map[string]string, body interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	_, err := utils.MakeRequest(ctx, client, http.MethodPost, url, headers, body)
+	return err
+}
+
+// SMTPConfig is the mail server and addresses of an EmailNotifier
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// EmailNotifier mails alerts through an SMTP server
+type EmailNotifier struct {
+	config SMTPConfig
+	send   func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier creates an email notifier. Port defaults to 587; the server must
+// offer STARTTLS for the credentials to be sent.
+func NewEmailNotifier(config SMTPConfig) (*EmailNotifier, error) {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("smtp host, from and to are required")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &EmailNotifier{config: config, send: smtp.SendMail}, nil
+}
+
+// Notify mails the alert
+func (en *EmailNotifier) Notify(alert *Alert) error {
+	var auth smtp.Auth
+	if en.config.Username != "" {
+		auth = smtp.PlainAuth("", en.config.Username, en.config.Password, en.config.Host)
+	}
+	addr := net.JoinHostPort(en.config.Host, strconv.Itoa(en.config.Port))
+	return en.send(addr, auth, en.config.From, en.config.To, en.message(newAlertNotification(alert)))
+}
+
+// message builds the plain-text email of a notification
+func (en *EmailNotifier) message(n *AlertNotification) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", en.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(en.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [Kelo relayer] %s %s %s\r\n", strings.ToUpper(n.Status), n.Severity, n.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "%s\r\n\r\n", n.Description)
+	fmt.Fprintf(&msg, "Alert:    %s\r\n", n.Title)
+	fmt.Fprintf(&msg, "Severity: %s\r\n", n.Severity)
+	fmt.Fprintf(&msg, "Status:   %s\r\n", n.Status)
+	fmt.Fprintf(&msg, "Raised:   %s\r\n", n.Timestamp.UTC().Format(time.RFC3339))
+	if n.Resolved {
+		fmt.Fprintf(&msg, "Resolved: %s\r\n", n.ResolvedAt.UTC().Format(time.RFC3339))
+	}
+	return msg.Bytes()
+}
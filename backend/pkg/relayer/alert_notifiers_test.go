@@ -0,0 +1,231 @@
+package relayer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer records the JSON bodies and headers posted to it
+func webhookServer(t *testing.T) (*httptest.Server, chan map[string]interface{}, chan http.Header) {
+	bodies := make(chan map[string]interface{}, 10)
+	headers := make(chan http.Header, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &body))
+		bodies <- body
+		headers <- r.Header
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies, headers
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case value := <-ch:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a notification")
+		var zero T
+		return zero
+	}
+}
+
+// recordingNotifier records the alerts it is sent
+type recordingNotifier struct {
+	alerts []*Alert
+}
+
+func (rn *recordingNotifier) Notify(alert *Alert) error {
+	rn.alerts = append(rn.alerts, alert)
+	return nil
+}
+
+func testAlert(rule string, severity AlertSeverity) *Alert {
+	return &Alert{
+		ID:          generateAlertID(),
+		Severity:    severity,
+		Title:       rule,
+		Description: "Something is wrong",
+		Timestamp:   time.Now(),
+		Metadata:    map[string]interface{}{"rule_name": rule},
+	}
+}
+
+func TestAlertNotifiers_Payloads(t *testing.T) {
+	server, bodies, headers := webhookServer(t)
+	alert := testAlert("circuit_open", SeverityHigh)
+
+	webhook, err := NewWebhookNotifier(server.URL, `{"rule": {{json .Title}}, "state": {{json .Status}}, "severity": {{json .Severity}}}`, map[string]string{"Authorization": "Bearer token"})
+	require.NoError(t, err)
+	require.NoError(t, webhook.Notify(alert))
+	assert.Equal(t, map[string]interface{}{"rule": "circuit_open", "state": "firing", "severity": "HIGH"}, receive(t, bodies))
+	header := receive(t, headers)
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+
+	_, err = NewWebhookNotifier(server.URL, `{{.Missing`, nil)
+	assert.Error(t, err)
+	broken, err := NewWebhookNotifier(server.URL, `{"rule": {{.Title}}}`, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, broken.Notify(alert), "valid JSON")
+
+	require.NoError(t, NewSlackNotifier(server.URL).Notify(alert))
+	assert.Equal(t, ":rotating_light: [FIRING] HIGH circuit_open: Something is wrong", receive(t, bodies)["text"])
+	<-headers
+
+	resolved := *alert
+	resolved.Resolved = true
+	resolved.ResolvedAt = time.Now()
+	require.NoError(t, NewTeamsNotifier(server.URL).Notify(&resolved))
+	card := receive(t, bodies)
+	assert.Equal(t, "MessageCard", card["@type"])
+	assert.Equal(t, "[RESOLVED] circuit_open", card["title"])
+	assert.Equal(t, "2EB67D", card["themeColor"])
+}
+
+func TestEmailNotifier_SendsMail(t *testing.T) {
+	_, err := NewEmailNotifier(SMTPConfig{Host: "smtp.example.com"})
+	assert.Error(t, err)
+
+	notifier, err := NewEmailNotifier(SMTPConfig{
+		Host:     "smtp.example.com",
+		Username: "relayer",
+		Password: "secret",
+		From:     "relayer@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+	})
+	require.NoError(t, err)
+
+	var sentAddr string
+	var sentTo []string
+	var sentMsg string
+	notifier.send = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		assert.NotNil(t, auth)
+		sentAddr, sentTo, sentMsg = addr, to, string(msg)
+		return nil
+	}
+	require.NoError(t, notifier.Notify(testAlert("health_check_failing", SeverityCritical)))
+
+	assert.Equal(t, "smtp.example.com:587", sentAddr)
+	assert.Equal(t, []string{"ops@example.com", "oncall@example.com"}, sentTo)
+	assert.Contains(t, sentMsg, "Subject: [Kelo relayer] FIRING CRITICAL health_check_failing\r\n")
+	assert.Contains(t, sentMsg, "To: ops@example.com, oncall@example.com\r\n")
+	assert.Contains(t, sentMsg, "Something is wrong")
+}
+
+func TestAlertRouter_RoutesDedupsAndSilences(t *testing.T) {
+	pager, chat := &recordingNotifier{}, &recordingNotifier{}
+	high := SeverityHigh
+	now := time.Now()
+	router, err := NewAlertRouter(
+		map[string]AlertNotifier{"pager": pager, "chat": chat},
+		[]AlertRoute{
+			{MinSeverity: &high, Notifiers: []string{"pager"}, SendResolved: true},
+			{Notifiers: []string{"chat"}},
+		},
+		[]AlertSilence{{Rules: []string{"noisy"}, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}},
+		10*time.Minute,
+	)
+	require.NoError(t, err)
+	router.now = func() time.Time { return now }
+
+	// Low severity only reaches chat, and resolving it notifies nobody
+	low := testAlert("high_failure_rate", SeverityLow)
+	require.NoError(t, router.Notify(low))
+	low.Resolved = true
+	require.NoError(t, router.Notify(low))
+	assert.Len(t, chat.alerts, 1)
+	assert.Empty(t, pager.alerts)
+
+	// High severity reaches both; only the pager asked for the resolution
+	critical := testAlert("circuit_open", SeverityCritical)
+	require.NoError(t, router.Notify(critical))
+	resolved := *critical
+	resolved.Resolved = true
+	require.NoError(t, router.Notify(&resolved))
+	assert.Len(t, chat.alerts, 2)
+	require.Len(t, pager.alerts, 2)
+	assert.True(t, pager.alerts[1].Resolved)
+
+	// The rule fires again within the dedup window after it resolved, which is news,
+	// but repeating that is not until the window passes
+	require.NoError(t, router.Notify(testAlert("circuit_open", SeverityCritical)))
+	assert.Len(t, pager.alerts, 3)
+	require.NoError(t, router.Notify(testAlert("circuit_open", SeverityCritical)))
+	assert.Len(t, pager.alerts, 3)
+	now = now.Add(11 * time.Minute)
+	require.NoError(t, router.Notify(testAlert("circuit_open", SeverityCritical)))
+	assert.Len(t, pager.alerts, 4)
+
+	// Silenced rules notify nobody
+	require.NoError(t, router.Notify(testAlert("noisy", SeverityCritical)))
+	assert.Len(t, pager.alerts, 4)
+	assert.Len(t, chat.alerts, 4)
+
+	_, err = NewAlertRouter(map[string]AlertNotifier{}, []AlertRoute{{Notifiers: []string{"missing"}}}, nil, 0)
+	assert.Error(t, err)
+}
+
+func TestTrustedRelayer_AlertRulesFromFile(t *testing.T) {
+	server, bodies, _ := webhookServer(t)
+	t.Setenv("ALERT_WEBHOOK_URL", server.URL)
+
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"notifiers": [{"name": "ops", "type": "webhook", "url": "${ALERT_WEBHOOK_URL}"}],
+		"routes": [{"min_severity": "MEDIUM", "notifiers": ["ops"], "send_resolved": true}],
+		"rules": [
+			{
+				"name": "ethereum_errors",
+				"severity": "HIGH",
+				"message": "The last event on ethereum is an error",
+				"condition": {"metric": "events", "event_type": "ERROR_OCCURRED", "chain_id": "ethereum", "last": 1, "op": ">=", "threshold": 1}
+			},
+			{"name": "high_failure_rate", "severity": "LOW", "enabled": false, "condition": {"metric": "failure_rate", "threshold": 0.5}}
+		]
+	}`), 0o600))
+
+	config, err := LoadAlertConfig(path)
+	require.NoError(t, err)
+	relayer := newTestRelayer(t)
+	relayer.registerAlertRules()
+	require.NoError(t, relayer.configureAlerts(config))
+
+	rules := relayer.monitor.alertManager.rules
+	require.Len(t, rules, 5)
+	for _, rule := range rules {
+		if rule.Name == "high_failure_rate" {
+			assert.False(t, rule.Enabled)
+		}
+	}
+
+	relayer.monitor.RecordEvent(&Event{ID: generateEventID(), Type: EventTypeErrorOccurred, ChainID: "ethereum", Timestamp: time.Now()})
+	firing := receive(t, bodies)
+	assert.Equal(t, "firing", firing["status"])
+	assert.Equal(t, "ethereum_errors", firing["alert"].(map[string]interface{})["title"])
+
+	relayer.monitor.RecordEvent(&Event{ID: generateEventID(), Type: EventTypeMessageProcessed, ChainID: "ethereum", Timestamp: time.Now()})
+	assert.Equal(t, "resolved", receive(t, bodies)["status"])
+
+	for _, invalid := range []AlertConfig{
+		{Rules: []AlertRuleSpec{{Name: "x", Condition: AlertConditionSpec{Metric: "latency"}}}},
+		{Rules: []AlertRuleSpec{{Name: "x", Condition: AlertConditionSpec{Metric: "events", Op: "!="}}}},
+		{Notifiers: []NotifierSpec{{Name: "x", Type: "pager"}}},
+		{DedupWindow: "soon"},
+	} {
+		assert.Error(t, newTestRelayer(t).configureAlerts(&invalid))
+	}
+}
@@ -0,0 +1,182 @@
+package relayer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AlertRoute sends the alerts of some severities to a set of notifiers
+type AlertRoute struct {
+	// MinSeverity and Severities select the alerts the route takes. A route that sets
+	// neither takes every alert.
+	MinSeverity *AlertSeverity  `json:"min_severity,omitempty"`
+	Severities  []AlertSeverity `json:"severities,omitempty"`
+	// Notifiers are the names of the notifiers the alerts go to
+	Notifiers []string `json:"notifiers"`
+	// SendResolved also sends a notification when the alert resolves
+	SendResolved bool `json:"send_resolved"`
+}
+
+// matches reports whether the route takes alerts of the severity
+func (r *AlertRoute) matches(severity AlertSeverity) bool {
+	if r.MinSeverity != nil && severity < *r.MinSeverity {
+		return false
+	}
+	if len(r.Severities) == 0 {
+		return true
+	}
+	for _, s := range r.Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertSilence mutes the notifications of some rules, or of every rule, for a window
+type AlertSilence struct {
+	// Rules are the names of the silenced rules; empty silences every rule
+	Rules    []string  `json:"rules,omitempty"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Comment  string    `json:"comment,omitempty"`
+}
+
+// mutes reports whether the silence mutes the rule at the time
+func (s *AlertSilence) mutes(rule string, at time.Time) bool {
+	if at.Before(s.StartsAt) || !at.Before(s.EndsAt) {
+		return false
+	}
+	if len(s.Rules) == 0 {
+		return true
+	}
+	for _, name := range s.Rules {
+		if name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertRouter is an AlertNotifier that passes each alert on to the notifiers of the
+// routes that take its severity. It drops a notification that repeats the rule's last
+// one within the dedup window, mutes silenced rules and only sends a resolved
+// notification to the notifiers that were told the alert fired. A rule that fires
+// again after its resolution went out is not a repeat and is always sent.
+type AlertRouter struct {
+	mu          sync.Mutex
+	notifiers   map[string]AlertNotifier
+	routes      []AlertRoute
+	silences    []AlertSilence
+	dedupWindow time.Duration
+	lastSent    map[string]time.Time       // by rule and status
+	fired       map[string]map[string]bool // notifiers told each alert fired, by alert ID
+	now         func() time.Time
+}
+
+// NewAlertRouter creates a router over named notifiers. Every notifier a route names
+// must exist.
+func NewAlertRouter(notifiers map[string]AlertNotifier, routes []AlertRoute, silences []AlertSilence, dedupWindow time.Duration) (*AlertRouter, error) {
+	for i, route := range routes {
+		if len(route.Notifiers) == 0 {
+			return nil, fmt.Errorf("alert route %d has no notifiers", i)
+		}
+		for _, name := range route.Notifiers {
+			if _, ok := notifiers[name]; !ok {
+				return nil, fmt.Errorf("alert route %d: unknown notifier %q", i, name)
+			}
+		}
+	}
+	for i, silence := range silences {
+		if !silence.EndsAt.After(silence.StartsAt) {
+			return nil, fmt.Errorf("alert silence %d ends before it starts", i)
+		}
+	}
+	return &AlertRouter{
+		notifiers:   notifiers,
+		routes:      routes,
+		silences:    silences,
+		dedupWindow: dedupWindow,
+		lastSent:    make(map[string]time.Time),
+		fired:       make(map[string]map[string]bool),
+		now:         time.Now,
+	}, nil
+}
+
+// Notify sends the alert to the notifiers of its routes
+func (ar *AlertRouter) Notify(alert *Alert) error {
+	targets := ar.targets(alert)
+	var errs []error
+	for _, name := range targets {
+		if err := ar.notifiers[name].Notify(alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// targets picks the notifiers the alert goes to and records that it was sent
+func (ar *AlertRouter) targets(alert *Alert) []string {
+	rule := alertRuleName(alert)
+	status := newAlertNotification(alert).Status
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	now := ar.now()
+	for i := range ar.silences {
+		if ar.silences[i].mutes(rule, now) {
+			log.Info().Str("alert_id", alert.ID).Str("rule_name", rule).Str("status", status).Msg("Alert notification silenced")
+			return nil
+		}
+	}
+	key := rule + "/" + status
+	if last, ok := ar.lastSent[key]; ok && now.Sub(last) < ar.dedupWindow {
+		log.Debug().Str("alert_id", alert.ID).Str("rule_name", rule).Str("status", status).Msg("Duplicate alert notification dropped")
+		return nil
+	}
+
+	fired := ar.fired[alert.ID]
+	seen := make(map[string]bool)
+	var targets []string
+	for i := range ar.routes {
+		route := &ar.routes[i]
+		if !route.matches(alert.Severity) || (alert.Resolved && !route.SendResolved) {
+			continue
+		}
+		for _, name := range route.Notifiers {
+			if seen[name] || (alert.Resolved && !fired[name]) {
+				continue
+			}
+			seen[name] = true
+			targets = append(targets, name)
+		}
+	}
+
+	if alert.Resolved {
+		delete(ar.fired, alert.ID)
+	} else if len(targets) > 0 {
+		ar.fired[alert.ID] = seen
+	}
+	if len(targets) > 0 {
+		ar.lastSent[key] = now
+		// The rule changed state, so its next notification of the other status is news
+		opposite := AlertResolved
+		if alert.Resolved {
+			opposite = AlertFiring
+		}
+		delete(ar.lastSent, rule+"/"+opposite)
+	}
+	return targets
+}
+
+// alertRuleName returns the name of the rule that raised the alert
+func alertRuleName(alert *Alert) string {
+	if name, ok := alert.Metadata["rule_name"].(string); ok {
+		return name
+	}
+	return alert.Title
+}
@@ -0,0 +1,272 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"kelo-backend/pkg/config"
+)
+
+var (
+	// ErrNoEligiblePool is returned when no pool can fund a loan.
+	ErrNoEligiblePool = errors.New("no eligible pool for loan")
+	// ErrPoolOvercommitted is returned when recording an allocation would lend out more
+	// than the pool has free, because another allocation took the liquidity first.
+	ErrPoolOvercommitted = errors.New("pool has insufficient liquidity")
+)
+
+// PoolState is a snapshot of a liquidity pool that can fund loans.
+type PoolState struct {
+	PoolID         string  `json:"pool_id"`
+	ChainID        string  `json:"chain_id"`
+	Asset          string  `json:"asset"`
+	TotalLiquidity float64 `json:"total_liquidity"`
+	Borrowed       float64 `json:"borrowed"`
+	UtilizationCap float64 `json:"utilization_cap"`
+	Priority       int     `json:"priority"`
+}
+
+// Available returns the liquidity that has not been lent out.
+func (p *PoolState) Available() float64 {
+	return p.TotalLiquidity - p.Borrowed
+}
+
+// PoolStore supplies pool snapshots and records where each loan was funded from.
+// RecordAllocation is idempotent per loan: a loan keeps the first pool recorded for it,
+// which is written back to the allocation. Both fail with ErrPoolOvercommitted when the pool
+// no longer has room for the amount. MoveAllocation moves a loan's reservation from
+// its recorded pool to the allocation's; a loan already funded from that chain keeps its pool.
+type PoolStore interface {
+	PoolStates(ctx context.Context) ([]*PoolState, error)
+	RecordAllocation(ctx context.Context, allocation *Allocation) error
+	MoveAllocation(ctx context.Context, allocation *Allocation) error
+}
+
+// AllocationRequest describes a loan that needs a funding pool.
+type AllocationRequest struct {
+	LoanID  string
+	Asset   string
+	Amount  float64
+	ChainID string // only considers pools on this chain when set
+}
+
+// Allocation is the pool chosen to fund a loan.
+type Allocation struct {
+	LoanID      string    `json:"loan_id"`
+	PoolID      string    `json:"pool_id"`
+	ChainID     string    `json:"chain_id"`
+	Asset       string    `json:"asset"`
+	Amount      float64   `json:"amount"`
+	Score       float64   `json:"score"`
+	AllocatedAt time.Time `json:"allocated_at"`
+}
+
+// AllocationWeights controls how much each factor contributes to a pool's score.
+type AllocationWeights struct {
+	Liquidity   float64
+	Utilization float64
+	GasCost     float64
+	Priority    float64
+}
+
+// DefaultAllocationWeights returns the weights used by NewAllocationEngine.
+func DefaultAllocationWeights() AllocationWeights {
+	return AllocationWeights{
+		Liquidity:   0.35,
+		Utilization: 0.25,
+		GasCost:     0.20,
+		Priority:    0.20,
+	}
+}
+
+// maxAllocationAttempts bounds how often a loan is allocated again after a concurrent
+// allocation took its pool's liquidity
+const maxAllocationAttempts = 3
+
+// defaultUtilizationCap applies to pools that do not set their own cap.
+const defaultUtilizationCap = 0.9
+
+// AllocationEngine picks exactly one pool to fund each approved loan.
+type AllocationEngine struct {
+	store        PoolStore
+	chainConfigs map[string]*ChainConfig
+	weights      AllocationWeights
+	controls     *RelayerControls
+}
+
+// NewAllocationEngine creates a new allocation engine
+func NewAllocationEngine(store PoolStore, chainConfigs map[string]*ChainConfig) *AllocationEngine {
+	return &AllocationEngine{
+		store:        store,
+		chainConfigs: chainConfigs,
+		weights:      DefaultAllocationWeights(),
+	}
+}
+
+// SetWeights overrides the scoring weights.
+func (ae *AllocationEngine) SetWeights(weights AllocationWeights) {
+	ae.weights = weights
+}
+
+// SetControls applies the operators' enabled overrides to the chains allocated to.
+func (ae *AllocationEngine) SetControls(controls *RelayerControls) {
+	ae.controls = controls
+}
+
+// candidate is an eligible pool together with the inputs to its score.
+type candidate struct {
+	pool           *PoolState
+	postUtil       float64
+	utilizationCap float64
+	gasCost        gasCost
+}
+
+// Allocate scores every eligible pool and returns the best one. A pool is eligible when its
+// chain is enabled, it holds the requested asset, it has enough free liquidity and funding
+// the loan would keep it under its utilization cap.
+func (ae *AllocationEngine) Allocate(ctx context.Context, req AllocationRequest) (*Allocation, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("allocation amount must be positive")
+	}
+
+	pools, err := ae.store.PoolStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool states: %w", err)
+	}
+
+	var candidates []candidate
+	var maxAvailable float64
+	maxGasCost := make(map[string]float64) // by unit
+	maxPriority := 0
+	for _, pool := range pools {
+		if req.ChainID != "" && pool.ChainID != req.ChainID {
+			continue
+		}
+		chain, ok := ae.chainConfigs[pool.ChainID]
+		if !ok || !ae.controls.Enabled(pool.ChainID, chain) {
+			continue
+		}
+		if req.Asset != "" && pool.Asset != req.Asset {
+			continue
+		}
+		if pool.TotalLiquidity <= 0 || pool.Available() < req.Amount {
+			continue
+		}
+
+		utilizationCap := pool.UtilizationCap
+		if utilizationCap <= 0 {
+			utilizationCap = defaultUtilizationCap
+		}
+		postUtil := (pool.Borrowed + req.Amount) / pool.TotalLiquidity
+		if postUtil > utilizationCap {
+			continue
+		}
+
+		c := candidate{
+			pool:           pool,
+			postUtil:       postUtil,
+			utilizationCap: utilizationCap,
+			gasCost:        estimateGasCost(chain),
+		}
+		candidates = append(candidates, c)
+
+		if pool.Available() > maxAvailable {
+			maxAvailable = pool.Available()
+		}
+		if c.gasCost.amount > maxGasCost[c.gasCost.unit] {
+			maxGasCost[c.gasCost.unit] = c.gasCost.amount
+		}
+		if pool.Priority > maxPriority {
+			maxPriority = pool.Priority
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w %s: %v %s", ErrNoEligiblePool, req.LoanID, req.Amount, req.Asset)
+	}
+
+	scores := make(map[string]float64, len(candidates))
+	for _, c := range candidates {
+		// Every factor is normalised to [0, 1] so the weights are comparable.
+		liquidity := (c.pool.Available() - req.Amount) / maxAvailable
+		utilization := 1 - c.postUtil/c.utilizationCap
+		gas := 1.0
+		if max := maxGasCost[c.gasCost.unit]; max > 0 {
+			gas = 1 - c.gasCost.amount/max
+		}
+		priority := 0.0
+		if maxPriority > 0 {
+			priority = float64(c.pool.Priority) / float64(maxPriority)
+		}
+
+		scores[c.pool.PoolID] = ae.weights.Liquidity*liquidity +
+			ae.weights.Utilization*utilization +
+			ae.weights.GasCost*gas +
+			ae.weights.Priority*priority
+	}
+
+	// Highest score wins; ties go to the pool with more free liquidity, then by pool ID so
+	// the choice is deterministic.
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].pool, candidates[j].pool
+		if scores[a.PoolID] != scores[b.PoolID] {
+			return scores[a.PoolID] > scores[b.PoolID]
+		}
+		if a.Available() != b.Available() {
+			return a.Available() > b.Available()
+		}
+		return a.PoolID < b.PoolID
+	})
+
+	best := candidates[0].pool
+	return &Allocation{
+		LoanID:      req.LoanID,
+		PoolID:      best.PoolID,
+		ChainID:     best.ChainID,
+		Asset:       best.Asset,
+		Amount:      req.Amount,
+		Score:       scores[best.PoolID],
+		AllocatedAt: time.Now(),
+	}, nil
+}
+
+// gasCost is a chain's configured worst-case fee for a disbursement. Fees are only ranked
+// against fees in the same unit.
+type gasCost struct {
+	unit   string // "usd", or the chain type when the fee is in whole native coins
+	amount float64
+}
+
+// estimateGasCost returns the configured worst-case fee for a disbursement on the chain.
+// It is priced in USD when the chain's native coin has a price, so chains of every type
+// are compared; otherwise it is in whole native coins and only compared with chains of
+// the same type.
+func estimateGasCost(chain *ChainConfig) gasCost {
+	chainType := chain.Type
+	if chainType == "" {
+		chainType = config.ChainTypeEVM
+	}
+	if chain.GasPrice == nil || chain.GasLimit == 0 {
+		return gasCost{unit: chainType}
+	}
+	fee := new(big.Int).Mul(chain.GasPrice, new(big.Int).SetUint64(chain.GasLimit))
+	coins := tokenAmount(fee, nativeDecimals(chainType))
+	if chain.NativePriceUSD > 0 {
+		return gasCost{unit: "usd", amount: coins * chain.NativePriceUSD}
+	}
+	return gasCost{unit: chainType, amount: coins}
+}
+
+// tokenAmount converts an on-chain integer amount into whole asset units.
+func tokenAmount(amount *big.Int, decimals int) float64 {
+	if amount == nil {
+		return 0
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), scale).Float64()
+	return f
+}
@@ -0,0 +1,143 @@
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePoolStore is an in-memory PoolStore for tests.
+type fakePoolStore struct {
+	pools    []*PoolState
+	recorded []*Allocation
+	taken    map[string]bool // pools another allocation fills before the next is recorded
+}
+
+func (f *fakePoolStore) PoolStates(ctx context.Context) ([]*PoolState, error) {
+	return f.pools, nil
+}
+
+func (f *fakePoolStore) RecordAllocation(ctx context.Context, allocation *Allocation) error {
+	for _, recorded := range f.recorded {
+		if recorded.LoanID == allocation.LoanID {
+			allocation.PoolID = recorded.PoolID
+			allocation.ChainID = recorded.ChainID
+			return nil
+		}
+	}
+	if f.taken[allocation.PoolID] {
+		for _, pool := range f.pools {
+			if pool.PoolID == allocation.PoolID {
+				pool.Borrowed = pool.TotalLiquidity
+			}
+		}
+		return ErrPoolOvercommitted
+	}
+	f.recorded = append(f.recorded, allocation)
+	return nil
+}
+
+func (f *fakePoolStore) MoveAllocation(ctx context.Context, allocation *Allocation) error {
+	for i, recorded := range f.recorded {
+		if recorded.LoanID != allocation.LoanID {
+			continue
+		}
+		if recorded.ChainID == allocation.ChainID {
+			allocation.PoolID = recorded.PoolID
+			return nil
+		}
+		f.recorded[i] = allocation
+		return nil
+	}
+	return fmt.Errorf("loan %s has no allocation", allocation.LoanID)
+}
+
+func testChainConfigs() map[string]*ChainConfig {
+	return map[string]*ChainConfig{
+		"ethereum": {Enabled: true, GasLimit: 500000, GasPrice: big.NewInt(20000000000)},
+		"base":     {Enabled: true, GasLimit: 500000, GasPrice: big.NewInt(1000000000)},
+		"solana":   {Enabled: false},
+	}
+}
+
+func TestAllocationEngine_PrefersCheaperChainWithEqualLiquidity(t *testing.T) {
+	store := &fakePoolStore{pools: []*PoolState{
+		{PoolID: "eth", ChainID: "ethereum", Asset: "USDC", TotalLiquidity: 10000},
+		{PoolID: "base", ChainID: "base", Asset: "USDC", TotalLiquidity: 10000},
+	}}
+	engine := NewAllocationEngine(store, testChainConfigs())
+
+	allocation, err := engine.Allocate(context.Background(), AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 500})
+	require.NoError(t, err)
+	assert.Equal(t, "base", allocation.ChainID)
+	assert.Equal(t, "base", allocation.PoolID)
+}
+
+func TestAllocationEngine_ComparesGasInUSDAcrossChainTypes(t *testing.T) {
+	store := &fakePoolStore{pools: []*PoolState{
+		{PoolID: "arb", ChainID: "arbitrum", Asset: "USDC", TotalLiquidity: 10000},
+		{PoolID: "aptos", ChainID: "aptos", Asset: "USDC", TotalLiquidity: 10000},
+	}}
+	chains := map[string]*ChainConfig{
+		// 0.000005 ETH at $3000 is $0.015
+		"arbitrum": {Enabled: true, Type: config.ChainTypeEVM, GasLimit: 500000, GasPrice: big.NewInt(10000000), NativePriceUSD: 3000},
+		// 0.2 APT at $10 is $2, though far fewer base units than the Arbitrum fee
+		"aptos": {Enabled: true, Type: config.ChainTypeAptos, GasLimit: 200000, GasPrice: big.NewInt(100), NativePriceUSD: 10},
+	}
+	engine := NewAllocationEngine(store, chains)
+	engine.SetWeights(AllocationWeights{GasCost: 1})
+
+	allocation, err := engine.Allocate(context.Background(), AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 500})
+	require.NoError(t, err)
+	assert.Equal(t, "arb", allocation.PoolID)
+}
+
+func TestAllocationEngine_Eligibility(t *testing.T) {
+	store := &fakePoolStore{pools: []*PoolState{
+		// Disabled chain
+		{PoolID: "sol", ChainID: "solana", Asset: "USDC", TotalLiquidity: 1000000},
+		// Wrong asset
+		{PoolID: "base_usdt", ChainID: "base", Asset: "USDT", TotalLiquidity: 1000000},
+		// Would breach its utilization cap
+		{PoolID: "base_capped", ChainID: "base", Asset: "USDC", TotalLiquidity: 10000, Borrowed: 8000, UtilizationCap: 0.85},
+		// Only eligible pool
+		{PoolID: "eth", ChainID: "ethereum", Asset: "USDC", TotalLiquidity: 10000, Borrowed: 2000},
+	}}
+	engine := NewAllocationEngine(store, testChainConfigs())
+
+	allocation, err := engine.Allocate(context.Background(), AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, "eth", allocation.PoolID)
+
+	_, err = engine.Allocate(context.Background(), AllocationRequest{LoanID: "2", Asset: "USDC", Amount: 9000})
+	assert.ErrorIs(t, err, ErrNoEligiblePool)
+}
+
+func TestAllocationEngine_Priority(t *testing.T) {
+	store := &fakePoolStore{pools: []*PoolState{
+		{PoolID: "eth", ChainID: "ethereum", Asset: "USDC", TotalLiquidity: 10000, Priority: 10},
+		{PoolID: "base", ChainID: "base", Asset: "USDC", TotalLiquidity: 10000},
+	}}
+	engine := NewAllocationEngine(store, testChainConfigs())
+	engine.SetWeights(AllocationWeights{Liquidity: 0.1, Utilization: 0.1, GasCost: 0.1, Priority: 0.7})
+
+	allocation, err := engine.Allocate(context.Background(), AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 500})
+	require.NoError(t, err)
+	assert.Equal(t, "eth", allocation.PoolID)
+
+	// A chain restriction overrides the score
+	allocation, err = engine.Allocate(context.Background(), AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 500, ChainID: "base"})
+	require.NoError(t, err)
+	assert.Equal(t, "base", allocation.PoolID)
+}
+
+func TestTokenAmount(t *testing.T) {
+	assert.Equal(t, 1.5, tokenAmount(big.NewInt(1500000), 6))
+	assert.Equal(t, 0.0, tokenAmount(nil, 6))
+}
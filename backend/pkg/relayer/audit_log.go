@@ -0,0 +1,98 @@
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
+)
+
+// AuditEntry records an action an operator took on the relayer.
+type AuditEntry struct {
+	ID           string                 `json:"id"`
+	Actor        string                 `json:"actor"`  // user ID of the operator
+	Action       string                 `json:"action"` // e.g. dead_letter.replay
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// AuditLog is an append-only record of operator actions.
+type AuditLog interface {
+	Record(ctx context.Context, entry *AuditEntry) error
+}
+
+// MemoryAuditLog is an in-process AuditLog for tests and development.
+type MemoryAuditLog struct {
+	mu      sync.Mutex
+	entries []*AuditEntry
+}
+
+// NewMemoryAuditLog creates an empty in-memory audit log
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (l *MemoryAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recorded := *entry
+	l.entries = append(l.entries, &recorded)
+	return nil
+}
+
+// Entries returns the recorded entries, oldest first.
+func (l *MemoryAuditLog) Entries() []*AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*AuditEntry(nil), l.entries...)
+}
+
+// SupabaseAuditLog appends entries to the relayer_audit_log table
+type SupabaseAuditLog struct {
+	db *supabase.Client
+}
+
+// NewSupabaseAuditLog creates a new Supabase-backed audit log
+func NewSupabaseAuditLog(db *supabase.Client) *SupabaseAuditLog {
+	return &SupabaseAuditLog{db: db}
+}
+
+func (l *SupabaseAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	_, _, err := l.db.From("relayer_audit_log").Insert(entry, false, "", "", "").Execute()
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// recordAudit writes an operator action to the audit log. Actions are audited before
+// they are applied, so an action that cannot be audited is not applied.
+func (tr *TrustedRelayer) recordAudit(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error {
+	entry := &AuditEntry{
+		ID:           uuid.NewString(),
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Details:      details,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := tr.audit.Record(ctx, entry); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("actor", actor).
+		Str("action", action).
+		Str("resource_type", resourceType).
+		Str("resource_id", resourceID).
+		Interface("details", details).
+		Msg("Operator action")
+	return nil
+}
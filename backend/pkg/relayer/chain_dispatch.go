@@ -0,0 +1,317 @@
+package relayer
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"math/big"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dispatchMessage sends a message to its destination chain and returns the transaction
+// hash. EVM chains are reached through LayerZero; the Solana and Aptos pools are called
+// directly by the relayer. Either way dispatchMessage returns once the transaction is
+// broadcast and reconcileSent confirms it from its receipt.
+func (tr *TrustedRelayer) dispatchMessage(ctx context.Context, message *Message) (string, error) {
+	chain, ok := tr.chainConfigs[message.ChainID]
+	if !ok {
+		return "", fmt.Errorf("unsupported chain ID: %s", message.ChainID)
+	}
+
+	signed, err := tr.signMessage(message, chain)
+	if err != nil {
+		return "", err
+	}
+
+	message.TxChainID = tr.txChainID(message.ChainID, chain)
+	message.TxExpiry = 0
+	message.TxNonce = nil
+	switch chain.Type {
+	case config.ChainTypeSolana:
+		return tr.sendSolanaDisbursement(ctx, message, chain)
+	case config.ChainTypeAptos:
+		return tr.sendAptosDisbursement(ctx, message, chain)
+	default:
+		lzMessage, err := tr.layerZeroMessage(message, chain, signed)
+		if err != nil {
+			return "", err
+		}
+		result, err := tr.layerZeroClient.Send(ctx, lzMessage)
+		if err != nil {
+			return "", err
+		}
+		// Provisional until reconcileSent reads the endpoint's GUID from the receipt
+		message.LayerZeroGUID = result.GUID
+		return result.TxHash, nil
+	}
+}
+
+// layerZeroMessage builds the LayerZero message that delivers a signed message to an EVM
+// chain's receiver
+func (tr *TrustedRelayer) layerZeroMessage(message *Message, chain *ChainConfig, signed *SignedMessage) (*LayerZeroMessage, error) {
+	dstEID, err := tr.getLayerZeroChainID(message.ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get LayerZero chain ID: %w", err)
+	}
+	gas := tr.controls.GasLimit(message.ChainID, chain)
+	if message.GasLimit != 0 {
+		gas = message.GasLimit
+	}
+	if gas == 0 {
+		gas = defaultLzReceiveGas
+	}
+	// The receiver checks the relayer's signature before acting on the payload
+	payload, err := signed.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LayerZeroMessage{
+		DstEID:   dstEID,
+		Receiver: chain.LayerZeroReceiver,
+		Payload:  payload,
+		Options:  ExecutorLzReceiveOption(gas, nil),
+		Source:   message,
+	}, nil
+}
+
+// txChainID returns the chain a message to chainID is submitted on. LayerZero messages
+// are submitted on the source chain.
+func (tr *TrustedRelayer) txChainID(chainID string, chain *ChainConfig) string {
+	switch chain.Type {
+	case config.ChainTypeSolana, config.ChainTypeAptos:
+		return chainID
+	default:
+		return tr.config.LayerZeroSourceChain
+	}
+}
+
+// submitTransaction builds, signs and sends a transaction through the chain's adapter
+// and returns the sent transaction. Sequence numbers on chains that use them come from
+// the nonce manager.
+func (tr *TrustedRelayer) submitTransaction(ctx context.Context, chainID string, req *blockchain.TxRequest, key crypto.Signer) (*blockchain.Transaction, error) {
+	if tr.chains == nil {
+		return nil, fmt.Errorf("%s is not configured", chainID)
+	}
+	adapter, err := tr.chains.Adapter(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Solana transactions are ordered by recent blockhash, not by nonce
+	if tr.nonces == nil || adapter.Spec().Type == config.ChainTypeSolana {
+		return tr.sendTransaction(ctx, adapter, req, key)
+	}
+
+	for attempt := 0; ; attempt++ {
+		nonce, err := tr.nonces.Allocate(ctx, chainID, req.From)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate nonce: %w", err)
+		}
+		withNonce := *req
+		withNonce.Nonce = &nonce
+
+		tx, err := tr.sendTransaction(ctx, adapter, &withNonce, key)
+		if err == nil {
+			tr.nonces.Confirm(chainID, req.From, nonce)
+			return tx, nil
+		}
+		if !tr.nonces.Fail(ctx, chainID, req.From, nonce, err) || attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// sendTransaction builds, signs and sends a fully specified transaction and returns it.
+func (tr *TrustedRelayer) sendTransaction(ctx context.Context, adapter blockchain.ChainAdapter, req *blockchain.TxRequest, key crypto.Signer) (signed *blockchain.Transaction, err error) {
+	ctx, span := tracer().Start(ctx, "relayer.sendTransaction", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("chain_id", adapter.Spec().Key)))
+	defer func() {
+		if signed != nil {
+			span.SetAttributes(attribute.String("tx_hash", signed.Hash))
+		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	tx, err := adapter.BuildTransaction(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	if signed, err = adapter.SignTransaction(ctx, tx, key); err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	hash, err := adapter.SendTransaction(ctx, signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	signed.Hash = hash
+	return signed, nil
+}
+
+// decodeDisbursement validates that the message is a disbursement and decodes its payload.
+func decodeDisbursement(message *Message) (*LoanDisbursementPayload, error) {
+	if message.Type != MessageTypeLoanDisbursement {
+		return nil, fmt.Errorf("unsupported message type for %s: %s", message.ChainID, message.Type)
+	}
+
+	decoded, err := DecodePayload(message.Type, message.PayloadVersion, message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	payload := decoded.(*LoanDisbursementPayload)
+	if payload.Amount == nil || payload.Amount.Sign() <= 0 || !payload.Amount.IsUint64() {
+		return nil, fmt.Errorf("invalid disbursement amount: %v", payload.Amount)
+	}
+
+	return payload, nil
+}
+
+// sendSolanaDisbursement calls Disburse on the Kelo pool program.
+func (tr *TrustedRelayer) sendSolanaDisbursement(ctx context.Context, message *Message, chain *ChainConfig) (string, error) {
+	req, err := tr.solanaDisbursementRequest(message, chain)
+	if err != nil {
+		return "", err
+	}
+	tx, err := tr.submitTransaction(ctx, message.ChainID, req, tr.solanaKey)
+	if err != nil {
+		return "", err
+	}
+	message.TxExpiry = tx.Expiry
+	return tx.Hash, nil
+}
+
+// solanaDisbursementRequest builds the Disburse call of a disbursement message
+func (tr *TrustedRelayer) solanaDisbursementRequest(message *Message, chain *ChainConfig) (*blockchain.TxRequest, error) {
+	if tr.solanaKey == nil {
+		return nil, fmt.Errorf("solana is not configured")
+	}
+
+	payload, err := decodeDisbursement(message)
+	if err != nil {
+		return nil, err
+	}
+	// The mint may not have the loan asset's decimals
+	amount, err := convertDecimals(payload.Amount, tr.config.LoanAssetDecimals, tr.config.SolanaTokenDecimals)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsUint64() {
+		return nil, fmt.Errorf("disbursement amount %s overflows %s", amount, tr.config.SolanaTokenMint)
+	}
+
+	wallet, err := tr.recipients.ResolveRecipient(tr.ctx, message.ChainID, payload.Merchant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Solana merchant wallet: %w", err)
+	}
+	merchant, err := blockchain.SolanaPublicKeyFromBase58(wallet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Solana merchant wallet %s: %w", wallet, err)
+	}
+	programID, err := blockchain.SolanaPublicKeyFromBase58(chain.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Solana program ID: %w", err)
+	}
+	mint, err := blockchain.SolanaPublicKeyFromBase58(tr.config.SolanaTokenMint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Solana token mint: %w", err)
+	}
+
+	relayer := blockchain.SolanaPublicKeyFromPrivateKey(tr.solanaKey)
+	instructions, err := blockchain.NewSolanaDisburseInstructions(relayer, blockchain.SolanaDisburseRequest{
+		ProgramID: programID,
+		Mint:      mint,
+		Merchant:  merchant,
+		Amount:    amount.Uint64(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockchain.TxRequest{
+		From: relayer.String(),
+		Call: instructions,
+	}, nil
+}
+
+// sendAptosDisbursement calls KeloLiquidityPool::disburse.
+func (tr *TrustedRelayer) sendAptosDisbursement(ctx context.Context, message *Message, chain *ChainConfig) (string, error) {
+	req, err := tr.aptosDisbursementRequest(message, chain)
+	if err != nil {
+		return "", err
+	}
+	tx, err := tr.submitTransaction(ctx, message.ChainID, req, tr.aptosKey)
+	if err != nil {
+		return "", err
+	}
+	message.TxExpiry = tx.Expiry
+	message.TxNonce = &tx.Nonce
+	return tx.Hash, nil
+}
+
+// aptosDisbursementRequest builds the disburse call of a disbursement message
+func (tr *TrustedRelayer) aptosDisbursementRequest(message *Message, chain *ChainConfig) (*blockchain.TxRequest, error) {
+	if tr.aptosKey == nil {
+		return nil, fmt.Errorf("aptos is not configured")
+	}
+
+	payload, err := decodeDisbursement(message)
+	if err != nil {
+		return nil, err
+	}
+	// The pool's coin may not have the loan asset's decimals
+	amount, err := convertDecimals(payload.Amount, tr.config.LoanAssetDecimals, tr.config.AptosCoinDecimals)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsUint64() {
+		return nil, fmt.Errorf("disbursement amount %s overflows %s", amount, tr.config.AptosCoinType)
+	}
+
+	wallet, err := tr.recipients.ResolveRecipient(tr.ctx, message.ChainID, payload.Merchant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Aptos merchant wallet: %w", err)
+	}
+	recipient, err := blockchain.AptosAddressFromHex(wallet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Aptos merchant wallet %s: %w", wallet, err)
+	}
+	module, err := blockchain.AptosAddressFromHex(chain.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Aptos module address: %w", err)
+	}
+
+	return &blockchain.TxRequest{
+		From: blockchain.AptosAddressFromPrivateKey(tr.aptosKey).String(),
+		Call: blockchain.NewAptosDisburseFunction(blockchain.AptosDisburseRequest{
+			ModuleAddress: module,
+			CoinType:      tr.config.AptosCoinType,
+			Recipient:     recipient,
+			Amount:        amount.Uint64(),
+		}),
+	}, nil
+}
+
+// convertDecimals converts an amount in the smallest unit of a token with from decimals
+// to one with to decimals. It fails rather than round off part of the amount.
+func convertDecimals(amount *big.Int, from, to int) (*big.Int, error) {
+	if from == to {
+		return amount, nil
+	}
+	if to > from {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil)
+		return new(big.Int).Mul(amount, scale), nil
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(from-to)), nil)
+	converted, remainder := new(big.Int).QuoRem(amount, scale, new(big.Int))
+	if remainder.Sign() != 0 {
+		return nil, fmt.Errorf("amount %s has more precision than %d decimals", amount, to)
+	}
+	return converted, nil
+}
@@ -0,0 +1,689 @@
+package relayer
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
+)
+
+var (
+	// ErrUnknownChain is returned when a control targets a chain the relayer does not
+	// know.
+	ErrUnknownChain = errors.New("unknown chain")
+	// ErrInvalidControl is returned when a control's settings are out of range.
+	ErrInvalidControl = errors.New("invalid control")
+)
+
+// heldMessageRecheck is how long a message held by a paused chain or a dry run waits
+// before it is looked at again
+const heldMessageRecheck = 30 * time.Second
+
+// maxDryRunResults is the number of dry-run results kept for operators
+const maxDryRunResults = 100
+
+// ChainState is the sending state of a chain set by an operator
+type ChainState string
+
+const (
+	// ChainActive chains send as usual
+	ChainActive ChainState = "active"
+	// ChainPaused chains hold their messages in the outbox until they are resumed
+	ChainPaused ChainState = "paused"
+	// ChainDraining chains move their unsent messages to the dead-letter queue
+	ChainDraining ChainState = "draining"
+)
+
+// ChainControl is the runtime state of a chain: its sending state and the enabled flag
+// and gas limit in effect
+type ChainControl struct {
+	ChainID   string     `json:"chain_id"`
+	State     ChainState `json:"state"`
+	Reason    string     `json:"reason,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Enabled   bool       `json:"enabled"`
+	GasLimit  uint64     `json:"gas_limit"`
+}
+
+// ChainUpdate changes a chain's enabled flag or gas limit. Nil fields are left unchanged.
+type ChainUpdate struct {
+	Enabled  *bool   `json:"enabled"`
+	GasLimit *uint64 `json:"gas_limit"`
+}
+
+// DryRunResult is a transaction that was built and signed for a message but not
+// broadcast. It holds the unsigned call and the hash, never the signed transaction,
+// which anyone could broadcast.
+type DryRunResult struct {
+	MessageID     string    `json:"message_id"`
+	ChainID       string    `json:"chain_id"`
+	TxChainID     string    `json:"tx_chain_id,omitempty"`
+	TxHash        string    `json:"tx_hash,omitempty"`
+	Nonce         uint64    `json:"nonce"`
+	To            string    `json:"to,omitempty"`
+	Value         string    `json:"value,omitempty"`
+	Data          string    `json:"data,omitempty"`
+	LayerZeroGUID string    `json:"layerzero_guid,omitempty"`
+	NativeFee     string    `json:"native_fee,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ChainOverride is what operators changed about a chain. Nil fields keep the chain's
+// configured value.
+type ChainOverride struct {
+	ChainID   string     `json:"chain_id"`
+	State     ChainState `json:"state"`
+	Reason    string     `json:"reason"`
+	Enabled   *bool      `json:"enabled"`
+	GasLimit  *uint64    `json:"gas_limit"`
+	UpdatedBy string     `json:"updated_by"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// StoredControls are the overrides kept in a ControlStore
+type StoredControls struct {
+	Chains []*ChainOverride
+	DryRun bool
+}
+
+// ControlStore persists the operators' overrides, so every relayer instance applies the
+// same ones and they survive a restart. Each change writes only the fields it sets, so
+// concurrent changes to different fields of a chain do not overwrite each other.
+type ControlStore interface {
+	Load(ctx context.Context) (*StoredControls, error)
+	SetChainState(ctx context.Context, chainID string, state ChainState, reason, actor string) error
+	UpdateChain(ctx context.Context, chainID string, update ChainUpdate, actor string) error
+	SetDryRun(ctx context.Context, enabled bool, actor string) error
+}
+
+// MemoryControlStore is an in-process ControlStore for tests and single-instance
+// development. Its overrides do not survive a restart.
+type MemoryControlStore struct {
+	mu     sync.Mutex
+	chains map[string]*ChainOverride
+	dryRun bool
+}
+
+// NewMemoryControlStore creates a control store with no overrides
+func NewMemoryControlStore() *MemoryControlStore {
+	return &MemoryControlStore{chains: make(map[string]*ChainOverride)}
+}
+
+func (s *MemoryControlStore) Load(ctx context.Context) (*StoredControls, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := &StoredControls{DryRun: s.dryRun}
+	for _, override := range s.chains {
+		c := *override
+		stored.Chains = append(stored.Chains, &c)
+	}
+	return stored, nil
+}
+
+func (s *MemoryControlStore) SetChainState(ctx context.Context, chainID string, state ChainState, reason, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	override := s.override(chainID)
+	override.State = state
+	override.Reason = reason
+	override.UpdatedBy = actor
+	override.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *MemoryControlStore) UpdateChain(ctx context.Context, chainID string, update ChainUpdate, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	override := s.override(chainID)
+	if update.Enabled != nil {
+		enabled := *update.Enabled
+		override.Enabled = &enabled
+	}
+	if update.GasLimit != nil {
+		gasLimit := *update.GasLimit
+		override.GasLimit = &gasLimit
+	}
+	override.UpdatedBy = actor
+	override.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *MemoryControlStore) SetDryRun(ctx context.Context, enabled bool, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dryRun = enabled
+	return nil
+}
+
+func (s *MemoryControlStore) override(chainID string) *ChainOverride {
+	override, ok := s.chains[chainID]
+	if !ok {
+		override = &ChainOverride{ChainID: chainID, State: ChainActive}
+		s.chains[chainID] = override
+	}
+	return override
+}
+
+// SupabaseControlStore stores chain overrides in the relayer_chain_controls table and
+// the dry-run mode in the single row of relayer_settings
+type SupabaseControlStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseControlStore creates a new Supabase-backed control store
+func NewSupabaseControlStore(db *supabase.Client) *SupabaseControlStore {
+	return &SupabaseControlStore{db: db}
+}
+
+func (s *SupabaseControlStore) Load(ctx context.Context) (*StoredControls, error) {
+	data, _, err := s.db.From("relayer_chain_controls").Select("*", "", false).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chain controls: %w", err)
+	}
+	stored := &StoredControls{}
+	if err := json.Unmarshal(data, &stored.Chains); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chain controls: %w", err)
+	}
+
+	data, _, err = s.db.From("relayer_settings").Select("dry_run", "", false).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load relayer settings: %w", err)
+	}
+	var settings []struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal relayer settings: %w", err)
+	}
+	if len(settings) > 0 {
+		stored.DryRun = settings[0].DryRun
+	}
+	return stored, nil
+}
+
+func (s *SupabaseControlStore) SetChainState(ctx context.Context, chainID string, state ChainState, reason, actor string) error {
+	return s.upsertChain(chainID, actor, map[string]interface{}{"state": state, "reason": reason})
+}
+
+func (s *SupabaseControlStore) UpdateChain(ctx context.Context, chainID string, update ChainUpdate, actor string) error {
+	fields := map[string]interface{}{}
+	if update.Enabled != nil {
+		fields["enabled"] = *update.Enabled
+	}
+	if update.GasLimit != nil {
+		fields["gas_limit"] = *update.GasLimit
+	}
+	return s.upsertChain(chainID, actor, fields)
+}
+
+// upsertChain writes the given columns of the chain's row, creating it if needed. The
+// other columns keep their stored values.
+func (s *SupabaseControlStore) upsertChain(chainID, actor string, fields map[string]interface{}) error {
+	fields["chain_id"] = chainID
+	fields["updated_by"] = actor
+	fields["updated_at"] = time.Now().UTC()
+	_, _, err := s.db.From("relayer_chain_controls").Insert(fields, true, "chain_id", "", "").Execute()
+	if err != nil {
+		return fmt.Errorf("failed to save chain control: %w", err)
+	}
+	return nil
+}
+
+func (s *SupabaseControlStore) SetDryRun(ctx context.Context, enabled bool, actor string) error {
+	row := map[string]interface{}{
+		"id":         true,
+		"dry_run":    enabled,
+		"updated_by": actor,
+		"updated_at": time.Now().UTC(),
+	}
+	_, _, err := s.db.From("relayer_settings").Insert(row, true, "id", "", "").Execute()
+	if err != nil {
+		return fmt.Errorf("failed to save dry-run mode: %w", err)
+	}
+	return nil
+}
+
+// RelayerControls holds the operators' runtime overrides: chain states, enabled flags
+// and gas limits, and the dry-run mode. They are kept in a ControlStore and reloaded on
+// every outbox poll, so all relayer instances apply the same overrides. A nil
+// RelayerControls has no overrides.
+type RelayerControls struct {
+	store     ControlStore
+	mu        sync.RWMutex
+	chains    map[string]*ChainOverride
+	dryRun    bool
+	dryRunIDs map[string]bool // messages dry-run since the mode was switched on
+	results   []*DryRunResult
+}
+
+// NewRelayerControls creates controls backed by the store. They have no overrides until
+// they are first refreshed.
+func NewRelayerControls(store ControlStore) *RelayerControls {
+	return &RelayerControls{
+		store:     store,
+		chains:    make(map[string]*ChainOverride),
+		dryRunIDs: make(map[string]bool),
+	}
+}
+
+// refresh reloads the overrides from the store. On failure the last overrides loaded
+// stay in effect.
+func (c *RelayerControls) refresh(ctx context.Context) error {
+	stored, err := c.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	chains := make(map[string]*ChainOverride, len(stored.Chains))
+	for _, override := range stored.Chains {
+		chains[override.ChainID] = override
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chains = chains
+	if stored.DryRun && !c.dryRun {
+		c.dryRunIDs = make(map[string]bool)
+	}
+	c.dryRun = stored.DryRun
+	return nil
+}
+
+// State returns the chain's sending state and the reason it was set
+func (c *RelayerControls) State(chainID string) (ChainState, string) {
+	if c == nil {
+		return ChainActive, ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if override, ok := c.chains[chainID]; ok && override.State != "" {
+		return override.State, override.Reason
+	}
+	return ChainActive, ""
+}
+
+// Enabled reports whether the chain takes new allocations
+func (c *RelayerControls) Enabled(chainID string, chain *ChainConfig) bool {
+	if c == nil {
+		return chain.Enabled
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if override, ok := c.chains[chainID]; ok && override.Enabled != nil {
+		return *override.Enabled
+	}
+	return chain.Enabled
+}
+
+// GasLimit returns the lzReceive gas limit of messages to the chain
+func (c *RelayerControls) GasLimit(chainID string, chain *ChainConfig) uint64 {
+	if c == nil {
+		return chain.GasLimit
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if override, ok := c.chains[chainID]; ok && override.GasLimit != nil {
+		return *override.GasLimit
+	}
+	return chain.GasLimit
+}
+
+// DryRun reports whether messages are built and signed without being broadcast
+func (c *RelayerControls) DryRun() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dryRun
+}
+
+// DryRunResults returns the most recent dry-run results, newest first
+func (c *RelayerControls) DryRunResults() []*DryRunResult {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	results := make([]*DryRunResult, 0, len(c.results))
+	for i := len(c.results) - 1; i >= 0; i-- {
+		results = append(results, c.results[i])
+	}
+	return results
+}
+
+// updated returns who last changed the chain's controls and when
+func (c *RelayerControls) updated(chainID string) (string, *time.Time) {
+	if c == nil {
+		return "", nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	override, ok := c.chains[chainID]
+	if !ok {
+		return "", nil
+	}
+	updatedAt := override.UpdatedAt
+	return override.UpdatedBy, &updatedAt
+}
+
+func (c *RelayerControls) setState(ctx context.Context, chainID string, state ChainState, actor, reason string) error {
+	if err := c.store.SetChainState(ctx, chainID, state, reason, actor); err != nil {
+		return err
+	}
+	return c.refresh(ctx)
+}
+
+func (c *RelayerControls) update(ctx context.Context, chainID string, actor string, update ChainUpdate) error {
+	if err := c.store.UpdateChain(ctx, chainID, update, actor); err != nil {
+		return err
+	}
+	return c.refresh(ctx)
+}
+
+func (c *RelayerControls) setDryRun(ctx context.Context, enabled bool, actor string) error {
+	if err := c.store.SetDryRun(ctx, enabled, actor); err != nil {
+		return err
+	}
+	return c.refresh(ctx)
+}
+
+// markDryRun records that the message was dry-run in the current dry-run mode and
+// reports whether it was not already
+func (c *RelayerControls) markDryRun(messageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dryRunIDs[messageID] {
+		return false
+	}
+	c.dryRunIDs[messageID] = true
+	return true
+}
+
+func (c *RelayerControls) addResult(result *DryRunResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, result)
+	if len(c.results) > maxDryRunResults {
+		c.results = c.results[len(c.results)-maxDryRunResults:]
+	}
+}
+
+// applyControls holds back a message for a paused chain, dead-letters one for a draining
+// chain and dry-runs it in dry-run mode. It reports whether the message was handled.
+func (tr *TrustedRelayer) applyControls(message *Message) bool {
+	switch state, reason := tr.controls.State(message.ChainID); state {
+	case ChainPaused:
+		tr.deferMessage(message, time.Now().Add(heldMessageRecheck), "chain paused by an operator")
+		return true
+	case ChainDraining:
+		tr.drainMessage(message, reason)
+		return true
+	}
+
+	if !tr.controls.DryRun() {
+		return false
+	}
+	// Each message is dry-run once and then held until dry-run mode is switched off
+	if tr.controls.markDryRun(message.ID) {
+		tr.dryRun(message)
+	}
+	tr.deferMessage(message, time.Now().Add(heldMessageRecheck), "dry-run mode")
+	return true
+}
+
+// drainMessage fails a claimed message into the dead-letter queue without sending it.
+// It can be replayed to another chain from there.
+func (tr *TrustedRelayer) drainMessage(message *Message, reason string) {
+	cause := fmt.Errorf("chain %s drained by an operator", message.ChainID)
+	if reason != "" {
+		cause = fmt.Errorf("%w: %s", cause, reason)
+	}
+	message.Attempts = append(message.Attempts, ErrorContext{
+		Operation:   "drain",
+		ChainID:     message.ChainID,
+		MessageType: message.Type,
+		RetryCount:  message.RetryCount,
+		LastError:   cause,
+		Timestamp:   time.Now().UTC(),
+	})
+	message.LastError = cause.Error()
+	if err := message.transition(StatusFailed); err != nil {
+		log.Error().Err(err).Str("message_id", message.ID).Msg("Invalid message state")
+		return
+	}
+	tr.saveFailure(message, tr.instanceID)
+}
+
+// dryRun builds and signs the message's transaction without broadcasting it and keeps
+// the result for operators
+func (tr *TrustedRelayer) dryRun(message *Message) *DryRunResult {
+	result, err := tr.buildDryRun(message)
+	if err != nil {
+		result.Error = err.Error()
+		log.Warn().Err(err).Str("message_id", message.ID).Str("chain_id", message.ChainID).Msg("Dry run failed")
+	} else {
+		log.Info().
+			Str("message_id", message.ID).
+			Str("chain_id", message.ChainID).
+			Str("tx_chain_id", result.TxChainID).
+			Str("tx_hash", result.TxHash).
+			Uint64("nonce", result.Nonce).
+			Msg("Dry run built transaction")
+	}
+	tr.controls.addResult(result)
+	return result
+}
+
+// buildDryRun builds and signs the transaction dispatchMessage would send. Nonces are
+// read from the chain, not reserved, so the transaction may share a nonce with the next
+// real one.
+func (tr *TrustedRelayer) buildDryRun(message *Message) (*DryRunResult, error) {
+	result := &DryRunResult{MessageID: message.ID, ChainID: message.ChainID, CreatedAt: time.Now().UTC()}
+	chain, ok := tr.chainConfigs[message.ChainID]
+	if !ok {
+		return result, fmt.Errorf("unsupported chain ID: %s", message.ChainID)
+	}
+
+	// Signing stamps the message, so the dry run works on a copy
+	dry := copyMessage(message)
+	signed, err := tr.signMessage(dry, chain)
+	if err != nil {
+		return result, err
+	}
+	result.TxChainID = tr.txChainID(dry.ChainID, chain)
+
+	var req *blockchain.TxRequest
+	var key crypto.PrivateKey
+	switch chain.Type {
+	case config.ChainTypeSolana:
+		req, err = tr.solanaDisbursementRequest(dry, chain)
+		key = tr.solanaKey
+	case config.ChainTypeAptos:
+		req, err = tr.aptosDisbursementRequest(dry, chain)
+		key = tr.aptosKey
+	default:
+		var lzMessage *LayerZeroMessage
+		if lzMessage, err = tr.layerZeroMessage(dry, chain, signed); err != nil {
+			return result, err
+		}
+		var send *LayerZeroSendResult
+		if req, send, err = tr.layerZeroClient.BuildSend(tr.ctx, lzMessage); err == nil {
+			result.LayerZeroGUID = send.GUID
+			result.NativeFee = send.NativeFee.String()
+		}
+		key = tr.signer
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if tr.chains == nil {
+		return result, fmt.Errorf("%s is not configured", result.TxChainID)
+	}
+	adapter, err := tr.chains.Adapter(result.TxChainID)
+	if err != nil {
+		return result, err
+	}
+	tx, err := adapter.BuildTransaction(tr.ctx, req)
+	if err != nil {
+		return result, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	signedTx, err := adapter.SignTransaction(tr.ctx, tx, key)
+	if err != nil {
+		return result, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	result.TxHash = signedTx.Hash
+	result.Nonce = signedTx.Nonce
+	result.To = req.To
+	if req.Value != nil {
+		result.Value = req.Value.String()
+	}
+	if len(req.Data) > 0 {
+		result.Data = hexutil.Encode(req.Data)
+	}
+	return result, nil
+}
+
+// ChainControls returns the runtime state of every chain, ordered by chain ID
+func (tr *TrustedRelayer) ChainControls() []*ChainControl {
+	controls := make([]*ChainControl, 0, len(tr.chainConfigs))
+	for chainID := range tr.chainConfigs {
+		controls = append(controls, tr.chainControl(chainID))
+	}
+	sort.Slice(controls, func(i, j int) bool { return controls[i].ChainID < controls[j].ChainID })
+	return controls
+}
+
+func (tr *TrustedRelayer) chainControl(chainID string) *ChainControl {
+	chain := tr.chainConfigs[chainID]
+	state, reason := tr.controls.State(chainID)
+	control := &ChainControl{
+		ChainID:  chainID,
+		State:    state,
+		Reason:   reason,
+		Enabled:  tr.controls.Enabled(chainID, chain),
+		GasLimit: tr.controls.GasLimit(chainID, chain),
+	}
+	control.UpdatedBy, control.UpdatedAt = tr.controls.updated(chainID)
+	return control
+}
+
+// PauseChain holds the chain's messages in the outbox until it is resumed. Messages
+// already sent are still confirmed.
+func (tr *TrustedRelayer) PauseChain(ctx context.Context, actor, chainID, reason string) (*ChainControl, error) {
+	return tr.setChainState(ctx, actor, chainID, ChainPaused, reason, "chain.pause")
+}
+
+// ResumeChain returns a paused or draining chain to sending
+func (tr *TrustedRelayer) ResumeChain(ctx context.Context, actor, chainID string) (*ChainControl, error) {
+	return tr.setChainState(ctx, actor, chainID, ChainActive, "", "chain.resume")
+}
+
+// DrainChain moves the chain's unsent messages to the dead-letter queue as they come due,
+// until the chain is resumed
+func (tr *TrustedRelayer) DrainChain(ctx context.Context, actor, chainID, reason string) (*ChainControl, error) {
+	return tr.setChainState(ctx, actor, chainID, ChainDraining, reason, "chain.drain")
+}
+
+func (tr *TrustedRelayer) setChainState(ctx context.Context, actor, chainID string, state ChainState, reason, action string) (*ChainControl, error) {
+	if _, ok := tr.chainConfigs[chainID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChain, chainID)
+	}
+
+	from, _ := tr.controls.State(chainID)
+	details := map[string]interface{}{"from": from, "to": state}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	if err := tr.recordAudit(ctx, actor, action, "chain", chainID, details); err != nil {
+		return nil, err
+	}
+	if err := tr.controls.setState(ctx, chainID, state, actor, reason); err != nil {
+		return nil, err
+	}
+
+	select {
+	case tr.wake <- struct{}{}:
+	default:
+	}
+	return tr.chainControl(chainID), nil
+}
+
+// UpdateChain changes whether the chain takes new allocations and the gas limit of
+// messages to it
+func (tr *TrustedRelayer) UpdateChain(ctx context.Context, actor, chainID string, update ChainUpdate) (*ChainControl, error) {
+	chain, ok := tr.chainConfigs[chainID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChain, chainID)
+	}
+
+	details := map[string]interface{}{}
+	if update.Enabled != nil {
+		details["enabled"] = map[string]bool{"from": tr.controls.Enabled(chainID, chain), "to": *update.Enabled}
+	}
+	if update.GasLimit != nil {
+		if *update.GasLimit == 0 {
+			return nil, fmt.Errorf("%w: gas limit must be positive", ErrInvalidControl)
+		}
+		details["gas_limit"] = map[string]uint64{"from": tr.controls.GasLimit(chainID, chain), "to": *update.GasLimit}
+	}
+
+	if err := tr.recordAudit(ctx, actor, "chain.update", "chain", chainID, details); err != nil {
+		return nil, err
+	}
+	if err := tr.controls.update(ctx, chainID, actor, update); err != nil {
+		return nil, err
+	}
+	return tr.chainControl(chainID), nil
+}
+
+// SetDryRun switches dry-run mode on or off. In dry-run mode each message is built and
+// signed once without being broadcast, and held in the outbox until the mode is
+// switched off.
+func (tr *TrustedRelayer) SetDryRun(ctx context.Context, actor string, enabled bool) error {
+	details := map[string]interface{}{"from": tr.controls.DryRun(), "to": enabled}
+	if err := tr.recordAudit(ctx, actor, "relayer.dry_run", "relayer", tr.instanceID, details); err != nil {
+		return err
+	}
+	if err := tr.controls.setDryRun(ctx, enabled, actor); err != nil {
+		return err
+	}
+
+	select {
+	case tr.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// DryRunMessage builds and signs the transaction for a message without broadcasting it.
+// The message is left as it is.
+func (tr *TrustedRelayer) DryRunMessage(ctx context.Context, actor, id string) (*DryRunResult, error) {
+	message, err := tr.outbox.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.recordAudit(ctx, actor, "message.dry_run", "message", id, map[string]interface{}{"chain_id": message.ChainID}); err != nil {
+		return nil, err
+	}
+	return tr.dryRun(message), nil
+}
+
+// DryRunResults returns the most recent dry-run results, newest first
+func (tr *TrustedRelayer) DryRunResults() []*DryRunResult {
+	return tr.controls.DryRunResults()
+}
@@ -0,0 +1,285 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSendingTestRelayer returns a test relayer that sends LayerZero messages to ethereum
+// through a fake source chain.
+func newSendingTestRelayer(t *testing.T) (*TrustedRelayer, *blockchain.FakeAdapter) {
+	relayer := newTestRelayer(t)
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["ethereum"].LayerZeroReceiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	relayer.layerZeroClient = lzClient
+	return relayer, source
+}
+
+// newTestControlRouter serves the control-plane routes as an authenticated admin.
+func newTestControlRouter(relayer *TrustedRelayer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(relayer)
+
+	router := gin.New()
+	admin := router.Group("/api/v1/admin/relayer", func(c *gin.Context) {
+		c.Set("userID", "admin-1")
+		c.Set("userRole", "admin")
+	})
+	admin.GET("/chains", h.ListChainControls)
+	admin.PATCH("/chains/:chain", h.UpdateChain)
+	admin.POST("/chains/:chain/pause", h.PauseChain)
+	admin.POST("/chains/:chain/resume", h.ResumeChain)
+	admin.POST("/chains/:chain/drain", h.DrainChain)
+	admin.PUT("/dry-run", h.SetDryRun)
+	admin.GET("/dry-runs", h.ListDryRuns)
+	admin.POST("/messages/:id/dry-run", h.DryRunMessage)
+	return router
+}
+
+// makeDue brings a held message's next attempt forward so the next pass claims it
+func makeDue(t *testing.T, relayer *TrustedRelayer, id string) {
+	message, err := relayer.outbox.Get(relayer.ctx, id)
+	require.NoError(t, err)
+	message.NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, relayer.outbox.Save(relayer.ctx, message, ""))
+}
+
+func TestHandler_PauseResumeAndDrainChain(t *testing.T) {
+	relayer, source := newSendingTestRelayer(t)
+	router := newTestControlRouter(relayer)
+	path := "/api/v1/admin/relayer/chains/ethereum"
+
+	w, _ := serveJSON(t, router, http.MethodPost, path+"/pause", map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = serveJSON(t, router, http.MethodPost, "/api/v1/admin/relayer/chains/fantom/pause", map[string]interface{}{"reason": "upgrade"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, response := serveJSON(t, router, http.MethodPost, path+"/pause", map[string]interface{}{"reason": "endpoint upgrade"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "paused", response.(map[string]interface{})["state"])
+
+	// A paused chain holds its messages without sending them
+	held := newPendingMessage(MessageTypeLoanDisbursement, "70", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(held))
+	relayer.processOutbox()
+	message, err := relayer.outbox.Get(relayer.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, message.Status)
+	assert.Equal(t, 0, message.RetryCount)
+	assert.True(t, message.NextAttemptAt.After(time.Now().Add(20*time.Second)))
+	assert.Empty(t, source.Sent())
+
+	w, _ = serveJSON(t, router, http.MethodPost, path+"/resume", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	makeDue(t, relayer, held.ID)
+	relayer.processOutbox()
+	message, err = relayer.outbox.Get(relayer.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, message.Status)
+	assert.Len(t, source.Sent(), 1)
+
+	// A draining chain dead-letters its messages instead
+	w, _ = serveJSON(t, router, http.MethodPost, path+"/drain", map[string]interface{}{"reason": "pool migration"})
+	require.Equal(t, http.StatusOK, w.Code)
+	drained := newPendingMessage(MessageTypeLoanDisbursement, "71", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(drained))
+	relayer.processOutbox()
+	message, err = relayer.outbox.Get(relayer.ctx, drained.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, message.Status)
+	assert.Len(t, source.Sent(), 1)
+
+	deadLetters, err := relayer.ListDeadLetters(relayer.ctx, DeadLetterActive, 10)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, drained.ID, deadLetters[0].MessageID)
+	assert.Contains(t, deadLetters[0].Reason, "pool migration")
+	require.Len(t, deadLetters[0].Attempts, 1)
+	assert.Equal(t, "drain", deadLetters[0].Attempts[0].Operation)
+
+	w, response = serveJSON(t, router, http.MethodGet, "/api/v1/admin/relayer/chains", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	chains := response.([]interface{})
+	require.Len(t, chains, 1)
+	assert.Equal(t, "draining", chains[0].(map[string]interface{})["state"])
+	assert.Equal(t, "admin-1", chains[0].(map[string]interface{})["updated_by"])
+
+	entries := relayer.audit.(*MemoryAuditLog).Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "chain.pause", entries[0].Action)
+	assert.Equal(t, "chain.resume", entries[1].Action)
+	assert.Equal(t, "chain.drain", entries[2].Action)
+	for _, entry := range entries {
+		assert.Equal(t, "admin-1", entry.Actor)
+		assert.Equal(t, "ethereum", entry.ResourceID)
+	}
+}
+
+func TestHandler_UpdateChain(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["ethereum"].GasLimit = 200000
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["base"] = &ChainConfig{Enabled: true}
+	store := relayer.poolStore.(*fakePoolStore)
+	store.pools = append(store.pools, &PoolState{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 500000})
+	router := newTestControlRouter(relayer)
+	path := "/api/v1/admin/relayer/chains/base"
+
+	w, _ := serveJSON(t, router, http.MethodPatch, path, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = serveJSON(t, router, http.MethodPatch, path, map[string]interface{}{"gas_limit": 0})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A disabled chain takes no new allocations
+	w, response := serveJSON(t, router, http.MethodPatch, path, map[string]interface{}{"enabled": false})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, response.(map[string]interface{})["enabled"])
+	allocation, err := relayer.allocator.Allocate(relayer.ctx, AllocationRequest{LoanID: "1", Asset: "USDC", Amount: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, "ethereum", allocation.ChainID)
+	assert.True(t, relayer.chainConfigs["base"].Enabled)
+
+	// The gas limit override reaches the LayerZero options of new messages
+	w, _ = serveJSON(t, router, http.MethodPatch, "/api/v1/admin/relayer/chains/ethereum", map[string]interface{}{"gas_limit": 350000})
+	require.Equal(t, http.StatusOK, w.Code)
+	lzMessage, err := relayer.layerZeroMessage(newPendingMessage(MessageTypeLoanDisbursement, "72", "ethereum", []byte{1}), relayer.chainConfigs["ethereum"], &SignedMessage{})
+	require.NoError(t, err)
+	assert.Equal(t, ExecutorLzReceiveOption(350000, nil), lzMessage.Options)
+
+	entries := relayer.audit.(*MemoryAuditLog).Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "chain.update", entries[0].Action)
+	assert.Equal(t, map[string]bool{"from": true, "to": false}, entries[0].Details["enabled"])
+	assert.Equal(t, map[string]uint64{"from": 200000, "to": 350000}, entries[1].Details["gas_limit"])
+}
+
+func TestHandler_DryRunDoesNotBroadcast(t *testing.T) {
+	relayer, source := newSendingTestRelayer(t)
+	router := newTestControlRouter(relayer)
+
+	w, _ := serveJSON(t, router, http.MethodPut, "/api/v1/admin/relayer/dry-run", map[string]interface{}{"enabled": true})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "73", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+	makeDue(t, relayer, message.ID)
+	relayer.processOutbox()
+
+	// The message is built and signed once and held until dry-run mode is switched off
+	held, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, held.Status)
+	assert.Empty(t, held.TxHash)
+	assert.Empty(t, source.Sent())
+
+	w, response := serveJSON(t, router, http.MethodGet, "/api/v1/admin/relayer/dry-runs", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	results := response.([]interface{})
+	require.Len(t, results, 1)
+	result := results[0].(map[string]interface{})
+	assert.Equal(t, message.ID, result["message_id"])
+	assert.Equal(t, "ethereum", result["tx_chain_id"])
+	assert.NotEmpty(t, result["tx_hash"])
+	assert.NotEmpty(t, result["data"])
+	// The signed transaction could be broadcast, so it is never returned
+	assert.NotContains(t, result, "raw_transaction")
+	assert.NotEmpty(t, result["layerzero_guid"])
+	assert.Equal(t, "1000", result["native_fee"])
+	assert.Nil(t, result["error"])
+
+	// A message can be dry-run on demand too
+	w, response = serveJSON(t, router, http.MethodPost, "/api/v1/admin/relayer/messages/"+message.ID+"/dry-run", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, result["tx_hash"], response.(map[string]interface{})["tx_hash"])
+	assert.Empty(t, source.Sent())
+
+	w, _ = serveJSON(t, router, http.MethodPut, "/api/v1/admin/relayer/dry-run", map[string]interface{}{"enabled": false})
+	require.Equal(t, http.StatusOK, w.Code)
+	makeDue(t, relayer, message.ID)
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, sent.Status)
+	require.Len(t, source.Sent(), 1)
+	assert.Equal(t, result["tx_hash"], sent.TxHash)
+
+	entries := relayer.audit.(*MemoryAuditLog).Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "relayer.dry_run", entries[0].Action)
+	assert.Equal(t, "message.dry_run", entries[1].Action)
+	assert.Equal(t, message.ID, entries[1].ResourceID)
+	assert.Equal(t, "relayer.dry_run", entries[2].Action)
+}
+
+// unreachableControlStore is a ControlStore whose database is down
+type unreachableControlStore struct {
+	*MemoryControlStore
+}
+
+func (unreachableControlStore) Load(ctx context.Context) (*StoredControls, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRelayerControls_SharedAcrossInstances(t *testing.T) {
+	store := NewMemoryControlStore()
+	operator := newTestRelayer(t)
+	operator.controls = NewRelayerControls(store)
+	sender, source := newSendingTestRelayer(t)
+	sender.controls = NewRelayerControls(store)
+
+	// Overrides set through one instance hold on another
+	_, err := operator.PauseChain(operator.ctx, "admin-1", "ethereum", "endpoint upgrade")
+	require.NoError(t, err)
+	gasLimit := uint64(300000)
+	_, err = operator.UpdateChain(operator.ctx, "admin-1", "ethereum", ChainUpdate{GasLimit: &gasLimit})
+	require.NoError(t, err)
+
+	held := newPendingMessage(MessageTypeLoanDisbursement, "72", "ethereum", []byte{1})
+	require.NoError(t, sender.enqueue(held))
+	sender.processOutbox()
+	message, err := sender.outbox.Get(sender.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, message.Status)
+	assert.Empty(t, source.Sent())
+
+	state, reason := sender.controls.State("ethereum")
+	assert.Equal(t, ChainPaused, state)
+	assert.Equal(t, "endpoint upgrade", reason)
+	assert.Equal(t, gasLimit, sender.controls.GasLimit("ethereum", sender.chainConfigs["ethereum"]))
+
+	// Nothing is sent while the overrides cannot be loaded
+	_, err = operator.ResumeChain(operator.ctx, "admin-1", "ethereum")
+	require.NoError(t, err)
+	sender.controls.store = unreachableControlStore{store}
+	makeDue(t, sender, held.ID)
+	sender.processOutbox()
+	message, err = sender.outbox.Get(sender.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, message.Status)
+	assert.Empty(t, source.Sent())
+
+	sender.controls.store = store
+	sender.processOutbox()
+	assert.Len(t, source.Sent(), 1)
+}
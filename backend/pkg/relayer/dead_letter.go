@@ -0,0 +1,500 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned when there is no dead letter with the given ID.
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterResolved is returned when acting on a dead letter that has already
+	// been replayed or discarded.
+	ErrDeadLetterResolved = errors.New("dead letter already resolved")
+	// ErrInvalidReplayTarget is returned when a dead letter is pointed at a chain the
+	// relayer cannot send to.
+	ErrInvalidReplayTarget = errors.New("invalid replay target")
+	// ErrReplayUnsafe is returned when replaying a dead letter could deliver its message
+	// twice, because its last transaction succeeded or may still be mined.
+	ErrReplayUnsafe = errors.New("replay could deliver the message twice")
+)
+
+// DeadLetterStatus is the state of a dead letter
+type DeadLetterStatus string
+
+const (
+	// DeadLetterActive dead letters are waiting for an operator
+	DeadLetterActive DeadLetterStatus = "active"
+	// DeadLetterReplayed dead letters were returned to the outbox
+	DeadLetterReplayed DeadLetterStatus = "replayed"
+	// DeadLetterDiscarded dead letters were dropped by an operator
+	DeadLetterDiscarded DeadLetterStatus = "discarded"
+)
+
+// DeadLetter records an outbox message that used up its retries. The message stays
+// FAILED in the outbox until an operator replays it; ChainID and GasLimit are the target
+// it will be replayed to and may be edited before then. A message that fails again after
+// a replay gets a new dead letter.
+type DeadLetter struct {
+	ID          string           `json:"id"`
+	MessageID   string           `json:"message_id"`
+	MessageType MessageType      `json:"message_type"`
+	ChainID     string           `json:"chain_id"`
+	GasLimit    uint64           `json:"gas_limit,omitempty"` // 0 uses the chain's gas limit
+	Reason      string           `json:"reason"`
+	Attempts    []ErrorContext   `json:"attempts"`
+	LastTxHash  string           `json:"last_tx_hash,omitempty"`
+	Status      DeadLetterStatus `json:"status"`
+	ResolvedBy  string           `json:"resolved_by,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// DeadLetterUpdate changes the target of a dead letter. Nil fields are left unchanged.
+type DeadLetterUpdate struct {
+	ChainID  *string `json:"chain_id"`
+	GasLimit *uint64 `json:"gas_limit"`
+}
+
+// DeadLetterStore persists dead letters.
+type DeadLetterStore interface {
+	Add(ctx context.Context, deadLetter *DeadLetter) error
+	Get(ctx context.Context, id string) (*DeadLetter, error)
+	// List returns up to limit dead letters with the given status, newest first. An
+	// empty status lists every dead letter.
+	List(ctx context.Context, status DeadLetterStatus, limit int) ([]*DeadLetter, error)
+	// Update saves the target, status and resolver of a dead letter.
+	Update(ctx context.Context, deadLetter *DeadLetter) error
+}
+
+// MemoryDeadLetterStore is an in-process DeadLetterStore for tests and development.
+type MemoryDeadLetterStore struct {
+	mu          sync.Mutex
+	deadLetters map[string]*DeadLetter
+}
+
+// NewMemoryDeadLetterStore creates an empty in-memory dead-letter store
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{deadLetters: make(map[string]*DeadLetter)}
+}
+
+func (s *MemoryDeadLetterStore) Add(ctx context.Context, deadLetter *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters[deadLetter.ID] = copyDeadLetter(deadLetter)
+	return nil
+}
+
+func (s *MemoryDeadLetterStore) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadLetter, ok := s.deadLetters[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeadLetterNotFound, id)
+	}
+	return copyDeadLetter(deadLetter), nil
+}
+
+func (s *MemoryDeadLetterStore) List(ctx context.Context, status DeadLetterStatus, limit int) ([]*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deadLetters []*DeadLetter
+	for _, deadLetter := range s.deadLetters {
+		if status == "" || deadLetter.Status == status {
+			deadLetters = append(deadLetters, copyDeadLetter(deadLetter))
+		}
+	}
+	sort.Slice(deadLetters, func(i, j int) bool { return deadLetters[i].CreatedAt.After(deadLetters[j].CreatedAt) })
+	if len(deadLetters) > limit {
+		deadLetters = deadLetters[:limit]
+	}
+	return deadLetters, nil
+}
+
+func (s *MemoryDeadLetterStore) Update(ctx context.Context, deadLetter *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deadLetters[deadLetter.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, deadLetter.ID)
+	}
+	s.deadLetters[deadLetter.ID] = copyDeadLetter(deadLetter)
+	return nil
+}
+
+func copyDeadLetter(deadLetter *DeadLetter) *DeadLetter {
+	c := *deadLetter
+	c.Attempts = append([]ErrorContext(nil), deadLetter.Attempts...)
+	return &c
+}
+
+// SupabaseDeadLetterStore stores dead letters in the relayer_dead_letters table
+type SupabaseDeadLetterStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseDeadLetterStore creates a new Supabase-backed dead-letter store
+func NewSupabaseDeadLetterStore(db *supabase.Client) *SupabaseDeadLetterStore {
+	return &SupabaseDeadLetterStore{db: db}
+}
+
+func (s *SupabaseDeadLetterStore) Add(ctx context.Context, deadLetter *DeadLetter) error {
+	_, _, err := s.db.From("relayer_dead_letters").Insert(deadLetter, false, "", "", "").Execute()
+	if err != nil {
+		return fmt.Errorf("failed to add dead letter: %w", err)
+	}
+	return nil
+}
+
+func (s *SupabaseDeadLetterStore) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	data, _, err := s.db.From("relayer_dead_letters").Select("*", "", false).Eq("id", id).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+
+	var deadLetters []*DeadLetter
+	if err := json.Unmarshal(data, &deadLetters); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter: %w", err)
+	}
+	if len(deadLetters) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDeadLetterNotFound, id)
+	}
+	return deadLetters[0], nil
+}
+
+func (s *SupabaseDeadLetterStore) List(ctx context.Context, status DeadLetterStatus, limit int) ([]*DeadLetter, error) {
+	query := s.db.From("relayer_dead_letters").Select("*", "", false)
+	if status != "" {
+		query = query.Eq("status", string(status))
+	}
+	data, _, err := query.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	var deadLetters []*DeadLetter
+	if err := json.Unmarshal(data, &deadLetters); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letters: %w", err)
+	}
+	return deadLetters, nil
+}
+
+func (s *SupabaseDeadLetterStore) Update(ctx context.Context, deadLetter *DeadLetter) error {
+	update := map[string]interface{}{
+		"chain_id":    deadLetter.ChainID,
+		"gas_limit":   deadLetter.GasLimit,
+		"status":      deadLetter.Status,
+		"resolved_by": deadLetter.ResolvedBy,
+		"updated_at":  deadLetter.UpdatedAt,
+	}
+	_, _, err := s.db.From("relayer_dead_letters").Update(update, "", "").Eq("id", deadLetter.ID).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to update dead letter: %w", err)
+	}
+	return nil
+}
+
+// deadLetter records a message that has used up its retries
+func (tr *TrustedRelayer) deadLetter(message *Message) {
+	now := time.Now().UTC()
+	deadLetter := &DeadLetter{
+		ID:          uuid.NewString(),
+		MessageID:   message.ID,
+		MessageType: message.Type,
+		ChainID:     message.ChainID,
+		GasLimit:    message.GasLimit,
+		Reason:      message.LastError,
+		Attempts:    message.Attempts,
+		LastTxHash:  message.TxHash,
+		Status:      DeadLetterActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := tr.deadLetters.Add(tr.ctx, deadLetter); err != nil {
+		log.Error().Err(err).Str("message_id", message.ID).Msg("Failed to dead-letter message")
+		return
+	}
+
+	log.Warn().
+		Str("message_id", message.ID).
+		Str("dead_letter_id", deadLetter.ID).
+		Str("chain_id", message.ChainID).
+		Int("attempts", len(message.Attempts)).
+		Str("reason", deadLetter.Reason).
+		Msg("Message dead-lettered")
+}
+
+// ListDeadLetters returns dead letters with the given status, newest first
+func (tr *TrustedRelayer) ListDeadLetters(ctx context.Context, status DeadLetterStatus, limit int) ([]*DeadLetter, error) {
+	return tr.deadLetters.List(ctx, status, limit)
+}
+
+// GetDeadLetter returns a dead letter by ID
+func (tr *TrustedRelayer) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	return tr.deadLetters.Get(ctx, id)
+}
+
+// activeDeadLetter returns a dead letter that is still waiting for an operator
+func (tr *TrustedRelayer) activeDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	deadLetter, err := tr.deadLetters.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetter.Status != DeadLetterActive {
+		return nil, fmt.Errorf("%w: %s is %s", ErrDeadLetterResolved, id, deadLetter.Status)
+	}
+	return deadLetter, nil
+}
+
+// UpdateDeadLetter changes the chain or gas limit a dead letter will be replayed with
+func (tr *TrustedRelayer) UpdateDeadLetter(ctx context.Context, actor, id string, update DeadLetterUpdate) (*DeadLetter, error) {
+	deadLetter, err := tr.activeDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{"message_id": deadLetter.MessageID}
+	var retarget *retargetedDisbursement
+	if update.ChainID != nil {
+		chain, ok := tr.chainConfigs[*update.ChainID]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported chain ID %s", ErrInvalidReplayTarget, *update.ChainID)
+		}
+		if !tr.controls.Enabled(*update.ChainID, chain) {
+			return nil, fmt.Errorf("%w: chain %s is disabled", ErrInvalidReplayTarget, *update.ChainID)
+		}
+		if *update.ChainID != deadLetter.ChainID {
+			retarget, err = tr.retargetDisbursement(ctx, deadLetter, *update.ChainID, chain)
+			if err != nil {
+				return nil, err
+			}
+			details["pool_id"] = retarget.allocation.PoolID
+		}
+		details["chain_id"] = map[string]string{"from": deadLetter.ChainID, "to": *update.ChainID}
+		deadLetter.ChainID = *update.ChainID
+	}
+	if update.GasLimit != nil {
+		details["gas_limit"] = map[string]uint64{"from": deadLetter.GasLimit, "to": *update.GasLimit}
+		deadLetter.GasLimit = *update.GasLimit
+	}
+
+	if err := tr.recordAudit(ctx, actor, "dead_letter.update", "dead_letter", id, details); err != nil {
+		return nil, err
+	}
+	if retarget != nil {
+		// Moving the reservation is a no-op once the loan is on the new chain, so an
+		// update that failed after it can be retried
+		if err := tr.poolStore.MoveAllocation(ctx, retarget.allocation); err != nil {
+			return nil, fmt.Errorf("failed to move allocation for loan %s: %w", retarget.message.LoanID, err)
+		}
+		if err := tr.outbox.Save(ctx, retarget.message, ""); err != nil {
+			return nil, err
+		}
+	}
+	deadLetter.UpdatedAt = time.Now().UTC()
+	if err := tr.deadLetters.Update(ctx, deadLetter); err != nil {
+		return nil, err
+	}
+	return deadLetter, nil
+}
+
+// retargetedDisbursement is a dead-lettered disbursement rebuilt for another chain,
+// with the pool on that chain that funds it instead.
+type retargetedDisbursement struct {
+	message    *Message
+	allocation *Allocation
+}
+
+// retargetDisbursement picks a pool on chainID for a dead-lettered disbursement and
+// rebuilds its payload for that chain. Only disbursements can move: a repayment
+// confirmation belongs to the pool that funded the loan.
+func (tr *TrustedRelayer) retargetDisbursement(ctx context.Context, deadLetter *DeadLetter, chainID string, chain *ChainConfig) (*retargetedDisbursement, error) {
+	if deadLetter.MessageType != MessageTypeLoanDisbursement {
+		return nil, fmt.Errorf("%w: a %s is only sent to the loan's funding chain", ErrInvalidReplayTarget, deadLetter.MessageType)
+	}
+	if tr.allocator == nil || tr.poolStore == nil {
+		return nil, fmt.Errorf("%w: no pool store to move the loan's allocation", ErrInvalidReplayTarget)
+	}
+	message, err := tr.outbox.Get(ctx, deadLetter.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status != StatusFailed {
+		return nil, fmt.Errorf("%w: message %s is %s", ErrDeadLetterResolved, message.ID, message.Status)
+	}
+	if message.LoanID == "" {
+		return nil, fmt.Errorf("%w: message %s has no loan", ErrInvalidReplayTarget, message.ID)
+	}
+	// A disbursement that may still land on its old chain cannot be funded from another
+	if err := tr.checkReplaySafe(ctx, message); err != nil {
+		return nil, err
+	}
+
+	decoded, err := DecodePayload(message.Type, message.PayloadVersion, message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	payload := decoded.(*LoanDisbursementPayload)
+	// EVM pools need the loan asset's token address; other chains resolve the asset themselves
+	payload.Token = common.Address{}
+	if chain.Type == config.ChainTypeEVM {
+		if chain.TokenAddress == (common.Address{}) {
+			return nil, fmt.Errorf("%w: no token address configured for chain %s", ErrInvalidReplayTarget, chainID)
+		}
+		payload.Token = chain.TokenAddress
+	}
+
+	allocation, err := tr.allocator.Allocate(ctx, AllocationRequest{
+		LoanID:  message.LoanID,
+		Asset:   tr.config.LoanAsset,
+		Amount:  tokenAmount(payload.Amount, tr.config.LoanAssetDecimals),
+		ChainID: chainID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReplayTarget, err)
+	}
+
+	message.Payload, err = EncodePayload(MessageTypeLoanDisbursement, payload)
+	if err != nil {
+		return nil, err
+	}
+	message.PayloadVersion = CurrentPayloadVersion
+	message.ChainID = chainID
+	return &retargetedDisbursement{message: message, allocation: allocation}, nil
+}
+
+// ReplayDeadLetter returns a dead-lettered message to the outbox with a fresh set of
+// retries, sending it to the dead letter's chain with its gas limit
+func (tr *TrustedRelayer) ReplayDeadLetter(ctx context.Context, actor, id string) (*DeadLetter, error) {
+	deadLetter, err := tr.activeDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	message, err := tr.outbox.Get(ctx, deadLetter.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status != StatusFailed {
+		// The message was resent some other way since it was dead-lettered
+		return nil, fmt.Errorf("%w: message %s is %s", ErrDeadLetterResolved, message.ID, message.Status)
+	}
+	if err := tr.checkReplaySafe(ctx, message); err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{
+		"message_id": message.ID,
+		"chain_id":   deadLetter.ChainID,
+		"gas_limit":  deadLetter.GasLimit,
+	}
+	if err := tr.recordAudit(ctx, actor, "dead_letter.replay", "dead_letter", id, details); err != nil {
+		return nil, err
+	}
+
+	// The attempts stay on the dead letter; the replay starts a new history
+	message.ChainID = deadLetter.ChainID
+	message.GasLimit = deadLetter.GasLimit
+	message.RetryCount = 0
+	message.Attempts = nil
+	message.LastError = ""
+	message.TxHash = ""
+	message.TxHashes = nil
+	message.TxChainID = ""
+	message.TxExpiry = 0
+	message.TxNonce = nil
+	message.BlockHash = ""
+	message.BlockNumber = 0
+	message.LayerZeroGUID = ""
+	message.NextAttemptAt = time.Now().UTC()
+	if err := message.transition(StatusPending); err != nil {
+		return nil, err
+	}
+	if err := tr.outbox.Save(ctx, message, ""); err != nil {
+		return nil, err
+	}
+
+	deadLetter.Status = DeadLetterReplayed
+	deadLetter.ResolvedBy = actor
+	deadLetter.UpdatedAt = time.Now().UTC()
+	if err := tr.deadLetters.Update(ctx, deadLetter); err != nil {
+		return nil, err
+	}
+
+	select {
+	case tr.wake <- struct{}{}:
+	default:
+	}
+	return deadLetter, nil
+}
+
+// checkReplaySafe checks that the message's last transaction can no longer deliver it:
+// it reverted, or it expired without being included. A transaction that succeeded, or
+// that is not found and could still be mined, makes a replay unsafe.
+func (tr *TrustedRelayer) checkReplaySafe(ctx context.Context, message *Message) error {
+	if message.TxHash == "" {
+		return nil
+	}
+	if tr.chains == nil {
+		return fmt.Errorf("%w: transaction %s on %s cannot be checked", ErrReplayUnsafe, message.TxHash, message.TxChainID)
+	}
+	adapter, err := tr.chains.Adapter(message.TxChainID)
+	if err != nil {
+		return fmt.Errorf("%w: transaction %s cannot be checked: %v", ErrReplayUnsafe, message.TxHash, err)
+	}
+
+	// Checked before the receipt, so a transaction included just before it expired is found
+	expired, err := blockchain.TransactionExpired(ctx, adapter, message.TxExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to check transaction expiry: %w", err)
+	}
+	receipt, err := tr.messageReceipt(adapter, message)
+	switch {
+	case err == nil && receipt.Success:
+		return fmt.Errorf("%w: transaction %s succeeded", ErrReplayUnsafe, receipt.TxHash)
+	case err == nil:
+		return nil
+	case errors.Is(err, blockchain.ErrTxNotFound) && expired:
+		return nil
+	case errors.Is(err, blockchain.ErrTxNotFound):
+		return fmt.Errorf("%w: transaction %s is not mined yet and may still be", ErrReplayUnsafe, message.TxHash)
+	default:
+		return fmt.Errorf("failed to get transaction receipt: %w", err)
+	}
+}
+
+// DiscardDeadLetter drops a dead letter. Its message stays FAILED in the outbox.
+func (tr *TrustedRelayer) DiscardDeadLetter(ctx context.Context, actor, id, reason string) (*DeadLetter, error) {
+	deadLetter, err := tr.activeDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{"message_id": deadLetter.MessageID, "reason": reason}
+	if err := tr.recordAudit(ctx, actor, "dead_letter.discard", "dead_letter", id, details); err != nil {
+		return nil, err
+	}
+
+	deadLetter.Status = DeadLetterDiscarded
+	deadLetter.ResolvedBy = actor
+	deadLetter.UpdatedAt = time.Now().UTC()
+	if err := tr.deadLetters.Update(ctx, deadLetter); err != nil {
+		return nil, err
+	}
+	return deadLetter, nil
+}
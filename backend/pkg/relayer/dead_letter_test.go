@@ -0,0 +1,326 @@
+package relayer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failMessage enqueues a message that cannot be sent and processes it until it is
+// dead-lettered.
+func failMessage(t *testing.T, relayer *TrustedRelayer, sourceKey string) *DeadLetter {
+	relayer.config.MaxRetries = 1
+
+	// No LayerZero source chain is configured, so every send fails
+	message := newPendingMessage(MessageTypeLoanDisbursement, sourceKey, "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	deadLetters, err := relayer.ListDeadLetters(relayer.ctx, DeadLetterActive, 10)
+	require.NoError(t, err)
+	for _, deadLetter := range deadLetters {
+		if deadLetter.MessageID == message.ID {
+			return deadLetter
+		}
+	}
+	t.Fatalf("message %s was not dead-lettered", message.ID)
+	return nil
+}
+
+// newTestDeadLetterRouter serves the dead-letter routes as an authenticated admin.
+func newTestDeadLetterRouter(relayer *TrustedRelayer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(relayer)
+
+	router := gin.New()
+	admin := router.Group("/api/v1/admin/relayer", func(c *gin.Context) {
+		c.Set("userID", "admin-1")
+		c.Set("userRole", "admin")
+	})
+	admin.GET("/dead-letters", h.ListDeadLetters)
+	admin.GET("/dead-letters/:id", h.GetDeadLetter)
+	admin.PATCH("/dead-letters/:id", h.UpdateDeadLetter)
+	admin.POST("/dead-letters/:id/replay", h.ReplayDeadLetter)
+	admin.POST("/dead-letters/:id/discard", h.DiscardDeadLetter)
+	return router
+}
+
+func serveJSON(t *testing.T, router http.Handler, method, path string, body interface{}) (*httptest.ResponseRecorder, interface{}) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestTrustedRelayer_DeadLettersFailedMessage(t *testing.T) {
+	relayer := newTestRelayer(t)
+	deadLetter := failMessage(t, relayer, "20")
+
+	assert.Equal(t, DeadLetterActive, deadLetter.Status)
+	assert.Equal(t, "ethereum", deadLetter.ChainID)
+	assert.Equal(t, MessageTypeLoanDisbursement, deadLetter.MessageType)
+	assert.NotEmpty(t, deadLetter.Reason)
+	require.Len(t, deadLetter.Attempts, 1)
+	assert.Equal(t, "dispatch", deadLetter.Attempts[0].Operation)
+	assert.Equal(t, 0, deadLetter.Attempts[0].RetryCount)
+	assert.EqualError(t, deadLetter.Attempts[0].LastError, deadLetter.Reason)
+
+	message, err := relayer.outbox.Get(relayer.ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, message.Status)
+}
+
+func TestTrustedRelayer_RetryingMessageIsNotDeadLettered(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 2
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "21", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	retrying, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRetrying, retrying.Status)
+	assert.Len(t, retrying.Attempts, 1)
+
+	deadLetters, err := relayer.ListDeadLetters(relayer.ctx, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestHandler_EditAndReplayDeadLetter(t *testing.T) {
+	relayer := newTestRelayer(t)
+	deadLetter := failMessage(t, relayer, "22")
+	router := newTestDeadLetterRouter(relayer)
+	path := "/api/v1/admin/relayer/dead-letters/" + deadLetter.ID
+
+	w, response := serveJSON(t, router, http.MethodGet, "/api/v1/admin/relayer/dead-letters", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, response, 1)
+
+	w, response = serveJSON(t, router, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	attempts := response.(map[string]interface{})["attempts"].([]interface{})
+	require.Len(t, attempts, 1)
+	assert.Equal(t, deadLetter.Reason, attempts[0].(map[string]interface{})["last_error"])
+
+	w, _ = serveJSON(t, router, http.MethodPatch, path, map[string]interface{}{"chain_id": "fantom"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = serveJSON(t, router, http.MethodPatch, path, map[string]interface{}{"gas_limit": 500000})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, _ = serveJSON(t, router, http.MethodPost, path+"/replay", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	message, err := relayer.outbox.Get(relayer.ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, message.Status)
+	assert.Equal(t, uint64(500000), message.GasLimit)
+	assert.Equal(t, 0, message.RetryCount)
+	assert.Empty(t, message.Attempts)
+	assert.Empty(t, message.LastError)
+
+	replayed, err := relayer.GetDeadLetter(relayer.ctx, deadLetter.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DeadLetterReplayed, replayed.Status)
+	assert.Equal(t, "admin-1", replayed.ResolvedBy)
+	assert.Len(t, replayed.Attempts, 1)
+
+	// A resolved dead letter cannot be replayed again
+	w, _ = serveJSON(t, router, http.MethodPost, path+"/replay", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	entries := relayer.audit.(*MemoryAuditLog).Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "dead_letter.update", entries[0].Action)
+	assert.Equal(t, "dead_letter.replay", entries[1].Action)
+	for _, entry := range entries {
+		assert.Equal(t, "admin-1", entry.Actor)
+		assert.Equal(t, deadLetter.ID, entry.ResourceID)
+	}
+
+	// The replayed message fails again and gets a new dead letter
+	message.NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, relayer.outbox.Save(relayer.ctx, message, ""))
+	relayer.processOutbox()
+	active, err := relayer.ListDeadLetters(relayer.ctx, DeadLetterActive, 10)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.NotEqual(t, deadLetter.ID, active[0].ID)
+	assert.Equal(t, uint64(500000), active[0].GasLimit)
+}
+
+func TestTrustedRelayer_ReplayChecksLastTransaction(t *testing.T) {
+	relayer := newTestRelayer(t)
+	deadLetter := failMessage(t, relayer, "24")
+	ctx := relayer.ctx
+
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "solana", Type: config.ChainTypeSolana})
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(adapter))
+
+	// lastTransaction sends a transaction and records it as the message's last one
+	lastTransaction := func() {
+		tx, err := adapter.BuildTransaction(ctx, &blockchain.TxRequest{From: "relayer", To: "pool"})
+		require.NoError(t, err)
+		signed, err := adapter.SignTransaction(ctx, tx, nil)
+		require.NoError(t, err)
+		hash, err := adapter.SendTransaction(ctx, signed)
+		require.NoError(t, err)
+
+		message, err := relayer.outbox.Get(ctx, deadLetter.MessageID)
+		require.NoError(t, err)
+		message.TxHash = hash
+		message.TxChainID = "solana"
+		message.TxExpiry = signed.Expiry
+		require.NoError(t, relayer.outbox.Save(ctx, message, ""))
+	}
+
+	// A transaction that succeeded already delivered the message
+	lastTransaction()
+	_, err := relayer.ReplayDeadLetter(ctx, "admin-1", deadLetter.ID)
+	assert.ErrorIs(t, err, ErrReplayUnsafe)
+
+	// So may one that is not mined yet
+	adapter.Hold = true
+	adapter.ExpiresAfter = 2
+	lastTransaction()
+	_, err = relayer.ReplayDeadLetter(ctx, "admin-1", deadLetter.ID)
+	assert.ErrorIs(t, err, ErrReplayUnsafe)
+	assert.Empty(t, relayer.audit.(*MemoryAuditLog).Entries())
+
+	// Once it expired without being included the message is sent again
+	adapter.DropHeld()
+	adapter.Mine(3)
+	_, err = relayer.ReplayDeadLetter(ctx, "admin-1", deadLetter.ID)
+	require.NoError(t, err)
+
+	message, err := relayer.outbox.Get(ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, message.Status)
+	assert.Empty(t, message.TxHash)
+	assert.Zero(t, message.TxExpiry)
+}
+
+func TestTrustedRelayer_RetargetDisbursement(t *testing.T) {
+	relayer := newTestRelayer(t)
+	token := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	relayer.chainConfigs["base"] = &ChainConfig{Enabled: true, Type: config.ChainTypeEVM, TokenAddress: token}
+	pools := relayer.poolStore.(*fakePoolStore)
+	pools.pools = append(pools.pools, &PoolState{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 100000})
+	deadLetter := failMessage(t, relayer, "25")
+	ctx := relayer.ctx
+
+	// The loan was funded from the Ethereum pool
+	require.NoError(t, relayer.poolStore.RecordAllocation(ctx, &Allocation{LoanID: "25", PoolID: "pool_eth", ChainID: "ethereum", Amount: 500}))
+	message, err := relayer.outbox.Get(ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	merchant := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	message.LoanID = "25"
+	message.Payload, err = EncodePayload(MessageTypeLoanDisbursement, &LoanDisbursementPayload{Merchant: merchant, Amount: big.NewInt(500)})
+	require.NoError(t, err)
+	require.NoError(t, relayer.outbox.Save(ctx, message, ""))
+
+	chainID := "base"
+	_, err = relayer.UpdateDeadLetter(ctx, "admin-1", deadLetter.ID, DeadLetterUpdate{ChainID: &chainID})
+	require.NoError(t, err)
+
+	// The reservation and the payload's token follow the loan to Base
+	require.Len(t, pools.recorded, 1)
+	assert.Equal(t, "pool_base", pools.recorded[0].PoolID)
+	assert.Equal(t, "base", pools.recorded[0].ChainID)
+	message, err = relayer.outbox.Get(ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	decoded, err := DecodePayload(message.Type, message.PayloadVersion, message.Payload)
+	require.NoError(t, err)
+	payload := decoded.(*LoanDisbursementPayload)
+	assert.Equal(t, token, payload.Token)
+	assert.Equal(t, merchant, payload.Merchant)
+	assert.Equal(t, int64(500), payload.Amount.Int64())
+
+	// A repayment confirmation belongs to the loan's funding pool
+	confirmation := &DeadLetter{ID: "confirmation", MessageType: MessageTypeRepaymentConfirmation, ChainID: "base", Status: DeadLetterActive}
+	require.NoError(t, relayer.deadLetters.Add(ctx, confirmation))
+	chainID = "ethereum"
+	_, err = relayer.UpdateDeadLetter(ctx, "admin-1", confirmation.ID, DeadLetterUpdate{ChainID: &chainID})
+	assert.ErrorIs(t, err, ErrInvalidReplayTarget)
+}
+
+func TestHandler_DiscardDeadLetter(t *testing.T) {
+	relayer := newTestRelayer(t)
+	deadLetter := failMessage(t, relayer, "23")
+	router := newTestDeadLetterRouter(relayer)
+	path := "/api/v1/admin/relayer/dead-letters/" + deadLetter.ID
+
+	w, _ := serveJSON(t, router, http.MethodPost, path+"/discard", map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = serveJSON(t, router, http.MethodPost, path+"/discard", map[string]interface{}{"reason": "loan cancelled"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	discarded, err := relayer.GetDeadLetter(relayer.ctx, deadLetter.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DeadLetterDiscarded, discarded.Status)
+
+	// Discarding leaves the message failed
+	message, err := relayer.outbox.Get(relayer.ctx, deadLetter.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, message.Status)
+
+	entries := relayer.audit.(*MemoryAuditLog).Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "dead_letter.discard", entries[0].Action)
+	assert.Equal(t, "loan cancelled", entries[0].Details["reason"])
+
+	w, _ = serveJSON(t, router, http.MethodGet, "/api/v1/admin/relayer/dead-letters/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestErrorContext_JSONRoundTrip(t *testing.T) {
+	original := ErrorContext{
+		Operation:   "dispatch",
+		ChainID:     "base",
+		MessageType: MessageTypeRepaymentConfirmation,
+		RetryCount:  2,
+		LastError:   errors.New("execution reverted"),
+		Timestamp:   time.Unix(1700000000, 0).UTC(),
+		Duration:    time.Second,
+	}
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"last_error":"execution reverted"`)
+
+	var decoded ErrorContext
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.EqualError(t, decoded.LastError, "execution reverted")
+	decoded.LastError = original.LastError
+	assert.Equal(t, original, decoded)
+}
@@ -0,0 +1,286 @@
+//go:build e2e
+
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The end-to-end tests run the relayer against contracts deployed on go-ethereum's
+// simulated backend. They deploy the contracts compiled to contracts/build/combined.json,
+// which is not committed. Generate it first (see generate.go), or the tests are skipped:
+//
+//	go generate ./pkg/relayer
+//	go test -tags=e2e ./pkg/relayer -run E2E
+
+const (
+	e2eSourceEID      = 30101
+	e2eDestinationEID = 30184
+)
+
+var e2eContractsPath = filepath.Join("..", "..", "..", "contracts", "build", "combined.json")
+
+// e2eContract is a compiled contract from the solc combined JSON output
+type e2eContract struct {
+	ABI abi.ABI
+	Bin []byte
+}
+
+// loadE2EContracts reads the compiled contracts, keyed by contract name
+func loadE2EContracts(t *testing.T) map[string]*e2eContract {
+	data, err := os.ReadFile(e2eContractsPath)
+	if os.IsNotExist(err) {
+		t.Skipf("contracts are not compiled: %s not found, run go generate ./pkg/relayer with solc 0.8 on the PATH", e2eContractsPath)
+	}
+	require.NoError(t, err)
+
+	var combined struct {
+		Contracts map[string]struct {
+			ABI json.RawMessage `json:"abi"`
+			Bin string          `json:"bin"`
+		} `json:"contracts"`
+	}
+	require.NoError(t, json.Unmarshal(data, &combined))
+
+	contracts := make(map[string]*e2eContract)
+	for key, compiled := range combined.Contracts {
+		// Older solc versions encode the ABI as a JSON string
+		definition := compiled.ABI
+		var quoted string
+		if json.Unmarshal(definition, &quoted) == nil {
+			definition = json.RawMessage(quoted)
+		}
+		parsed, err := abi.JSON(strings.NewReader(string(definition)))
+		require.NoError(t, err, key)
+		contracts[key[strings.LastIndex(key, ":")+1:]] = &e2eContract{ABI: parsed, Bin: common.FromHex(compiled.Bin)}
+	}
+	return contracts
+}
+
+// simulatedChain adds the block number the EVM adapter reads to the simulated backend
+type simulatedChain struct {
+	*backends.SimulatedBackend
+}
+
+func (s simulatedChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return s.Blockchain().CurrentBlock().Number.Uint64(), nil
+}
+
+// e2eEnvironment is a simulated chain with the Kelo contracts deployed. It plays both
+// the LayerZero source chain and the destination: the mock endpoint delivers each
+// message to the receiver in the transaction that sends it.
+type e2eEnvironment struct {
+	t         *testing.T
+	sim       *backends.SimulatedBackend
+	contracts map[string]*e2eContract
+	deployer  *bind.TransactOpts
+
+	token    common.Address
+	endpoint common.Address
+	receiver common.Address
+	pool     common.Address
+}
+
+func newE2EEnvironment(t *testing.T, relayerAddress common.Address) *e2eEnvironment {
+	contracts := loadE2EContracts(t)
+
+	deployerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	deployer, err := bind.NewKeyedTransactorWithChainID(deployerKey, big.NewInt(1337))
+	require.NoError(t, err)
+
+	funds := new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		deployer.From:  {Balance: funds},
+		relayerAddress: {Balance: funds},
+	}, 30000000)
+	t.Cleanup(func() { sim.Close() })
+
+	env := &e2eEnvironment{t: t, sim: sim, contracts: contracts, deployer: deployer}
+	env.token = env.deploy("MockERC20", "USD Coin", "USDC")
+	env.endpoint = env.deploy("MockLayerZeroEndpoint", uint32(e2eSourceEID), big.NewInt(1e15))
+	env.receiver = env.deploy("LayerZeroEVMReceiver", env.endpoint, common.Address{})
+	env.pool = env.deploy("KeloLiquidityPool", env.token, env.token, env.receiver)
+
+	env.transact("LayerZeroEVMReceiver", env.receiver, "setKeloLiquidityPool", env.pool)
+	env.transact("LayerZeroEVMReceiver", env.receiver, "setPeer", uint32(e2eSourceEID), common.BytesToHash(relayerAddress.Bytes()))
+	env.transact("LayerZeroEVMReceiver", env.receiver, "setSigner", uint32(1), relayerAddress, true)
+	env.transact("MockERC20", env.token, "mint", env.pool, big.NewInt(1000000))
+	return env
+}
+
+// deploy deploys a contract and mines it
+func (e *e2eEnvironment) deploy(name string, params ...interface{}) common.Address {
+	contract, ok := e.contracts[name]
+	require.True(e.t, ok, "contract %s is not compiled", name)
+	address, _, _, err := bind.DeployContract(e.deployer, contract.ABI, contract.Bin, e.sim, params...)
+	require.NoError(e.t, err, name)
+	e.sim.Commit()
+	return address
+}
+
+// transact calls a contract as the deployer and requires it to succeed
+func (e *e2eEnvironment) transact(name string, address common.Address, method string, params ...interface{}) {
+	bound := bind.NewBoundContract(address, e.contracts[name].ABI, e.sim, e.sim, e.sim)
+	tx, err := bound.Transact(e.deployer, method, params...)
+	require.NoError(e.t, err, method)
+	e.sim.Commit()
+
+	receipt, err := e.sim.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(e.t, err)
+	require.Equal(e.t, types.ReceiptStatusSuccessful, receipt.Status, method)
+}
+
+// call reads a single value from a contract
+func (e *e2eEnvironment) call(name string, address common.Address, method string, params ...interface{}) interface{} {
+	bound := bind.NewBoundContract(address, e.contracts[name].ABI, e.sim, e.sim, e.sim)
+	var out []interface{}
+	require.NoError(e.t, bound.Call(&bind.CallOpts{}, &out, method, params...), method)
+	return out[0]
+}
+
+// newE2ERelayer wires a relayer to the environment: the simulated chain is the
+// LayerZero source chain "ethereum" and the receiver's chain "base".
+func newE2ERelayer(t *testing.T) (*TrustedRelayer, *e2eEnvironment) {
+	relayer := newTestRelayer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	relayer.ctx = ctx
+
+	env := newE2EEnvironment(t, relayer.signer.Address())
+
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.config.LayerZeroEndpoint = env.endpoint.Hex()
+	relayer.config.RelayerSignerSetVersion = 1
+	relayer.config.MaxRetries = 3
+
+	relayer.chainConfigs["ethereum"].Type = config.ChainTypeEVM
+	relayer.chainConfigs["ethereum"].LayerZeroEID = e2eSourceEID
+	relayer.chainConfigs["base"] = &ChainConfig{
+		ChainID:           "1337",
+		Type:              config.ChainTypeEVM,
+		TokenAddress:      env.token,
+		LayerZeroEID:      e2eDestinationEID,
+		LayerZeroReceiver: env.receiver,
+		GasLimit:          500000,
+		Enabled:           true,
+	}
+	relayer.poolStore.(*fakePoolStore).pools = []*PoolState{
+		{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 1000000},
+	}
+
+	source := blockchain.NewEVMAdapter(config.ChainSpec{
+		Key:           "ethereum",
+		Type:          config.ChainTypeEVM,
+		EVMChainID:    1337,
+		LayerZeroEID:  e2eSourceEID,
+		Confirmations: 2,
+	}, simulatedChain{env.sim})
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+
+	tm := NewTransactionManager(relayer.chains, relayer.signer, NewNonceManager(relayer.chains, NewMemoryNonceStore()), ctx)
+	relayer.transactionManager = tm
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	lzClient.SetSubmitter(tm)
+	relayer.layerZeroClient = lzClient
+	return relayer, env
+}
+
+func TestE2E_LoanApprovalDisbursesFromPool(t *testing.T) {
+	relayer, env := newE2ERelayer(t)
+	merchant := common.HexToAddress("0x0987654321098765432109876543210987654321")
+
+	event := &LoanApprovalEvent{
+		TokenID:      big.NewInt(1),
+		Borrower:     common.HexToAddress("0x1234567890123456789012345678901234567890"),
+		Merchant:     merchant,
+		Amount:       big.NewInt(1000),
+		InterestRate: big.NewInt(5),
+		Duration:     big.NewInt(30),
+		Timestamp:    time.Now(),
+	}
+	require.NoError(t, relayer.handleLoanApproval(event))
+	messageID := NewMessageID(MessageTypeLoanDisbursement, "1")
+
+	// The relayer signs the message and sends it through the endpoint
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, messageID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status, sent.LastError)
+	assert.Equal(t, "ethereum", sent.TxChainID)
+	assert.NotEmpty(t, sent.LayerZeroGUID)
+
+	// Mined, but not final until the second confirmation
+	env.sim.Commit()
+	relayer.reconcileSent()
+	mined, _ := relayer.outbox.Get(relayer.ctx, messageID)
+	assert.Equal(t, StatusSent, mined.Status)
+	assert.NotEmpty(t, mined.BlockHash)
+
+	env.sim.Commit()
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, messageID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, uint64(1), relayer.metrics.MessagesConfirmed)
+
+	// The receiver verified the signature and the pool paid the merchant
+	assert.Equal(t, big.NewInt(1000), env.call("MockERC20", env.token, "balanceOf", merchant))
+	assert.Equal(t, big.NewInt(999000), env.call("MockERC20", env.token, "balanceOf", env.pool))
+	assert.Equal(t, true, env.call("LayerZeroEVMReceiver", env.receiver, "usedNonces", MessageNonce(messageID)))
+
+	// The endpoint assigned the GUID the relayer recorded
+	logs, err := env.sim.FilterLogs(context.Background(), ethereum.FilterQuery{
+		Addresses: []common.Address{env.endpoint},
+		Topics:    [][]common.Hash{{env.contracts["MockLayerZeroEndpoint"].ABI.Events["PacketDelivered"].ID}},
+	})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, confirmed.LayerZeroGUID, logs[0].Topics[1].Hex())
+}
+
+func TestE2E_ReceiverRejectsReplayedMessage(t *testing.T) {
+	relayer, env := newE2ERelayer(t)
+
+	payload, err := EncodePayload(MessageTypeRepaymentConfirmation, &RepaymentConfirmationPayload{
+		LoanID:      big.NewInt(2),
+		Payer:       common.HexToAddress("0x1234567890123456789012345678901234567890"),
+		Amount:      big.NewInt(500),
+		TotalRepaid: big.NewInt(500),
+		Timestamp:   uint64(time.Now().Unix()),
+	})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeRepaymentConfirmation, "2:500", "base", payload)
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+	env.sim.Commit()
+	assert.Equal(t, big.NewInt(500), env.call("KeloLiquidityPool", env.pool, "totalRepaid"))
+
+	// Sending the same message again, even freshly signed, reuses its nonce
+	_, err = relayer.dispatchMessage(relayer.ctx, message)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Nonce already used")
+	assert.Equal(t, big.NewInt(500), env.call("KeloLiquidityPool", env.pool, "totalRepaid"))
+}
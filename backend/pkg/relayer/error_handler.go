@@ -2,8 +2,13 @@ package relayer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -59,6 +64,36 @@ type ErrorContext struct {
 	Duration      time.Duration `json:"duration"`
 }
 
+// MarshalJSON stores LastError as its message, since error values do not marshal.
+func (c ErrorContext) MarshalJSON() ([]byte, error) {
+	type plain ErrorContext
+	var lastError string
+	if c.LastError != nil {
+		lastError = c.LastError.Error()
+	}
+	return json.Marshal(struct {
+		plain
+		LastError string `json:"last_error,omitempty"`
+	}{plain(c), lastError})
+}
+
+// UnmarshalJSON restores LastError from its message.
+func (c *ErrorContext) UnmarshalJSON(data []byte) error {
+	type plain ErrorContext
+	var decoded struct {
+		plain
+		LastError string `json:"last_error"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*c = ErrorContext(decoded.plain)
+	if decoded.LastError != "" {
+		c.LastError = errors.New(decoded.LastError)
+	}
+	return nil
+}
+
 // RecoveryStrategy defines how to recover from different types of errors
 type RecoveryStrategy interface {
 	ShouldRetry(err error, ctx *ErrorContext) bool
@@ -174,7 +209,7 @@ func (eh *ErrorHandler) getCircuitBreaker(operation string) *CircuitBreaker {
 // shouldRetry determines if an error should be retried
 func (eh *ErrorHandler) shouldRetry(err error, ctx *ErrorContext) bool {
 	// Don't retry context errors
-	if err == context.Canceled || err == context.DeadlineExceeded {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false
 	}
 	
@@ -184,23 +219,65 @@ func (eh *ErrorHandler) shouldRetry(err error, ctx *ErrorContext) bool {
 	}
 	
 	// Check error type
-	switch err.(type) {
-	case *TemporaryError:
+	switch ErrorType(err) {
+	case "temporary", "rate_limit", "network":
 		return true
-	case *RateLimitError:
-		return true
-	case *NetworkError:
-		return true
-	case *ValidationError:
-		return false // Validation errors are permanent
-	case *AuthenticationError:
-		return false // Authentication errors are permanent
+	case "validation", "authentication":
+		return false // Validation and authentication errors are permanent
 	default:
 		// For unknown errors, retry up to max retries
 		return ctx.RetryCount < eh.maxRetries
 	}
 }
 
+// NextRetry reports whether an operation that failed with ctx.LastError after
+// ctx.RetryCount attempts should be tried again, and after how long. A rate limit's
+// RetryAfter is honoured when it is longer than the backoff.
+func (eh *ErrorHandler) NextRetry(ctx *ErrorContext) (time.Duration, bool) {
+	if !eh.shouldRetry(ctx.LastError, ctx) {
+		return 0, false
+	}
+	delay := eh.calculateDelay(ctx.RetryCount-1, ctx)
+	var rateLimit *RateLimitError
+	if errors.As(ctx.LastError, &rateLimit) && rateLimit.RetryAfter > delay {
+		delay = rateLimit.RetryAfter
+	}
+	return delay, true
+}
+
+// CircuitBreaker returns the circuit breaker with the given name, creating it on first use
+func (eh *ErrorHandler) CircuitBreaker(name string) *CircuitBreaker {
+	return eh.getCircuitBreaker(name)
+}
+
+// CircuitBreakerStats returns the statistics of every circuit breaker, ordered by name
+func (eh *ErrorHandler) CircuitBreakerStats() []map[string]interface{} {
+	eh.cbMutex.RLock()
+	defer eh.cbMutex.RUnlock()
+	
+	stats := make([]map[string]interface{}, 0, len(eh.circuitBreakers))
+	for _, cb := range eh.circuitBreakers {
+		stats = append(stats, cb.GetStats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i]["name"].(string) < stats[j]["name"].(string) })
+	return stats
+}
+
+// OpenCircuits returns the names of the circuit breakers that are open
+func (eh *ErrorHandler) OpenCircuits() []string {
+	eh.cbMutex.RLock()
+	defer eh.cbMutex.RUnlock()
+	
+	var open []string
+	for name, cb := range eh.circuitBreakers {
+		if cb.GetState() == CircuitOpen {
+			open = append(open, name)
+		}
+	}
+	sort.Strings(open)
+	return open
+}
+
 // calculateDelay calculates the delay before the next retry
 func (eh *ErrorHandler) calculateDelay(attempt int, ctx *ErrorContext) time.Duration {
 	// Exponential backoff with jitter
@@ -259,9 +336,10 @@ func (cb *CircuitBreaker) OnSuccess() {
 	if cb.state == CircuitHalfOpen {
 		// Reset to closed state
 		cb.state = CircuitClosed
-		cb.failures = 0
 		log.Info().Str("circuit_breaker", cb.name).Msg("Circuit breaker reset to closed state")
 	}
+	// Only consecutive failures open the circuit
+	cb.failures = 0
 }
 
 // OnFailure is called when an operation fails
@@ -272,7 +350,8 @@ func (cb *CircuitBreaker) OnFailure() {
 	cb.failures++
 	cb.lastFailure = time.Now()
 	
-	if cb.failures >= cb.maxFailures {
+	// A failed trial attempt reopens the circuit straight away
+	if cb.failures >= cb.maxFailures || cb.state == CircuitHalfOpen {
 		if cb.state != CircuitOpen {
 			cb.state = CircuitOpen
 			log.Warn().
@@ -284,6 +363,13 @@ func (cb *CircuitBreaker) OnFailure() {
 	}
 }
 
+// RetryAt returns when an open circuit breaker will let the next attempt through
+func (cb *CircuitBreaker) RetryAt() time.Time {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+	return cb.lastFailure.Add(cb.resetTimeout)
+}
+
 // GetState returns the current state of the circuit breaker
 func (cb *CircuitBreaker) GetState() CircuitState {
 	cb.mutex.RLock()
@@ -318,6 +404,11 @@ func (cs CircuitState) String() string {
 	}
 }
 
+// MarshalText encodes the state as its name
+func (cs CircuitState) MarshalText() ([]byte, error) {
+	return []byte(cs.String()), nil
+}
+
 // Error types for different failure scenarios
 
 // TemporaryError represents a temporary error that should be retried
@@ -386,6 +477,73 @@ func (e *AuthenticationError) Unwrap() error {
 	return e.Err
 }
 
+// ClassifyError wraps an untyped error from a chain client, RPC node or API in the error
+// type that describes it, so retries and circuit breakers can treat it accordingly.
+// Errors that are already typed, context errors and unrecognised errors are returned
+// unchanged.
+func ClassifyError(err error) error {
+	if err == nil || ErrorType(err) != "unknown" {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &NetworkError{Err: err}
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case containsAny(message, "429", "too many requests", "rate limit"):
+		return &RateLimitError{Err: err}
+	case containsAny(message, "401", "403", "unauthorized", "forbidden", "invalid api key"):
+		return &AuthenticationError{Err: err}
+	case containsAny(message, "connection refused", "connection reset", "no such host", "i/o timeout", "tls handshake", "unexpected eof"):
+		return &NetworkError{Err: err}
+	case containsAny(message, "execution reverted", "unsupported message type", "failed to decode"):
+		return &ValidationError{Err: err}
+	case isNonceUsedError(err), containsAny(message, "underpriced", "insufficient funds", "timeout", "502", "503", "504"):
+		return &TemporaryError{Err: err}
+	}
+	return err
+}
+
+// ErrorType returns a short name for the type of err, used in metrics and logs
+func ErrorType(err error) string {
+	var (
+		temporary      *TemporaryError
+		rateLimit      *RateLimitError
+		network        *NetworkError
+		validation     *ValidationError
+		authentication *AuthenticationError
+	)
+	switch {
+	case errors.As(err, &rateLimit):
+		return "rate_limit"
+	case errors.As(err, &network):
+		return "network"
+	case errors.As(err, &temporary):
+		return "temporary"
+	case errors.As(err, &validation):
+		return "validation"
+	case errors.As(err, &authentication):
+		return "authentication"
+	default:
+		return "unknown"
+	}
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
 // RetryableOperation represents an operation that can be retried
 type RetryableOperation struct {
 	Name        string
@@ -0,0 +1,6 @@
+package relayer
+
+// The e2e tests deploy the contracts compiled to contracts/build/combined.json. It is
+// build output and not committed, so generate it before running them, with solc 0.8
+// on the PATH.
+//go:generate sh -c "cd ../../.. && npm install --no-save @openzeppelin/contracts@4.9.6 && solc @openzeppelin/=node_modules/@openzeppelin/ --combined-json abi,bin --overwrite -o contracts/build contracts/evm/KeloLiquidityPool.sol contracts/layerzero/LayerZeroEVMReceiver.sol contracts/test/MockERC20.sol contracts/test/MockLayerZeroEndpoint.sol"
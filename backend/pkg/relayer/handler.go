@@ -1,7 +1,9 @@
 package relayer
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"kelo-backend/pkg/middleware"
 	"kelo-backend/pkg/utils"
@@ -29,17 +31,30 @@ func (h *Handler) RegisterRoutes(router gin.IRouter) {
 
 	admin.GET("/status", h.GetRelayerStatus)
 	admin.GET("/metrics", h.GetRelayerMetrics)
+	admin.GET("/limits", h.GetLimitUsage)
 	admin.GET("/messages/:id", h.GetMessageStatus)
+
+	admin.GET("/dead-letters", h.ListDeadLetters)
+	admin.GET("/dead-letters/:id", h.GetDeadLetter)
+	admin.PATCH("/dead-letters/:id", h.UpdateDeadLetter)
+	admin.POST("/dead-letters/:id/replay", h.ReplayDeadLetter)
+	admin.POST("/dead-letters/:id/discard", h.DiscardDeadLetter)
+
+	admin.GET("/chains", h.ListChainControls)
+	admin.PATCH("/chains/:chain", h.UpdateChain)
+	admin.POST("/chains/:chain/pause", h.PauseChain)
+	admin.POST("/chains/:chain/resume", h.ResumeChain)
+	admin.POST("/chains/:chain/drain", h.DrainChain)
+
+	admin.PUT("/dry-run", h.SetDryRun)
+	admin.GET("/dry-runs", h.ListDryRuns)
+	admin.POST("/messages/:id/dry-run", h.DryRunMessage)
 }
 
-// GetRelayerStatus provides a simple status check for the relayer.
+// GetRelayerStatus reports the relayer's health checks, circuit breakers and active
+// alerts.
 func (h *Handler) GetRelayerStatus(c *gin.Context) {
-	// In a real-world scenario, this would check the health of underlying connections.
-	// For now, we'll return a simple "running" status if the handler is active.
-	utils.WriteSuccessResponse(c, gin.H{
-		"status": "running",
-		"address": h.service.publicAddress.Hex(),
-	})
+	utils.WriteSuccessResponse(c, h.service.GetStatus())
 }
 
 // GetRelayerMetrics returns the current performance metrics for the relayer.
@@ -48,6 +63,12 @@ func (h *Handler) GetRelayerMetrics(c *gin.Context) {
 	utils.WriteSuccessResponse(c, metrics)
 }
 
+// GetLimitUsage returns each chain's submission limits, its usage of them and the limit
+// its sends are paused by, if any.
+func (h *Handler) GetLimitUsage(c *gin.Context) {
+	utils.WriteSuccessResponse(c, h.service.GetLimitUsage())
+}
+
 // GetMessageStatus returns the status of a specific message being processed by the relayer.
 func (h *Handler) GetMessageStatus(c *gin.Context) {
 	messageID := c.Param("id")
@@ -57,10 +78,228 @@ func (h *Handler) GetMessageStatus(c *gin.Context) {
 	}
 
 	message, err := h.service.GetMessageStatus(messageID)
-	if err != nil {
+	if errors.Is(err, ErrMessageNotFound) {
 		utils.WriteErrorResponse(c, http.StatusNotFound, err.Error())
 		return
 	}
+	if err != nil {
+		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	utils.WriteSuccessResponse(c, message)
+}
+
+// ListDeadLetters lists dead-lettered messages, newest first. Only active dead letters
+// are listed unless a status is given; status=all lists every dead letter.
+func (h *Handler) ListDeadLetters(c *gin.Context) {
+	status := DeadLetterStatus(c.DefaultQuery("status", string(DeadLetterActive)))
+	if status == "all" {
+		status = ""
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	deadLetters, err := h.service.ListDeadLetters(c.Request.Context(), status, limit)
+	if err != nil {
+		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteSuccessResponse(c, deadLetters)
+}
+
+// GetDeadLetter returns a dead letter with its failed attempts.
+func (h *Handler) GetDeadLetter(c *gin.Context) {
+	deadLetter, err := h.service.GetDeadLetter(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, deadLetter)
+}
+
+// UpdateDeadLetter changes the chain or gas limit a dead letter will be replayed with.
+func (h *Handler) UpdateDeadLetter(c *gin.Context) {
+	var req DeadLetterUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ChainID == nil && req.GasLimit == nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "chain_id or gas_limit is required")
+		return
+	}
+
+	deadLetter, err := h.service.UpdateDeadLetter(c.Request.Context(), c.GetString("userID"), c.Param("id"), req)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, deadLetter)
+}
+
+// ReplayDeadLetter returns a dead-lettered message to the outbox.
+func (h *Handler) ReplayDeadLetter(c *gin.Context) {
+	deadLetter, err := h.service.ReplayDeadLetter(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, deadLetter)
+}
+
+// DiscardDeadLetter drops a dead letter without replaying its message.
+func (h *Handler) DiscardDeadLetter(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	deadLetter, err := h.service.DiscardDeadLetter(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Reason)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, deadLetter)
+}
+
+// ListChainControls returns each chain's sending state, enabled flag and gas limit.
+func (h *Handler) ListChainControls(c *gin.Context) {
+	utils.WriteSuccessResponse(c, h.service.ChainControls())
+}
+
+// UpdateChain enables or disables a chain for new allocations or changes its gas limit.
+func (h *Handler) UpdateChain(c *gin.Context) {
+	var req ChainUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Enabled == nil && req.GasLimit == nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "enabled or gas_limit is required")
+		return
+	}
+
+	control, err := h.service.UpdateChain(c.Request.Context(), c.GetString("userID"), c.Param("chain"), req)
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, control)
+}
+
+// PauseChain holds a chain's messages in the outbox until it is resumed.
+func (h *Handler) PauseChain(c *gin.Context) {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	control, err := h.service.PauseChain(c.Request.Context(), c.GetString("userID"), c.Param("chain"), reason)
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, control)
+}
+
+// ResumeChain returns a paused or draining chain to sending.
+func (h *Handler) ResumeChain(c *gin.Context) {
+	control, err := h.service.ResumeChain(c.Request.Context(), c.GetString("userID"), c.Param("chain"))
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, control)
+}
+
+// DrainChain moves a chain's unsent messages to the dead-letter queue.
+func (h *Handler) DrainChain(c *gin.Context) {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	control, err := h.service.DrainChain(c.Request.Context(), c.GetString("userID"), c.Param("chain"), reason)
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, control)
+}
+
+// SetDryRun switches dry-run mode, in which transactions are signed but not broadcast.
+func (h *Handler) SetDryRun(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "enabled is required")
+		return
+	}
+
+	if err := h.service.SetDryRun(c.Request.Context(), c.GetString("userID"), *req.Enabled); err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, gin.H{"dry_run": *req.Enabled})
+}
+
+// ListDryRuns returns the most recent dry-run results.
+func (h *Handler) ListDryRuns(c *gin.Context) {
+	utils.WriteSuccessResponse(c, h.service.DryRunResults())
+}
+
+// DryRunMessage builds and signs a message's transaction without broadcasting it.
+func (h *Handler) DryRunMessage(c *gin.Context) {
+	result, err := h.service.DryRunMessage(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		writeControlError(c, err)
+		return
+	}
+	utils.WriteSuccessResponse(c, result)
+}
+
+// bindReason reads the reason operators give for pausing or draining a chain.
+func bindReason(c *gin.Context) (string, bool) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c, http.StatusBadRequest, "A reason is required")
+		return "", false
+	}
+	return req.Reason, true
+}
+
+// writeControlError maps control-plane errors to HTTP status codes.
+func writeControlError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownChain), errors.Is(err, ErrMessageNotFound):
+		utils.WriteErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidControl):
+		utils.WriteErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeDeadLetterError maps dead-letter errors to HTTP status codes.
+func writeDeadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound), errors.Is(err, ErrMessageNotFound):
+		utils.WriteErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidReplayTarget):
+		utils.WriteErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrDeadLetterResolved), errors.Is(err, ErrReplayUnsafe):
+		utils.WriteErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		utils.WriteErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
 }
\ No newline at end of file
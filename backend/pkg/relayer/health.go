@@ -0,0 +1,236 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Retry and health settings
+const (
+	maxRetryBackoff    = 5 * time.Minute
+	healthCheckTimeout = 10 * time.Second
+	// failureRateAlertMinimum is how many messages must have been processed before the
+	// failure rate can raise an alert
+	failureRateAlertMinimum = 10
+)
+
+// newRelayerErrorHandler creates the error handler behind outbox retries. The number of
+// attempts a message gets comes from the relayer's MaxRetries setting, so the handler
+// only decides whether an error is worth retrying and how long to wait.
+func newRelayerErrorHandler() *ErrorHandler {
+	return NewErrorHandler(&RetryConfig{
+		MaxRetries:    math.MaxInt32,
+		BaseDelay:     retryBackoff,
+		MaxDelay:      maxRetryBackoff,
+		BackoffFactor: 2.0,
+	})
+}
+
+// RelayerStatus is the health of the relayer as reported to operators
+type RelayerStatus struct {
+	Status          HealthStatus             `json:"status"`
+	Address         string                   `json:"address"`
+	HealthChecks    map[string]*HealthCheck  `json:"health_checks"`
+	CircuitBreakers []map[string]interface{} `json:"circuit_breakers"`
+	Limits          []ChainLimitUsage        `json:"limits"`
+	Alerts          []*Alert                 `json:"alerts"`
+	RecentEvents    []*Event                 `json:"recent_events"`
+	Metrics         *RelayerMetrics          `json:"metrics"`
+}
+
+// GetStatus returns the relayer's health checks, circuit breakers, limit usage and
+// active alerts. The relayer is unhealthy if a health check fails and degraded while a
+// chain's circuit is open or its sends are paused by a limit.
+func (tr *TrustedRelayer) GetStatus() *RelayerStatus {
+	status := &RelayerStatus{
+		Status:          tr.monitor.GetHealthStatus(),
+		Address:         tr.publicAddress.Hex(),
+		HealthChecks:    tr.monitor.GetHealthChecks(),
+		CircuitBreakers: tr.errorHandler.CircuitBreakerStats(),
+		Limits:          tr.limits.Usage(tr.ctx),
+		Alerts:          tr.monitor.GetActiveAlerts(),
+		RecentEvents:    tr.monitor.GetRecentEvents(20),
+		Metrics:         tr.GetMetrics(),
+	}
+	if status.Status == HealthStatusHealthy && (len(tr.errorHandler.OpenCircuits()) > 0 || len(tr.limits.PausedChains()) > 0) {
+		status.Status = HealthStatusDegraded
+	}
+	return status
+}
+
+// NewHealthHandler serves the relayer's probes for cmd/relayer: /healthz returns its
+// status, with 503 while it is unhealthy, and /metrics the Prometheus metrics.
+func NewHealthHandler(tr *TrustedRelayer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", tr.serveHealthz)
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+func (tr *TrustedRelayer) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	status := tr.GetStatus()
+	code := http.StatusOK
+	if status.Status == HealthStatusUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// recordMessageEvent records a message lifecycle event with the monitor
+func (tr *TrustedRelayer) recordMessageEvent(eventType EventType, status EventStatus, message *Message, duration time.Duration, err error) {
+	event := &Event{
+		ID:          generateEventID(),
+		Type:        eventType,
+		ChainID:     message.ChainID,
+		MessageType: message.Type,
+		Timestamp:   time.Now(),
+		Duration:    duration,
+		Status:      status,
+		Metadata: map[string]interface{}{
+			"message_id":  message.ID,
+			"retry_count": message.RetryCount,
+		},
+	}
+	if message.TxHash != "" {
+		event.Metadata["tx_hash"] = message.TxHash
+	}
+	if err != nil {
+		event.Error = err.Error()
+		event.Metadata["error_type"] = ErrorType(err)
+	}
+	tr.monitor.RecordEvent(event)
+}
+
+// recordLimitEvent records a message held back by a chain limit. The event belongs to the
+// chain whose limit was reached.
+func (tr *TrustedRelayer) recordLimitEvent(message *Message, exceeded *LimitExceeded) {
+	tr.monitor.RecordEvent(&Event{
+		ID:          generateEventID(),
+		Type:        EventTypeLimitReached,
+		ChainID:     exceeded.ChainID,
+		MessageType: message.Type,
+		Timestamp:   time.Now(),
+		Status:      EventStatusWarning,
+		Metadata: map[string]interface{}{
+			"message_id": message.ID,
+			"limit":      exceeded.Limit,
+			"used":       exceeded.Used,
+			"max":        exceeded.Max,
+			"retry_at":   exceeded.RetryAt,
+		},
+	})
+}
+
+// recordReorgEvent records a reorg that moved a message's transaction to blockHash, or
+// dropped it. The event belongs to the chain the transaction was sent on.
+func (tr *TrustedRelayer) recordReorgEvent(message *Message, outcome, blockHash string) {
+	event := &Event{
+		ID:          generateEventID(),
+		Type:        EventTypeChainReorg,
+		ChainID:     message.TxChainID,
+		MessageType: message.Type,
+		Timestamp:   time.Now(),
+		Status:      EventStatusWarning,
+		Metadata: map[string]interface{}{
+			"message_id":     message.ID,
+			"tx_hash":        message.TxHash,
+			"outcome":        outcome,
+			"old_block_hash": message.BlockHash,
+			"block_number":   message.BlockNumber,
+		},
+	}
+	if blockHash != "" {
+		event.Metadata["block_hash"] = blockHash
+	}
+	tr.monitor.RecordEvent(event)
+}
+
+// registerHealthChecks checks that every configured chain answers and that the outbox
+// can be read
+func (tr *TrustedRelayer) registerHealthChecks() {
+	if tr.chains != nil {
+		for _, chainID := range tr.chains.Chains() {
+			adapter, err := tr.chains.Adapter(chainID)
+			if err != nil {
+				continue
+			}
+			tr.monitor.AddHealthCheck("chain:"+chainID, func() error {
+				ctx, cancel := context.WithTimeout(tr.ctx, healthCheckTimeout)
+				defer cancel()
+				_, err := adapter.EstimateFee(ctx)
+				return err
+			})
+		}
+	}
+
+	tr.monitor.AddHealthCheck("outbox", func() error {
+		ctx, cancel := context.WithTimeout(tr.ctx, healthCheckTimeout)
+		defer cancel()
+		_, err := tr.outbox.ListByStatus(ctx, StatusPending, 1)
+		return err
+	})
+}
+
+// registerAlertRules raises alerts for open circuits, chains paused by a limit, failing
+// health checks and a high message failure rate
+func (tr *TrustedRelayer) registerAlertRules() {
+	tr.monitor.AddAlertRule(&AlertRule{
+		Name:     "circuit_open",
+		Severity: SeverityHigh,
+		Message:  "Sends to a chain keep failing and are paused by its circuit breaker",
+		Enabled:  true,
+		Condition: func(m *Monitor) bool {
+			return len(tr.errorHandler.OpenCircuits()) > 0
+		},
+	})
+	tr.monitor.AddAlertRule(&AlertRule{
+		Name:     "submission_limit_reached",
+		Severity: SeverityHigh,
+		Message:  "Sends to a chain reached a rate or spend limit and are paused",
+		Enabled:  true,
+		Condition: func(m *Monitor) bool {
+			return len(tr.limits.PausedChains()) > 0
+		},
+	})
+	tr.monitor.AddAlertRule(&AlertRule{
+		Name:     "health_check_failing",
+		Severity: SeverityCritical,
+		Message:  "A relayer health check is failing",
+		Enabled:  true,
+		Condition: func(m *Monitor) bool {
+			return m.GetHealthStatus() == HealthStatusUnhealthy
+		},
+	})
+	tr.monitor.AddAlertRule(&AlertRule{
+		Name:     "high_failure_rate",
+		Severity: SeverityMedium,
+		Message:  "More than half of the recent message attempts failed",
+		Enabled:  true,
+		Condition: func(m *Monitor) bool {
+			return highFailureRate(m.GetRecentEvents(100))
+		},
+	})
+}
+
+// highFailureRate reports whether more than half of the processed messages among the
+// events failed
+func highFailureRate(events []*Event) bool {
+	processed, failed := 0, 0
+	for _, event := range events {
+		if event.Type != EventTypeMessageProcessed {
+			continue
+		}
+		processed++
+		if event.Status == EventStatusFailure {
+			failed++
+		}
+	}
+	return processed >= failureRateAlertMinimum && failed*2 > processed
+}
@@ -0,0 +1,214 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("429 Too Many Requests"), "rate_limit"},
+		{fmt.Errorf("failed to send transaction: %w", errors.New("dial tcp: connection refused")), "network"},
+		{errors.New("execution reverted: INSUFFICIENT_LIQUIDITY"), "validation"},
+		{errors.New("401 Unauthorized"), "authentication"},
+		{errors.New("nonce too low"), "temporary"},
+		{&NetworkError{Err: errors.New("already typed")}, "network"},
+		{errors.New("something else"), "unknown"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorType(ClassifyError(tt.err)), tt.err.Error())
+	}
+
+	assert.Nil(t, ClassifyError(nil))
+	assert.Equal(t, context.Canceled, ClassifyError(context.Canceled))
+}
+
+func TestCircuitBreaker_OpensOnConsecutiveFailures(t *testing.T) {
+	cb := NewCircuitBreaker("base", 2, time.Minute)
+
+	// A success in between resets the count
+	cb.OnFailure()
+	cb.OnSuccess()
+	cb.OnFailure()
+	assert.Equal(t, CircuitClosed, cb.GetState())
+
+	cb.OnFailure()
+	assert.Equal(t, CircuitOpen, cb.GetState())
+	assert.False(t, cb.Allow())
+
+	// After the reset timeout one failed trial reopens the circuit
+	cb.lastFailure = time.Now().Add(-2 * time.Minute)
+	assert.True(t, cb.Allow())
+	assert.Equal(t, CircuitHalfOpen, cb.GetState())
+	cb.OnFailure()
+	assert.Equal(t, CircuitOpen, cb.GetState())
+}
+
+func TestTrustedRelayer_RetryOrFailUsesErrorType(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 5
+
+	// Validation errors are not retried
+	message := newPendingMessage(MessageTypeLoanDisbursement, "30", "ethereum", []byte{1})
+	message.Status = StatusProcessing
+	relayer.retryOrFail(message, "dispatch", &ValidationError{Err: errors.New("execution reverted")}, 0)
+	assert.Equal(t, StatusFailed, message.Status)
+
+	// Rate limits wait at least as long as asked
+	message = newPendingMessage(MessageTypeLoanDisbursement, "31", "ethereum", []byte{1})
+	message.Status = StatusProcessing
+	relayer.retryOrFail(message, "dispatch", &RateLimitError{RetryAfter: time.Hour, Err: errors.New("429")}, 0)
+	assert.Equal(t, StatusRetrying, message.Status)
+	assert.True(t, message.NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+
+	// Backoff grows with the number of attempts
+	message = newPendingMessage(MessageTypeLoanDisbursement, "32", "ethereum", []byte{1})
+	message.Status = StatusProcessing
+	relayer.retryOrFail(message, "dispatch", errors.New("boom"), 0)
+	first := time.Until(message.NextAttemptAt)
+	message.Status = StatusProcessing
+	relayer.retryOrFail(message, "dispatch", errors.New("boom"), 0)
+	assert.Greater(t, time.Until(message.NextAttemptAt), first)
+	assert.Equal(t, 2, message.RetryCount)
+}
+
+func TestTrustedRelayer_CircuitBreakerDefersMessages(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 5
+	relayer.registerAlertRules()
+	relayer.errorHandler.circuitBreakers["ethereum"] = NewCircuitBreaker("ethereum", 2, time.Minute)
+
+	// No LayerZero source chain is configured, so every send fails
+	for _, key := range []string{"40", "41"} {
+		require.NoError(t, relayer.enqueue(newPendingMessage(MessageTypeLoanDisbursement, key, "ethereum", []byte{1})))
+	}
+	relayer.processOutbox()
+	assert.Equal(t, []string{"ethereum"}, relayer.errorHandler.OpenCircuits())
+
+	held := newPendingMessage(MessageTypeLoanDisbursement, "42", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(held))
+	relayer.processOutbox()
+
+	deferred, err := relayer.outbox.Get(relayer.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, deferred.Status)
+	assert.Equal(t, 0, deferred.RetryCount)
+	assert.Empty(t, deferred.Attempts)
+	assert.True(t, deferred.NextAttemptAt.After(time.Now().Add(50*time.Second)))
+
+	status := relayer.GetStatus()
+	assert.Equal(t, HealthStatusDegraded, status.Status)
+	require.Len(t, status.Alerts, 1)
+	assert.Equal(t, "circuit_open", status.Alerts[0].Title)
+	assert.Equal(t, SeverityHigh, status.Alerts[0].Severity)
+}
+
+func TestAlertManager_RaisesOnceAndResolves(t *testing.T) {
+	monitor := NewMonitor(context.Background())
+	firing := true
+	monitor.AddAlertRule(&AlertRule{
+		Name:      "test",
+		Severity:  SeverityLow,
+		Enabled:   true,
+		Condition: func(*Monitor) bool { return firing },
+	})
+
+	event := &Event{ID: generateEventID(), Type: EventTypeMessageProcessed}
+	monitor.RecordEvent(event)
+	monitor.RecordEvent(event)
+	assert.Len(t, monitor.GetActiveAlerts(), 1)
+
+	firing = false
+	monitor.RecordEvent(event)
+	assert.Empty(t, monitor.GetActiveAlerts())
+	alerts := monitor.GetAlerts()
+	require.Len(t, alerts, 1)
+	assert.True(t, alerts[0].Resolved)
+}
+
+func TestMonitor_HealthCheckFailure(t *testing.T) {
+	monitor := NewMonitor(context.Background())
+	monitor.healthChecks["rpc"] = &HealthCheck{Name: "rpc"}
+
+	monitor.checkHealth("rpc", func() error { return errors.New("dial tcp: connection refused") })
+	assert.Equal(t, HealthStatusUnhealthy, monitor.GetHealthStatus())
+	assert.Equal(t, "dial tcp: connection refused", monitor.GetHealthChecks()["rpc"].Error)
+
+	events := monitor.GetRecentEvents(1)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventTypeErrorOccurred, events[0].Type)
+	assert.Equal(t, "network", events[0].Metadata["error_type"])
+
+	monitor.checkHealth("rpc", func() error { return nil })
+	assert.Equal(t, HealthStatusHealthy, monitor.GetHealthStatus())
+}
+
+func TestHandler_GetRelayerStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	relayer := newTestRelayer(t)
+	relayer.monitor.healthChecks["outbox"] = &HealthCheck{Name: "outbox"}
+	relayer.monitor.checkHealth("outbox", func() error { return errors.New("database unavailable") })
+
+	router := gin.New()
+	router.GET("/status", NewHandler(relayer).GetRelayerStatus)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status struct {
+		Status       string `json:"status"`
+		HealthChecks map[string]struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"health_checks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "UNHEALTHY", status.Status)
+	assert.Equal(t, "UNHEALTHY", status.HealthChecks["outbox"].Status)
+	assert.Equal(t, "database unavailable", status.HealthChecks["outbox"].Error)
+}
+
+func TestHealthHandler_HealthzAndMetrics(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.monitor.healthChecks["outbox"] = &HealthCheck{Name: "outbox"}
+	relayer.monitor.checkHealth("outbox", func() error { return nil })
+	handler := NewHealthHandler(relayer)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Clients such as the status command read the status back
+	var status RelayerStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, HealthStatusHealthy, status.Status)
+	assert.Equal(t, HealthStatusHealthy, status.HealthChecks["outbox"].Status)
+
+	relayer.monitor.checkHealth("outbox", func() error { return errors.New("database unavailable") })
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	status = RelayerStatus{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, HealthStatusUnhealthy, status.Status)
+	require.NotEmpty(t, status.RecentEvents)
+	assert.Equal(t, EventTypeErrorOccurred, status.RecentEvents[0].Type)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "relayer_errors_total")
+}
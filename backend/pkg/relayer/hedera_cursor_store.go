@@ -0,0 +1,86 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/supabase-community/supabase-go"
+)
+
+// HederaCursorStore persists how far each mirror node stream has been read, as the
+// consensus timestamp of the last record handled.
+type HederaCursorStore interface {
+	// LoadCursor returns the stream's cursor, or an empty string for a new stream.
+	LoadCursor(ctx context.Context, stream string) (string, error)
+	SaveCursor(ctx context.Context, stream, timestamp string) error
+}
+
+// MemoryCursorStore is an in-process HederaCursorStore for tests and development.
+type MemoryCursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]string
+}
+
+// NewMemoryCursorStore creates an empty in-memory cursor store
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return &MemoryCursorStore{cursors: make(map[string]string)}
+}
+
+func (s *MemoryCursorStore) LoadCursor(ctx context.Context, stream string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors[stream], nil
+}
+
+func (s *MemoryCursorStore) SaveCursor(ctx context.Context, stream, timestamp string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[stream] = timestamp
+	return nil
+}
+
+// SupabaseCursorStore stores cursors in the relayer_cursors table
+type SupabaseCursorStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseCursorStore creates a new Supabase-backed cursor store
+func NewSupabaseCursorStore(db *supabase.Client) *SupabaseCursorStore {
+	return &SupabaseCursorStore{db: db}
+}
+
+func (s *SupabaseCursorStore) LoadCursor(ctx context.Context, stream string) (string, error) {
+	data, _, err := s.db.From("relayer_cursors").Select("consensus_timestamp", "", false).
+		Eq("stream", stream).
+		Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	var rows []struct {
+		ConsensusTimestamp string `json:"consensus_timestamp"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", fmt.Errorf("failed to unmarshal cursor: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	return rows[0].ConsensusTimestamp, nil
+}
+
+func (s *SupabaseCursorStore) SaveCursor(ctx context.Context, stream, timestamp string) error {
+	row := map[string]interface{}{
+		"stream":              stream,
+		"consensus_timestamp": timestamp,
+		"updated_at":          time.Now(),
+	}
+	_, _, err := s.db.From("relayer_cursors").Insert(row, true, "stream", "", "").Execute()
+	if err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
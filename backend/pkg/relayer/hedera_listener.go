@@ -2,71 +2,402 @@ package relayer
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/utils"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/rs/zerolog/log"
 )
 
-// HederaEventListener listens for events on Hedera smart contracts
+// Mirror node polling settings
+const (
+	hederaPollInterval = 5 * time.Second
+	mirrorPageLimit    = 100
+)
+
+// loanAgreementABI holds the LoanAgreementNFT events the relayer follows.
+const loanAgreementABI = `[{"anonymous":false,"name":"LoanAgreementCreated","type":"event","inputs":[
+	{"indexed":true,"name":"tokenId","type":"uint256"},
+	{"indexed":true,"name":"borrower","type":"address"},
+	{"indexed":true,"name":"merchant","type":"address"},
+	{"indexed":false,"name":"loanAmount","type":"uint256"},
+	{"indexed":false,"name":"interestRate","type":"uint256"},
+	{"indexed":false,"name":"repaymentDeadline","type":"uint256"}]}]`
+
+var loanAgreementCreated = mustParseABI(loanAgreementABI).Events["LoanAgreementCreated"]
+
+// Loan topic message events
+const (
+	hederaEventLoanDisbursed = "loan_disbursed"
+	hederaEventLoanRepaid    = "loan_repaid"
+)
+
+// HederaEventListener polls the Hedera mirror node for LoanAgreementNFT contract logs
+// and loan topic messages. Each stream keeps a cursor, the consensus timestamp of the
+// last record handled, so a restarted listener resumes where it stopped.
 type HederaEventListener struct {
-	client       *blockchain.HederaClient
-	contractAddr common.Address
+	mirrorURL    string
+	contractID   string // contract ID (0.0.x) or EVM address
+	topicID      string
+	cursors      HederaCursorStore
+	httpClient   *http.Client
+	pollInterval time.Duration
 	eventHandler func(interface{}) error
 	quitChan     chan struct{}
+	stopOnce     sync.Once
+}
+
+// mirrorLog is a contract log from /api/v1/contracts/{id}/results/logs
+type mirrorLog struct {
+	Data            string   `json:"data"`
+	Index           int      `json:"index"`
+	Topics          []string `json:"topics"`
+	Timestamp       string   `json:"timestamp"`
+	TransactionHash string   `json:"transaction_hash"`
+}
+
+// mirrorTopicMessage is a consensus message from /api/v1/topics/{id}/messages
+type mirrorTopicMessage struct {
+	ConsensusTimestamp string `json:"consensus_timestamp"`
+	Message            string `json:"message"` // base64
+	SequenceNumber     uint64 `json:"sequence_number"`
+}
+
+type mirrorLinks struct {
+	Next string `json:"next"`
+}
+
+// hederaLoanTopicMessage is a loan lifecycle message on the loan topic, such as the
+// loan_repaid message of blockchain.LoanRepaidMessage. Amounts are decimal strings in the
+// loan asset's base units. Messages without a known event, such as NFT status updates,
+// are ignored. TraceContext is the W3C trace context of the
+// request that submitted the message.
+type hederaLoanTopicMessage struct {
+	Event        string            `json:"event"`
+	TokenID      string            `json:"token_id"`
+	Amount       string            `json:"amount"`
+	TotalRepaid  string            `json:"total_repaid,omitempty"`
+	Payer        string            `json:"payer,omitempty"`
+	Merchant     string            `json:"merchant,omitempty"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 // NewHederaEventListener creates a new Hedera event listener
-func NewHederaEventListener(client *blockchain.HederaClient, contractAddr string) (*HederaEventListener, error) {
+func NewHederaEventListener(mirrorURL, contractAddr, topicID string, cursors HederaCursorStore) (*HederaEventListener, error) {
 	if contractAddr == "" {
 		return nil, fmt.Errorf("contract address is required")
 	}
+	if mirrorURL == "" {
+		return nil, fmt.Errorf("mirror node URL is required")
+	}
 
 	return &HederaEventListener{
-		client:       client,
-		contractAddr: common.HexToAddress(contractAddr),
+		mirrorURL:    strings.TrimRight(mirrorURL, "/"),
+		contractID:   contractAddr,
+		topicID:      topicID,
+		cursors:      cursors,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		pollInterval: hederaPollInterval,
 		quitChan:     make(chan struct{}),
 	}, nil
 }
 
-// Start starts the event listener
+// Start starts polling the mirror node
 func (hel *HederaEventListener) Start(ctx context.Context, eventHandler func(interface{}) error) error {
-	log.Info().Str("contract", hel.contractAddr.Hex()).Msg("Starting Hedera event listener")
+	log.Info().
+		Str("contract", hel.contractID).
+		Str("topic", hel.topicID).
+		Str("mirror_node", hel.mirrorURL).
+		Msg("Starting Hedera event listener")
 	hel.eventHandler = eventHandler
-	// In a real implementation, you would start polling or subscribing to events here.
-	// For this mock implementation, we will wait for simulated events.
+
 	go func() {
-		<-ctx.Done()
-		hel.Stop()
+		ticker := time.NewTicker(hel.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			hel.poll(ctx)
+
+			select {
+			case <-ctx.Done():
+				hel.Stop()
+				return
+			case <-hel.quitChan:
+				return
+			case <-ticker.C:
+			}
+		}
 	}()
 	return nil
 }
 
 // Stop stops the event listener
 func (hel *HederaEventListener) Stop() {
-	log.Info().Msg("Stopping Hedera event listener")
-	close(hel.quitChan)
+	hel.stopOnce.Do(func() {
+		log.Info().Msg("Stopping Hedera event listener")
+		close(hel.quitChan)
+	})
+}
+
+// poll reads every stream up to its latest record
+func (hel *HederaEventListener) poll(ctx context.Context) {
+	if err := hel.pollContractLogs(ctx); err != nil {
+		log.Error().Err(err).Str("contract", hel.contractID).Msg("Failed to poll Hedera contract logs")
+	}
+	if hel.topicID == "" {
+		return
+	}
+	if err := hel.pollTopicMessages(ctx); err != nil {
+		log.Error().Err(err).Str("topic", hel.topicID).Msg("Failed to poll Hedera topic messages")
+	}
+}
+
+// pollContractLogs handles the LoanAgreementCreated logs after the cursor. Logs of one
+// transaction share a timestamp, so the cursor only moves past a timestamp once all its
+// logs are handled.
+func (hel *HederaEventListener) pollContractLogs(ctx context.Context) error {
+	stream := "contract_logs:" + hel.contractID
+	path := "/api/v1/contracts/" + url.PathEscape(hel.contractID) + "/results/logs"
+
+	return hel.readStream(ctx, stream, path, func(body []byte, save func(string) error) (string, error) {
+		var page struct {
+			Logs  []mirrorLog `json:"logs"`
+			Links mirrorLinks `json:"links"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return "", fmt.Errorf("failed to unmarshal contract logs: %w", err)
+		}
+
+		for i, entry := range page.Logs {
+			if i > 0 && entry.Timestamp != page.Logs[i-1].Timestamp {
+				if err := save(page.Logs[i-1].Timestamp); err != nil {
+					return "", err
+				}
+			}
+
+			event, err := decodeLoanAgreementLog(entry)
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Str("tx_hash", entry.TransactionHash).
+					Int("log_index", entry.Index).
+					Msg("Skipping undecodable Hedera contract log")
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			if err := hel.eventHandler(event); err != nil {
+				return "", fmt.Errorf("failed to handle log %s/%d: %w", entry.TransactionHash, entry.Index, err)
+			}
+		}
+		if len(page.Logs) > 0 {
+			if err := save(page.Logs[len(page.Logs)-1].Timestamp); err != nil {
+				return "", err
+			}
+		}
+		return page.Links.Next, nil
+	})
+}
+
+// pollTopicMessages handles the loan topic messages after the cursor
+func (hel *HederaEventListener) pollTopicMessages(ctx context.Context) error {
+	stream := "topic_messages:" + hel.topicID
+	path := "/api/v1/topics/" + url.PathEscape(hel.topicID) + "/messages"
+
+	return hel.readStream(ctx, stream, path, func(body []byte, save func(string) error) (string, error) {
+		var page struct {
+			Messages []mirrorTopicMessage `json:"messages"`
+			Links    mirrorLinks          `json:"links"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return "", fmt.Errorf("failed to unmarshal topic messages: %w", err)
+		}
+
+		for _, message := range page.Messages {
+			event, err := decodeLoanTopicMessage(message)
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Uint64("sequence_number", message.SequenceNumber).
+					Msg("Skipping undecodable Hedera topic message")
+			} else if event != nil {
+				if err := hel.eventHandler(event); err != nil {
+					return "", fmt.Errorf("failed to handle topic message %d: %w", message.SequenceNumber, err)
+				}
+			}
+			if err := save(message.ConsensusTimestamp); err != nil {
+				return "", err
+			}
+		}
+		return page.Links.Next, nil
+	})
+}
+
+// readStream pages through a mirror node list endpoint from the stream's cursor.
+// handlePage handles one page, saving the cursor as it goes, and returns the next link.
+func (hel *HederaEventListener) readStream(ctx context.Context, stream, path string, handlePage func(body []byte, save func(string) error) (string, error)) error {
+	cursor, err := hel.cursors.LoadCursor(ctx, stream)
+	if err != nil {
+		return fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("order", "asc")
+	query.Set("limit", strconv.Itoa(mirrorPageLimit))
+	if cursor != "" {
+		query.Set("timestamp", "gt:"+cursor)
+	}
+	next := path + "?" + query.Encode()
+
+	save := func(timestamp string) error {
+		if timestamp == "" || timestamp == cursor {
+			return nil
+		}
+		if err := hel.cursors.SaveCursor(ctx, stream, timestamp); err != nil {
+			return fmt.Errorf("failed to save cursor: %w", err)
+		}
+		cursor = timestamp
+		return nil
+	}
+
+	for next != "" {
+		body, err := utils.MakeRequest(ctx, hel.httpClient, http.MethodGet, hel.mirrorURL+next, map[string]string{"Accept": "application/json"}, nil)
+		if err != nil {
+			return fmt.Errorf("mirror node request failed: %w", err)
+		}
+		if next, err = handlePage(body, save); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeLoanAgreementLog decodes a LoanAgreementCreated log. Other logs of the
+// contract, such as ERC-721 transfers, decode to nil.
+func decodeLoanAgreementLog(entry mirrorLog) (*LoanApprovalEvent, error) {
+	if len(entry.Topics) == 0 || common.HexToHash(entry.Topics[0]) != loanAgreementCreated.ID {
+		return nil, nil
+	}
+	if len(entry.Topics) != 4 {
+		return nil, fmt.Errorf("expected 4 topics, got %d", len(entry.Topics))
+	}
+
+	data, err := hexutil.Decode(entry.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log data: %w", err)
+	}
+	values, err := loanAgreementCreated.Inputs.NonIndexed().Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack log data: %w", err)
+	}
+	timestamp, err := parseConsensusTimestamp(entry.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The contract stores the repayment deadline; the event carries the loan term in days
+	deadline := values[2].(*big.Int)
+	days := new(big.Int).Sub(deadline, big.NewInt(timestamp.Unix()))
+	days.Div(days, big.NewInt(int64(24*time.Hour/time.Second)))
+	if days.Sign() < 0 {
+		days.SetInt64(0)
+	}
+
+	return &LoanApprovalEvent{
+		TokenID:      common.HexToHash(entry.Topics[1]).Big(),
+		Borrower:     common.BytesToAddress(common.HexToHash(entry.Topics[2]).Bytes()),
+		Merchant:     common.BytesToAddress(common.HexToHash(entry.Topics[3]).Bytes()),
+		Amount:       values[0].(*big.Int),
+		InterestRate: values[1].(*big.Int),
+		Duration:     days,
+		Timestamp:    timestamp,
+	}, nil
 }
 
-// SimulateLoanCreatedEvent simulates a loan creation event for testing purposes
-func (hel *HederaEventListener) SimulateLoanCreatedEvent() {
-	event := &LoanApprovalEvent{
-		TokenID:      big.NewInt(int64(time.Now().Unix())),
-		Borrower:     common.HexToAddress("0x1234567890123456789012345678901234567890"),
-		Merchant:     common.HexToAddress("0x0987654321098765432109876543210987654321"),
-		Amount:       big.NewInt(1000000000000000000), // 1 ETH
-		InterestRate: big.NewInt(1000),             // 10%
-		Duration:     big.NewInt(30),               // 30 days
-		BorrowerDID:  "did:hedera:test:123",
-		MerchantDID:  "did:hedera:test:456",
-		Timestamp:    time.Now(),
+// decodeLoanTopicMessage decodes a loan topic message into a repayment or disbursement
+// event. Messages without a known event decode to nil.
+func decodeLoanTopicMessage(message mirrorTopicMessage) (interface{}, error) {
+	raw, err := base64.StdEncoding.DecodeString(message.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message encoding: %w", err)
+	}
+	var decoded hederaLoanTopicMessage
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		// Not every message on the topic is JSON
+		return nil, nil
+	}
+	if decoded.Event != hederaEventLoanDisbursed && decoded.Event != hederaEventLoanRepaid {
+		return nil, nil
+	}
+
+	timestamp, err := parseConsensusTimestamp(message.ConsensusTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	tokenID, ok := new(big.Int).SetString(decoded.TokenID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid token_id %q", decoded.TokenID)
+	}
+	amount, ok := new(big.Int).SetString(decoded.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", decoded.Amount)
 	}
 
-	if err := hel.eventHandler(event); err != nil {
-		log.Error().Err(err).Msg("Failed to handle simulated event")
+	if decoded.Event == hederaEventLoanDisbursed {
+		if !common.IsHexAddress(decoded.Merchant) {
+			return nil, fmt.Errorf("invalid merchant %q", decoded.Merchant)
+		}
+		return &LoanDisbursementEvent{
+			TokenID:      tokenID,
+			Amount:       amount,
+			Merchant:     common.HexToAddress(decoded.Merchant),
+			Timestamp:    timestamp,
+			TraceContext: decoded.TraceContext,
+		}, nil
+	}
+
+	totalRepaid, ok := new(big.Int).SetString(decoded.TotalRepaid, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid total_repaid %q", decoded.TotalRepaid)
+	}
+	if !common.IsHexAddress(decoded.Payer) {
+		return nil, fmt.Errorf("invalid payer %q", decoded.Payer)
+	}
+	return &RepaymentEvent{
+		TokenID:      tokenID,
+		Amount:       amount,
+		TotalRepaid:  totalRepaid,
+		Payer:        common.HexToAddress(decoded.Payer),
+		Timestamp:    timestamp,
+		TraceContext: decoded.TraceContext,
+	}, nil
+}
+
+// parseConsensusTimestamp parses a mirror node timestamp such as "1700000000.123456789"
+func parseConsensusTimestamp(value string) (time.Time, error) {
+	seconds, nanos, _ := strings.Cut(value, ".")
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid consensus timestamp %q", value)
+	}
+	var nsec int64
+	if nanos != "" {
+		nanos = (nanos + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(nanos, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid consensus timestamp %q", value)
+		}
 	}
+	return time.Unix(sec, nsec).UTC(), nil
 }
@@ -0,0 +1,277 @@
+package relayer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMirrorNode serves contract logs and topic messages the way the Hedera mirror
+// node REST API does: ascending, filtered by timestamp=gt:, two records per page.
+type fakeMirrorNode struct {
+	mu       sync.Mutex
+	logs     []mirrorLog
+	messages []mirrorTopicMessage
+	requests []string
+}
+
+func (m *fakeMirrorNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, r.URL.String())
+
+	after := strings.TrimPrefix(r.URL.Query().Get("timestamp"), "gt:")
+	newer := func(timestamp string) bool {
+		if after == "" {
+			return true
+		}
+		a, _ := parseConsensusTimestamp(after)
+		b, _ := parseConsensusTimestamp(timestamp)
+		return b.After(a)
+	}
+	next := func(last string) string {
+		return fmt.Sprintf("%s?order=asc&limit=2&timestamp=gt:%s", r.URL.Path, last)
+	}
+
+	switch {
+	case r.URL.Path == "/api/v1/contracts/0.0.1234/results/logs":
+		var page []mirrorLog
+		for _, l := range m.logs {
+			if newer(l.Timestamp) && len(page) < 2 {
+				page = append(page, l)
+			}
+		}
+		links := map[string]interface{}{"next": nil}
+		if len(page) == 2 {
+			links["next"] = next(page[1].Timestamp)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"logs": page, "links": links})
+	case r.URL.Path == "/api/v1/topics/0.0.5678/messages":
+		var page []mirrorTopicMessage
+		for _, message := range m.messages {
+			if newer(message.ConsensusTimestamp) && len(page) < 2 {
+				page = append(page, message)
+			}
+		}
+		links := map[string]interface{}{"next": nil}
+		if len(page) == 2 {
+			links["next"] = next(page[1].ConsensusTimestamp)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"messages": page, "links": links})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func loanAgreementLog(t *testing.T, timestamp string, tokenID int64, borrower, merchant common.Address, amount int64, deadline time.Time) mirrorLog {
+	data, err := loanAgreementCreated.Inputs.NonIndexed().Pack(big.NewInt(amount), big.NewInt(500), big.NewInt(deadline.Unix()))
+	require.NoError(t, err)
+	return mirrorLog{
+		Data: hexutil.Encode(data),
+		Topics: []string{
+			loanAgreementCreated.ID.Hex(),
+			common.BigToHash(big.NewInt(tokenID)).Hex(),
+			common.BytesToHash(borrower.Bytes()).Hex(),
+			common.BytesToHash(merchant.Bytes()).Hex(),
+		},
+		Timestamp:       timestamp,
+		TransactionHash: "0x" + strings.Repeat("ab", 32),
+	}
+}
+
+func topicMessage(timestamp string, sequence uint64, body string) mirrorTopicMessage {
+	return mirrorTopicMessage{
+		ConsensusTimestamp: timestamp,
+		Message:            base64.StdEncoding.EncodeToString([]byte(body)),
+		SequenceNumber:     sequence,
+	}
+}
+
+func TestLoanAgreementCreated_MatchesContract(t *testing.T) {
+	source, err := os.ReadFile("../../../contracts/hedera/LoanAgreementNFT.sol")
+	require.NoError(t, err)
+
+	match := regexp.MustCompile(`event LoanAgreementCreated\(([^)]*)\)`).FindSubmatch(source)
+	require.NotNil(t, match, "LoanAgreementCreated not found in contract")
+
+	var types []string
+	for _, param := range strings.Split(string(match[1]), ",") {
+		types = append(types, strings.Fields(param)[0])
+	}
+	assert.Equal(t, "LoanAgreementCreated("+strings.Join(types, ",")+")", loanAgreementCreated.Sig)
+}
+
+func TestHederaEventListener_PollsMirrorNode(t *testing.T) {
+	borrower := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	merchant := common.HexToAddress("0x0987654321098765432109876543210987654321")
+	created := time.Unix(1700000000, 0).UTC()
+
+	mirror := &fakeMirrorNode{
+		logs: []mirrorLog{
+			loanAgreementLog(t, "1700000000.000000001", 1, borrower, merchant, 1000, created.Add(30*24*time.Hour)),
+			// An ERC-721 transfer in the same transaction is ignored
+			{Topics: []string{common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef").Hex()}, Data: "0x", Timestamp: "1700000000.000000001"},
+			loanAgreementLog(t, "1700000005.000000000", 2, borrower, merchant, 2000, created.Add(60*24*time.Hour)),
+		},
+		messages: []mirrorTopicMessage{
+			topicMessage("1700000010.000000000", 1, `{"event":"loan_disbursed","token_id":"1","amount":"1000","merchant":"`+merchant.Hex()+`"}`),
+			topicMessage("1700000011.000000000", 2, `{"tokenId":"0.0.99","serialNumber":1,"newMetadata":"{}"}`),
+			topicMessage("1700000012.000000000", 3, `{"event":"loan_repaid","token_id":"1","amount":"400","total_repaid":"400","payer":"`+borrower.Hex()+`"}`),
+		},
+	}
+	server := httptest.NewServer(mirror)
+	defer server.Close()
+
+	cursors := NewMemoryCursorStore()
+	listener, err := NewHederaEventListener(server.URL, "0.0.1234", "0.0.5678", cursors)
+	require.NoError(t, err)
+
+	var events []interface{}
+	listener.eventHandler = func(event interface{}) error {
+		events = append(events, event)
+		return nil
+	}
+	listener.poll(context.Background())
+
+	require.Len(t, events, 4)
+	approval := events[0].(*LoanApprovalEvent)
+	assert.Equal(t, big.NewInt(1), approval.TokenID)
+	assert.Equal(t, borrower, approval.Borrower)
+	assert.Equal(t, merchant, approval.Merchant)
+	assert.Equal(t, big.NewInt(1000), approval.Amount)
+	assert.Equal(t, big.NewInt(500), approval.InterestRate)
+	assert.Equal(t, big.NewInt(30), approval.Duration)
+	assert.Equal(t, time.Unix(1700000000, 1).UTC(), approval.Timestamp)
+	assert.Equal(t, big.NewInt(2), events[1].(*LoanApprovalEvent).TokenID)
+
+	disbursement := events[2].(*LoanDisbursementEvent)
+	assert.Equal(t, merchant, disbursement.Merchant)
+	assert.Equal(t, big.NewInt(1000), disbursement.Amount)
+
+	repayment := events[3].(*RepaymentEvent)
+	assert.Equal(t, borrower, repayment.Payer)
+	assert.Equal(t, big.NewInt(400), repayment.TotalRepaid)
+
+	logsCursor, _ := cursors.LoadCursor(context.Background(), "contract_logs:0.0.1234")
+	assert.Equal(t, "1700000005.000000000", logsCursor)
+	topicCursor, _ := cursors.LoadCursor(context.Background(), "topic_messages:0.0.5678")
+	assert.Equal(t, "1700000012.000000000", topicCursor)
+
+	// A restarted listener resumes from the persisted cursors
+	restarted, err := NewHederaEventListener(server.URL, "0.0.1234", "0.0.5678", cursors)
+	require.NoError(t, err)
+	restarted.eventHandler = listener.eventHandler
+	mirror.mu.Lock()
+	mirror.logs = append(mirror.logs, loanAgreementLog(t, "1700000020.000000000", 3, borrower, merchant, 3000, created.Add(90*24*time.Hour)))
+	mirror.requests = nil
+	mirror.mu.Unlock()
+
+	restarted.poll(context.Background())
+	require.Len(t, events, 5)
+	assert.Equal(t, big.NewInt(3), events[4].(*LoanApprovalEvent).TokenID)
+	assert.Contains(t, mirror.requests[0], "timestamp=gt%3A1700000005.000000000")
+}
+
+func TestHederaEventListener_HandlerErrorKeepsCursor(t *testing.T) {
+	borrower := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	merchant := common.HexToAddress("0x0987654321098765432109876543210987654321")
+	deadline := time.Unix(1700000000, 0).Add(30 * 24 * time.Hour)
+
+	mirror := &fakeMirrorNode{
+		logs: []mirrorLog{
+			loanAgreementLog(t, "1700000000.000000000", 1, borrower, merchant, 1000, deadline),
+			loanAgreementLog(t, "1700000001.000000000", 2, borrower, merchant, 1000, deadline),
+		},
+	}
+	server := httptest.NewServer(mirror)
+	defer server.Close()
+
+	cursors := NewMemoryCursorStore()
+	listener, err := NewHederaEventListener(server.URL, "0.0.1234", "", cursors)
+	require.NoError(t, err)
+
+	var handled []string
+	fail := true
+	listener.eventHandler = func(event interface{}) error {
+		tokenID := event.(*LoanApprovalEvent).TokenID.String()
+		if tokenID == "2" && fail {
+			return errors.New("database unavailable")
+		}
+		handled = append(handled, tokenID)
+		return nil
+	}
+
+	listener.poll(context.Background())
+	assert.Equal(t, []string{"1"}, handled)
+	cursor, _ := cursors.LoadCursor(context.Background(), "contract_logs:0.0.1234")
+	assert.Equal(t, "1700000000.000000000", cursor)
+
+	// The failed log is retried on the next poll
+	fail = false
+	listener.poll(context.Background())
+	assert.Equal(t, []string{"1", "2"}, handled)
+}
+
+func TestHederaEventListener_StartAndStop(t *testing.T) {
+	server := httptest.NewServer(&fakeMirrorNode{})
+	defer server.Close()
+
+	listener, err := NewHederaEventListener(server.URL, "0.0.1234", "", NewMemoryCursorStore())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, listener.Start(ctx, func(interface{}) error { return nil }))
+	cancel()
+	// Stopping after the context is cancelled is safe
+	time.Sleep(10 * time.Millisecond)
+	listener.Stop()
+}
+
+func TestDecodeLoanTopicMessage_PublishedRepayment(t *testing.T) {
+	payer := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	published, err := blockchain.LoanRepaidMessage("7", big.NewInt(250000000), big.NewInt(750000000), payer.Hex())
+	require.NoError(t, err)
+
+	event, err := decodeLoanTopicMessage(topicMessage("1700000000.000000001", 1, string(published)))
+	require.NoError(t, err)
+	assert.Equal(t, &RepaymentEvent{
+		TokenID:     big.NewInt(7),
+		Amount:      big.NewInt(250000000),
+		TotalRepaid: big.NewInt(750000000),
+		Payer:       payer,
+		Timestamp:   time.Unix(1700000000, 1).UTC(),
+	}, event)
+
+	// The NFT status update published alongside it is not an event
+	statusUpdate := `{"tokenId":"7","serialNumber":1,"newMetadata":"{\"loanId\":\"abc\",\"outstanding_amount\":250,\"status\":\"PARTIALLY_PAID\"}"}`
+	event, err = decodeLoanTopicMessage(topicMessage("1700000000.000000002", 2, statusUpdate))
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestParseConsensusTimestamp(t *testing.T) {
+	ts, err := parseConsensusTimestamp("1700000000.5")
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 500000000).UTC(), ts)
+
+	_, err = parseConsensusTimestamp("not-a-timestamp")
+	assert.Error(t, err)
+}
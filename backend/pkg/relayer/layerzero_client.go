@@ -2,107 +2,357 @@ package relayer
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"encoding/binary"
 	"fmt"
+	"kelo-backend/pkg/blockchain"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/tracing"
 	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// layerZeroEndpointV2ABI covers the EndpointV2 functions the relayer calls and the
+// PacketSent event of its sends.
+const layerZeroEndpointV2ABI = `[
+	{"name":"quote","type":"function","stateMutability":"view",
+	 "inputs":[{"name":"_params","type":"tuple","components":[{"name":"dstEid","type":"uint32"},{"name":"receiver","type":"bytes32"},{"name":"message","type":"bytes"},{"name":"options","type":"bytes"},{"name":"payInLzToken","type":"bool"}]},{"name":"_sender","type":"address"}],
+	 "outputs":[{"name":"","type":"tuple","components":[{"name":"nativeFee","type":"uint256"},{"name":"lzTokenFee","type":"uint256"}]}]},
+	{"name":"send","type":"function","stateMutability":"payable",
+	 "inputs":[{"name":"_params","type":"tuple","components":[{"name":"dstEid","type":"uint32"},{"name":"receiver","type":"bytes32"},{"name":"message","type":"bytes"},{"name":"options","type":"bytes"},{"name":"payInLzToken","type":"bool"}]},{"name":"_refundAddress","type":"address"}],
+	 "outputs":[{"name":"","type":"tuple","components":[{"name":"guid","type":"bytes32"},{"name":"nonce","type":"uint64"},{"name":"fee","type":"tuple","components":[{"name":"nativeFee","type":"uint256"},{"name":"lzTokenFee","type":"uint256"}]}]}]},
+	{"name":"outboundNonce","type":"function","stateMutability":"view",
+	 "inputs":[{"name":"_sender","type":"address"},{"name":"_dstEid","type":"uint32"},{"name":"_receiver","type":"bytes32"}],
+	 "outputs":[{"name":"","type":"uint64"}]},
+	{"name":"PacketSent","type":"event","anonymous":false,
+	 "inputs":[{"name":"encodedPayload","type":"bytes","indexed":false},{"name":"options","type":"bytes","indexed":false},{"name":"sendLibrary","type":"address","indexed":false}]}
+]`
+
+var layerZeroEndpointABI = mustParseABI(layerZeroEndpointV2ABI)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
+}
+
+// defaultLzReceiveGas is the executor gas for lzReceive when the chain sets no gas limit.
+const defaultLzReceiveGas = 200000
+
+// layerZeroPacketHeaderLength is the length of a V1 packet up to the end of its GUID:
+// version, nonce, source EID, sender, destination EID, receiver and GUID.
+const layerZeroPacketHeaderLength = 1 + 8 + 4 + 32 + 4 + 32 + 32
+
+// layerZeroMessagingParams mirrors the EndpointV2 MessagingParams struct.
+type layerZeroMessagingParams struct {
+	DstEid       uint32
+	Receiver     [32]byte
+	Message      []byte
+	Options      []byte
+	PayInLzToken bool
+}
+
+// LayerZeroFee is the fee quoted by the endpoint, in the source chain's native unit.
+type LayerZeroFee struct {
+	NativeFee  *big.Int `json:"native_fee"`
+	LzTokenFee *big.Int `json:"lz_token_fee"`
+}
+
+// LayerZeroMessage is a message to send through the LayerZero V2 endpoint.
+type LayerZeroMessage struct {
+	DstEID   uint32
+	Receiver common.Address
+	Payload  []byte
+	// Options are the executor options, see ExecutorLzReceiveOption.
+	Options []byte
+	// Source is the relayer message being delivered, if any.
+	Source *Message
+}
+
+// LayerZeroSendResult describes a submitted LayerZero message. The GUID and nonce are
+// provisional: they are predicted from the endpoint's outbound nonce before the send,
+// which another send on the path can take first. The endpoint's own are read from the
+// PacketSent log once the transaction is mined (see SentPacket).
+type LayerZeroSendResult struct {
+	TxHash    string
+	GUID      string
+	Nonce     uint64
+	NativeFee *big.Int
+}
+
+// ExecutorLzReceiveOption builds type 3 options asking the executor to call lzReceive
+// with the given gas and, optionally, native value.
+func ExecutorLzReceiveOption(gas uint64, value *big.Int) []byte {
+	option := common.LeftPadBytes(new(big.Int).SetUint64(gas).Bytes(), 16)
+	if value != nil && value.Sign() > 0 {
+		option = append(option, common.LeftPadBytes(value.Bytes(), 16)...)
+	}
+
+	options := []byte{0x00, 0x03}   // options type 3
+	options = append(options, 0x01) // executor worker
+	options = binary.BigEndian.AppendUint16(options, uint16(len(option)+1))
+	options = append(options, 0x01) // lzReceive option
+	return append(options, option...)
+}
+
+// LayerZeroGUID computes the GUID the endpoint assigns to an outbound message.
+func LayerZeroGUID(nonce uint64, srcEID uint32, sender common.Address, dstEID uint32, receiver common.Address) common.Hash {
+	packed := binary.BigEndian.AppendUint64(nil, nonce)
+	packed = binary.BigEndian.AppendUint32(packed, srcEID)
+	packed = append(packed, common.LeftPadBytes(sender.Bytes(), 32)...)
+	packed = binary.BigEndian.AppendUint32(packed, dstEID)
+	packed = append(packed, common.LeftPadBytes(receiver.Bytes(), 32)...)
+	return crypto.Keccak256Hash(packed)
+}
+
+// LayerZeroPacket is the GUID and nonce the endpoint assigned to a sent message.
+type LayerZeroPacket struct {
+	GUID  string
+	Nonce uint64
+}
+
+// TxSubmitter submits transactions with managed nonces and fees. It is implemented
+// by TransactionManager.
+type TxSubmitter interface {
+	Submit(ctx context.Context, chainID string, req *blockchain.TxRequest, message *Message) (*blockchain.Transaction, error)
+}
+
 // LayerZeroClient handles LayerZero message sending
 type LayerZeroClient struct {
-	evmClient       *ethclient.Client
-	privateKey      *ecdsa.PrivateKey
+	source          blockchain.ChainAdapter
+	signer          Signer
 	endpointAddress string
+	submitter       TxSubmitter
 }
 
-// NewLayerZeroClient creates a new LayerZero client
-func NewLayerZeroClient(evmClient *ethclient.Client, privateKey *ecdsa.PrivateKey, cfg *config.Config) (*LayerZeroClient, error) {
-	if privateKey == nil {
-		return nil, fmt.Errorf("private key is required")
+// NewLayerZeroClient creates a new LayerZero client that sends from the source chain
+func NewLayerZeroClient(source blockchain.ChainAdapter, signer Signer, cfg *config.Config) (*LayerZeroClient, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("signer is required")
 	}
 	if cfg.LayerZeroEndpoint == "" {
 		return nil, fmt.Errorf("LayerZero endpoint address is not configured")
 	}
 
 	return &LayerZeroClient{
-		evmClient:       evmClient,
-		privateKey:      privateKey,
+		source:          source,
+		signer:          signer,
 		endpointAddress: cfg.LayerZeroEndpoint,
 	}, nil
 }
 
-// SendTransaction sends a transaction to the LayerZero endpoint
-func (lzc *LayerZeroClient) SendTransaction(ctx context.Context, destinationChainID uint32, payload []byte) (string, error) {
-	// Get the sender's address from the private key
-	publicKey := lzc.privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+// SetSubmitter routes endpoint transactions through the submitter instead of sending
+// them directly
+func (lzc *LayerZeroClient) SetSubmitter(submitter TxSubmitter) {
+	lzc.submitter = submitter
+}
+
+func (lzc *LayerZeroClient) sender() common.Address {
+	return lzc.signer.Address()
+}
+
+func (lzc *LayerZeroClient) messagingParams(msg *LayerZeroMessage) layerZeroMessagingParams {
+	return layerZeroMessagingParams{
+		DstEid:   msg.DstEID,
+		Receiver: common.BytesToHash(msg.Receiver.Bytes()),
+		Message:  msg.Payload,
+		Options:  msg.Options,
+	}
+}
+
+// call executes a read-only endpoint call on the source chain and unpacks the result
+func (lzc *LayerZeroClient) call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	if lzc.source == nil {
+		return nil, fmt.Errorf("LayerZero source chain is not configured")
+	}
+	caller, ok := lzc.source.(blockchain.ContractCaller)
 	if !ok {
-		return "", fmt.Errorf("error casting public key to ECDSA")
+		return nil, fmt.Errorf("%w: contract calls on %s", blockchain.ErrNotSupported, lzc.source.ChainID())
+	}
+
+	data, err := layerZeroEndpointABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	result, err := caller.CallContract(ctx, &blockchain.TxRequest{
+		From: lzc.sender().Hex(),
+		To:   lzc.endpointAddress,
+		Data: data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
 	}
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
+	values, err := layerZeroEndpointABI.Unpack(method, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %w", method, err)
+	}
+	return values, nil
+}
 
-	// Get the nonce for the sender's account
-	nonce, err := lzc.evmClient.PendingNonceAt(ctx, fromAddress)
+// Quote returns the fee the endpoint charges to deliver the message
+func (lzc *LayerZeroClient) Quote(ctx context.Context, msg *LayerZeroMessage) (*LayerZeroFee, error) {
+	values, err := lzc.call(ctx, "quote", lzc.messagingParams(msg), lzc.sender())
 	if err != nil {
-		return "", fmt.Errorf("failed to get nonce: %w", err)
+		return nil, err
 	}
 
-	// Get the suggested gas price
-	gasPrice, err := lzc.evmClient.SuggestGasPrice(ctx)
+	return abi.ConvertType(values[0], new(LayerZeroFee)).(*LayerZeroFee), nil
+}
+
+// nextNonce returns the outbound nonce the endpoint would assign to a message on the
+// path if it were mined next. Sends that are pending, or made by another instance, may
+// take it first, so it is only a prediction.
+func (lzc *LayerZeroClient) nextNonce(ctx context.Context, msg *LayerZeroMessage) (uint64, error) {
+	receiver := common.BytesToHash(msg.Receiver.Bytes())
+	values, err := lzc.call(ctx, "outboundNonce", lzc.sender(), msg.DstEID, receiver)
 	if err != nil {
-		return "", fmt.Errorf("failed to get gas price: %w", err)
+		return 0, err
 	}
+	return values[0].(uint64) + 1, nil
+}
 
-	// Use the configured LayerZero endpoint address
-	lzEndpointAddress := common.HexToAddress(lzc.endpointAddress)
+// SentPacket returns the GUID and nonce the endpoint assigned to the message sent in
+// the receipt's transaction, from the endpoint's PacketSent log. It returns nil if the
+// receipt has no such log.
+func (lzc *LayerZeroClient) SentPacket(receipt *blockchain.Receipt) (*LayerZeroPacket, error) {
+	event := layerZeroEndpointABI.Events["PacketSent"]
+	for _, l := range receipt.Logs {
+		if !strings.EqualFold(l.Address, lzc.endpointAddress) || len(l.Topics) == 0 || common.HexToHash(l.Topics[0]) != event.ID {
+			continue
+		}
+		values, err := event.Inputs.Unpack(l.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack PacketSent: %w", err)
+		}
+		packet := values[0].([]byte)
+		if len(packet) < layerZeroPacketHeaderLength {
+			return nil, fmt.Errorf("LayerZero packet too short: %d bytes", len(packet))
+		}
+		// Only the relayer's own packet; another application may send in the same transaction
+		if common.BytesToAddress(packet[13:45]) != lzc.sender() {
+			continue
+		}
+		return &LayerZeroPacket{
+			GUID:  common.BytesToHash(packet[81:113]).Hex(),
+			Nonce: binary.BigEndian.Uint64(packet[1:9]),
+		}, nil
+	}
+	return nil, nil
+}
 
-	// ABI encode the call to the 'send' function of the LayerZero endpoint
-	// function send(uint16 _dstChainId, bytes calldata _payload, bytes calldata _options)
-	// For simplicity, we are not including options.
-	contractABI, err := abi.JSON(strings.NewReader(`[{"inputs":[{"name":"_dstChainId","type":"uint16"},{"name":"_payload","type":"bytes"},{"name":"_options","type":"bytes"}],"name":"send","outputs":[],"stateMutability":"payable","type":"function"}]`))
+// Send quotes the message fee and submits it to the LayerZero endpoint with the fee
+// attached. Any overpayment is refunded to the relayer.
+func (lzc *LayerZeroClient) Send(ctx context.Context, msg *LayerZeroMessage) (_ *LayerZeroSendResult, err error) {
+	ctx, span := tracer().Start(ctx, "LayerZeroClient.Send", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int64("layerzero.dst_eid", int64(msg.DstEID))))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	req, result, err := lzc.prepareSend(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := lzc.submit(ctx, req, msg.Source)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse contract ABI: %w", err)
+		return nil, err
 	}
+	result.TxHash = txHash
+	span.SetAttributes(attribute.String("tx_hash", txHash), attribute.String("layerzero.guid", result.GUID))
+
+	log.Info().
+		Str("tx_hash", txHash).
+		Str("guid", result.GUID).
+		Uint64("lz_nonce", result.Nonce).
+		Uint32("dst_eid", msg.DstEID).
+		Str("native_fee", result.NativeFee.String()).
+		Msg("Successfully sent LayerZero transaction")
+
+	return result, nil
+}
+
+// BuildSend returns the endpoint transaction Send would submit for the message, with
+// the provisional GUID and the fee it would get.
+func (lzc *LayerZeroClient) BuildSend(ctx context.Context, msg *LayerZeroMessage) (*blockchain.TxRequest, *LayerZeroSendResult, error) {
+	return lzc.prepareSend(ctx, msg)
+}
 
-	// Pack the arguments for the 'send' function
-	// Note: destinationChainID is uint32, but the example ABI uses uint16.
-	// This might need adjustment based on the actual LayerZero contract version.
-	packedData, err := contractABI.Pack("send", uint16(destinationChainID), payload, []byte{})
+// prepareSend quotes the message fee and builds the endpoint transaction
+func (lzc *LayerZeroClient) prepareSend(ctx context.Context, msg *LayerZeroMessage) (*blockchain.TxRequest, *LayerZeroSendResult, error) {
+	if lzc.source == nil {
+		return nil, nil, fmt.Errorf("LayerZero source chain is not configured")
+	}
+	if msg.DstEID == 0 {
+		return nil, nil, fmt.Errorf("LayerZero destination endpoint ID is not set")
+	}
+	if msg.Receiver == (common.Address{}) {
+		return nil, nil, fmt.Errorf("LayerZero receiver is not set")
+	}
+
+	fee, err := lzc.Quote(ctx, msg)
 	if err != nil {
-		return "", fmt.Errorf("failed to pack data for 'send' function: %w", err)
+		return nil, nil, fmt.Errorf("failed to quote LayerZero fee: %w", err)
 	}
+	nonce, err := lzc.nextNonce(ctx, msg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get LayerZero nonce: %w", err)
+	}
+	guid := LayerZeroGUID(nonce, lzc.source.Spec().LayerZeroEID, lzc.sender(), msg.DstEID, msg.Receiver)
 
-	// Create the raw transaction
-	tx := types.NewTransaction(nonce, lzEndpointAddress, big.NewInt(0), 200000, gasPrice, packedData)
+	packedData, err := layerZeroEndpointABI.Pack("send", lzc.messagingParams(msg), lzc.sender())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pack data for 'send' function: %w", err)
+	}
 
-	// Sign the transaction
-	chainID, err := lzc.evmClient.NetworkID(ctx)
+	// Gas is estimated by the adapter with the fee attached
+	req := &blockchain.TxRequest{
+		From:  lzc.sender().Hex(),
+		To:    lzc.endpointAddress,
+		Value: fee.NativeFee,
+		Data:  packedData,
+	}
+	return req, &LayerZeroSendResult{
+		GUID:      guid.Hex(),
+		Nonce:     nonce,
+		NativeFee: fee.NativeFee,
+	}, nil
+}
+
+// submit sends the endpoint transaction through the submitter, or signs and sends it
+// directly when there is none
+func (lzc *LayerZeroClient) submit(ctx context.Context, req *blockchain.TxRequest, message *Message) (string, error) {
+	if lzc.submitter != nil {
+		tx, err := lzc.submitter.Submit(ctx, lzc.source.ChainID(), req, message)
+		if err != nil {
+			return "", err
+		}
+		return tx.Hash, nil
+	}
+
+	tx, err := lzc.source.BuildTransaction(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get network ID: %w", err)
+		return "", fmt.Errorf("failed to build transaction: %w", err)
 	}
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), lzc.privateKey)
+
+	// Sign the transaction
+	signedTx, err := lzc.source.SignTransaction(ctx, tx, lzc.signer)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
 	// Send the transaction
-	err = lzc.evmClient.SendTransaction(ctx, signedTx)
+	txHash, err := lzc.source.SendTransaction(ctx, signedTx)
 	if err != nil {
 		return "", fmt.Errorf("failed to send transaction: %w", err)
 	}
-
-	log.Info().
-		Str("tx_hash", signedTx.Hash().Hex()).
-		Uint32("destination_chain_id", destinationChainID).
-		Msg("Successfully sent LayerZero transaction")
-
-	return signedTx.Hash().Hex(), nil
+	return txHash, nil
 }
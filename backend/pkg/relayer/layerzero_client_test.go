@@ -0,0 +1,203 @@
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLayerZeroEndpoint answers EndpointV2 quote and outboundNonce calls on the adapter.
+func fakeLayerZeroEndpoint(t *testing.T, adapter *blockchain.FakeAdapter, nativeFee *big.Int, outboundNonce uint64) {
+	adapter.CallFunc = func(req *blockchain.TxRequest) ([]byte, error) {
+		method, err := layerZeroEndpointABI.MethodById(req.Data[:4])
+		require.NoError(t, err)
+		switch method.Name {
+		case "quote":
+			return method.Outputs.Pack(struct {
+				NativeFee  *big.Int
+				LzTokenFee *big.Int
+			}{nativeFee, big.NewInt(0)})
+		case "outboundNonce":
+			return method.Outputs.Pack(outboundNonce)
+		}
+		return nil, fmt.Errorf("unexpected call %s", method.Name)
+	}
+}
+
+func TestExecutorLzReceiveOption(t *testing.T) {
+	// OptionsBuilder.newOptions().addExecutorLzReceiveOption(200000, 0)
+	assert.Equal(t, "00030100110100000000000000000000000000030d40", hex.EncodeToString(ExecutorLzReceiveOption(200000, nil)))
+
+	withValue := ExecutorLzReceiveOption(200000, big.NewInt(1))
+	assert.Len(t, withValue, 6+32)
+	assert.Equal(t, []byte{0x00, 0x21}, withValue[3:5])
+}
+
+func TestLayerZeroClient_Send(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	sender := signer.Address()
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(12345), 6)
+
+	client, err := NewLayerZeroClient(source, signer, &config.Config{LayerZeroEndpoint: "0x1a44076050125825900e736c501f859c50fE728c"})
+	require.NoError(t, err)
+
+	receiver := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	msg := &LayerZeroMessage{DstEID: 30184, Receiver: receiver, Payload: []byte{0xaa}, Options: ExecutorLzReceiveOption(300000, nil)}
+
+	result, err := client.Send(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), result.Nonce)
+	assert.Equal(t, LayerZeroGUID(7, 30101, sender, 30184, receiver).Hex(), result.GUID)
+
+	sent := source.Sent()
+	require.Len(t, sent, 1)
+	req := sent[0].Native.(*blockchain.TxRequest)
+	assert.Equal(t, big.NewInt(12345), req.Value)
+
+	// The full 32-bit endpoint ID reaches the endpoint
+	values, err := layerZeroEndpointABI.Methods["send"].Inputs.Unpack(req.Data[4:])
+	require.NoError(t, err)
+	params := abi.ConvertType(values[0], new(layerZeroMessagingParams)).(*layerZeroMessagingParams)
+	assert.Equal(t, uint32(30184), params.DstEid)
+	assert.True(t, bytes.Equal(common.LeftPadBytes(receiver.Bytes(), 32), params.Receiver[:]))
+	assert.Equal(t, msg.Options, params.Options)
+	assert.Equal(t, sender, values[1])
+
+	// Until the first is mined the endpoint still reports nonce 6, so a second send gets
+	// the same provisional GUID; the receipts tell them apart
+	result, err = client.Send(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), result.Nonce)
+}
+
+// packetSentLog builds the PacketSent log the endpoint emits for a V1 packet
+func packetSentLog(t *testing.T, endpoint string, nonce uint64, srcEID uint32, sender common.Address, dstEID uint32, receiver common.Address) blockchain.ChainLog {
+	guid := LayerZeroGUID(nonce, srcEID, sender, dstEID, receiver)
+	packet := []byte{0x01}
+	packet = binary.BigEndian.AppendUint64(packet, nonce)
+	packet = binary.BigEndian.AppendUint32(packet, srcEID)
+	packet = append(packet, common.LeftPadBytes(sender.Bytes(), 32)...)
+	packet = binary.BigEndian.AppendUint32(packet, dstEID)
+	packet = append(packet, common.LeftPadBytes(receiver.Bytes(), 32)...)
+	packet = append(packet, guid.Bytes()...)
+	packet = append(packet, 0xaa)
+
+	event := layerZeroEndpointABI.Events["PacketSent"]
+	data, err := event.Inputs.Pack(packet, []byte{}, common.HexToAddress("0x5555555555555555555555555555555555555555"))
+	require.NoError(t, err)
+	return blockchain.ChainLog{Address: endpoint, Topics: []string{event.ID.Hex()}, Data: data}
+}
+
+func TestLayerZeroClient_SentPacket(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	endpoint := "0x1a44076050125825900e736c501f859c50fE728c"
+	client, err := NewLayerZeroClient(nil, signer, &config.Config{LayerZeroEndpoint: endpoint})
+	require.NoError(t, err)
+	receiver := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	// The endpoint's log is read; a log from another contract or for another sender is not
+	other := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	receipt := &blockchain.Receipt{Logs: []blockchain.ChainLog{
+		packetSentLog(t, "0x4444444444444444444444444444444444444444", 3, 30101, signer.Address(), 30184, receiver),
+		packetSentLog(t, endpoint, 4, 30101, other, 30184, receiver),
+		packetSentLog(t, strings.ToLower(endpoint), 9, 30101, signer.Address(), 30184, receiver),
+	}}
+	packet, err := client.SentPacket(receipt)
+	require.NoError(t, err)
+	require.NotNil(t, packet)
+	assert.Equal(t, uint64(9), packet.Nonce)
+	assert.Equal(t, LayerZeroGUID(9, 30101, signer.Address(), 30184, receiver).Hex(), packet.GUID)
+
+	packet, err = client.SentPacket(&blockchain.Receipt{})
+	require.NoError(t, err)
+	assert.Nil(t, packet)
+}
+
+func TestLayerZeroClient_FailedSendKeepsNonce(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	sender := signer.Address()
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(12345), 6)
+
+	client, err := NewLayerZeroClient(source, signer, &config.Config{LayerZeroEndpoint: "0x1a44076050125825900e736c501f859c50fE728c"})
+	require.NoError(t, err)
+
+	receiver := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	msg := &LayerZeroMessage{DstEID: 30184, Receiver: receiver, Payload: []byte{0xaa}}
+
+	source.SendErr = fmt.Errorf("connection reset")
+	_, err = client.Send(context.Background(), msg)
+	require.Error(t, err)
+
+	// The endpoint never saw the failed send, so the retry gets its nonce and GUID
+	source.SendErr = nil
+	result, err := client.Send(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), result.Nonce)
+	assert.Equal(t, LayerZeroGUID(7, 30101, sender, 30184, receiver).Hex(), result.GUID)
+}
+
+func TestLayerZeroClient_SendRequiresReceiver(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	client, err := NewLayerZeroClient(source, signer, &config.Config{LayerZeroEndpoint: "0x1a44076050125825900e736c501f859c50fE728c"})
+	require.NoError(t, err)
+
+	_, err = client.Send(context.Background(), &LayerZeroMessage{DstEID: 30184})
+	assert.Error(t, err)
+	assert.Empty(t, source.Sent())
+}
+
+func TestTrustedRelayer_RecordsEndpointGUIDFromReceipt(t *testing.T) {
+	relayer, source := newSendingTestRelayer(t)
+	source.Hold = true
+	receiver := relayer.chainConfigs["ethereum"].LayerZeroReceiver
+	sender := relayer.signer.Address()
+
+	// Another instance's send takes nonce 1 first, so the endpoint assigns nonce 2
+	source.LogsFunc = func(tx *blockchain.Transaction) []blockchain.ChainLog {
+		return []blockchain.ChainLog{packetSentLog(t, relayer.config.LayerZeroEndpoint, 2, 30101, sender, 30101, receiver)}
+	}
+
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{
+		TokenID:  big.NewInt(18),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(500),
+	}, common.Address{})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "18", "ethereum", payload)
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status)
+	assert.Equal(t, LayerZeroGUID(1, 30101, sender, 30101, receiver).Hex(), sent.LayerZeroGUID)
+
+	source.Mine(1)
+	relayer.reconcileSent()
+	confirmed, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, LayerZeroGUID(2, 30101, sender, 30101, receiver).Hex(), confirmed.LayerZeroGUID)
+}
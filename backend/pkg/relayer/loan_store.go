@@ -0,0 +1,90 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/supabase-community/supabase-go"
+)
+
+// LoanStore reads and updates the loan records behind Hedera loan events. Loans are
+// identified by their LoanAgreementNFT token ID.
+type LoanStore interface {
+	// FundingChain returns the chain of the pool that funded the loan.
+	FundingChain(ctx context.Context, loanID string) (string, error)
+	// RecordDisbursement marks the loan as disbursed and its order as completed.
+	RecordDisbursement(ctx context.Context, loanID string, disbursedAt time.Time) error
+}
+
+// SupabaseLoanStore keeps loan records in the loans and orders tables
+type SupabaseLoanStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseLoanStore creates a new Supabase-backed loan store
+func NewSupabaseLoanStore(db *supabase.Client) *SupabaseLoanStore {
+	return &SupabaseLoanStore{db: db}
+}
+
+type loanRecord struct {
+	ID           string  `json:"id"`
+	OrderID      string  `json:"order_id"`
+	FundingChain *string `json:"funding_chain"`
+}
+
+func (s *SupabaseLoanStore) getLoan(loanID string) (*loanRecord, error) {
+	data, _, err := s.db.From("loans").Select("id,order_id,funding_chain", "", false).
+		Eq("onchain_id", loanID).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	var loans []loanRecord
+	if err := json.Unmarshal(data, &loans); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loan: %w", err)
+	}
+	if len(loans) == 0 {
+		return nil, fmt.Errorf("loan not found: %s", loanID)
+	}
+	return &loans[0], nil
+}
+
+func (s *SupabaseLoanStore) FundingChain(ctx context.Context, loanID string) (string, error) {
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return "", err
+	}
+	if loan.FundingChain == nil || *loan.FundingChain == "" {
+		return "", fmt.Errorf("loan %s has not been allocated to a pool", loanID)
+	}
+	return *loan.FundingChain, nil
+}
+
+func (s *SupabaseLoanStore) RecordDisbursement(ctx context.Context, loanID string, disbursedAt time.Time) error {
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	loanUpdate := map[string]interface{}{
+		"disbursed_at": disbursedAt.UTC().Format(time.RFC3339),
+		"updated_at":   now,
+	}
+	if _, _, err := s.db.From("loans").Update(loanUpdate, "", "").Eq("id", loan.ID).Execute(); err != nil {
+		return fmt.Errorf("failed to update loan: %w", err)
+	}
+
+	// The order is complete once the merchant has been paid
+	orderUpdate := map[string]interface{}{
+		"status":     "completed",
+		"updated_at": now,
+	}
+	if _, _, err := s.db.From("orders").Update(orderUpdate, "", "").Eq("id", loan.OrderID).Execute(); err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package relayer
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/big"
 
@@ -9,37 +8,42 @@ import (
 )
 
 // MessageFactory creates cross-chain messages from Hedera events
-type MessageFactory struct {
-	layerzeroChainID uint32
-}
+type MessageFactory struct{}
 
 // NewMessageFactory creates a new message factory
-func NewMessageFactory(layerzeroChainID uint32) *MessageFactory {
-	return &MessageFactory{
-		layerzeroChainID: layerzeroChainID,
-	}
+func NewMessageFactory() *MessageFactory {
+	return &MessageFactory{}
 }
 
-// LoanDisbursementPayload represents the data sent to the EVM chain
-type LoanDisbursementPayload struct {
-	LoanID   *big.Int       `json:"loan_id"`
-	Merchant common.Address `json:"merchant"`
-	Amount   *big.Int       `json:"amount"`
-}
-
-// CreateLoanDisbursementPayload creates the payload for a loan disbursement message
-func (mf *MessageFactory) CreateLoanDisbursementPayload(event *LoanApprovalEvent) ([]byte, error) {
+// CreateLoanDisbursementPayload creates the payload for a loan disbursement message.
+// token is the loan asset on the destination chain.
+func (mf *MessageFactory) CreateLoanDisbursementPayload(event *LoanApprovalEvent, token common.Address) ([]byte, error) {
 	if event == nil {
 		return nil, fmt.Errorf("event cannot be nil")
 	}
 
-	payload := LoanDisbursementPayload{
-		LoanID:   event.TokenID,
+	return EncodePayload(MessageTypeLoanDisbursement, &LoanDisbursementPayload{
+		Token:    token,
 		Merchant: event.Merchant,
 		Amount:   event.Amount,
+	})
+}
+
+// CreateRepaymentConfirmationPayload creates the payload for a repayment confirmation message
+func (mf *MessageFactory) CreateRepaymentConfirmationPayload(event *RepaymentEvent) ([]byte, error) {
+	if event == nil {
+		return nil, fmt.Errorf("event cannot be nil")
 	}
 
-	// In a real implementation, you would use ABI encoding.
-	// For now, we'll use JSON encoding.
-	return json.Marshal(payload)
+	totalRepaid := event.TotalRepaid
+	if totalRepaid == nil {
+		totalRepaid = new(big.Int)
+	}
+	return EncodePayload(MessageTypeRepaymentConfirmation, &RepaymentConfirmationPayload{
+		LoanID:      event.TokenID,
+		Payer:       event.Payer,
+		Amount:      event.Amount,
+		TotalRepaid: totalRepaid,
+		Timestamp:   uint64(event.Timestamp.Unix()),
+	})
 }
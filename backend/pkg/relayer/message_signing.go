@@ -0,0 +1,388 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// EIP-712 domain of relayer signatures. The verifying contract is the receiver on the
+// destination chain, so a signature cannot be replayed against another receiver.
+const (
+	SigningDomainName    = "KeloRelayer"
+	SigningDomainVersion = "1"
+
+	// relayerMessageType is the EIP-712 type LayerZeroEVMReceiver hashes
+	relayerMessageType = "RelayerMessage(uint8 messageType,bytes payload,uint32 srcChainId,uint256 nonce,uint64 expiry,uint32 signerSetVersion)"
+
+	// signatureTTL is how long a signature stays valid. Messages are signed again on
+	// every dispatch attempt, so this only has to cover delivery of one attempt.
+	signatureTTL = 24 * time.Hour
+)
+
+var (
+	eip712DomainTypeHash   = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	relayerMessageTypeHash = crypto.Keccak256Hash([]byte(relayerMessageType))
+
+	// signedMessageArgs is abi.encode(RelayerMessage, bytes signature), the payload
+	// the receiver's lzReceive decodes
+	signedMessageArgs = mustSignedMessageArgs()
+)
+
+// Signature verification errors
+var (
+	ErrMalformedSignedMessage = errors.New("malformed signed message")
+	ErrSignatureExpired       = errors.New("signature expired")
+	ErrSourceChainMismatch    = errors.New("source chain mismatch")
+	ErrSignerSetRetired       = errors.New("signer set retired")
+	ErrUnknownSigner          = errors.New("signer not in signer set")
+)
+
+// SigningDomain identifies the receiver a signature is valid for
+type SigningDomain struct {
+	ChainID           *big.Int       // EIP-155 chain ID of the destination, zero off EVM
+	VerifyingContract common.Address // receiver contract on the destination
+}
+
+// Separator returns the EIP-712 domain separator, as computed by OpenZeppelin's EIP712
+func (d SigningDomain) Separator() common.Hash {
+	chainID := d.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	return crypto.Keccak256Hash(
+		eip712DomainTypeHash.Bytes(),
+		crypto.Keccak256([]byte(SigningDomainName)),
+		crypto.Keccak256([]byte(SigningDomainVersion)),
+		math.U256Bytes(new(big.Int).Set(chainID)),
+		common.LeftPadBytes(d.VerifyingContract.Bytes(), 32),
+	)
+}
+
+// SignedMessage is a relayer payload together with the fields the relayer signs
+// over it. Receivers reject a message once its nonce has been used, after its expiry,
+// if it comes from another source chain or if its signer set has been retired.
+type SignedMessage struct {
+	MessageType      MessageType `json:"message_type"`
+	Payload          []byte      `json:"payload"`
+	SrcChainID       uint32      `json:"src_chain_id"` // LayerZero endpoint ID of the sending chain
+	Nonce            *big.Int    `json:"nonce"`
+	Expiry           uint64      `json:"expiry"` // unix seconds
+	SignerSetVersion uint32      `json:"signer_set_version"`
+	Signature        []byte      `json:"signature,omitempty"`
+}
+
+// StructHash returns the EIP-712 hash of the signed fields
+func (m *SignedMessage) StructHash() common.Hash {
+	nonce := m.Nonce
+	if nonce == nil {
+		nonce = new(big.Int)
+	}
+	return crypto.Keccak256Hash(
+		relayerMessageTypeHash.Bytes(),
+		common.LeftPadBytes([]byte{uint8(m.MessageType)}, 32),
+		crypto.Keccak256(m.Payload),
+		common.LeftPadBytes(new(big.Int).SetUint64(uint64(m.SrcChainID)).Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(nonce)),
+		common.LeftPadBytes(new(big.Int).SetUint64(m.Expiry).Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(uint64(m.SignerSetVersion)).Bytes(), 32),
+	)
+}
+
+// TypedDataHash returns the EIP-712 digest the relayer signs for the domain
+func (m *SignedMessage) TypedDataHash(domain SigningDomain) common.Hash {
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domain.Separator().Bytes(), m.StructHash().Bytes())
+}
+
+// TypedData returns the message as EIP-712 typed data for the domain, the form remote
+// signers sign
+func (m *SignedMessage) TypedData(domain SigningDomain) apitypes.TypedData {
+	chainID := domain.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	nonce := m.Nonce
+	if nonce == nil {
+		nonce = new(big.Int)
+	}
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"RelayerMessage": {
+				{Name: "messageType", Type: "uint8"},
+				{Name: "payload", Type: "bytes"},
+				{Name: "srcChainId", Type: "uint32"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "expiry", Type: "uint64"},
+				{Name: "signerSetVersion", Type: "uint32"},
+			},
+		},
+		PrimaryType: "RelayerMessage",
+		Domain: apitypes.TypedDataDomain{
+			Name:              SigningDomainName,
+			Version:           SigningDomainVersion,
+			ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainID)),
+			VerifyingContract: domain.VerifyingContract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"messageType":      strconv.Itoa(int(m.MessageType)),
+			"payload":          hexutil.Encode(m.Payload),
+			"srcChainId":       strconv.FormatUint(uint64(m.SrcChainID), 10),
+			"nonce":            nonce.String(),
+			"expiry":           strconv.FormatUint(m.Expiry, 10),
+			"signerSetVersion": strconv.FormatUint(uint64(m.SignerSetVersion), 10),
+		},
+	}
+}
+
+// Sign signs the message for the domain and sets its signature
+func (m *SignedMessage) Sign(ctx context.Context, domain SigningDomain, signer Signer) error {
+	signature, err := signer.SignTypedData(ctx, m.TypedData(domain))
+	if err != nil {
+		return fmt.Errorf("failed to sign message: %w", err)
+	}
+	m.Signature = signature
+	return nil
+}
+
+// Signer recovers the address that signed the message for the domain
+func (m *SignedMessage) Signer(domain SigningDomain) (common.Address, error) {
+	if len(m.Signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: signature is %d bytes", ErrMalformedSignedMessage, len(m.Signature))
+	}
+	signature := append([]byte(nil), m.Signature...)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(m.TypedDataHash(domain).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrMalformedSignedMessage, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// relayerMessageTuple mirrors the receiver's RelayerMessage struct for ABI encoding
+type relayerMessageTuple struct {
+	MessageType      uint8
+	Payload          []byte
+	SrcChainId       uint32
+	Nonce            *big.Int
+	Expiry           uint64
+	SignerSetVersion uint32
+}
+
+func mustSignedMessageArgs() abi.Arguments {
+	message, err := abi.NewType("tuple", "RelayerMessage", []abi.ArgumentMarshaling{
+		{Name: "messageType", Type: "uint8"},
+		{Name: "payload", Type: "bytes"},
+		{Name: "srcChainId", Type: "uint32"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "expiry", Type: "uint64"},
+		{Name: "signerSetVersion", Type: "uint32"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	signature, err := abi.NewType("bytes", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return abi.Arguments{{Type: message}, {Type: signature}}
+}
+
+// Encode ABI-encodes the message and its signature as abi.encode(RelayerMessage, bytes)
+func (m *SignedMessage) Encode() ([]byte, error) {
+	nonce := m.Nonce
+	if nonce == nil {
+		nonce = new(big.Int)
+	}
+	data, err := signedMessageArgs.Pack(relayerMessageTuple{
+		MessageType:      uint8(m.MessageType),
+		Payload:          m.Payload,
+		SrcChainId:       m.SrcChainID,
+		Nonce:            nonce,
+		Expiry:           m.Expiry,
+		SignerSetVersion: m.SignerSetVersion,
+	}, m.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signed message: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeSignedMessage decodes a payload built by SignedMessage.Encode
+func DecodeSignedMessage(data []byte) (*SignedMessage, error) {
+	values, err := signedMessageArgs.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedSignedMessage, err)
+	}
+	tuple, ok := abi.ConvertType(values[0], new(relayerMessageTuple)).(*relayerMessageTuple)
+	if !ok {
+		return nil, ErrMalformedSignedMessage
+	}
+	signature, ok := values[1].([]byte)
+	if !ok {
+		return nil, ErrMalformedSignedMessage
+	}
+	return &SignedMessage{
+		MessageType:      MessageType(tuple.MessageType),
+		Payload:          tuple.Payload,
+		SrcChainID:       tuple.SrcChainId,
+		Nonce:            tuple.Nonce,
+		Expiry:           tuple.Expiry,
+		SignerSetVersion: tuple.SignerSetVersion,
+		Signature:        signature,
+	}, nil
+}
+
+// MessageNonce derives a message's signing nonce from its ID. IDs are derived from the
+// source event, so a replayed event cannot be delivered twice.
+func MessageNonce(messageID string) *big.Int {
+	return new(big.Int).SetBytes(crypto.Keccak256([]byte(messageID)))
+}
+
+// SignerSet is a version of the set of keys allowed to sign relayer messages. Keys are
+// rotated by publishing a new version and retiring the old one once nothing signed
+// with it is in flight.
+type SignerSet struct {
+	Version uint32           `json:"version"`
+	Signers []common.Address `json:"signers"`
+}
+
+// MessageVerifier checks signed relayer messages the way LayerZeroEVMReceiver does.
+// Nonce reuse is not tracked: callers that act on a message must remember its nonce.
+type MessageVerifier struct {
+	domain              SigningDomain
+	srcChainID          uint32
+	signerSets          map[uint32]map[common.Address]bool
+	minSignerSetVersion uint32
+	now                 func() time.Time
+}
+
+// NewMessageVerifier creates a verifier for messages sent to the domain from the
+// source chain
+func NewMessageVerifier(domain SigningDomain, srcChainID uint32, sets ...SignerSet) *MessageVerifier {
+	v := &MessageVerifier{
+		domain:     domain,
+		srcChainID: srcChainID,
+		signerSets: make(map[uint32]map[common.Address]bool),
+		now:        time.Now,
+	}
+	for _, set := range sets {
+		v.AddSignerSet(set)
+	}
+	return v
+}
+
+// AddSignerSet allows the set's signers for its version
+func (v *MessageVerifier) AddSignerSet(set SignerSet) {
+	signers := v.signerSets[set.Version]
+	if signers == nil {
+		signers = make(map[common.Address]bool)
+		v.signerSets[set.Version] = signers
+	}
+	for _, signer := range set.Signers {
+		signers[signer] = true
+	}
+}
+
+// SetMinSignerSetVersion retires every signer set older than version
+func (v *MessageVerifier) SetMinSignerSetVersion(version uint32) {
+	v.minSignerSetVersion = version
+}
+
+// Verify decodes an encoded signed message and verifies it
+func (v *MessageVerifier) Verify(data []byte) (*SignedMessage, common.Address, error) {
+	message, err := DecodeSignedMessage(data)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	signer, err := v.VerifyMessage(message)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return message, signer, nil
+}
+
+// VerifyMessage checks the message's expiry, source chain and signer set and returns
+// its signer
+func (v *MessageVerifier) VerifyMessage(message *SignedMessage) (common.Address, error) {
+	if uint64(v.now().Unix()) > message.Expiry {
+		return common.Address{}, fmt.Errorf("%w at %s", ErrSignatureExpired, time.Unix(int64(message.Expiry), 0).UTC())
+	}
+	if message.SrcChainID != v.srcChainID {
+		return common.Address{}, fmt.Errorf("%w: got %d, want %d", ErrSourceChainMismatch, message.SrcChainID, v.srcChainID)
+	}
+	if message.SignerSetVersion < v.minSignerSetVersion {
+		return common.Address{}, fmt.Errorf("%w: version %d", ErrSignerSetRetired, message.SignerSetVersion)
+	}
+
+	signer, err := message.Signer(v.domain)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !v.signerSets[message.SignerSetVersion][signer] {
+		return common.Address{}, fmt.Errorf("%w %d: %s", ErrUnknownSigner, message.SignerSetVersion, signer.Hex())
+	}
+	return signer, nil
+}
+
+// signingDomain returns the domain of signatures for the chain's receiver. Solana and
+// Aptos pools are called by the relayer directly and have no EIP-155 chain ID.
+func signingDomain(chain *ChainConfig) SigningDomain {
+	domain := SigningDomain{ChainID: new(big.Int), VerifyingContract: chain.LayerZeroReceiver}
+	if chain.Type != config.ChainTypeSolana && chain.Type != config.ChainTypeAptos {
+		if chainID, ok := new(big.Int).SetString(chain.ChainID, 10); ok {
+			domain.ChainID = chainID
+		}
+	}
+	return domain
+}
+
+// sourceEID returns the LayerZero endpoint ID of the chain messages are sent from
+func (tr *TrustedRelayer) sourceEID() uint32 {
+	if chain, ok := tr.chainConfigs[tr.config.LayerZeroSourceChain]; ok {
+		return chain.LayerZeroEID
+	}
+	return 0
+}
+
+// signMessage signs the message for its destination with the relayer key. Messages are
+// signed again on every attempt, so a retried message never carries an expired
+// signature and a message replayed to another chain is signed for that chain.
+func (tr *TrustedRelayer) signMessage(message *Message, chain *ChainConfig) (*SignedMessage, error) {
+	expiry := time.Now().Add(signatureTTL).UTC().Truncate(time.Second)
+	signed := &SignedMessage{
+		MessageType:      message.Type,
+		Payload:          message.Payload,
+		SrcChainID:       tr.sourceEID(),
+		Nonce:            MessageNonce(message.ID),
+		Expiry:           uint64(expiry.Unix()),
+		SignerSetVersion: uint32(tr.config.RelayerSignerSetVersion),
+	}
+	if err := signed.Sign(tr.ctx, signingDomain(chain), tr.signer); err != nil {
+		return nil, err
+	}
+
+	message.Signature = signed.Signature
+	message.SignerSetVersion = signed.SignerSetVersion
+	message.SignatureExpiry = &expiry
+	return signed, nil
+}
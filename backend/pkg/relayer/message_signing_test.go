@@ -0,0 +1,205 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSigningDomain = SigningDomain{
+	ChainID:           big.NewInt(8453),
+	VerifyingContract: common.HexToAddress("0x2222222222222222222222222222222222222222"),
+}
+
+func newTestSignedMessage(t *testing.T, signer Signer, version uint32, expiry time.Time) *SignedMessage {
+	message := &SignedMessage{
+		MessageType:      MessageTypeRepaymentConfirmation,
+		Payload:          []byte{0xde, 0xad, 0xbe, 0xef},
+		SrcChainID:       30101,
+		Nonce:            MessageNonce("repayment-7"),
+		Expiry:           uint64(expiry.Unix()),
+		SignerSetVersion: version,
+	}
+	require.NoError(t, message.Sign(context.Background(), testSigningDomain, signer))
+	return message
+}
+
+func TestSignedMessage_MatchesEIP712TypedData(t *testing.T) {
+	message := &SignedMessage{
+		MessageType:      MessageTypeLoanDisbursement,
+		Payload:          []byte{0x01, 0x02, 0x03},
+		SrcChainID:       30101,
+		Nonce:            MessageNonce("loan-1"),
+		Expiry:           1700000000,
+		SignerSetVersion: 3,
+	}
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"RelayerMessage": {
+				{Name: "messageType", Type: "uint8"},
+				{Name: "payload", Type: "bytes"},
+				{Name: "srcChainId", Type: "uint32"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "expiry", Type: "uint64"},
+				{Name: "signerSetVersion", Type: "uint32"},
+			},
+		},
+		PrimaryType: "RelayerMessage",
+		Domain: apitypes.TypedDataDomain{
+			Name:              SigningDomainName,
+			Version:           SigningDomainVersion,
+			ChainId:           ethmath.NewHexOrDecimal256(8453),
+			VerifyingContract: testSigningDomain.VerifyingContract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"messageType":      fmt.Sprint(int(message.MessageType)),
+			"payload":          hexutil.Encode(message.Payload),
+			"srcChainId":       "30101",
+			"nonce":            message.Nonce.String(),
+			"expiry":           "1700000000",
+			"signerSetVersion": "3",
+		},
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	assert.Equal(t, hash, message.TypedDataHash(testSigningDomain).Bytes())
+
+	// What remote signers are asked to sign hashes the same
+	hash, _, err = apitypes.TypedDataAndHash(message.TypedData(testSigningDomain))
+	require.NoError(t, err)
+	assert.Equal(t, hash, message.TypedDataHash(testSigningDomain).Bytes())
+	assert.Equal(t, []byte(typedData.TypeHash("RelayerMessage")), relayerMessageTypeHash.Bytes())
+}
+
+func TestMessageVerifier_VerifiesEncodedMessage(t *testing.T) {
+	key, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	signer := key.Address()
+	message := newTestSignedMessage(t, key, 1, time.Now().Add(time.Hour))
+
+	data, err := message.Encode()
+	require.NoError(t, err)
+
+	verifier := NewMessageVerifier(testSigningDomain, 30101, SignerSet{Version: 1, Signers: []common.Address{signer}})
+	decoded, recovered, err := verifier.Verify(data)
+	require.NoError(t, err)
+	assert.Equal(t, signer, recovered)
+	assert.Equal(t, message, decoded)
+
+	// A signature for one receiver is worthless at another
+	other := NewMessageVerifier(SigningDomain{ChainID: big.NewInt(8453), VerifyingContract: common.HexToAddress("0x3333333333333333333333333333333333333333")}, 30101, SignerSet{Version: 1, Signers: []common.Address{signer}})
+	_, _, err = other.Verify(data)
+	assert.True(t, errors.Is(err, ErrUnknownSigner))
+
+	// So is a tampered payload
+	tampered := *message
+	tampered.Payload = []byte{0xde, 0xad, 0xbe, 0xee}
+	_, err = verifier.VerifyMessage(&tampered)
+	assert.True(t, errors.Is(err, ErrUnknownSigner))
+
+	_, _, err = verifier.Verify([]byte{0x01})
+	assert.True(t, errors.Is(err, ErrMalformedSignedMessage))
+}
+
+func TestMessageVerifier_RejectsExpiredAndForeignMessages(t *testing.T) {
+	key, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	verifier := NewMessageVerifier(testSigningDomain, 30101, SignerSet{Version: 1, Signers: []common.Address{key.Address()}})
+
+	expired := newTestSignedMessage(t, key, 1, time.Now().Add(-time.Minute))
+	_, err = verifier.VerifyMessage(expired)
+	assert.True(t, errors.Is(err, ErrSignatureExpired))
+
+	foreign := newTestSignedMessage(t, key, 1, time.Now().Add(time.Hour))
+	foreign.SrcChainID = 30184
+	require.NoError(t, foreign.Sign(context.Background(), testSigningDomain, key))
+	_, err = verifier.VerifyMessage(foreign)
+	assert.True(t, errors.Is(err, ErrSourceChainMismatch))
+}
+
+func TestMessageVerifier_SignerSetRotation(t *testing.T) {
+	oldKey, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	newKey, err := GenerateLocalSigner()
+	require.NoError(t, err)
+
+	verifier := NewMessageVerifier(testSigningDomain, 30101,
+		SignerSet{Version: 1, Signers: []common.Address{oldKey.Address()}},
+		SignerSet{Version: 2, Signers: []common.Address{newKey.Address()}},
+	)
+	expiry := time.Now().Add(time.Hour)
+
+	// While both sets are live each key verifies under its own version only
+	_, err = verifier.VerifyMessage(newTestSignedMessage(t, oldKey, 1, expiry))
+	assert.NoError(t, err)
+	_, err = verifier.VerifyMessage(newTestSignedMessage(t, newKey, 2, expiry))
+	assert.NoError(t, err)
+	_, err = verifier.VerifyMessage(newTestSignedMessage(t, oldKey, 2, expiry))
+	assert.True(t, errors.Is(err, ErrUnknownSigner))
+
+	// Retiring version 1 rejects the old key
+	verifier.SetMinSignerSetVersion(2)
+	_, err = verifier.VerifyMessage(newTestSignedMessage(t, oldKey, 1, expiry))
+	assert.True(t, errors.Is(err, ErrSignerSetRetired))
+	_, err = verifier.VerifyMessage(newTestSignedMessage(t, newKey, 2, expiry))
+	assert.NoError(t, err)
+}
+
+func TestReceiver_MatchesSigningScheme(t *testing.T) {
+	source, err := os.ReadFile("../../../contracts/layerzero/LayerZeroEVMReceiver.sol")
+	require.NoError(t, err)
+	receiver := string(source)
+
+	assert.Contains(t, receiver, fmt.Sprintf("%q", relayerMessageType))
+	assert.Contains(t, receiver, fmt.Sprintf("EIP712(%q, %q)", SigningDomainName, SigningDomainVersion))
+	assert.Contains(t, receiver, fmt.Sprintf("MESSAGE_TYPE_LOAN_DISBURSEMENT = %d;", MessageTypeLoanDisbursement))
+	assert.Contains(t, receiver, fmt.Sprintf("MESSAGE_TYPE_REPAYMENT_CONFIRMATION = %d;", MessageTypeRepaymentConfirmation))
+	assert.Contains(t, receiver, "abi.decode(_message, (RelayerMessage, bytes))")
+}
+
+func TestTrustedRelayer_SignsMessagesForDestination(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.config.RelayerSignerSetVersion = 4
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	chain := &ChainConfig{ChainID: "8453", Type: "evm", LayerZeroReceiver: testSigningDomain.VerifyingContract}
+
+	message := newPendingMessage(MessageTypeRepaymentConfirmation, "50", "base", []byte{0x05})
+	signed, err := relayer.signMessage(message, chain)
+	require.NoError(t, err)
+	assert.Equal(t, MessageNonce(message.ID), signed.Nonce)
+	assert.Equal(t, uint32(4), message.SignerSetVersion)
+	assert.Equal(t, signed.Signature, message.Signature)
+	require.NotNil(t, message.SignatureExpiry)
+	assert.Equal(t, uint64(message.SignatureExpiry.Unix()), signed.Expiry)
+
+	verifier := NewMessageVerifier(testSigningDomain, 30101, SignerSet{Version: 4, Signers: []common.Address{relayer.signer.Address()}})
+	_, err = verifier.VerifyMessage(signed)
+	assert.NoError(t, err)
+
+	// The signature survives the outbox
+	require.NoError(t, relayer.enqueue(message))
+	stored, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, message.Signature, stored.Signature)
+	assert.Equal(t, uint32(4), stored.SignerSetVersion)
+}
@@ -3,6 +3,7 @@ package relayer
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -66,6 +67,8 @@ const (
 	EventTypeTransactionFailed
 	EventTypeErrorOccurred
 	EventTypeHealthCheck
+	EventTypeChainReorg
+	EventTypeLimitReached
 )
 
 // EventStatus represents the status of an event
@@ -143,7 +146,8 @@ type AlertManager struct {
 	notifiers []AlertNotifier
 }
 
-// AlertRule defines a rule for generating alerts
+// AlertRule defines a rule for generating alerts. A rule raises one alert while its
+// condition holds and resolves it once the condition clears.
 type AlertRule struct {
 	Name        string
 	Condition   func(*Monitor) bool
@@ -151,6 +155,8 @@ type AlertRule struct {
 	Message     string
 	Enabled     bool
 	LastTriggered time.Time
+	
+	activeAlert *Alert
 }
 
 // AlertNotifier sends alert notifications
@@ -166,6 +172,8 @@ type PrometheusMetrics struct {
 	transactionLatency *prometheus.HistogramVec
 	errorCount       *prometheus.CounterVec
 	healthStatus     *prometheus.GaugeVec
+	chainReorgs      *prometheus.CounterVec
+	limitPauses      *prometheus.CounterVec
 }
 
 // NewMonitor creates a new monitor
@@ -181,8 +189,21 @@ func NewMonitor(ctx context.Context) *Monitor {
 	}
 }
 
-// NewPrometheusMetrics creates new Prometheus metrics
+var (
+	prometheusMetricsOnce sync.Once
+	prometheusMetrics     *PrometheusMetrics
+)
+
+// NewPrometheusMetrics returns the relayer's Prometheus metrics. They are registered
+// with the default registry once and shared by every monitor in the process.
 func NewPrometheusMetrics() *PrometheusMetrics {
+	prometheusMetricsOnce.Do(func() {
+		prometheusMetrics = newPrometheusMetrics()
+	})
+	return prometheusMetrics
+}
+
+func newPrometheusMetrics() *PrometheusMetrics {
 	return &PrometheusMetrics{
 		messagesProcessed: promauto.NewCounterVec(
 			prometheus.CounterOpts{
@@ -227,6 +248,20 @@ func NewPrometheusMetrics() *PrometheusMetrics {
 			},
 			[]string{"component"},
 		),
+		chainReorgs: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "relayer_chain_reorgs_total",
+				Help: "Total number of sent transactions moved or dropped by a chain reorg",
+			},
+			[]string{"chain_id", "outcome"},
+		),
+		limitPauses: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "relayer_limit_pauses_total",
+				Help: "Total number of messages held back by a chain's rate or spend limit",
+			},
+			[]string{"chain_id", "limit"},
+		),
 	}
 }
 
@@ -249,18 +284,18 @@ func NewAlertManager() *AlertManager {
 // RecordEvent records an event
 func (m *Monitor) RecordEvent(event *Event) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	
 	// Add to event buffer
 	m.eventBuffer.Add(event)
 	
 	// Update metrics
 	m.updateMetrics(event)
+	m.mu.Unlock()
 	
 	// Log event
 	m.logEvent(event)
 	
-	// Check alert rules
+	// Check alert rules. Conditions read the monitor, so this runs without its lock.
 	m.alertManager.CheckRules(m)
 }
 
@@ -287,7 +322,7 @@ func (m *Monitor) updateMetrics(event *Event) {
 		m.promMetrics.messagesFailed.WithLabelValues(
 			event.ChainID,
 			event.MessageType.String(),
-			"unknown",
+			metadataString(event.Metadata, "error_type", "unknown"),
 		).Inc()
 		
 	case EventTypeTransactionSubmitted:
@@ -298,15 +333,27 @@ func (m *Monitor) updateMetrics(event *Event) {
 			).Observe(event.Duration.Seconds())
 		}
 		
+	case EventTypeChainReorg:
+		m.promMetrics.chainReorgs.WithLabelValues(
+			event.ChainID,
+			metadataString(event.Metadata, "outcome", "unknown"),
+		).Inc()
+		
+	case EventTypeLimitReached:
+		m.promMetrics.limitPauses.WithLabelValues(
+			event.ChainID,
+			metadataString(event.Metadata, "limit", "unknown"),
+		).Inc()
+		
 	case EventTypeErrorOccurred:
 		m.promMetrics.errorCount.WithLabelValues(
-			event.Metadata["operation"].(string),
-			"unknown",
+			metadataString(event.Metadata, "operation", "unknown"),
+			metadataString(event.Metadata, "error_type", "unknown"),
 		).Inc()
 	}
 	
 	// Update average latency
-	if event.Duration > 0 {
+	if event.Type == EventTypeMessageProcessed && event.Duration > 0 {
 		m.metrics.AverageLatency = time.Duration(
 			(int64(m.metrics.AverageLatency)*int64(m.metrics.MessagesProcessed-1) + int64(event.Duration)) /
 				int64(m.metrics.MessagesProcessed),
@@ -316,6 +363,14 @@ func (m *Monitor) updateMetrics(event *Event) {
 	m.metrics.LastProcessedTime = time.Now()
 }
 
+// metadataString returns a string metadata value, or def if it is missing
+func metadataString(metadata map[string]interface{}, key, def string) string {
+	if value, ok := metadata[key].(string); ok && value != "" {
+		return value
+	}
+	return def
+}
+
 // logEvent logs the event
 func (m *Monitor) logEvent(event *Event) {
 	eventLog := m.logger.Info()
@@ -326,6 +381,14 @@ func (m *Monitor) logEvent(event *Event) {
 		eventLog = m.logger.Warn()
 	}
 	
+	if event.Error != "" {
+		eventLog = eventLog.Str("error", event.Error)
+	}
+	
+	if len(event.Metadata) > 0 {
+		eventLog = eventLog.Interface("metadata", event.Metadata)
+	}
+	
 	eventLog.
 		Str("event_id", event.ID).
 		Str("event_type", event.Type.String()).
@@ -335,14 +398,6 @@ func (m *Monitor) logEvent(event *Event) {
 		Time("timestamp", event.Timestamp).
 		Dur("duration", event.Duration).
 		Msg("Event recorded")
-	
-	if event.Error != "" {
-		eventLog.Str("error", event.Error)
-	}
-	
-	if len(event.Metadata) > 0 {
-		eventLog.Interface("metadata", event.Metadata)
-	}
 }
 
 // AddHealthCheck adds a health check
@@ -359,54 +414,63 @@ func (m *Monitor) AddHealthCheck(name string, checkFunc func() error) {
 	go m.runHealthCheck(name, checkFunc)
 }
 
-// runHealthCheck runs a health check periodically
+// runHealthCheck runs a health check straight away and then periodically
 func (m *Monitor) runHealthCheck(name string, checkFunc func() error) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 	
 	for {
+		m.checkHealth(name, checkFunc)
+		
 		select {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			start := time.Now()
-			err := checkFunc()
-			duration := time.Since(start)
-			
-			m.mu.Lock()
-			healthCheck := m.healthChecks[name]
-			healthCheck.LastChecked = time.Now()
-			healthCheck.Duration = duration
-			
-			if err != nil {
-				healthCheck.Status = HealthStatusUnhealthy
-				healthCheck.Error = err.Error()
-				
-				// Record error event
-				m.RecordEvent(&Event{
-					ID:        generateEventID(),
-					Type:      EventTypeErrorOccurred,
-					Timestamp: time.Now(),
-					Status:    EventStatusFailure,
-					Error:     err.Error(),
-					Metadata: map[string]interface{}{
-						"operation":     "health_check",
-						"health_check":  name,
-						"check_duration": duration,
-					},
-				})
-			} else {
-				healthCheck.Status = HealthStatusHealthy
-				healthCheck.Error = ""
-			}
-			
-			// Update Prometheus metrics
-			m.promMetrics.healthStatus.WithLabelValues(name).Set(float64(healthCheck.Status))
-			m.mu.Unlock()
 		}
 	}
 }
 
+// checkHealth runs a health check once and records its result
+func (m *Monitor) checkHealth(name string, checkFunc func() error) {
+	start := time.Now()
+	err := checkFunc()
+	duration := time.Since(start)
+	
+	m.mu.Lock()
+	healthCheck := m.healthChecks[name]
+	healthCheck.LastChecked = time.Now()
+	healthCheck.Duration = duration
+	
+	if err != nil {
+		healthCheck.Status = HealthStatusUnhealthy
+		healthCheck.Error = err.Error()
+	} else {
+		healthCheck.Status = HealthStatusHealthy
+		healthCheck.Error = ""
+	}
+	
+	// Update Prometheus metrics
+	m.promMetrics.healthStatus.WithLabelValues(name).Set(float64(healthCheck.Status))
+	m.mu.Unlock()
+	
+	if err != nil {
+		// Record error event
+		m.RecordEvent(&Event{
+			ID:        generateEventID(),
+			Type:      EventTypeErrorOccurred,
+			Timestamp: time.Now(),
+			Status:    EventStatusFailure,
+			Error:     err.Error(),
+			Metadata: map[string]interface{}{
+				"operation":      "health_check",
+				"error_type":     ErrorType(ClassifyError(err)),
+				"health_check":   name,
+				"check_duration": duration,
+			},
+		})
+	}
+}
+
 // GetHealthStatus returns the overall health status
 func (m *Monitor) GetHealthStatus() HealthStatus {
 	m.mu.RLock()
@@ -454,7 +518,8 @@ func (m *Monitor) GetHealthChecks() map[string]*HealthCheck {
 	
 	checks := make(map[string]*HealthCheck)
 	for k, v := range m.healthChecks {
-		checks[k] = v
+		check := *v
+		checks[k] = &check
 	}
 	
 	return checks
@@ -465,11 +530,21 @@ func (m *Monitor) GetAlerts() []*Alert {
 	return m.alertManager.GetAlerts()
 }
 
+// GetActiveAlerts returns the alerts that have not been resolved
+func (m *Monitor) GetActiveAlerts() []*Alert {
+	return m.alertManager.GetActiveAlerts()
+}
+
 // AddAlertRule adds an alert rule
 func (m *Monitor) AddAlertRule(rule *AlertRule) {
 	m.alertManager.AddRule(rule)
 }
 
+// SetAlertRule adds an alert rule, replacing the rule of the same name if there is one
+func (m *Monitor) SetAlertRule(rule *AlertRule) {
+	m.alertManager.SetRule(rule)
+}
+
 // AddAlertNotifier adds an alert notifier
 func (m *Monitor) AddAlertNotifier(notifier AlertNotifier) {
 	m.alertManager.AddNotifier(notifier)
@@ -512,6 +587,24 @@ func (am *AlertManager) AddRule(rule *AlertRule) {
 	am.rules = append(am.rules, rule)
 }
 
+// SetRule adds an alert rule, replacing the rule of the same name and resolving its
+// alert if there is one
+func (am *AlertManager) SetRule(rule *AlertRule) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for i, existing := range am.rules {
+		if existing.Name == rule.Name {
+			if existing.activeAlert != nil {
+				am.resolve(existing)
+			}
+			am.rules[i] = rule
+			return
+		}
+	}
+	am.rules = append(am.rules, rule)
+}
+
 // AddNotifier adds an alert notifier
 func (am *AlertManager) AddNotifier(notifier AlertNotifier) {
 	am.mu.Lock()
@@ -520,17 +613,32 @@ func (am *AlertManager) AddNotifier(notifier AlertNotifier) {
 	am.notifiers = append(am.notifiers, notifier)
 }
 
-// CheckRules checks all alert rules
+// CheckRules checks all alert rules. A rule whose condition holds raises an alert
+// unless it already has an unresolved one; a rule whose condition has cleared resolves
+// its alert.
 func (am *AlertManager) CheckRules(monitor *Monitor) {
 	am.mu.RLock()
-	defer am.mu.RUnlock()
+	rules := append([]*AlertRule(nil), am.rules...)
+	am.mu.RUnlock()
+	
+	// Conditions may read alerts, so they are evaluated without holding the lock
+	triggered := make([]bool, len(rules))
+	for i, rule := range rules {
+		triggered[i] = rule.Enabled && rule.Condition(monitor)
+	}
+	
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	
-	for _, rule := range am.rules {
-		if !rule.Enabled {
+	for i, rule := range rules {
+		if !triggered[i] {
+			if rule.activeAlert != nil {
+				am.resolve(rule)
+			}
 			continue
 		}
 		
-		if rule.Condition(monitor) {
+		if rule.activeAlert == nil {
 			// Create alert
 			alert := &Alert{
 				ID:          generateAlertID(),
@@ -546,24 +654,39 @@ func (am *AlertManager) CheckRules(monitor *Monitor) {
 			}
 			
 			// Add alert
-			am.mu.Lock()
 			am.alerts[alert.ID] = alert
-			am.mu.Unlock()
+			rule.activeAlert = alert
 			
 			// Send notifications
-			for _, notifier := range am.notifiers {
-				go func(n AlertNotifier, a *Alert) {
-					if err := n.Notify(a); err != nil {
-						log.Error().Err(err).Str("alert_id", a.ID).Msg("Failed to send alert notification")
-					}
-				}(notifier, alert)
-			}
+			am.notify(alert)
 			
 			rule.LastTriggered = time.Now()
 		}
 	}
 }
 
+// resolve resolves the rule's alert and notifies it. The caller holds the lock.
+func (am *AlertManager) resolve(rule *AlertRule) {
+	rule.activeAlert.Resolved = true
+	rule.activeAlert.ResolvedAt = time.Now()
+	log.Info().Str("alert_id", rule.activeAlert.ID).Str("rule_name", rule.Name).Msg("Alert resolved")
+	am.notify(rule.activeAlert)
+	rule.activeAlert = nil
+}
+
+// notify sends a copy of the alert to every notifier in the background. The caller
+// holds the lock.
+func (am *AlertManager) notify(alert *Alert) {
+	for _, notifier := range am.notifiers {
+		copied := *alert
+		go func(n AlertNotifier, a *Alert) {
+			if err := n.Notify(a); err != nil {
+				log.Error().Err(err).Str("alert_id", a.ID).Msg("Failed to send alert notification")
+			}
+		}(notifier, &copied)
+	}
+}
+
 // GetAlerts returns all alerts
 func (am *AlertManager) GetAlerts() []*Alert {
 	am.mu.RLock()
@@ -571,12 +694,25 @@ func (am *AlertManager) GetAlerts() []*Alert {
 	
 	alerts := make([]*Alert, 0, len(am.alerts))
 	for _, alert := range am.alerts {
-		alerts = append(alerts, alert)
+		copied := *alert
+		alerts = append(alerts, &copied)
 	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Timestamp.Before(alerts[j].Timestamp) })
 	
 	return alerts
 }
 
+// GetActiveAlerts returns the unresolved alerts, oldest first
+func (am *AlertManager) GetActiveAlerts() []*Alert {
+	var active []*Alert
+	for _, alert := range am.GetAlerts() {
+		if !alert.Resolved {
+			active = append(active, alert)
+		}
+	}
+	return active
+}
+
 // Helper functions
 
 // generateEventID generates a unique event ID
@@ -603,6 +739,8 @@ func (et EventType) String() string {
 		"TRANSACTION_FAILED",
 		"ERROR_OCCURRED",
 		"HEALTH_CHECK",
+		"CHAIN_REORG",
+		"LIMIT_REACHED",
 	}[et]
 }
 
@@ -640,6 +778,51 @@ func (as AlertSeverity) String() string {
 	}[as]
 }
 
+// MarshalText methods encode the enums by name in API responses
+
+func (et EventType) MarshalText() ([]byte, error)     { return []byte(et.String()), nil }
+func (es EventStatus) MarshalText() ([]byte, error)   { return []byte(es.String()), nil }
+func (hs HealthStatus) MarshalText() ([]byte, error)  { return []byte(hs.String()), nil }
+func (at AlertType) MarshalText() ([]byte, error)     { return []byte(at.String()), nil }
+func (as AlertSeverity) MarshalText() ([]byte, error) { return []byte(as.String()), nil }
+
+// UnmarshalText methods decode the names back for clients of the API, such as the
+// relayer's status command
+
+func (et *EventType) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, et, EventTypeLimitReached)
+}
+
+func (es *EventStatus) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, es, EventStatusWarning)
+}
+
+func (hs *HealthStatus) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, hs, HealthStatusUnhealthy)
+}
+
+func (at *AlertType) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, at, AlertTypeInfo)
+}
+
+func (as *AlertSeverity) UnmarshalText(text []byte) error {
+	return unmarshalEnum(text, as, SeverityCritical)
+}
+
+// unmarshalEnum sets value to the enum value up to last whose name is text
+func unmarshalEnum[T interface {
+	~int
+	String() string
+}](text []byte, value *T, last T) error {
+	for v := T(0); v <= last; v++ {
+		if v.String() == string(text) {
+			*value = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value %q", text)
+}
+
 // LogNotifier is a simple alert notifier that logs alerts
 type LogNotifier struct{}
 
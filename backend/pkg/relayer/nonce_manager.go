@@ -0,0 +1,419 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
+)
+
+// defaultGapGrace is how long a released nonce waits to be reused by new work before
+// it is filled with a no-op transaction.
+const defaultGapGrace = time.Minute
+
+// defaultReservationLease is how long a nonce another instance reserved may take to
+// reach the chain. Until then it is not treated as a gap.
+const defaultReservationLease = 5 * time.Minute
+
+// NonceStore persists nonce reservations so a restarted relayer never hands out a
+// nonce it may already have signed with.
+type NonceStore interface {
+	// Reserve atomically returns the larger of the stored next nonce and floor, and
+	// stores the nonce after it.
+	Reserve(ctx context.Context, chainID, signer string, floor uint64) (uint64, error)
+	// Next returns the stored next nonce; false if nothing has been reserved yet.
+	Next(ctx context.Context, chainID, signer string) (uint64, bool, error)
+}
+
+// GapFiller sends a no-op transaction at the given nonce.
+type GapFiller func(ctx context.Context, chainID, signer string, nonce uint64) error
+
+// NonceManager hands out transaction nonces per chain and signer. Every code path
+// that signs for a relayer account must allocate through the same manager.
+//
+// An allocated nonce must be either confirmed once the transaction is broadcast or
+// released when it never was. Released nonces below the highest allocated nonce are
+// gaps that block later transactions; they are handed out again before new nonces,
+// and filled with no-op transactions if nothing reuses them.
+//
+// Nonces that were reserved in the store before the manager started may belong to
+// another instance that is about to send them. They only become gaps once they are
+// still missing from the chain after the reservation lease.
+type NonceManager struct {
+	chains   *blockchain.Registry
+	store    NonceStore
+	gapGrace time.Duration
+	lease    time.Duration
+
+	mu       sync.Mutex
+	accounts map[string]*nonceAccount
+}
+
+// nonceAccount is the nonce state of one signer on one chain.
+type nonceAccount struct {
+	mu      sync.Mutex
+	chainID string
+	signer  string
+	synced  bool
+	// floor is the chain's pending nonce at the last sync; no nonce below it is used.
+	floor uint64
+	// next is one past the highest nonce this manager has reserved.
+	next     uint64
+	free     map[uint64]time.Time // released nonces and when they were released
+	inflight map[uint64]bool
+	// unclaimed are nonces reserved before the first sync that have not reached the
+	// chain, and when they were first seen.
+	unclaimed map[uint64]time.Time
+}
+
+// NewNonceManager creates a nonce manager backed by the store
+func NewNonceManager(chains *blockchain.Registry, store NonceStore) *NonceManager {
+	return &NonceManager{
+		chains:   chains,
+		store:    store,
+		gapGrace: defaultGapGrace,
+		lease:    defaultReservationLease,
+		accounts: make(map[string]*nonceAccount),
+	}
+}
+
+func (nm *NonceManager) account(chainID, signer string) *nonceAccount {
+	key := chainID + ":" + strings.ToLower(signer)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	acct, ok := nm.accounts[key]
+	if !ok {
+		acct = &nonceAccount{
+			chainID:   chainID,
+			signer:    signer,
+			free:      make(map[uint64]time.Time),
+			inflight:  make(map[uint64]bool),
+			unclaimed: make(map[uint64]time.Time),
+		}
+		nm.accounts[key] = acct
+	}
+	return acct
+}
+
+// Allocate reserves a nonce for the signer, reusing the lowest released nonce first.
+func (nm *NonceManager) Allocate(ctx context.Context, chainID, signer string) (uint64, error) {
+	acct := nm.account(chainID, signer)
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	if !acct.synced {
+		if err := nm.resync(ctx, acct); err != nil {
+			return 0, err
+		}
+	}
+
+	if gaps := acct.sortedFree(); len(gaps) > 0 {
+		nonce := gaps[0]
+		delete(acct.free, nonce)
+		acct.inflight[nonce] = true
+		return nonce, nil
+	}
+
+	nonce, err := nm.store.Reserve(ctx, chainID, signer, acct.floor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve nonce: %w", err)
+	}
+	// Another relayer instance sharing the signer may have reserved past acct.next
+	if nonce+1 > acct.next {
+		acct.next = nonce + 1
+	}
+	acct.inflight[nonce] = true
+	return nonce, nil
+}
+
+// Confirm marks an allocated nonce as broadcast.
+func (nm *NonceManager) Confirm(chainID, signer string, nonce uint64) {
+	acct := nm.account(chainID, signer)
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	delete(acct.inflight, nonce)
+}
+
+// Release returns an allocated nonce that was never broadcast.
+func (nm *NonceManager) Release(chainID, signer string, nonce uint64) {
+	acct := nm.account(chainID, signer)
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	delete(acct.inflight, nonce)
+	if nonce >= acct.floor {
+		acct.free[nonce] = time.Now()
+	}
+}
+
+// Fail handles a send error for an allocated nonce. It reports whether the send should
+// be retried with a new nonce: when the chain already used the nonce it is dropped and
+// the account is resynced; otherwise the nonce is released.
+func (nm *NonceManager) Fail(ctx context.Context, chainID, signer string, nonce uint64, sendErr error) bool {
+	switch {
+	case isNonceUsedError(sendErr):
+		acct := nm.account(chainID, signer)
+		acct.mu.Lock()
+		defer acct.mu.Unlock()
+
+		delete(acct.inflight, nonce)
+		delete(acct.free, nonce)
+		delete(acct.unclaimed, nonce)
+		if err := nm.resync(ctx, acct); err != nil {
+			log.Error().Err(err).Str("chain_id", chainID).Msg("Failed to resync nonce")
+		}
+		log.Warn().
+			Err(sendErr).
+			Str("chain_id", chainID).
+			Str("signer", signer).
+			Uint64("nonce", nonce).
+			Uint64("chain_nonce", acct.floor).
+			Msg("Nonce already used, resynced with chain")
+		return true
+	default:
+		nm.Release(chainID, signer, nonce)
+		return false
+	}
+}
+
+// Resync reloads the signer's nonce from the chain and the store.
+func (nm *NonceManager) Resync(ctx context.Context, chainID, signer string) error {
+	acct := nm.account(chainID, signer)
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	return nm.resync(ctx, acct)
+}
+
+// resync takes the chain's pending nonce as the floor. On the first sync, nonces
+// between it and the last stored reservation were reserved but have not reached the
+// chain. They may have been lost in a crash, or another instance may be sending them,
+// so they only become gaps once they are older than the reservation lease.
+func (nm *NonceManager) resync(ctx context.Context, acct *nonceAccount) error {
+	adapter, err := nm.chains.Adapter(acct.chainID)
+	if err != nil {
+		return err
+	}
+	pending, err := adapter.Nonce(ctx, acct.signer)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce from chain: %w", err)
+	}
+	stored, found, err := nm.store.Next(ctx, acct.chainID, acct.signer)
+	if err != nil {
+		return fmt.Errorf("failed to load stored nonce: %w", err)
+	}
+
+	acct.floor = pending
+	for nonce := range acct.free {
+		if nonce < pending {
+			delete(acct.free, nonce)
+		}
+	}
+	if found && stored > pending {
+		if !acct.synced {
+			for nonce := pending; nonce < stored; nonce++ {
+				if !acct.inflight[nonce] {
+					acct.unclaimed[nonce] = time.Now()
+				}
+			}
+		}
+		if stored > acct.next {
+			acct.next = stored
+		}
+	}
+	if pending > acct.next {
+		acct.next = pending
+	}
+	acct.synced = true
+
+	for nonce, seen := range acct.unclaimed {
+		switch {
+		case nonce < pending:
+			delete(acct.unclaimed, nonce)
+		case time.Since(seen) >= nm.lease:
+			delete(acct.unclaimed, nonce)
+			acct.free[nonce] = seen
+		}
+	}
+
+	if len(acct.free) > 0 {
+		log.Warn().
+			Str("chain_id", acct.chainID).
+			Str("signer", acct.signer).
+			Uint64("chain_nonce", pending).
+			Uint64("next_nonce", acct.next).
+			Int("gaps", len(acct.free)).
+			Msg("Nonce gaps detected")
+	}
+	return nil
+}
+
+// Gaps returns the released nonces of the signer, lowest first.
+func (nm *NonceManager) Gaps(chainID, signer string) []uint64 {
+	acct := nm.account(chainID, signer)
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	return acct.sortedFree()
+}
+
+// FillGaps fills every gap that has not been reused within the grace period. Gaps on
+// chains the filler does not support are left for new transactions to reuse.
+func (nm *NonceManager) FillGaps(ctx context.Context, filler GapFiller) {
+	nm.mu.Lock()
+	accounts := make([]*nonceAccount, 0, len(nm.accounts))
+	for _, acct := range nm.accounts {
+		accounts = append(accounts, acct)
+	}
+	nm.mu.Unlock()
+
+	for _, acct := range accounts {
+		acct.mu.Lock()
+		// Nonces reserved before the first sync become gaps once their lease is over
+		if len(acct.unclaimed) > 0 {
+			if err := nm.resync(ctx, acct); err != nil {
+				log.Error().Err(err).Str("chain_id", acct.chainID).Msg("Failed to resync nonce")
+			}
+		}
+		var due []uint64
+		for _, nonce := range acct.sortedFree() {
+			if time.Since(acct.free[nonce]) >= nm.gapGrace {
+				delete(acct.free, nonce)
+				acct.inflight[nonce] = true
+				due = append(due, nonce)
+			}
+		}
+		acct.mu.Unlock()
+
+		for _, nonce := range due {
+			err := filler(ctx, acct.chainID, acct.signer, nonce)
+			switch {
+			case err == nil:
+				nm.Confirm(acct.chainID, acct.signer, nonce)
+				log.Info().Str("chain_id", acct.chainID).Uint64("nonce", nonce).Msg("Filled nonce gap")
+			case errors.Is(err, blockchain.ErrNotSupported):
+				nm.Release(acct.chainID, acct.signer, nonce)
+			default:
+				nm.Fail(ctx, acct.chainID, acct.signer, nonce, err)
+			}
+		}
+	}
+}
+
+func (acct *nonceAccount) sortedFree() []uint64 {
+	nonces := make([]uint64, 0, len(acct.free))
+	for nonce := range acct.free {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	return nonces
+}
+
+// isNonceUsedError reports whether a send failed because the nonce is already used,
+// either on chain or by another transaction in the mempool.
+func isNonceUsedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"nonce too low",
+		"already known",
+		"replacement transaction underpriced",
+		"sequence_number_too_old",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryNonceStore is an in-process NonceStore for tests and development.
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	next map[string]uint64
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{next: make(map[string]uint64)}
+}
+
+func (s *MemoryNonceStore) Reserve(ctx context.Context, chainID, signer string, floor uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := chainID + ":" + strings.ToLower(signer)
+	nonce := s.next[key]
+	if floor > nonce {
+		nonce = floor
+	}
+	s.next[key] = nonce + 1
+	return nonce, nil
+}
+
+func (s *MemoryNonceStore) Next(ctx context.Context, chainID, signer string) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := s.next[chainID+":"+strings.ToLower(signer)]
+	return next, ok, nil
+}
+
+// SupabaseNonceStore stores nonce reservations in the relayer_nonces table. Reservations
+// go through the reserve_relayer_nonce function so concurrent relayer instances
+// sharing a signer never receive the same nonce.
+type SupabaseNonceStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseNonceStore creates a new Supabase-backed nonce store
+func NewSupabaseNonceStore(db *supabase.Client) *SupabaseNonceStore {
+	return &SupabaseNonceStore{db: db}
+}
+
+func (s *SupabaseNonceStore) Reserve(ctx context.Context, chainID, signer string, floor uint64) (uint64, error) {
+	// Note: The Rpc method in this library version returns only a string.
+	// Errors are handled by returning an empty string, which will then fail to unmarshal.
+	result := s.db.Rpc("reserve_relayer_nonce", "", map[string]interface{}{
+		"p_chain_id": chainID,
+		"p_signer":   strings.ToLower(signer),
+		"p_floor":    floor,
+	})
+
+	var nonce uint64
+	if err := json.Unmarshal([]byte(result), &nonce); err != nil {
+		return 0, fmt.Errorf("failed to reserve nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+func (s *SupabaseNonceStore) Next(ctx context.Context, chainID, signer string) (uint64, bool, error) {
+	data, _, err := s.db.From("relayer_nonces").Select("next_nonce", "", false).
+		Eq("chain_id", chainID).
+		Eq("signer", strings.ToLower(signer)).
+		Execute()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load nonce: %w", err)
+	}
+
+	var rows []struct {
+		NextNonce uint64 `json:"next_nonce"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return 0, false, fmt.Errorf("failed to unmarshal nonce: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, false, nil
+	}
+	return rows[0].NextNonce, true, nil
+}
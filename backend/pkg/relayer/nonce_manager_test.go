@@ -0,0 +1,217 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSigner = "0x1234567890123456789012345678901234567890"
+
+func newTestNonceManager(t *testing.T, store NonceStore) (*NonceManager, *blockchain.FakeAdapter) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453})
+	registry := blockchain.NewRegistry()
+	require.NoError(t, registry.Register(adapter))
+	return NewNonceManager(registry, store), adapter
+}
+
+func TestNonceManager_ResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryNonceStore()
+	nm, adapter := newTestNonceManager(t, store)
+
+	for want := uint64(0); want < 3; want++ {
+		nonce, err := nm.Allocate(ctx, "base", testSigner)
+		require.NoError(t, err)
+		assert.Equal(t, want, nonce)
+	}
+
+	// Only nonce 0 reached the chain before the crash
+	adapter.SetNonce(testSigner, 1)
+
+	restarted := NewNonceManager(nm.chains, store)
+	restarted.lease = 0
+	require.NoError(t, restarted.Resync(ctx, "base", testSigner))
+	assert.Equal(t, []uint64{1, 2}, restarted.Gaps("base", testSigner))
+
+	for _, want := range []uint64{1, 2, 3} {
+		nonce, err := restarted.Allocate(ctx, "base", testSigner)
+		require.NoError(t, err)
+		assert.Equal(t, want, nonce)
+	}
+}
+
+func TestNonceManager_KeepsOtherInstancesReservations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryNonceStore()
+	other, adapter := newTestNonceManager(t, store)
+
+	// Another instance sharing the signer has reserved nonces 0 and 1 but not sent them yet
+	for want := uint64(0); want < 2; want++ {
+		nonce, err := other.Allocate(ctx, "base", testSigner)
+		require.NoError(t, err)
+		assert.Equal(t, want, nonce)
+	}
+
+	nm := NewNonceManager(other.chains, store)
+	nm.gapGrace = 0
+	nonce, err := nm.Allocate(ctx, "base", testSigner)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), nonce)
+	assert.Empty(t, nm.Gaps("base", testSigner))
+
+	// Nonce 0 reaches the chain; nonce 1 never does, so it becomes a gap after the lease
+	adapter.SetNonce(testSigner, 1)
+	var filled []uint64
+	filler := func(ctx context.Context, chainID, signer string, nonce uint64) error {
+		filled = append(filled, nonce)
+		return nil
+	}
+	nm.FillGaps(ctx, filler)
+	assert.Empty(t, filled)
+
+	nm.lease = 0
+	nm.FillGaps(ctx, filler)
+	assert.Equal(t, []uint64{1}, filled)
+}
+
+func TestNonceManager_ResyncsWhenNonceTooLow(t *testing.T) {
+	ctx := context.Background()
+	nm, adapter := newTestNonceManager(t, NewMemoryNonceStore())
+
+	nonce, err := nm.Allocate(ctx, "base", testSigner)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), nonce)
+
+	// The key was used outside the relayer
+	adapter.SetNonce(testSigner, 5)
+	assert.True(t, nm.Fail(ctx, "base", testSigner, nonce, errors.New("nonce too low: next nonce 5, tx nonce 0")))
+
+	nonce, err = nm.Allocate(ctx, "base", testSigner)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+
+	// Other errors release the nonce for reuse
+	assert.False(t, nm.Fail(ctx, "base", testSigner, nonce, errors.New("connection refused")))
+	nonce, err = nm.Allocate(ctx, "base", testSigner)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+}
+
+func TestNonceManager_FillsGaps(t *testing.T) {
+	ctx := context.Background()
+	nm, _ := newTestNonceManager(t, NewMemoryNonceStore())
+	nm.gapGrace = 0
+
+	for i := 0; i < 3; i++ {
+		_, err := nm.Allocate(ctx, "base", testSigner)
+		require.NoError(t, err)
+	}
+	nm.Confirm("base", testSigner, 0)
+	nm.Confirm("base", testSigner, 2)
+	nm.Release("base", testSigner, 1)
+	assert.Equal(t, []uint64{1}, nm.Gaps("base", testSigner))
+
+	var filled []uint64
+	nm.FillGaps(ctx, func(ctx context.Context, chainID, signer string, nonce uint64) error {
+		filled = append(filled, nonce)
+		return nil
+	})
+	assert.Equal(t, []uint64{1}, filled)
+	assert.Empty(t, nm.Gaps("base", testSigner))
+
+	// Gaps a chain cannot fill stay available for new transactions
+	nm.Release("base", testSigner, 1)
+	nm.FillGaps(ctx, func(ctx context.Context, chainID, signer string, nonce uint64) error {
+		return blockchain.ErrNotSupported
+	})
+	assert.Equal(t, []uint64{1}, nm.Gaps("base", testSigner))
+}
+
+func TestNonceManager_ConcurrentAllocation(t *testing.T) {
+	ctx := context.Background()
+	nm, _ := newTestNonceManager(t, NewMemoryNonceStore())
+
+	var mu sync.Mutex
+	var nonces []uint64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := nm.Allocate(ctx, "base", testSigner)
+			assert.NoError(t, err)
+			mu.Lock()
+			nonces = append(nonces, nonce)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	for i, nonce := range nonces {
+		assert.Equal(t, uint64(i), nonce)
+	}
+}
+
+func TestTransactionManager_RetriesWhenNonceUsedElsewhere(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321"})
+	tm := newTestTransactionManager(t, adapter)
+	ctx := context.Background()
+	message := &Message{ChainID: "base", Type: MessageTypeLoanDisbursement, Payload: []byte{0x01}}
+
+	tx, err := tm.SubmitTransaction(ctx, "base", message)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), tx.Nonce)
+
+	adapter.SetNonce(tm.publicAddress.Hex(), 3)
+	tx, err = tm.SubmitTransaction(ctx, "base", message)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), tx.Nonce)
+}
+
+func TestTransactionManager_FillsNonceGap(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321"})
+	tm := newTestTransactionManager(t, adapter)
+	tm.nonces.gapGrace = 0
+	ctx := context.Background()
+	relayer := tm.publicAddress.Hex()
+
+	// Nonce 0 is allocated but never broadcast while nonce 1 is
+	lost, err := tm.nonces.Allocate(ctx, "base", relayer)
+	require.NoError(t, err)
+	tx, err := tm.SubmitTransaction(ctx, "base", &Message{ChainID: "base", Type: MessageTypeLoanDisbursement, Payload: []byte{0x01}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), tx.Nonce)
+	tm.nonces.Release("base", relayer, lost)
+
+	pending, _ := adapter.Nonce(ctx, relayer)
+	assert.Equal(t, uint64(0), pending)
+
+	tm.nonces.FillGaps(ctx, tm.fillNonceGap)
+	sent := adapter.Sent()
+	require.Len(t, sent, 2)
+	filler := sent[1].Native.(*blockchain.TxRequest)
+	assert.Equal(t, uint64(0), sent[1].Nonce)
+	assert.Equal(t, relayer, filler.To)
+	assert.Equal(t, uint64(21000), filler.GasLimit)
+
+	pending, _ = adapter.Nonce(ctx, relayer)
+	assert.Equal(t, uint64(2), pending)
+}
+
+func TestIsNonceUsedError(t *testing.T) {
+	assert.True(t, isNonceUsedError(errors.New("failed to submit transaction: nonce too low")))
+	assert.True(t, isNonceUsedError(errors.New("already known")))
+	assert.True(t, isNonceUsedError(errors.New("replacement transaction underpriced")))
+	assert.False(t, isNonceUsedError(errors.New("insufficient funds for gas * price + value")))
+	assert.False(t, isNonceUsedError(nil))
+}
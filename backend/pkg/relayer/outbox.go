@@ -0,0 +1,236 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMessageNotFound is returned when the outbox has no message with the given ID.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrLeaseLost is returned when saving a message whose lease has expired and been
+	// claimed by another relayer instance.
+	ErrLeaseLost = errors.New("message lease lost")
+)
+
+// Outbox is the durable store of relayer messages. Messages are claimed under a time-
+// limited lease so that several relayer instances never process the same message at
+// once. Delivery is at-least-once: a message whose lease expires mid-send is retried.
+type Outbox interface {
+	// Enqueue stores a new pending message. It returns false if a message with the same
+	// ID already exists.
+	Enqueue(ctx context.Context, message *Message) (bool, error)
+	Get(ctx context.Context, id string) (*Message, error)
+	// Claim leases up to limit messages that are due for delivery, including messages
+	// whose previous lease has expired.
+	Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Message, error)
+	// Save persists the message's delivery state. When owner is set the save only
+	// succeeds while owner still holds the lease, and the lease is released.
+	Save(ctx context.Context, message *Message, owner string) error
+	ListByStatus(ctx context.Context, status MessageStatus, limit int) ([]*Message, error)
+	// ReleaseExpired returns messages with expired leases to the pending state.
+	ReleaseExpired(ctx context.Context) (int, error)
+}
+
+// messageTransitions lists the allowed status transitions of an outbox message.
+var messageTransitions = map[MessageStatus][]MessageStatus{
+	StatusPending:    {StatusProcessing},
+	StatusRetrying:   {StatusProcessing},
+	StatusProcessing: {StatusSent, StatusRetrying, StatusFailed, StatusPending},
+	// Sent messages go back to pending when a reorg drops their transaction
+	StatusSent: {StatusConfirmed, StatusRetrying, StatusFailed, StatusPending},
+	// Failed messages only leave the dead-letter queue when an operator replays them
+	StatusFailed: {StatusPending},
+}
+
+// transition moves the message to a new status if the transition is allowed.
+func (m *Message) transition(to MessageStatus) error {
+	for _, allowed := range messageTransitions[m.Status] {
+		if allowed == to {
+			m.Status = to
+			m.UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid message status transition %s -> %s", m.Status, to)
+}
+
+// messageNamespace scopes relayer message IDs.
+var messageNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://kelo.finance/relayer/messages"))
+
+// NewMessageID returns a stable message ID derived from the message type and the key of
+// the source event, so a replayed event maps to the message already in the outbox.
+func NewMessageID(messageType MessageType, sourceKey string) string {
+	return uuid.NewSHA1(messageNamespace, []byte(messageType.String()+":"+sourceKey)).String()
+}
+
+// newPendingMessage creates a pending message ready to enqueue.
+func newPendingMessage(messageType MessageType, sourceKey, chainID string, payload []byte) *Message {
+	now := time.Now().UTC()
+	return &Message{
+		ID:             NewMessageID(messageType, sourceKey),
+		Type:           messageType,
+		ChainID:        chainID,
+		Payload:        payload,
+		PayloadVersion: CurrentPayloadVersion,
+		Timestamp:      now,
+		Status:         StatusPending,
+		NextAttemptAt:  now,
+		UpdatedAt:      now,
+	}
+}
+
+// MemoryOutbox is an in-process Outbox for tests and single-instance development.
+// Its contents do not survive a restart.
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages map[string]*Message
+	now      func() time.Time
+}
+
+// NewMemoryOutbox creates an empty in-memory outbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{
+		messages: make(map[string]*Message),
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (o *MemoryOutbox) Enqueue(ctx context.Context, message *Message) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := o.messages[message.ID]; exists {
+		return false, nil
+	}
+	o.messages[message.ID] = copyMessage(message)
+	return true, nil
+}
+
+func (o *MemoryOutbox) Get(ctx context.Context, id string) (*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	message, ok := o.messages[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+	}
+	return copyMessage(message), nil
+}
+
+func (o *MemoryOutbox) Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.now()
+	var due []*Message
+	for _, message := range o.messages {
+		switch message.Status {
+		case StatusPending, StatusRetrying:
+			if !message.NextAttemptAt.After(now) {
+				due = append(due, message)
+			}
+		case StatusProcessing:
+			if message.LeaseExpiresAt != nil && message.LeaseExpiresAt.Before(now) {
+				due = append(due, message)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	expires := now.Add(lease)
+	claimed := make([]*Message, 0, len(due))
+	for _, message := range due {
+		message.Status = StatusProcessing
+		message.LeaseOwner = owner
+		message.LeaseExpiresAt = &expires
+		message.UpdatedAt = now
+		claimed = append(claimed, copyMessage(message))
+	}
+	return claimed, nil
+}
+
+func (o *MemoryOutbox) Save(ctx context.Context, message *Message, owner string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stored, ok := o.messages[message.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMessageNotFound, message.ID)
+	}
+	if owner != "" && stored.LeaseOwner != owner {
+		return fmt.Errorf("%w: %s", ErrLeaseLost, message.ID)
+	}
+
+	saved := copyMessage(message)
+	if owner != "" {
+		saved.LeaseOwner = ""
+		saved.LeaseExpiresAt = nil
+	}
+	o.messages[message.ID] = saved
+	return nil
+}
+
+func (o *MemoryOutbox) ListByStatus(ctx context.Context, status MessageStatus, limit int) ([]*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var messages []*Message
+	for _, message := range o.messages {
+		if message.Status == status {
+			messages = append(messages, copyMessage(message))
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Timestamp.Before(messages[j].Timestamp) })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (o *MemoryOutbox) ReleaseExpired(ctx context.Context) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.now()
+	released := 0
+	for _, message := range o.messages {
+		if message.Status == StatusProcessing && message.LeaseExpiresAt != nil && message.LeaseExpiresAt.Before(now) {
+			message.Status = StatusPending
+			message.LeaseOwner = ""
+			message.LeaseExpiresAt = nil
+			message.UpdatedAt = now
+			released++
+		}
+	}
+	return released, nil
+}
+
+func copyMessage(message *Message) *Message {
+	c := *message
+	c.TxHashes = append([]string(nil), message.TxHashes...)
+	c.Attempts = append([]ErrorContext(nil), message.Attempts...)
+	c.Signature = append([]byte(nil), message.Signature...)
+	if message.LeaseExpiresAt != nil {
+		expires := *message.LeaseExpiresAt
+		c.LeaseExpiresAt = &expires
+	}
+	if message.SignatureExpiry != nil {
+		expiry := *message.SignatureExpiry
+		c.SignatureExpiry = &expiry
+	}
+	if message.TxNonce != nil {
+		nonce := *message.TxNonce
+		c.TxNonce = &nonce
+	}
+	return &c
+}
@@ -0,0 +1,312 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/supabase-community/supabase-go"
+)
+
+// outboxRow is a row of the relayer_messages table.
+type outboxRow struct {
+	ID               string            `json:"id"`
+	Type             int               `json:"type"`
+	ChainID          string            `json:"chain_id"`
+	Payload          []byte            `json:"payload"`
+	PayloadVersion   int               `json:"payload_version"`
+	Signature        []byte            `json:"signature,omitempty"`
+	SignerSetVersion *uint32           `json:"signer_set_version"`
+	SignatureExpiry  *time.Time        `json:"signature_expiry"`
+	Status           string            `json:"status"`
+	RetryCount       int               `json:"retry_count"`
+	TraceContext     map[string]string `json:"trace_context,omitempty"`
+	LoanID           *string           `json:"loan_id"`
+	TxHash           *string           `json:"tx_hash"`
+	TxChainID        *string           `json:"tx_chain_id"`
+	LayerZeroGUID    *string           `json:"layerzero_guid"`
+	TxHashes         []string          `json:"tx_hashes"`
+	BlockHash        *string           `json:"block_hash"`
+	BlockNumber      *uint64           `json:"block_number"`
+	TxExpiry         *uint64           `json:"tx_expiry"`
+	TxNonce          *uint64           `json:"tx_nonce"`
+	LastError        *string           `json:"last_error"`
+	Attempts         []ErrorContext    `json:"attempts"`
+	GasLimit         *uint64           `json:"gas_limit"`
+	NextAttemptAt    time.Time         `json:"next_attempt_at"`
+	LeaseOwner       *string           `json:"lease_owner"`
+	LeaseExpiresAt   *time.Time        `json:"lease_expires_at"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// SupabaseOutbox stores relayer messages in the relayer_messages table. Claims go
+// through the claim_relayer_messages function, which locks rows with SKIP LOCKED.
+type SupabaseOutbox struct {
+	db *supabase.Client
+}
+
+// NewSupabaseOutbox creates a new Supabase-backed outbox
+func NewSupabaseOutbox(db *supabase.Client) *SupabaseOutbox {
+	return &SupabaseOutbox{db: db}
+}
+
+func (o *SupabaseOutbox) Enqueue(ctx context.Context, message *Message) (bool, error) {
+	if _, err := o.Get(ctx, message.ID); err == nil {
+		return false, nil
+	}
+
+	row := toOutboxRow(message)
+	row.CreatedAt = message.Timestamp
+	_, _, err := o.db.From("relayer_messages").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue message: %w", err)
+	}
+	return true, nil
+}
+
+func (o *SupabaseOutbox) Get(ctx context.Context, id string) (*Message, error) {
+	data, _, err := o.db.From("relayer_messages").Select("*", "", false).Eq("id", id).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	messages, err := decodeOutboxRows(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+	}
+	return messages[0], nil
+}
+
+func (o *SupabaseOutbox) Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Message, error) {
+	// Note: The Rpc method in this library version returns only a string.
+	// Errors are handled by returning an empty string, which will then fail to unmarshal.
+	result := o.db.Rpc("claim_relayer_messages", "", map[string]interface{}{
+		"p_owner":         owner,
+		"p_limit":         limit,
+		"p_lease_seconds": int(lease.Seconds()),
+	})
+	messages, err := decodeOutboxRows([]byte(result))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (o *SupabaseOutbox) Save(ctx context.Context, message *Message, owner string) error {
+	row := toOutboxRow(message)
+	update := map[string]interface{}{
+		"status":             row.Status,
+		"chain_id":           row.ChainID,
+		"payload":            row.Payload,
+		"payload_version":    row.PayloadVersion,
+		"signature":          row.Signature,
+		"signer_set_version": row.SignerSetVersion,
+		"signature_expiry":   row.SignatureExpiry,
+		"gas_limit":          row.GasLimit,
+		"attempts":           row.Attempts,
+		"retry_count":        row.RetryCount,
+		"tx_hash":            row.TxHash,
+		"tx_chain_id":        row.TxChainID,
+		"layerzero_guid":     row.LayerZeroGUID,
+		"tx_hashes":          row.TxHashes,
+		"block_hash":         row.BlockHash,
+		"block_number":       row.BlockNumber,
+		"tx_expiry":          row.TxExpiry,
+		"tx_nonce":           row.TxNonce,
+		"last_error":         row.LastError,
+		"next_attempt_at":    row.NextAttemptAt,
+		"updated_at":         time.Now().UTC(),
+	}
+
+	if owner != "" {
+		update["lease_owner"] = nil
+		update["lease_expires_at"] = nil
+	}
+
+	query := o.db.From("relayer_messages").Update(update, "representation", "").Eq("id", message.ID)
+	if owner != "" {
+		query = query.Eq("lease_owner", owner)
+	}
+
+	data, _, err := query.Execute()
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	saved, err := decodeOutboxRows(data)
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		if owner != "" {
+			return fmt.Errorf("%w: %s", ErrLeaseLost, message.ID)
+		}
+		return fmt.Errorf("%w: %s", ErrMessageNotFound, message.ID)
+	}
+	return nil
+}
+
+func (o *SupabaseOutbox) ListByStatus(ctx context.Context, status MessageStatus, limit int) ([]*Message, error) {
+	data, _, err := o.db.From("relayer_messages").Select("*", "", false).
+		Eq("status", status.String()).
+		Limit(limit, "").
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	return decodeOutboxRows(data)
+}
+
+func (o *SupabaseOutbox) ReleaseExpired(ctx context.Context) (int, error) {
+	update := map[string]interface{}{
+		"status":           StatusPending.String(),
+		"lease_owner":      nil,
+		"lease_expires_at": nil,
+		"updated_at":       time.Now().UTC(),
+	}
+	data, _, err := o.db.From("relayer_messages").Update(update, "representation", "").
+		Eq("status", StatusProcessing.String()).
+		Lt("lease_expires_at", time.Now().UTC().Format(time.RFC3339)).
+		Execute()
+	if err != nil {
+		return 0, fmt.Errorf("failed to release expired leases: %w", err)
+	}
+	released, err := decodeOutboxRows(data)
+	if err != nil {
+		return 0, err
+	}
+	return len(released), nil
+}
+
+func toOutboxRow(message *Message) *outboxRow {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	row := &outboxRow{
+		ID:              message.ID,
+		Type:            int(message.Type),
+		ChainID:         message.ChainID,
+		Payload:         message.Payload,
+		PayloadVersion:  int(message.PayloadVersion),
+		Signature:       message.Signature,
+		SignatureExpiry: message.SignatureExpiry,
+		Status:          message.Status.String(),
+		RetryCount:      message.RetryCount,
+		TraceContext:    message.TraceContext,
+		LoanID:          optional(message.LoanID),
+		TxHash:          optional(message.TxHash),
+		TxChainID:       optional(message.TxChainID),
+		LayerZeroGUID:   optional(message.LayerZeroGUID),
+		TxHashes:        message.TxHashes,
+		BlockHash:       optional(message.BlockHash),
+		LastError:       optional(message.LastError),
+		Attempts:        message.Attempts,
+		NextAttemptAt:   message.NextAttemptAt,
+		LeaseOwner:      optional(message.LeaseOwner),
+		LeaseExpiresAt:  message.LeaseExpiresAt,
+		TxNonce:         message.TxNonce,
+		UpdatedAt:       message.UpdatedAt,
+	}
+	if message.GasLimit != 0 {
+		gasLimit := message.GasLimit
+		row.GasLimit = &gasLimit
+	}
+	if message.SignerSetVersion != 0 {
+		version := message.SignerSetVersion
+		row.SignerSetVersion = &version
+	}
+	if message.BlockNumber != 0 {
+		blockNumber := message.BlockNumber
+		row.BlockNumber = &blockNumber
+	}
+	if message.TxExpiry != 0 {
+		expiry := message.TxExpiry
+		row.TxExpiry = &expiry
+	}
+	return row
+}
+
+func decodeOutboxRows(data []byte) ([]*Message, error) {
+	var rows []outboxRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal messages: %w", err)
+	}
+
+	messages := make([]*Message, 0, len(rows))
+	for _, row := range rows {
+		status, err := parseMessageStatus(row.Status)
+		if err != nil {
+			return nil, err
+		}
+		message := &Message{
+			ID:              row.ID,
+			Type:            MessageType(row.Type),
+			ChainID:         row.ChainID,
+			Payload:         row.Payload,
+			PayloadVersion:  PayloadVersion(row.PayloadVersion),
+			Signature:       row.Signature,
+			SignatureExpiry: row.SignatureExpiry,
+			Timestamp:       row.CreatedAt,
+			RetryCount:      row.RetryCount,
+			TraceContext:    row.TraceContext,
+			Status:          status,
+			NextAttemptAt:   row.NextAttemptAt,
+			TxHashes:        row.TxHashes,
+			Attempts:        row.Attempts,
+			LeaseExpiresAt:  row.LeaseExpiresAt,
+			TxNonce:         row.TxNonce,
+			UpdatedAt:       row.UpdatedAt,
+		}
+		if row.LoanID != nil {
+			message.LoanID = *row.LoanID
+		}
+		if row.TxHash != nil {
+			message.TxHash = *row.TxHash
+		}
+		if row.TxChainID != nil {
+			message.TxChainID = *row.TxChainID
+		}
+		if row.LayerZeroGUID != nil {
+			message.LayerZeroGUID = *row.LayerZeroGUID
+		}
+		if row.BlockHash != nil {
+			message.BlockHash = *row.BlockHash
+		}
+		if row.BlockNumber != nil {
+			message.BlockNumber = *row.BlockNumber
+		}
+		if row.TxExpiry != nil {
+			message.TxExpiry = *row.TxExpiry
+		}
+		if row.LastError != nil {
+			message.LastError = *row.LastError
+		}
+		if row.LeaseOwner != nil {
+			message.LeaseOwner = *row.LeaseOwner
+		}
+		if row.GasLimit != nil {
+			message.GasLimit = *row.GasLimit
+		}
+		if row.SignerSetVersion != nil {
+			message.SignerSetVersion = *row.SignerSetVersion
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// parseMessageStatus parses the string form of a MessageStatus.
+func parseMessageStatus(s string) (MessageStatus, error) {
+	for status := StatusPending; status <= StatusProcessing; status++ {
+		if status.String() == s {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown message status: %s", s)
+}
@@ -0,0 +1,428 @@
+package relayer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageID_Stable(t *testing.T) {
+	assert.Equal(t, NewMessageID(MessageTypeLoanDisbursement, "42"), NewMessageID(MessageTypeLoanDisbursement, "42"))
+	assert.NotEqual(t, NewMessageID(MessageTypeLoanDisbursement, "42"), NewMessageID(MessageTypeLoanDisbursement, "43"))
+	assert.NotEqual(t, NewMessageID(MessageTypeLoanDisbursement, "42"), NewMessageID(MessageTypeRepaymentConfirmation, "42"))
+}
+
+func TestMessage_Transition(t *testing.T) {
+	message := newPendingMessage(MessageTypeLoanDisbursement, "1", "base", nil)
+
+	assert.Error(t, message.transition(StatusConfirmed))
+	require.NoError(t, message.transition(StatusProcessing))
+	require.NoError(t, message.transition(StatusSent))
+	require.NoError(t, message.transition(StatusConfirmed))
+	assert.Error(t, message.transition(StatusProcessing))
+}
+
+func TestMemoryOutbox_Leases(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewMemoryOutbox()
+	now := time.Now().UTC()
+	outbox.now = func() time.Time { return now }
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "1", "base", []byte{1})
+	message.NextAttemptAt = now
+	created, err := outbox.Enqueue(ctx, message)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = outbox.Enqueue(ctx, message)
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	claimed, err := outbox.Claim(ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, StatusProcessing, claimed[0].Status)
+
+	// A second instance cannot claim a leased message
+	claimed, err = outbox.Claim(ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// Once the lease expires another instance takes it over and the first loses it
+	now = now.Add(2 * time.Minute)
+	claimedByB, err := outbox.Claim(ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimedByB, 1)
+
+	stale := claimedByB[0]
+	require.NoError(t, stale.transition(StatusSent))
+	assert.True(t, errors.Is(outbox.Save(ctx, stale, "a"), ErrLeaseLost))
+	require.NoError(t, outbox.Save(ctx, stale, "b"))
+
+	saved, err := outbox.Get(ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, saved.Status)
+	assert.Empty(t, saved.LeaseOwner)
+
+	_, err = outbox.Get(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+}
+
+func TestMemoryOutbox_ReleaseExpired(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewMemoryOutbox()
+	now := time.Now().UTC()
+	outbox.now = func() time.Time { return now }
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "1", "base", nil)
+	message.NextAttemptAt = now
+	_, err := outbox.Enqueue(ctx, message)
+	require.NoError(t, err)
+	_, err = outbox.Claim(ctx, "crashed", 10, time.Minute)
+	require.NoError(t, err)
+
+	released, err := outbox.ReleaseExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	now = now.Add(2 * time.Minute)
+	released, err = outbox.ReleaseExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, released)
+
+	saved, err := outbox.Get(ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, saved.Status)
+}
+
+func TestSupabaseOutbox_RowRoundTrip(t *testing.T) {
+	token := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	merchant := common.HexToAddress("0x0987654321098765432109876543210987654321")
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{TokenID: big.NewInt(1), Merchant: merchant, Amount: big.NewInt(2500000)}, token)
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "1", "solana", payload)
+	message.TxHash = "0xabc"
+	message.GasLimit = 300000
+	message.TxExpiry = 1700000060
+	nonce := uint64(0)
+	message.TxNonce = &nonce
+
+	// The row as Supabase stores and returns it
+	messages, err := decodeOutboxRows(mustMarshal(t, []*outboxRow{toOutboxRow(message)}))
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	loaded := messages[0]
+	assert.Equal(t, PayloadV1, loaded.PayloadVersion)
+	assert.Equal(t, message.TxHash, loaded.TxHash)
+	assert.Equal(t, message.GasLimit, loaded.GasLimit)
+	assert.Equal(t, message.TxExpiry, loaded.TxExpiry)
+	assert.Equal(t, message.TxNonce, loaded.TxNonce)
+
+	decoded, err := DecodePayload(loaded.Type, loaded.PayloadVersion, loaded.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, &LoanDisbursementPayload{Token: token, Merchant: merchant, Amount: big.NewInt(2500000)}, decoded)
+}
+
+func TestTrustedRelayer_HandleLoanApproval_ReplayedEvent(t *testing.T) {
+	relayer := newTestRelayer(t)
+	store := relayer.poolStore.(*fakePoolStore)
+
+	event := &LoanApprovalEvent{
+		TokenID:  big.NewInt(9),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(1000),
+	}
+
+	require.NoError(t, relayer.handleLoanApproval(event))
+	require.NoError(t, relayer.handleLoanApproval(event))
+
+	assert.Len(t, pendingMessages(t, relayer), 1)
+	assert.Len(t, store.recorded, 1)
+}
+
+func TestTrustedRelayer_OutboxDelivery(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 3
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["ethereum"].LayerZeroReceiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101, Confirmations: 2})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	relayer.layerZeroClient = lzClient
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "10", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, sent.Status)
+	assert.Equal(t, "ethereum", sent.TxChainID)
+	assert.NotEmpty(t, sent.TxHash)
+	assert.NotEmpty(t, sent.LayerZeroGUID)
+
+	// Not final until the source chain has two confirmations
+	relayer.reconcileSent()
+	sent, _ = relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusSent, sent.Status)
+
+	source.Mine(1)
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, uint64(1), relayer.metrics.MessagesConfirmed)
+}
+
+// fakeRecipients resolves every merchant to one wallet per chain
+type fakeRecipients map[string]string
+
+func (f fakeRecipients) ResolveRecipient(ctx context.Context, chainID string, merchant common.Address) (string, error) {
+	wallet, ok := f[chainID]
+	if !ok {
+		return "", errors.New("no wallet")
+	}
+	return wallet, nil
+}
+
+// newSolanaTestRelayer creates a test relayer that disburses on a fake Solana chain
+func newSolanaTestRelayer(t *testing.T) (*TrustedRelayer, *blockchain.FakeAdapter) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 3
+	relayer.config.SolanaTokenMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	relayer.chainConfigs["solana"] = &ChainConfig{ChainID: "solana", Type: config.ChainTypeSolana, ProgramID: "KeLo111111111111111111111111111111111111111", Enabled: true}
+	relayer.recipients = fakeRecipients{"solana": "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N"}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	relayer.solanaKey = key
+
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "solana", Type: config.ChainTypeSolana})
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(adapter))
+	return relayer, adapter
+}
+
+func TestTrustedRelayer_SolanaDisbursementConfirmedByReconciliation(t *testing.T) {
+	relayer, adapter := newSolanaTestRelayer(t)
+	adapter.Hold = true
+
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{
+		TokenID:  big.NewInt(14),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(2500000),
+	}, common.Address{})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "14", "solana", payload)
+	require.NoError(t, relayer.enqueue(message))
+
+	// The processor does not wait for the transaction to land
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status)
+	assert.Equal(t, "solana", sent.TxChainID)
+	require.Len(t, adapter.Sent(), 1)
+	assert.Equal(t, adapter.Sent()[0].Hash, sent.TxHash)
+
+	// Still in flight, so it is neither resent nor failed
+	relayer.reconcileSent()
+	relayer.processOutbox()
+	sent, _ = relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusSent, sent.Status)
+	assert.Len(t, adapter.Sent(), 1)
+
+	adapter.Mine(1)
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, sent.TxHash, confirmed.TxHash)
+}
+
+func TestTrustedRelayer_ExpiredSolanaDisbursementResubmitted(t *testing.T) {
+	relayer, adapter := newSolanaTestRelayer(t)
+	adapter.Hold = true
+	adapter.ExpiresAfter = 2
+
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{
+		TokenID:  big.NewInt(15),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(2500000),
+	}, common.Address{})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "15", "solana", payload)
+	require.NoError(t, relayer.enqueue(message))
+
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status)
+	assert.Equal(t, uint64(2), sent.TxExpiry)
+
+	// Dropped, but it could still be included until the chain passes its expiry
+	adapter.DropHeld()
+	adapter.Mine(2)
+	relayer.reconcileSent()
+	sent, _ = relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusSent, sent.Status)
+
+	adapter.Mine(1)
+	relayer.reconcileSent()
+	pending, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusPending, pending.Status)
+	assert.Empty(t, pending.TxHash)
+	assert.Zero(t, pending.TxExpiry)
+
+	relayer.processOutbox()
+	adapter.Mine(1)
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Len(t, adapter.Sent(), 2)
+}
+
+func TestTrustedRelayer_ReorgResubmitsMessage(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["ethereum"].LayerZeroReceiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101, Confirmations: 3})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	relayer.layerZeroClient = lzClient
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "13", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	// The block of the mined transaction is recorded
+	relayer.reconcileSent()
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status)
+	require.NotEmpty(t, sent.BlockHash)
+
+	// A reorg that re-includes the transaction only moves it
+	source.Reorg(1, true)
+	relayer.reconcileSent()
+	moved, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusSent, moved.Status)
+	assert.NotEqual(t, sent.BlockHash, moved.BlockHash)
+
+	// One that drops it sends the message again
+	source.Reorg(1, false)
+	relayer.reconcileSent()
+	pending, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusPending, pending.Status)
+	assert.Empty(t, pending.TxHash)
+	assert.Empty(t, pending.BlockHash)
+
+	var outcomes []interface{}
+	for _, event := range relayer.monitor.GetRecentEvents(100) {
+		if event.Type == EventTypeChainReorg {
+			assert.Equal(t, "ethereum", event.ChainID)
+			outcomes = append(outcomes, event.Metadata["outcome"])
+		}
+	}
+	assert.ElementsMatch(t, []interface{}{"moved", "dropped"}, outcomes)
+
+	relayer.processOutbox()
+	resent, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.Equal(t, StatusSent, resent.Status)
+	assert.Len(t, source.Sent(), 2)
+
+	source.Mine(2)
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, resent.TxHash, confirmed.TxHash)
+}
+
+func TestTrustedRelayer_OutboxRetry(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.MaxRetries = 2
+
+	// No LayerZero source chain is configured, so every send fails
+	message := newPendingMessage(MessageTypeLoanDisbursement, "11", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+
+	relayer.processOutbox()
+	retrying, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRetrying, retrying.Status)
+	assert.Equal(t, 1, retrying.RetryCount)
+	assert.NotEmpty(t, retrying.LastError)
+	assert.True(t, retrying.NextAttemptAt.After(time.Now()))
+
+	// Not due yet
+	relayer.processOutbox()
+	retrying, _ = relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, 1, retrying.RetryCount)
+
+	retrying.NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, relayer.outbox.Save(relayer.ctx, retrying, ""))
+	relayer.processOutbox()
+	failed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusFailed, failed.Status)
+}
+
+func TestTrustedRelayer_ReplacedTransactionConfirmsMessage(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["ethereum"].LayerZeroReceiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	source.Hold = true
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+
+	tm := NewTransactionManager(relayer.chains, relayer.signer, NewNonceManager(relayer.chains, NewMemoryNonceStore()), relayer.ctx)
+	tm.stuckAfter = 0
+	tm.OnReplaced = relayer.recordReplacement
+	relayer.transactionManager = tm
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	lzClient.SetSubmitter(tm)
+	relayer.layerZeroClient = lzClient
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "12", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	sent, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusSent, sent.Status)
+	firstHash := sent.TxHash
+
+	tm.replaceStuckTransactions()
+	replaced, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.Len(t, replaced.TxHashes, 2)
+	assert.Equal(t, firstHash, replaced.TxHashes[0])
+	assert.Equal(t, replaced.TxHashes[1], replaced.TxHash)
+
+	source.Mine(1)
+	relayer.reconcileSent()
+	confirmed, _ := relayer.outbox.Get(relayer.ctx, message.ID)
+	assert.Equal(t, StatusConfirmed, confirmed.Status)
+	assert.Equal(t, replaced.TxHashes[1], confirmed.TxHash)
+}
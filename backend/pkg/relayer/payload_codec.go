@@ -0,0 +1,176 @@
+package relayer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrUnsupportedPayload is returned when no codec exists for a message type and version.
+var ErrUnsupportedPayload = errors.New("unsupported payload")
+
+// PayloadVersion identifies the ABI layout of a message payload. The version is kept
+// alongside the message rather than inside the payload, so the payload bytes are exactly
+// what the receiving contract passes to abi.decode.
+type PayloadVersion uint8
+
+const (
+	PayloadV1 PayloadVersion = 1
+
+	// CurrentPayloadVersion is the version used for new messages.
+	CurrentPayloadVersion = PayloadV1
+)
+
+// LoanDisbursementPayload is decoded by LayerZeroEVMReceiver.lzReceive and passed
+// straight to KeloLiquidityPool.disburse. Amount is in the token's smallest unit.
+type LoanDisbursementPayload struct {
+	Token    common.Address `json:"token"`
+	Merchant common.Address `json:"merchant"`
+	Amount   *big.Int       `json:"amount"`
+}
+
+// RepaymentConfirmationPayload tells the funding pool that a loan repayment has settled.
+type RepaymentConfirmationPayload struct {
+	LoanID      *big.Int       `json:"loan_id"`
+	Payer       common.Address `json:"payer"`
+	Amount      *big.Int       `json:"amount"`
+	TotalRepaid *big.Int       `json:"total_repaid"`
+	Timestamp   uint64         `json:"timestamp"`
+}
+
+// LiquidityTransferPayload moves liquidity between two pools.
+type LiquidityTransferPayload struct {
+	Token    common.Address `json:"token"`
+	FromPool common.Address `json:"from_pool"`
+	ToPool   common.Address `json:"to_pool"`
+	Amount   *big.Int       `json:"amount"`
+}
+
+// CreditScoreUpdatePayload publishes a borrower's latest credit score.
+type CreditScoreUpdatePayload struct {
+	Borrower  common.Address `json:"borrower"`
+	Score     uint16         `json:"score"`
+	UpdatedAt uint64         `json:"updated_at"`
+}
+
+// payloadCodec encodes one version of a message payload. Argument names map to the
+// payload struct fields in camel case, e.g. "loanID" to LoanID.
+type payloadCodec struct {
+	args       abi.Arguments
+	newPayload func() interface{}
+}
+
+type payloadKey struct {
+	messageType MessageType
+	version     PayloadVersion
+}
+
+var payloadCodecs = map[payloadKey]*payloadCodec{
+	{MessageTypeLoanDisbursement, PayloadV1}: newPayloadCodec(
+		func() interface{} { return &LoanDisbursementPayload{} },
+		"address token", "address merchant", "uint256 amount",
+	),
+	{MessageTypeRepaymentConfirmation, PayloadV1}: newPayloadCodec(
+		func() interface{} { return &RepaymentConfirmationPayload{} },
+		"uint256 loanID", "address payer", "uint256 amount", "uint256 totalRepaid", "uint64 timestamp",
+	),
+	{MessageTypeLiquidityTransfer, PayloadV1}: newPayloadCodec(
+		func() interface{} { return &LiquidityTransferPayload{} },
+		"address token", "address fromPool", "address toPool", "uint256 amount",
+	),
+	{MessageTypeCreditScoreUpdate, PayloadV1}: newPayloadCodec(
+		func() interface{} { return &CreditScoreUpdatePayload{} },
+		"address borrower", "uint16 score", "uint64 updatedAt",
+	),
+}
+
+// newPayloadCodec builds a codec from Solidity parameters such as "address token".
+func newPayloadCodec(newPayload func() interface{}, params ...string) *payloadCodec {
+	args := make(abi.Arguments, 0, len(params))
+	for _, param := range params {
+		fields := strings.Fields(param)
+		typ, err := abi.NewType(fields[0], "", nil)
+		if err != nil {
+			panic(fmt.Sprintf("invalid payload type %q: %v", param, err))
+		}
+		args = append(args, abi.Argument{Name: fields[1], Type: typ})
+	}
+	return &payloadCodec{args: args, newPayload: newPayload}
+}
+
+func lookupPayloadCodec(messageType MessageType, version PayloadVersion) (*payloadCodec, error) {
+	codec, ok := payloadCodecs[payloadKey{messageType, version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnsupportedPayload, messageType, version)
+	}
+	return codec, nil
+}
+
+// EncodePayload ABI-encodes a payload struct with the current version of its codec.
+func EncodePayload(messageType MessageType, payload interface{}) ([]byte, error) {
+	codec, err := lookupPayloadCodec(messageType, CurrentPayloadVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	value := reflect.ValueOf(payload)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Type() != reflect.TypeOf(codec.newPayload()).Elem() {
+		return nil, fmt.Errorf("%w: %T is not a %s payload", ErrUnsupportedPayload, payload, messageType)
+	}
+
+	values := make([]interface{}, 0, len(codec.args))
+	for _, arg := range codec.args {
+		field := value.FieldByName(abi.ToCamelCase(arg.Name))
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			return nil, fmt.Errorf("%s payload is missing %s", messageType, arg.Name)
+		}
+		values = append(values, field.Interface())
+	}
+
+	data, err := codec.args.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", messageType, err)
+	}
+	return data, nil
+}
+
+// DecodePayload decodes a payload into a pointer to the message type's payload struct.
+func DecodePayload(messageType MessageType, version PayloadVersion, data []byte) (interface{}, error) {
+	codec, err := lookupPayloadCodec(messageType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := codec.args.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload: %w", messageType, err)
+	}
+	payload := codec.newPayload()
+	if err := codec.args.Copy(payload, values); err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload: %w", messageType, err)
+	}
+	return payload, nil
+}
+
+// PayloadSignature returns the Solidity tuple a receiver passes to abi.decode for the
+// payload, e.g. "(address,address,uint256)".
+func PayloadSignature(messageType MessageType, version PayloadVersion) (string, error) {
+	codec, err := lookupPayloadCodec(messageType, version)
+	if err != nil {
+		return "", err
+	}
+
+	types := make([]string, 0, len(codec.args))
+	for _, arg := range codec.args {
+		types = append(types, arg.Type.String())
+	}
+	return "(" + strings.Join(types, ",") + ")", nil
+}
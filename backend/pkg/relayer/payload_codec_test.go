@@ -0,0 +1,200 @@
+package relayer
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiverDecodeTuples reads the tuples LayerZeroEVMReceiver passes to abi.decode, in
+// source order: the disbursement first, then the repayment.
+func receiverDecodeTuples(t *testing.T) []string {
+	source, err := os.ReadFile("../../../contracts/layerzero/LayerZeroEVMReceiver.sol")
+	require.NoError(t, err)
+
+	matches := regexp.MustCompile(`abi\.decode\(\s*_payload,\s*\(([^)]*)\)`).FindAllSubmatch(source, -1)
+	require.NotEmpty(t, matches, "abi.decode not found in receiver")
+
+	var tuples []string
+	for _, match := range matches {
+		tuples = append(tuples, "("+strings.Join(strings.Fields(string(match[1])), "")+")")
+	}
+	return tuples
+}
+
+// receiverDecodeTuple reads the disbursement tuple LayerZeroEVMReceiver passes to abi.decode.
+func receiverDecodeTuple(t *testing.T) string {
+	return receiverDecodeTuples(t)[0]
+}
+
+// receiverArgs builds unnamed ABI arguments from a Solidity tuple such as "(address,uint256)".
+func receiverArgs(t *testing.T, tuple string) abi.Arguments {
+	var args abi.Arguments
+	for _, name := range strings.Split(strings.Trim(tuple, "()"), ",") {
+		typ, err := abi.NewType(name, "", nil)
+		require.NoError(t, err)
+		args = append(args, abi.Argument{Type: typ})
+	}
+	return args
+}
+
+func TestLoanDisbursementPayload_DecodesWithReceiverABI(t *testing.T) {
+	tuple := receiverDecodeTuple(t)
+	signature, err := PayloadSignature(MessageTypeLoanDisbursement, PayloadV1)
+	require.NoError(t, err)
+	assert.Equal(t, tuple, signature)
+
+	token := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	merchant := common.HexToAddress("0x0987654321098765432109876543210987654321")
+	event := &LoanApprovalEvent{TokenID: big.NewInt(1), Merchant: merchant, Amount: big.NewInt(2500000)}
+
+	data, err := NewMessageFactory().CreateLoanDisbursementPayload(event, token)
+	require.NoError(t, err)
+
+	// abi.decode(_payload, (address, address, uint256)) on the receiver side
+	values, err := receiverArgs(t, tuple).Unpack(data)
+	require.NoError(t, err)
+	require.Len(t, values, 3)
+	assert.Equal(t, token, values[0])
+	assert.Equal(t, merchant, values[1])
+	assert.Equal(t, big.NewInt(2500000), values[2])
+
+	// Three static words with no version prefix
+	expected := append(common.LeftPadBytes(token.Bytes(), 32), common.LeftPadBytes(merchant.Bytes(), 32)...)
+	expected = append(expected, common.LeftPadBytes(big.NewInt(2500000).Bytes(), 32)...)
+	assert.Equal(t, expected, data)
+
+	decoded, err := DecodePayload(MessageTypeLoanDisbursement, PayloadV1, data)
+	require.NoError(t, err)
+	assert.Equal(t, &LoanDisbursementPayload{Token: token, Merchant: merchant, Amount: big.NewInt(2500000)}, decoded)
+}
+
+func TestRepaymentConfirmationPayload_DecodesWithReceiverABI(t *testing.T) {
+	tuples := receiverDecodeTuples(t)
+	require.Len(t, tuples, 2)
+	signature, err := PayloadSignature(MessageTypeRepaymentConfirmation, PayloadV1)
+	require.NoError(t, err)
+	assert.Equal(t, tuples[1], signature)
+
+	data, err := NewMessageFactory().CreateRepaymentConfirmationPayload(&RepaymentEvent{
+		TokenID:     big.NewInt(7),
+		Amount:      big.NewInt(400),
+		TotalRepaid: big.NewInt(1000),
+		Payer:       common.HexToAddress("0x1234567890123456789012345678901234567890"),
+		Timestamp:   time.Unix(1700000000, 0),
+	})
+	require.NoError(t, err)
+
+	// Five static words, with no version prefix
+	assert.Len(t, data, 5*32)
+	values, err := receiverArgs(t, tuples[1]).Unpack(data)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(7), values[0])
+	assert.Equal(t, big.NewInt(1000), values[3])
+}
+
+func TestPayloadCodecs_RoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		messageType MessageType
+		tuple       string
+		payload     interface{}
+		values      []interface{}
+	}{
+		{
+			messageType: MessageTypeRepaymentConfirmation,
+			tuple:       "(uint256,address,uint256,uint256,uint64)",
+			payload: &RepaymentConfirmationPayload{
+				LoanID:      big.NewInt(7),
+				Payer:       common.HexToAddress("0x1234567890123456789012345678901234567890"),
+				Amount:      big.NewInt(500),
+				TotalRepaid: big.NewInt(1500),
+				Timestamp:   uint64(timestamp.Unix()),
+			},
+			values: []interface{}{
+				big.NewInt(7),
+				common.HexToAddress("0x1234567890123456789012345678901234567890"),
+				big.NewInt(500),
+				big.NewInt(1500),
+				uint64(timestamp.Unix()),
+			},
+		},
+		{
+			messageType: MessageTypeLiquidityTransfer,
+			tuple:       "(address,address,address,uint256)",
+			payload: &LiquidityTransferPayload{
+				Token:    common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"),
+				FromPool: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+				ToPool:   common.HexToAddress("0x2222222222222222222222222222222222222222"),
+				Amount:   big.NewInt(1000000),
+			},
+			values: []interface{}{
+				common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"),
+				common.HexToAddress("0x1111111111111111111111111111111111111111"),
+				common.HexToAddress("0x2222222222222222222222222222222222222222"),
+				big.NewInt(1000000),
+			},
+		},
+		{
+			messageType: MessageTypeCreditScoreUpdate,
+			tuple:       "(address,uint16,uint64)",
+			payload: &CreditScoreUpdatePayload{
+				Borrower:  common.HexToAddress("0x1234567890123456789012345678901234567890"),
+				Score:     720,
+				UpdatedAt: uint64(timestamp.Unix()),
+			},
+			values: []interface{}{
+				common.HexToAddress("0x1234567890123456789012345678901234567890"),
+				uint16(720),
+				uint64(timestamp.Unix()),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.messageType.String(), func(t *testing.T) {
+			signature, err := PayloadSignature(tt.messageType, CurrentPayloadVersion)
+			require.NoError(t, err)
+			assert.Equal(t, tt.tuple, signature)
+
+			data, err := EncodePayload(tt.messageType, tt.payload)
+			require.NoError(t, err)
+
+			// What a receiver decoding the tuple sees
+			values, err := receiverArgs(t, tt.tuple).Unpack(data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.values, values)
+
+			decoded, err := DecodePayload(tt.messageType, CurrentPayloadVersion, data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.payload, decoded)
+		})
+	}
+}
+
+func TestPayloadCodecs_Errors(t *testing.T) {
+	_, err := EncodePayload(MessageTypeLoanApproval, &LoanDisbursementPayload{})
+	assert.True(t, errors.Is(err, ErrUnsupportedPayload))
+
+	_, err = EncodePayload(MessageTypeLoanDisbursement, &CreditScoreUpdatePayload{})
+	assert.True(t, errors.Is(err, ErrUnsupportedPayload))
+
+	_, err = EncodePayload(MessageTypeLoanDisbursement, &LoanDisbursementPayload{})
+	assert.Error(t, err)
+
+	_, err = DecodePayload(MessageTypeLoanDisbursement, PayloadVersion(9), nil)
+	assert.True(t, errors.Is(err, ErrUnsupportedPayload))
+
+	_, err = DecodePayload(MessageTypeLoanDisbursement, PayloadV1, []byte{0x01})
+	assert.Error(t, err)
+}
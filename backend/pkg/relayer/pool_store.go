@@ -0,0 +1,119 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"kelo-backend/pkg/models"
+
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabasePoolStore reads pool state from the liquidity_pools table and records
+// allocations on the loans table.
+type SupabasePoolStore struct {
+	db *supabase.Client
+}
+
+// NewSupabasePoolStore creates a new Supabase-backed pool store
+func NewSupabasePoolStore(db *supabase.Client) *SupabasePoolStore {
+	return &SupabasePoolStore{db: db}
+}
+
+// PoolStates returns every pool that is deployed on a chain.
+func (s *SupabasePoolStore) PoolStates(ctx context.Context) ([]*PoolState, error) {
+	var pools []models.LiquidityPool
+	data, _, err := s.db.From("liquidity_pools").Select("*", "exact", false).Not("chain_id", "is", "null").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liquidity pools: %w", err)
+	}
+	if err := json.Unmarshal(data, &pools); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal liquidity pools: %w", err)
+	}
+
+	states := make([]*PoolState, 0, len(pools))
+	for _, pool := range pools {
+		states = append(states, &PoolState{
+			PoolID:         pool.ID,
+			ChainID:        pool.ChainID,
+			Asset:          pool.Asset,
+			TotalLiquidity: pool.TotalStaked,
+			Borrowed:       pool.TotalBorrowed,
+			UtilizationCap: pool.UtilizationCap,
+			Priority:       pool.Priority,
+		})
+	}
+	return states, nil
+}
+
+// RecordAllocation records the funding pool on the loan and reserves the amount in the
+// pool in one transaction, through the record_loan_allocation function. A loan that
+// already has a pool keeps it and nothing is reserved again; the allocation is updated
+// to the recorded pool and chain.
+func (s *SupabasePoolStore) RecordAllocation(ctx context.Context, allocation *Allocation) error {
+	// Note: The Rpc method in this library version returns only a string.
+	// Errors come back as an error object, which then fails to unmarshal.
+	result := s.db.Rpc("record_loan_allocation", "", map[string]interface{}{
+		"p_loan_id":      allocation.LoanID,
+		"p_pool_id":      allocation.PoolID,
+		"p_chain_id":     allocation.ChainID,
+		"p_amount":       allocation.Amount,
+		"p_allocated_at": allocation.AllocatedAt.UTC().Format(time.RFC3339),
+	})
+
+	var rows []struct {
+		PoolID  string `json:"recorded_pool_id"`
+		ChainID string `json:"recorded_chain_id"`
+	}
+	if err := json.Unmarshal([]byte(result), &rows); err != nil {
+		return allocationError("record", result)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("failed to record loan allocation: no result for loan %s", allocation.LoanID)
+	}
+
+	allocation.PoolID = rows[0].PoolID
+	allocation.ChainID = rows[0].ChainID
+	return nil
+}
+
+// MoveAllocation moves a loan's reservation from its recorded pool to the allocation's
+// pool in one transaction, through the move_loan_allocation function. A loan already
+// funded from the allocation's chain keeps its pool, which is written back to the
+// allocation.
+func (s *SupabasePoolStore) MoveAllocation(ctx context.Context, allocation *Allocation) error {
+	result := s.db.Rpc("move_loan_allocation", "", map[string]interface{}{
+		"p_loan_id":      allocation.LoanID,
+		"p_pool_id":      allocation.PoolID,
+		"p_chain_id":     allocation.ChainID,
+		"p_amount":       allocation.Amount,
+		"p_allocated_at": allocation.AllocatedAt.UTC().Format(time.RFC3339),
+	})
+
+	var rows []struct {
+		PoolID  string `json:"recorded_pool_id"`
+		ChainID string `json:"recorded_chain_id"`
+	}
+	if err := json.Unmarshal([]byte(result), &rows); err != nil {
+		return allocationError("move", result)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("failed to move loan allocation: no result for loan %s", allocation.LoanID)
+	}
+
+	allocation.PoolID = rows[0].PoolID
+	allocation.ChainID = rows[0].ChainID
+	return nil
+}
+
+// allocationError turns the error object returned by an allocation function into an
+// error, recognizing a pool that no longer has room for the loan
+func allocationError(action, result string) error {
+	if strings.Contains(result, "insufficient liquidity") {
+		return fmt.Errorf("failed to %s loan allocation: %w: %s", action, ErrPoolOvercommitted, result)
+	}
+	return fmt.Errorf("failed to %s loan allocation: %s", action, result)
+}
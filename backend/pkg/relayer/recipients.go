@@ -0,0 +1,61 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/supabase-community/supabase-go"
+)
+
+// RecipientResolver maps a merchant's EVM address, as carried in loan events, to the
+// merchant's wallet on a non-EVM chain.
+type RecipientResolver interface {
+	ResolveRecipient(ctx context.Context, chainID string, merchant common.Address) (string, error)
+}
+
+// SupabaseRecipientResolver looks merchants up by profile wallet address and returns
+// their wallet from the merchant_wallets table.
+type SupabaseRecipientResolver struct {
+	db *supabase.Client
+}
+
+// NewSupabaseRecipientResolver creates a new Supabase-backed recipient resolver
+func NewSupabaseRecipientResolver(db *supabase.Client) *SupabaseRecipientResolver {
+	return &SupabaseRecipientResolver{db: db}
+}
+
+// ResolveRecipient returns the merchant's wallet address on the given chain.
+func (r *SupabaseRecipientResolver) ResolveRecipient(ctx context.Context, chainID string, merchant common.Address) (string, error) {
+	var profiles []struct {
+		ID string `json:"id"`
+	}
+	data, _, err := r.db.From("profiles").Select("id", "exact", false).Ilike("wallet_address", strings.ToLower(merchant.Hex())).Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to get merchant profile: %w", err)
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return "", fmt.Errorf("failed to unmarshal merchant profile: %w", err)
+	}
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("no merchant profile for wallet %s", merchant.Hex())
+	}
+
+	var wallets []struct {
+		Address string `json:"address"`
+	}
+	data, _, err = r.db.From("merchant_wallets").Select("address", "exact", false).Eq("merchant_id", profiles[0].ID).Eq("chain_id", chainID).Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to get merchant wallet: %w", err)
+	}
+	if err := json.Unmarshal(data, &wallets); err != nil {
+		return "", fmt.Errorf("failed to unmarshal merchant wallet: %w", err)
+	}
+	if len(wallets) == 0 {
+		return "", fmt.Errorf("merchant %s has no %s wallet", profiles[0].ID, chainID)
+	}
+
+	return wallets[0].Address, nil
+}
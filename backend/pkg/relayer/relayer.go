@@ -2,18 +2,25 @@ package relayer
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"math/big"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"kelo-backend/pkg/blockchain"
 	"kelo-backend/pkg/config"
+	"kelo-backend/pkg/tracing"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/supabase-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MessageType represents the type of cross-chain message
@@ -40,13 +47,36 @@ func (mt MessageType) String() string {
 
 // Message represents a cross-chain message
 type Message struct {
+	ID          string          `json:"id"`
 	Type        MessageType      `json:"type"`
 	ChainID     string          `json:"chain_id"`
 	Payload     []byte          `json:"payload"`
+	PayloadVersion PayloadVersion `json:"payload_version"`
 	Signature   []byte          `json:"signature"`
+	SignerSetVersion uint32     `json:"signer_set_version,omitempty"` // signer set of the relayer key that signed it
+	SignatureExpiry *time.Time  `json:"signature_expiry,omitempty"`
 	Timestamp   time.Time       `json:"timestamp"`
 	RetryCount  int             `json:"retry_count"`
 	Status      MessageStatus   `json:"status"`
+	TraceContext map[string]string `json:"trace_context,omitempty"` // W3C trace context of the event that queued it
+	LoanID      string          `json:"loan_id,omitempty"` // loan a disbursement or repayment confirmation is for
+
+	// Outbox delivery state
+	TxHash         string     `json:"tx_hash,omitempty"`
+	TxChainID      string     `json:"tx_chain_id,omitempty"` // chain the transaction was submitted on
+	LayerZeroGUID  string     `json:"layerzero_guid,omitempty"`
+	TxHashes       []string   `json:"tx_hashes,omitempty"` // every hash broadcast for TxHash's nonce when it was replaced
+	BlockHash      string     `json:"block_hash,omitempty"`   // block the transaction was mined in
+	BlockNumber    uint64     `json:"block_number,omitempty"`
+	TxExpiry       uint64     `json:"tx_expiry,omitempty"` // when TxHash can no longer be included, on chains where transactions expire
+	TxNonce        *uint64    `json:"tx_nonce,omitempty"`  // sequence number of TxHash on Aptos
+	LastError      string     `json:"last_error,omitempty"`
+	Attempts       []ErrorContext `json:"attempts,omitempty"` // failed delivery attempts, oldest first
+	GasLimit       uint64     `json:"gas_limit,omitempty"`   // overrides the destination chain's gas limit
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LeaseOwner     string     `json:"lease_owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 // MessageStatus represents the status of a message
@@ -58,6 +88,7 @@ const (
 	StatusConfirmed
 	StatusFailed
 	StatusRetrying
+	StatusProcessing
 )
 
 // String returns the string representation of MessageStatus
@@ -68,6 +99,7 @@ func (ms MessageStatus) String() string {
 		"CONFIRMED",
 		"FAILED",
 		"RETRYING",
+		"PROCESSING",
 	}[ms]
 }
 
@@ -82,6 +114,7 @@ type LoanApprovalEvent struct {
 	BorrowerDID   string
 	MerchantDID   string
 	Timestamp     time.Time
+	TraceContext  map[string]string
 }
 
 // LoanDisbursementEvent represents a loan disbursement event from Hedera
@@ -90,6 +123,7 @@ type LoanDisbursementEvent struct {
 	Amount    *big.Int
 	Merchant  common.Address
 	Timestamp time.Time
+	TraceContext map[string]string
 }
 
 // RepaymentEvent represents a repayment event from Hedera
@@ -99,27 +133,45 @@ type RepaymentEvent struct {
 	TotalRepaid  *big.Int
 	Payer        common.Address
 	Timestamp    time.Time
+	TraceContext map[string]string
 }
 
 // TrustedRelayer is the main service that acts as a trusted relayer
 type TrustedRelayer struct {
 	config          *config.Config
 	blockchain      *blockchain.Clients
-	privateKey      *ecdsa.PrivateKey
+	chains          *blockchain.Registry
+	signer          Signer
 	publicAddress   common.Address
+	solanaKey       ed25519.PrivateKey
+	aptosKey        ed25519.PrivateKey
 	
 	// Event listeners
 	hederaListener  *HederaEventListener
 	
 	// Message processing
-	messageQueue    chan *Message
+	outbox          Outbox
+	errorHandler    *ErrorHandler // retry policy and per-chain circuit breakers
+	limits          *SubmissionLimiter // per-chain message, disbursement and gas limits
+	controls        *RelayerControls   // operator pauses, drains, overrides and dry-run mode
+	monitor         *Monitor
+	deadLetters     DeadLetterStore
+	audit           AuditLog
+	instanceID      string
+	wake            chan struct{}
 	processing      sync.WaitGroup
-	messageStore    map[string]*Message
-	storeMutex      sync.RWMutex
 	
 	// LayerZero integration
 	layerZeroClient *LayerZeroClient
 	messageFactory  *MessageFactory
+	transactionManager *TransactionManager
+	nonces          *NonceManager
+	
+	// Loan funding
+	allocator       *AllocationEngine
+	poolStore       PoolStore
+	recipients      RecipientResolver
+	loans           LoanStore
 	
 	// Chain configurations
 	chainConfigs    map[string]*ChainConfig
@@ -136,12 +188,19 @@ type TrustedRelayer struct {
 type ChainConfig struct {
 	ChainID          string          `json:"chain_id"`
 	Name             string          `json:"name"`
+	Type             string          `json:"type"`
 	RPCURL           string          `json:"rpc_url"`
 	ContractAddress  common.Address  `json:"contract_address"`
+	ProgramID        string          `json:"program_id,omitempty"` // Solana program ID or Aptos module address
+	TokenAddress     common.Address  `json:"token_address,omitempty"`  // loan asset on EVM chains
+	LayerZeroEID     uint32          `json:"layerzero_eid,omitempty"`
+	LayerZeroReceiver common.Address `json:"layerzero_receiver,omitempty"` // OApp that receives LayerZero messages
 	GasLimit         uint64          `json:"gas_limit"`
 	GasPrice         *big.Int        `json:"gas_price"`
+	NativePriceUSD   float64         `json:"native_price_usd,omitempty"` // prices gas so chains of different types can be compared
 	Confirmations    uint64          `json:"confirmations"`
 	Enabled          bool            `json:"enabled"`
+	Limits           config.ChainLimits `json:"limits"`
 }
 
 // RelayerMetrics tracks relayer performance metrics
@@ -155,100 +214,216 @@ type RelayerMetrics struct {
 }
 
 
+// Outbox processing settings
+const (
+	outboxPollInterval  = 2 * time.Second
+	outboxBatchSize     = 10
+	outboxLeaseDuration = 5 * time.Minute
+	retryBackoff        = 5 * time.Second
+)
+
+// newInstanceID identifies this relayer process as a lease owner
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "relayer"
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
+
 // NewTrustedRelayer creates a new trusted relayer service
-func NewTrustedRelayer(cfg *config.Config, bc *blockchain.Clients) (*TrustedRelayer, error) {
+func NewTrustedRelayer(cfg *config.Config, bc *blockchain.Clients, db *supabase.Client) (*TrustedRelayer, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	// Parse private key
-	privateKey, err := crypto.HexToECDSA(cfg.RelayerPrivateKey)
+	// The relayer key stays behind a signer: a keystore file, a remote signer or, in
+	// development, a raw key
+	signer, err := NewSigner(cfg)
 	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+		return nil, err
 	}
 	
-	publicAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
+	publicAddress := signer.Address()
+
+	// Receivers look the relayer key up by the signer set version it signs with
+	if cfg.RelayerSignerSetVersion < 1 {
+		cancel()
+		return nil, fmt.Errorf("invalid signer set version: %d", cfg.RelayerSignerSetVersion)
+	}
 	
 	// Initialize chain configurations
 	chainConfigs := initializeChainConfigs(cfg)
+
+	// Parse the ed25519 keys used to sign on Solana and Aptos
+	var solanaKey, aptosKey ed25519.PrivateKey
+	if chainEnabled(chainConfigs, config.ChainTypeSolana) {
+		if solanaKey, err = blockchain.ParseSolanaPrivateKey(cfg.SolanaRelayerKey); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse Solana relayer key: %w", err)
+		}
+	}
+	if chainEnabled(chainConfigs, config.ChainTypeAptos) {
+		if aptosKey, err = blockchain.ParseAptosPrivateKey(cfg.AptosRelayerKey); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse Aptos relayer key: %w", err)
+		}
+	}
 	
-	// Initialize LayerZero client
-	// We'll use the Ethereum client as a placeholder for the EVM client
-	layerZeroClient, err := NewLayerZeroClient(bc.GetEthereumClient(), privateKey, cfg)
+	// Initialize LayerZero client on the source chain
+	var lzSource blockchain.ChainAdapter
+	if adapter, err := bc.Adapter(cfg.LayerZeroSourceChain); err == nil {
+		lzSource = adapter
+	}
+	layerZeroClient, err := NewLayerZeroClient(lzSource, signer, cfg)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to initialize LayerZero client: %w", err)
 	}
 
+	// Every transaction signed by the relayer takes its nonce from the nonce manager
+	nonces := NewNonceManager(bc.Registry(), NewSupabaseNonceStore(db))
+
+	// Source chain transactions go through the transaction manager, which replaces
+	// them when they get stuck
+	transactionManager := NewTransactionManager(bc.Registry(), signer, nonces, ctx)
+	layerZeroClient.SetSubmitter(transactionManager)
+
 	// Initialize Hedera event listener
-	hederaListener, err := NewHederaEventListener(bc.GetHederaClient(), cfg.HederaContractAddress)
+	hederaListener, err := NewHederaEventListener(cfg.HederaMirrorNodeURL, cfg.HederaContractAddress, cfg.HederaLoanUpdateTopicID, NewSupabaseCursorStore(db))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to initialize Hedera listener: %w", err)
 	}
 	
+	poolStore := NewSupabasePoolStore(db)
+	
 	relayer := &TrustedRelayer{
 		config:          cfg,
 		blockchain:      bc,
-		privateKey:      privateKey,
+		chains:          bc.Registry(),
+		signer:          signer,
 		publicAddress:   publicAddress,
+		solanaKey:       solanaKey,
+		aptosKey:        aptosKey,
 		hederaListener:  hederaListener,
-		messageQueue:    make(chan *Message, 1000),
-		messageStore:    make(map[string]*Message),
+		outbox:          NewSupabaseOutbox(db),
+		errorHandler:    newRelayerErrorHandler(),
+		limits:          NewSubmissionLimiter(chainConfigs, NewSupabaseLimitUsageStore(db)),
+		controls:        NewRelayerControls(NewSupabaseControlStore(db)),
+		monitor:         NewMonitor(ctx),
+		deadLetters:     NewSupabaseDeadLetterStore(db),
+		audit:           NewSupabaseAuditLog(db),
+		instanceID:      newInstanceID(),
+		wake:            make(chan struct{}, 1),
 		layerZeroClient: layerZeroClient,
+		transactionManager: transactionManager,
+		nonces:          nonces,
+		allocator:       NewAllocationEngine(poolStore, chainConfigs),
+		poolStore:       poolStore,
+		recipients:      NewSupabaseRecipientResolver(db),
+		loans:           NewSupabaseLoanStore(db),
 		chainConfigs:    chainConfigs,
 		ctx:            ctx,
 		cancel:         cancel,
 		metrics:        &RelayerMetrics{},
-		messageFactory: NewMessageFactory(101), // Placeholder for LayerZero chain ID
+		messageFactory: NewMessageFactory(),
+	}
+	transactionManager.OnReplaced = relayer.recordReplacement
+	if err := relayer.controls.refresh(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to load relayer controls: %w", err)
+	}
+	relayer.allocator.SetControls(relayer.controls)
+	relayer.registerAlertRules()
+	if cfg.RelayerAlertsPath != "" {
+		alertConfig, err := LoadAlertConfig(cfg.RelayerAlertsPath)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := relayer.configureAlerts(alertConfig); err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid alert config: %w", err)
+		}
 	}
 
 	return relayer, nil
 }
 
-// initializeChainConfigs initializes chain configurations
+// NewOfflineRelayer returns a relayer backed only by the database stores. It neither
+// listens, signs nor sends; tools that run beside the relayer service use it to manage
+// the outbox and dead letters, and the service picks up their changes on its next poll.
+// It reads the chains to check a message's last transaction before it is replayed.
+func NewOfflineRelayer(cfg *config.Config, db *supabase.Client) *TrustedRelayer {
+	chainConfigs := initializeChainConfigs(cfg)
+	chains, err := blockchain.NewRegistryFromConfig(cfg.Chains)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to connect to the liquidity chains")
+		chains = nil
+	}
+	poolStore := NewSupabasePoolStore(db)
+	relayer := &TrustedRelayer{
+		config:       cfg,
+		chains:       chains,
+		allocator:    NewAllocationEngine(poolStore, chainConfigs),
+		poolStore:    poolStore,
+		outbox:       NewSupabaseOutbox(db),
+		errorHandler: newRelayerErrorHandler(),
+		limits:       NewSubmissionLimiter(chainConfigs, NewSupabaseLimitUsageStore(db)),
+		controls:     NewRelayerControls(NewSupabaseControlStore(db)),
+		monitor:      NewMonitor(context.Background()),
+		deadLetters:  NewSupabaseDeadLetterStore(db),
+		audit:        NewSupabaseAuditLog(db),
+		instanceID:   newInstanceID(),
+		wake:         make(chan struct{}, 1),
+		chainConfigs: chainConfigs,
+		metrics:      &RelayerMetrics{},
+		ctx:          context.Background(),
+	}
+	if err := relayer.controls.refresh(relayer.ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to load relayer controls")
+	}
+	return relayer
+}
+
+// initializeChainConfigs initializes chain configurations from the configured chain specs
 func initializeChainConfigs(cfg *config.Config) map[string]*ChainConfig {
-	return map[string]*ChainConfig{
-		"ethereum": {
-			ChainID:         "1",
-			Name:           "Ethereum",
-			RPCURL:         cfg.EthereumRPC,
-			ContractAddress: common.HexToAddress(cfg.EthereumLiquidityPool),
-			GasLimit:       500000,
-			GasPrice:       big.NewInt(20000000000), // 20 Gwei
-			Confirmations:  12,
-			Enabled:        cfg.EthereumRPC != "",
-		},
-		"base": {
-			ChainID:         "8453",
-			Name:           "Base",
-			RPCURL:         cfg.BaseRPC,
-			ContractAddress: common.HexToAddress(cfg.BaseLiquidityPool),
-			GasLimit:       500000,
-			GasPrice:       big.NewInt(1000000000), // 1 Gwei
-			Confirmations:  5,
-			Enabled:        cfg.BaseRPC != "",
-		},
-		"solana": {
-			ChainID:         "solana",
-			Name:           "Solana",
-			RPCURL:         cfg.SolanaRPC,
-			ContractAddress: common.HexToAddress("0x0000000000000000000000000000000000000000"), // Placeholder
-			GasLimit:       0, // Solana doesn't use gas
-			GasPrice:       big.NewInt(0),
-			Confirmations:  1,
-			Enabled:        cfg.SolanaRPC != "",
-		},
-		"aptos": {
-			ChainID:         "aptos",
-			Name:           "Aptos",
-			RPCURL:         cfg.AptosRPC,
-			ContractAddress: common.HexToAddress("0x0000000000000000000000000000000000000000"), // Placeholder
-			GasLimit:       0, // Aptos doesn't use gas
-			GasPrice:       big.NewInt(0),
-			Confirmations:  1,
-			Enabled:        cfg.AptosRPC != "",
-		},
+	chainConfigs := make(map[string]*ChainConfig, len(cfg.Chains))
+	for _, spec := range cfg.Chains {
+		chain := &ChainConfig{
+			ChainID:       spec.Key,
+			Name:          spec.Name,
+			Type:          spec.Type,
+			RPCURL:        spec.RPCURL,
+			LayerZeroEID:  spec.LayerZeroEID,
+			GasLimit:      spec.GasLimit,
+			GasPrice:      new(big.Int).SetUint64(spec.GasPriceWei),
+			NativePriceUSD: spec.NativePriceUSD,
+			Confirmations: spec.Confirmations,
+			Enabled:       spec.Enabled,
+			Limits:        spec.Limits,
+		}
+		if spec.Type == config.ChainTypeEVM {
+			chain.ChainID = strconv.FormatUint(spec.EVMChainID, 10)
+			chain.ContractAddress = common.HexToAddress(spec.ContractAddress)
+			chain.TokenAddress = common.HexToAddress(spec.TokenAddress)
+			chain.LayerZeroReceiver = common.HexToAddress(spec.LayerZeroReceiver)
+		} else {
+			chain.ProgramID = spec.ContractAddress
+		}
+		chainConfigs[spec.Key] = chain
 	}
+	return chainConfigs
+}
+
+// chainEnabled reports whether any enabled chain has the given type.
+func chainEnabled(chainConfigs map[string]*ChainConfig, chainType string) bool {
+	for _, chain := range chainConfigs {
+		if chain.Type == chainType && chain.Enabled {
+			return true
+		}
+	}
+	return false
 }
 
 // Start starts the trusted relayer service
@@ -260,6 +435,23 @@ func (tr *TrustedRelayer) Start() error {
 		return fmt.Errorf("failed to start Hedera listener: %w", err)
 	}
 	
+	// Resync the source chain nonce so gaps left by a previous run are found and filled
+	if tr.nonces != nil && tr.config.LayerZeroSourceChain != "" {
+		if err := tr.nonces.Resync(tr.ctx, tr.config.LayerZeroSourceChain, tr.publicAddress.Hex()); err != nil {
+			log.Error().Err(err).Str("chain_id", tr.config.LayerZeroSourceChain).Msg("Failed to resync relayer nonce")
+		}
+	}
+	
+	// Recover messages left behind by a previous run before taking new work
+	tr.recoverOutbox()
+	
+	tr.registerHealthChecks()
+	
+	// Replace source chain transactions that get stuck
+	if tr.transactionManager != nil {
+		tr.transactionManager.StartWatchdog()
+	}
+	
 	// Start message processors
 	tr.processing.Add(1)
 	go tr.messageProcessor()
@@ -299,10 +491,20 @@ func (tr *TrustedRelayer) Stop() error {
 }
 
 // handleHederaEvent handles events from Hedera
-func (tr *TrustedRelayer) handleHederaEvent(event interface{}) error {
+func (tr *TrustedRelayer) handleHederaEvent(event interface{}) (err error) {
+	_, span := tr.startEventSpan(event)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	switch e := event.(type) {
 	case *LoanApprovalEvent:
 		return tr.handleLoanApproval(e)
+	case *RepaymentEvent:
+		return tr.handleRepayment(e)
+	case *LoanDisbursementEvent:
+		return tr.handleLoanDisbursement(e)
 	default:
 		log.Warn().Interface("event", event).Msg("Unknown event type")
 		return nil
@@ -318,123 +520,593 @@ func (tr *TrustedRelayer) handleLoanApproval(event *LoanApprovalEvent) error {
 		Str("amount", event.Amount.String()).
 		Msg("Processing loan approval event")
 
-	// Create cross-chain message for each enabled chain
-	for chainID, config := range tr.chainConfigs {
-		if !config.Enabled {
-			continue
+	// A replayed event maps to the message already in the outbox
+	if _, err := tr.outbox.Get(tr.ctx, NewMessageID(MessageTypeLoanDisbursement, event.TokenID.String())); err == nil {
+		log.Info().Str("token_id", event.TokenID.String()).Msg("Loan disbursement already queued, skipping")
+		return nil
+	}
+
+	// Pick the single pool that funds this loan and record the choice before sending, so
+	// a loan is never funded from an unrecorded pool. A loan allocated before keeps its
+	// pool, so a retry after a later failure does not reserve the amount again.
+	allocation, err := tr.allocateLoan(tr.ctx, AllocationRequest{
+		LoanID: event.TokenID.String(),
+		Asset:  tr.config.LoanAsset,
+		Amount: tokenAmount(event.Amount, tr.config.LoanAssetDecimals),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to allocate loan %s: %w", event.TokenID.String(), err)
+	}
+
+	log.Info().
+		Str("token_id", event.TokenID.String()).
+		Str("pool_id", allocation.PoolID).
+		Str("chain_id", allocation.ChainID).
+		Float64("score", allocation.Score).
+		Msg("Loan allocated to pool")
+
+	// EVM pools need the loan asset's token address; other chains resolve the asset themselves
+	var token common.Address
+	if chain := tr.chainConfigs[allocation.ChainID]; chain != nil && chain.Type == config.ChainTypeEVM {
+		if chain.TokenAddress == (common.Address{}) {
+			return fmt.Errorf("no token address configured for chain %s", allocation.ChainID)
 		}
+		token = chain.TokenAddress
+	}
+
+	payload, err := tr.messageFactory.CreateLoanDisbursementPayload(event, token)
+	if err != nil {
+		return fmt.Errorf("failed to create loan disbursement payload: %w", err)
+	}
+
+	// Queue message for processing
+	message := newPendingMessage(MessageTypeLoanDisbursement, event.TokenID.String(), allocation.ChainID, payload)
+	message.LoanID = event.TokenID.String()
+	message.TraceContext = event.TraceContext
+	return tr.enqueue(message)
+}
 
-		payload, err := tr.messageFactory.CreateLoanDisbursementPayload(event)
+// allocateLoan picks a pool for the loan and records the allocation. When another
+// allocation took the pool's liquidity in the meantime the loan is allocated again from
+// fresh pool states.
+func (tr *TrustedRelayer) allocateLoan(ctx context.Context, req AllocationRequest) (*Allocation, error) {
+	for attempt := 1; ; attempt++ {
+		allocation, err := tr.allocator.Allocate(ctx, req)
 		if err != nil {
-			log.Error().Err(err).Str("chain_id", chainID).Msg("Failed to create loan disbursement payload")
-			continue
+			return nil, err
 		}
-
-		message := &Message{
-			Type:      MessageTypeLoanDisbursement,
-			ChainID:   chainID,
-			Payload:   payload,
-			Timestamp: time.Now(),
-			Status:    StatusPending,
+		err = tr.poolStore.RecordAllocation(ctx, allocation)
+		if err == nil {
+			return allocation, nil
 		}
+		if !errors.Is(err, ErrPoolOvercommitted) || attempt == maxAllocationAttempts {
+			return nil, fmt.Errorf("failed to record allocation: %w", err)
+		}
+		log.Warn().Err(err).Str("loan_id", req.LoanID).Str("pool_id", allocation.PoolID).Msg("Pool filled by another allocation, allocating again")
+	}
+}
 
-		// Queue message for processing
-		tr.messageQueue <- message
+// handleRepayment sends a repayment confirmation to the pool that funded the loan, so
+// the pool's LP accounting reflects the repayment
+func (tr *TrustedRelayer) handleRepayment(event *RepaymentEvent) error {
+	loanID := event.TokenID.String()
+	log.Info().
+		Str("token_id", loanID).
+		Str("payer", event.Payer.Hex()).
+		Str("amount", event.Amount.String()).
+		Str("total_repaid", event.TotalRepaid.String()).
+		Msg("Processing repayment event")
+
+	chainID, err := tr.loans.FundingChain(tr.ctx, loanID)
+	if err != nil {
+		return fmt.Errorf("failed to get funding chain for loan %s: %w", loanID, err)
+	}
+	chain, ok := tr.chainConfigs[chainID]
+	if !ok {
+		return fmt.Errorf("unsupported chain ID: %s", chainID)
+	}
+	// The Solana and Aptos pool programs have no repayment instruction yet
+	if chain.Type == config.ChainTypeSolana || chain.Type == config.ChainTypeAptos {
+		log.Warn().
+			Str("token_id", loanID).
+			Str("chain_id", chainID).
+			Msg("Pool does not record repayments, skipping confirmation")
+		return nil
+	}
+
+	payload, err := tr.messageFactory.CreateRepaymentConfirmationPayload(event)
+	if err != nil {
+		return fmt.Errorf("failed to create repayment confirmation payload: %w", err)
 	}
 
+	// Each repayment raises the loan's total repaid, which identifies it
+	message := newPendingMessage(MessageTypeRepaymentConfirmation, loanID+":"+event.TotalRepaid.String(), chainID, payload)
+	message.LoanID = loanID
+	message.TraceContext = event.TraceContext
+	return tr.enqueue(message)
+}
+
+// handleLoanDisbursement records a confirmed disbursement on the loan and its order
+func (tr *TrustedRelayer) handleLoanDisbursement(event *LoanDisbursementEvent) error {
+	loanID := event.TokenID.String()
+	log.Info().
+		Str("token_id", loanID).
+		Str("merchant", event.Merchant.Hex()).
+		Str("amount", event.Amount.String()).
+		Msg("Processing loan disbursement event")
+
+	if err := tr.loans.RecordDisbursement(tr.ctx, loanID, event.Timestamp); err != nil {
+		return fmt.Errorf("failed to record disbursement for loan %s: %w", loanID, err)
+	}
+	return nil
+}
+
+// enqueue stores a message in the outbox and wakes the processor
+func (tr *TrustedRelayer) enqueue(message *Message) error {
+	created, err := tr.outbox.Enqueue(tr.ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to queue message: %w", err)
+	}
+	if !created {
+		log.Info().Str("message_id", message.ID).Msg("Message already queued")
+		return nil
+	}
+
+	select {
+	case tr.wake <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
-// messageProcessor processes messages from the queue
+// messageProcessor claims due messages from the outbox and processes them
 func (tr *TrustedRelayer) messageProcessor() {
 	defer tr.processing.Done()
 	
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	
 	for {
+		tr.processOutbox()
+		tr.reconcileSent()
+		
 		select {
 		case <-tr.ctx.Done():
 			return
-		case message := <-tr.messageQueue:
-			tr.processMessage(message)
+		case <-ticker.C:
+		case <-tr.wake:
 		}
 	}
 }
 
+// processOutbox claims a batch of due messages and processes them
+func (tr *TrustedRelayer) processOutbox() {
+	// Pick up the overrides operators set through any instance. Nothing is sent until
+	// they load, so a chain paused elsewhere is never sent to.
+	if err := tr.controls.refresh(tr.ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to load relayer controls")
+		return
+	}
+	messages, err := tr.outbox.Claim(tr.ctx, tr.instanceID, outboxBatchSize, outboxLeaseDuration)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to claim messages")
+		return
+	}
+	for _, message := range messages {
+		tr.processMessage(message)
+	}
+}
+
 // processMessage processes a single message
 func (tr *TrustedRelayer) processMessage(message *Message) {
-	startTime := time.Now()
-	
 	log.Info().
+		Str("message_id", message.ID).
 		Str("message_type", message.Type.String()).
 		Str("chain_id", message.ChainID).
-		Str("status", message.Status.String()).
+		Int("retry_count", message.RetryCount).
 		Msg("Processing message")
+
+	// Continue the trace of the event that queued the message
+	ctx, span := tracer().Start(tracing.Extract(tr.ctx, message.TraceContext), "relayer.processMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("message_id", message.ID),
+			attribute.String("message_type", message.Type.String()),
+			attribute.String("chain_id", message.ChainID),
+			attribute.Int("retry_count", message.RetryCount),
+		))
+	defer span.End()
+	
+	// Hold, drain or dry-run messages as operators have set the relayer
+	if tr.applyControls(message) {
+		span.AddEvent("held by relayer controls")
+		return
+	}
+
+	// Hold messages for a chain over its submission limits until they free up
+	reservation, exceeded := tr.reserveLimits(message)
+	if exceeded != nil {
+		span.AddEvent("deferred", trace.WithAttributes(attribute.String("reason", exceeded.Error())))
+		tr.deferMessage(message, exceeded.RetryAt, exceeded.Error())
+		return
+	}
+
+	// Hold messages for a chain whose circuit is open until it lets a trial through
+	breaker := tr.errorHandler.CircuitBreaker(message.ChainID)
+	if !breaker.Allow() {
+		tr.limits.Release(tr.ctx, reservation)
+		span.AddEvent("deferred", trace.WithAttributes(attribute.String("reason", "circuit breaker open")))
+		tr.deferMessage(message, breaker.RetryAt(), "circuit breaker open")
+		return
+	}
 	
 	// Update metrics
 	tr.metrics.MessagesProcessed++
 
-	// Send message via LayerZero
-	chainID, err := getLayerZeroChainID(message.ChainID)
+	// Send message to the destination chain
+	started := time.Now()
+	txHash, err := tr.dispatchMessage(ctx, message)
+	if err != nil && txHash != "" {
+		// The transaction was broadcast, so sending the message again could pay twice.
+		// It is recorded as sent and reconcileSent settles it from its receipt.
+		log.Warn().Err(err).Str("message_id", message.ID).Str("tx_hash", txHash).Msg("Message sent with an error, leaving it to reconciliation")
+		err = nil
+	}
 	if err != nil {
-		log.Error().Err(err).Str("chain_id", message.ChainID).Msg("Failed to get LayerZero chain ID")
+		// Nothing was broadcast, so the send does not count against the limits
+		tr.limits.Release(tr.ctx, reservation)
+		err = ClassifyError(err)
+		tracing.RecordError(span, err)
+		span.SetAttributes(attribute.String("error_type", ErrorType(err)))
+		log.Error().Err(err).Str("message_id", message.ID).Str("chain_id", message.ChainID).Str("error_type", ErrorType(err)).Msg("Failed to send message")
+		tr.metrics.MessagesFailed++
+		// A message the chain rejects says nothing about the chain's health
+		if ErrorType(err) != "validation" {
+			breaker.OnFailure()
+		}
+		tr.retryOrFail(message, "dispatch", err, time.Since(started))
+		tr.recordMessageEvent(EventTypeMessageProcessed, EventStatusFailure, message, time.Since(started), err)
+		tr.saveFailure(message, tr.instanceID)
+		return
+	}
+	breaker.OnSuccess()
+	span.SetAttributes(attribute.String("tx_hash", txHash), attribute.String("tx_chain_id", message.TxChainID))
+	
+	// Update message status
+	message.TxHash = txHash
+	message.TxHashes = nil
+	message.BlockHash = ""
+	message.BlockNumber = 0
+	message.LastError = ""
+	if err := message.transition(StatusSent); err != nil {
+		log.Error().Err(err).Str("message_id", message.ID).Msg("Invalid message state")
+		return
+	}
+	tr.metrics.MessagesSent++
+	tr.recordMessageEvent(EventTypeMessageProcessed, EventStatusSuccess, message, time.Since(started), nil)
+	tr.recordMessageEvent(EventTypeMessageSent, EventStatusSuccess, message, 0, nil)
+	tr.saveMessage(message, tr.instanceID)
+
+	log.Info().
+		Str("message_id", message.ID).
+		Str("tx_hash", txHash).
+		Str("tx_chain_id", message.TxChainID).
+		Msg("Transaction sent")
+}
+
+// retryOrFail records the failed attempt and, if the error handler allows another,
+// schedules it with exponential backoff. Messages that fail permanently or use up their
+// retries are failed.
+func (tr *TrustedRelayer) retryOrFail(message *Message, operation string, cause error, duration time.Duration) {
+	attempt := ErrorContext{
+		Operation:   operation,
+		ChainID:     message.ChainID,
+		MessageType: message.Type,
+		RetryCount:  message.RetryCount,
+		LastError:   cause,
+		Timestamp:   time.Now().UTC(),
+		Duration:    duration,
+	}
+	message.Attempts = append(message.Attempts, attempt)
+	message.RetryCount++
+	message.LastError = cause.Error()
+
+	if message.RetryCount < tr.config.MaxRetries {
+		// The error handler counts the attempts made so far
+		attempt.RetryCount = message.RetryCount
+		if delay, retry := tr.errorHandler.NextRetry(&attempt); retry {
+			message.NextAttemptAt = time.Now().UTC().Add(delay)
+			_ = message.transition(StatusRetrying)
+			return
+		}
+	}
+	_ = message.transition(StatusFailed)
+}
+
+// deferMessage returns a claimed message to the outbox without counting an attempt, to
+// be picked up again at the given time
+func (tr *TrustedRelayer) deferMessage(message *Message, until time.Time, reason string) {
+	message.NextAttemptAt = until.UTC()
+	if err := message.transition(StatusPending); err != nil {
+		log.Error().Err(err).Str("message_id", message.ID).Msg("Invalid message state")
 		return
 	}
-	txHash, err := tr.layerZeroClient.SendTransaction(tr.ctx, chainID, message.Payload)
+	tr.saveMessage(message, tr.instanceID)
+
+	log.Warn().
+		Str("message_id", message.ID).
+		Str("chain_id", message.ChainID).
+		Time("next_attempt_at", message.NextAttemptAt).
+		Str("reason", reason).
+		Msg("Deferring message")
+}
+
+// saveMessage persists the message, releasing the lease held by owner, and reports
+// whether it was saved
+func (tr *TrustedRelayer) saveMessage(message *Message, owner string) bool {
+	if err := tr.outbox.Save(tr.ctx, message, owner); err != nil {
+		log.Error().Err(err).Str("message_id", message.ID).Str("status", message.Status.String()).Msg("Failed to save message")
+		return false
+	}
+	return true
+}
+
+// saveFailure persists a message after a failed attempt and dead-letters it if it has
+// no retries left
+func (tr *TrustedRelayer) saveFailure(message *Message, owner string) {
+	if tr.saveMessage(message, owner) && message.Status == StatusFailed {
+		last := message.Attempts[len(message.Attempts)-1]
+		tr.recordMessageEvent(EventTypeMessageFailed, EventStatusFailure, message, 0, last.LastError)
+		tr.deadLetter(message)
+	}
+}
+
+// reconcileSent checks the receipts of sent messages and confirms those that are final.
+// The block of each mined transaction is recorded and re-checked until it is final: a
+// reorg that drops the transaction returns the message to pending for resubmission, as
+// does a transaction that expired before it was included.
+func (tr *TrustedRelayer) reconcileSent() {
+	if tr.chains == nil {
+		return
+	}
+
+	messages, err := tr.outbox.ListByStatus(tr.ctx, StatusSent, outboxBatchSize*10)
 	if err != nil {
-		log.Error().Err(err).Str("chain_id", message.ChainID).Msg("Failed to send message")
+		log.Error().Err(err).Msg("Failed to list sent messages")
+		return
+	}
 
-		// Update message status
-		message.Status = StatusFailed
-		message.RetryCount++
-		
-		// Retry logic
-		if message.RetryCount < tr.config.MaxRetries {
-			message.Status = StatusRetrying
-			go func() {
-				time.Sleep(time.Duration(message.RetryCount) * time.Second * 5)
-				tr.messageQueue <- message
-			}()
+	for _, message := range messages {
+		adapter, err := tr.chains.Adapter(message.TxChainID)
+		if err != nil {
+			continue
 		}
-		
-		tr.metrics.MessagesFailed++
+		// Checked before the receipt, so a transaction included just before it expired is found
+		expired, err := blockchain.TransactionExpired(tr.ctx, adapter, message.TxExpiry)
+		if err != nil {
+			log.Warn().Err(err).Str("message_id", message.ID).Msg("Failed to check message transaction expiry")
+			continue
+		}
+		receipt, err := tr.messageReceipt(adapter, message)
+		if err != nil {
+			switch {
+			case !errors.Is(err, blockchain.ErrTxNotFound):
+				log.Warn().Err(err).Str("message_id", message.ID).Msg("Failed to get message receipt")
+			case message.BlockHash != "":
+				tr.resubmitReorged(message)
+			case expired:
+				tr.resubmitExpired(adapter, message)
+			}
+			continue
+		}
+		if receipt.TxHash != message.TxHash {
+			log.Info().
+				Str("message_id", message.ID).
+				Str("tx_hash", receipt.TxHash).
+				Str("latest_tx_hash", message.TxHash).
+				Msg("Earlier transaction of a replaced message was mined")
+			message.TxHash = receipt.TxHash
+		}
+		moved := receipt.BlockHash != message.BlockHash
+		if moved {
+			if message.BlockHash != "" {
+				log.Warn().
+					Str("message_id", message.ID).
+					Str("tx_hash", receipt.TxHash).
+					Str("old_block_hash", message.BlockHash).
+					Str("block_hash", receipt.BlockHash).
+					Msg("Message transaction moved to another block by a reorg")
+				tr.recordReorgEvent(message, "moved", receipt.BlockHash)
+			}
+			message.BlockHash = receipt.BlockHash
+			message.BlockNumber = receipt.BlockNumber
+		}
+		identified := receipt.Success && tr.recordLayerZeroPacket(message, receipt)
+
+		switch {
+		case !receipt.Success:
+			tr.recordGasSpend(message.TxChainID, receipt)
+			tr.metrics.MessagesFailed++
+			tr.retryOrFail(message, "confirm", fmt.Errorf("transaction %s reverted", message.TxHash), 0)
+			tr.saveFailure(message, "")
+			continue
+		case receipt.Final:
+			if err := message.transition(StatusConfirmed); err != nil {
+				continue
+			}
+			tr.recordGasSpend(message.TxChainID, receipt)
+			tr.recordConfirmation(message)
+		case !moved && !identified:
+			continue
+		}
+		tr.saveMessage(message, "")
+	}
+}
+
+// recordLayerZeroPacket replaces the provisional GUID of a LayerZero message with the one
+// the endpoint assigned, from the PacketSent log in its receipt. It reports whether the
+// GUID changed.
+func (tr *TrustedRelayer) recordLayerZeroPacket(message *Message, receipt *blockchain.Receipt) bool {
+	if message.LayerZeroGUID == "" || tr.layerZeroClient == nil {
+		return false
+	}
+	packet, err := tr.layerZeroClient.SentPacket(receipt)
+	if err != nil || packet == nil {
+		log.Warn().Err(err).Str("message_id", message.ID).Str("tx_hash", receipt.TxHash).Msg("No LayerZero packet in message receipt, keeping the provisional GUID")
+		return false
+	}
+	if packet.GUID == message.LayerZeroGUID {
+		return false
+	}
+	log.Info().
+		Str("message_id", message.ID).
+		Str("provisional_guid", message.LayerZeroGUID).
+		Str("guid", packet.GUID).
+		Uint64("lz_nonce", packet.Nonce).
+		Msg("Recorded the LayerZero GUID assigned by the endpoint")
+	message.LayerZeroGUID = packet.GUID
+	return true
+}
+
+// resubmitReorged returns a sent message to pending after a reorg dropped the block its
+// transaction was mined in. The receiver rejects a reused message nonce, so the message is
+// delivered once even if the dropped transaction is mined again.
+func (tr *TrustedRelayer) resubmitReorged(message *Message) {
+	log.Warn().
+		Str("message_id", message.ID).
+		Str("tx_hash", message.TxHash).
+		Str("block_hash", message.BlockHash).
+		Uint64("block_number", message.BlockNumber).
+		Msg("Message transaction dropped by a reorg, resubmitting")
+	tr.recordReorgEvent(message, "dropped", "")
+	tr.resubmit(message)
+}
+
+// resubmitExpired returns a sent message to pending once its transaction expired without
+// being included. It can no longer be, so sending the message again cannot pay twice.
+func (tr *TrustedRelayer) resubmitExpired(adapter blockchain.ChainAdapter, message *Message) {
+	log.Warn().
+		Str("message_id", message.ID).
+		Str("tx_hash", message.TxHash).
+		Str("tx_chain_id", message.TxChainID).
+		Uint64("tx_expiry", message.TxExpiry).
+		Msg("Message transaction expired before it was included, resubmitting")
+
+	// The expired sequence number is reused so it does not block later transactions
+	if tr.nonces != nil && message.TxNonce != nil && adapter.Spec().Type == config.ChainTypeAptos && tr.aptosKey != nil {
+		tr.nonces.Release(message.TxChainID, blockchain.AptosAddressFromPrivateKey(tr.aptosKey).String(), *message.TxNonce)
+	}
+	tr.resubmit(message)
+}
+
+// resubmit returns a sent message whose transaction will never be included to pending
+// and wakes the processor to send it again
+func (tr *TrustedRelayer) resubmit(message *Message) {
+	if err := message.transition(StatusPending); err != nil {
+		return
+	}
+	message.TxHash = ""
+	message.TxHashes = nil
+	message.LayerZeroGUID = ""
+	message.BlockHash = ""
+	message.BlockNumber = 0
+	message.TxExpiry = 0
+	message.TxNonce = nil
+	message.NextAttemptAt = time.Now().UTC()
+	if !tr.saveMessage(message, "") {
 		return
 	}
-	
-	// Update message status
-	message.Status = StatusSent
 
-	// In a real implementation, you would wait for confirmation here.
-	// For now, we'll just log the transaction hash.
-	log.Info().Str("tx_hash", txHash).Msg("Transaction sent")
+	select {
+	case tr.wake <- struct{}{}:
+	default:
+	}
+}
 
-	// Update metrics
-	message.Status = StatusConfirmed
-	tr.metrics.MessagesSent++
+// messageReceipt returns the receipt of whichever of the message's transactions was
+// mined. Replacements share a nonce, so at most one of them can be.
+func (tr *TrustedRelayer) messageReceipt(adapter blockchain.ChainAdapter, message *Message) (*blockchain.Receipt, error) {
+	hashes := message.TxHashes
+	if len(hashes) == 0 {
+		hashes = []string{message.TxHash}
+	}
+	for i := len(hashes) - 1; i >= 0; i-- {
+		receipt, err := adapter.TransactionReceipt(tr.ctx, hashes[i])
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, blockchain.ErrTxNotFound) {
+			return nil, err
+		}
+	}
+	return nil, blockchain.ErrTxNotFound
+}
+
+// recordReplacement stores the hash of a replacement transaction on its sent message,
+// so the message confirms whichever of its transactions is mined
+func (tr *TrustedRelayer) recordReplacement(pendingTx *PendingTransaction, oldHash string) {
+	if pendingTx.Message == nil || pendingTx.Message.ID == "" {
+		return
+	}
+	message, err := tr.outbox.Get(tr.ctx, pendingTx.Message.ID)
+	if err != nil {
+		log.Error().Err(err).Str("message_id", pendingTx.Message.ID).Msg("Failed to load replaced message")
+		return
+	}
+	if message.Status != StatusSent || message.TxHash != oldHash {
+		return
+	}
+
+	if len(message.TxHashes) == 0 {
+		message.TxHashes = []string{message.TxHash}
+	}
+	message.TxHashes = append(message.TxHashes, pendingTx.TxHash)
+	message.TxHash = pendingTx.TxHash
+	message.UpdatedAt = time.Now().UTC()
+	tr.saveMessage(message, "")
+}
+
+// recordConfirmation updates the confirmation metrics for a message
+func (tr *TrustedRelayer) recordConfirmation(message *Message) {
 	tr.metrics.MessagesConfirmed++
 	
 	// Update latency
-	latency := time.Since(startTime)
+	latency := time.Since(message.Timestamp)
 	tr.metrics.AverageLatency = time.Duration(
 		(int64(tr.metrics.AverageLatency)*int64(tr.metrics.MessagesConfirmed-1) + int64(latency)) /
 			int64(tr.metrics.MessagesConfirmed),
 	)
 	tr.metrics.LastProcessedTime = time.Now()
+	tr.recordMessageEvent(EventTypeMessageConfirmed, EventStatusSuccess, message, latency, nil)
 	
 	log.Info().
+		Str("message_id", message.ID).
 		Str("message_type", message.Type.String()).
 		Str("chain_id", message.ChainID).
 		Dur("latency", latency).
 		Msg("Message processed successfully")
 }
 
-func getLayerZeroChainID(chainID string) (uint32, error) {
-	switch chainID {
-	case "ethereum":
-		return 101, nil
-	case "base":
-		return 184, nil
-	default:
+// recoverOutbox returns messages whose leases expired while no relayer held them, for
+// example after a crash, and confirms messages that were sent before the restart.
+func (tr *TrustedRelayer) recoverOutbox() {
+	released, err := tr.outbox.ReleaseExpired(tr.ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to release expired message leases")
+	} else if released > 0 {
+		log.Warn().Int("released", released).Msg("Recovered messages with expired leases")
+	}
+
+	tr.reconcileSent()
+}
+
+// getLayerZeroChainID returns the LayerZero endpoint ID of a configured chain
+func (tr *TrustedRelayer) getLayerZeroChainID(chainID string) (uint32, error) {
+	chain, ok := tr.chainConfigs[chainID]
+	if !ok || chain.LayerZeroEID == 0 {
 		return 0, fmt.Errorf("unsupported chain ID: %s", chainID)
 	}
+	return chain.LayerZeroEID, nil
 }
 
 // metricsReporter reports metrics periodically
@@ -473,13 +1145,5 @@ func (tr *TrustedRelayer) GetMetrics() *RelayerMetrics {
 
 // GetMessageStatus returns the status of a specific message
 func (tr *TrustedRelayer) GetMessageStatus(messageID string) (*Message, error) {
-	tr.storeMutex.RLock()
-	defer tr.storeMutex.RUnlock()
-	
-	message, ok := tr.messageStore[messageID]
-	if !ok {
-		return nil, fmt.Errorf("message not found: %s", messageID)
-	}
-	
-	return message, nil
-}
\ No newline at end of file
+	return tr.outbox.Get(tr.ctx, messageID)
+}
@@ -1,39 +1,98 @@
 package relayer
 
 import (
+	"context"
+	"fmt"
 	"math/big"
 	"testing"
+	"time"
+
+	"kelo-backend/pkg/config"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
 // newTestRelayer creates a new TrustedRelayer for testing purposes.
 func newTestRelayer(t *testing.T) *TrustedRelayer {
 	// Generate a dummy private key for the relayer.
-	privateKey, err := crypto.GenerateKey()
+	signer, err := GenerateLocalSigner()
 	assert.NoError(t, err)
 
+	cfg := &config.Config{
+		LayerZeroEndpoint: "0x1111111111111111111111111111111111111111",
+		LoanAsset:         "USDC",
+		LoanAssetDecimals: 0,
+	}
+
 	// Mock LayerZeroClient - we pass nil for ethclient as it's not used in the mock
-	lzClient, err := NewLayerZeroClient(nil, privateKey)
+	lzClient, err := NewLayerZeroClient(nil, signer, cfg)
 	assert.NoError(t, err)
 
-	relayer := &TrustedRelayer{
-		privateKey:   privateKey,
-		messageQueue: make(chan *Message, 100),
-		messageStore: make(map[string]*Message),
-		chainConfigs: map[string]*ChainConfig{
-			"ethereum": {
-				Enabled: true,
-			},
+	chainConfigs := map[string]*ChainConfig{
+		"ethereum": {
+			Enabled: true,
+		},
+	}
+	poolStore := &fakePoolStore{
+		pools: []*PoolState{
+			{PoolID: "pool_eth", ChainID: "ethereum", Asset: "USDC", TotalLiquidity: 100000},
 		},
+	}
+
+	relayer := &TrustedRelayer{
+		config:          cfg,
+		signer:          signer,
+		outbox:          NewMemoryOutbox(),
+		errorHandler:    newRelayerErrorHandler(),
+		limits:          NewSubmissionLimiter(chainConfigs, NewMemoryLimitUsageStore()),
+		controls:        NewRelayerControls(NewMemoryControlStore()),
+		monitor:         NewMonitor(context.Background()),
+		deadLetters:     NewMemoryDeadLetterStore(),
+		audit:           NewMemoryAuditLog(),
+		instanceID:      "test-relayer",
+		metrics:         &RelayerMetrics{},
+		wake:            make(chan struct{}, 1),
+		chainConfigs:    chainConfigs,
 		layerZeroClient: lzClient,
-		messageFactory:  NewMessageFactory(101), // Placeholder for LayerZero chain ID
+		messageFactory:  NewMessageFactory(),
+		allocator:       NewAllocationEngine(poolStore, chainConfigs),
+		poolStore:       poolStore,
+		loans:           &fakeLoanStore{fundingChains: map[string]string{}, disbursed: map[string]time.Time{}},
+		ctx:             context.Background(),
 	}
+	relayer.allocator.SetControls(relayer.controls)
 	return relayer
 }
 
+// fakeLoanStore is an in-memory LoanStore for tests.
+type fakeLoanStore struct {
+	fundingChains map[string]string
+	disbursed     map[string]time.Time
+}
+
+func (f *fakeLoanStore) FundingChain(ctx context.Context, loanID string) (string, error) {
+	chainID, ok := f.fundingChains[loanID]
+	if !ok {
+		return "", fmt.Errorf("loan not found: %s", loanID)
+	}
+	return chainID, nil
+}
+
+func (f *fakeLoanStore) RecordDisbursement(ctx context.Context, loanID string, disbursedAt time.Time) error {
+	if _, ok := f.fundingChains[loanID]; !ok {
+		return fmt.Errorf("loan not found: %s", loanID)
+	}
+	f.disbursed[loanID] = disbursedAt
+	return nil
+}
+
+func pendingMessages(t *testing.T, relayer *TrustedRelayer) []*Message {
+	messages, err := relayer.outbox.ListByStatus(context.Background(), StatusPending, 100)
+	assert.NoError(t, err)
+	return messages
+}
+
 func TestTrustedRelayer_HandleLoanApproval(t *testing.T) {
 	relayer := newTestRelayer(t)
 
@@ -48,5 +107,166 @@ func TestTrustedRelayer_HandleLoanApproval(t *testing.T) {
 
 	err := relayer.handleLoanApproval(event)
 	assert.NoError(t, err)
-	assert.Len(t, relayer.messageQueue, 1)
+	assert.Len(t, pendingMessages(t, relayer), 1)
+}
+
+func TestTrustedRelayer_HandleLoanApproval_SingleChain(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["base"] = &ChainConfig{Enabled: true}
+	store := relayer.poolStore.(*fakePoolStore)
+	store.pools = append(store.pools, &PoolState{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 500000})
+
+	event := &LoanApprovalEvent{
+		TokenID:  big.NewInt(7),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(1000),
+	}
+
+	err := relayer.handleLoanApproval(event)
+	assert.NoError(t, err)
+	pending := pendingMessages(t, relayer)
+	assert.Len(t, pending, 1)
+
+	message := pending[0]
+	assert.Equal(t, "base", message.ChainID)
+	assert.Len(t, store.recorded, 1)
+	assert.Equal(t, "7", store.recorded[0].LoanID)
+	assert.Equal(t, "pool_base", store.recorded[0].PoolID)
+}
+
+func TestTrustedRelayer_HandleLoanApproval_PoolTakenConcurrently(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["base"] = &ChainConfig{Enabled: true}
+	store := relayer.poolStore.(*fakePoolStore)
+	store.pools = append(store.pools, &PoolState{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 500000})
+	store.taken = map[string]bool{"pool_base": true}
+
+	event := &LoanApprovalEvent{
+		TokenID:  big.NewInt(8),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(1000),
+	}
+
+	// The larger Base pool is filled by another allocation, so the loan goes to Ethereum
+	err := relayer.handleLoanApproval(event)
+	assert.NoError(t, err)
+	assert.Len(t, store.recorded, 1)
+	assert.Equal(t, "pool_eth", store.recorded[0].PoolID)
+	pending := pendingMessages(t, relayer)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "ethereum", pending[0].ChainID)
+}
+
+func TestTrustedRelayer_HandleLoanApproval_RetryKeepsAllocation(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["ethereum"].Type = config.ChainTypeEVM
+	store := relayer.poolStore.(*fakePoolStore)
+
+	event := &LoanApprovalEvent{
+		TokenID:  big.NewInt(7),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(1000),
+	}
+
+	// The chain has no token address, so the loan is allocated but not queued
+	assert.Error(t, relayer.handleLoanApproval(event))
+	assert.Empty(t, pendingMessages(t, relayer))
+	assert.Len(t, store.recorded, 1)
+
+	// A cheaper pool now exists, but the retry keeps the recorded one
+	relayer.chainConfigs["base"] = &ChainConfig{Enabled: true}
+	store.pools = append(store.pools, &PoolState{PoolID: "pool_base", ChainID: "base", Asset: "USDC", TotalLiquidity: 500000})
+	relayer.chainConfigs["ethereum"].TokenAddress = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+	assert.NoError(t, relayer.handleLoanApproval(event))
+	pending := pendingMessages(t, relayer)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "ethereum", pending[0].ChainID)
+	}
+	assert.Len(t, store.recorded, 1)
+}
+
+func TestTrustedRelayer_HandleLoanApproval_NoLiquidity(t *testing.T) {
+	relayer := newTestRelayer(t)
+
+	event := &LoanApprovalEvent{
+		TokenID:  big.NewInt(8),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(1000000),
+	}
+
+	err := relayer.handleLoanApproval(event)
+	assert.ErrorIs(t, err, ErrNoEligiblePool)
+	assert.Len(t, pendingMessages(t, relayer), 0)
+}
+
+func TestTrustedRelayer_HandleRepayment(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["solana"] = &ChainConfig{Type: config.ChainTypeSolana, Enabled: true}
+	loans := relayer.loans.(*fakeLoanStore)
+	loans.fundingChains["7"] = "ethereum"
+	loans.fundingChains["8"] = "solana"
+
+	payer := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	event := &RepaymentEvent{
+		TokenID:     big.NewInt(7),
+		Amount:      big.NewInt(400),
+		TotalRepaid: big.NewInt(400),
+		Payer:       payer,
+		Timestamp:   time.Unix(1700000000, 0),
+	}
+	assert.NoError(t, relayer.handleHederaEvent(event))
+
+	pending := pendingMessages(t, relayer)
+	assert.Len(t, pending, 1)
+	message := pending[0]
+	assert.Equal(t, MessageTypeRepaymentConfirmation, message.Type)
+	assert.Equal(t, "ethereum", message.ChainID)
+
+	decoded, err := DecodePayload(message.Type, message.PayloadVersion, message.Payload)
+	assert.NoError(t, err)
+	assert.Equal(t, &RepaymentConfirmationPayload{
+		LoanID:      big.NewInt(7),
+		Payer:       payer,
+		Amount:      big.NewInt(400),
+		TotalRepaid: big.NewInt(400),
+		Timestamp:   1700000000,
+	}, decoded)
+
+	// A replayed repayment maps to the same message; the next one is new
+	assert.NoError(t, relayer.handleHederaEvent(event))
+	assert.Len(t, pendingMessages(t, relayer), 1)
+	assert.NoError(t, relayer.handleHederaEvent(&RepaymentEvent{
+		TokenID: big.NewInt(7), Amount: big.NewInt(600), TotalRepaid: big.NewInt(1000), Payer: payer,
+	}))
+	assert.Len(t, pendingMessages(t, relayer), 2)
+
+	// Pools without a repayment instruction are skipped
+	assert.NoError(t, relayer.handleHederaEvent(&RepaymentEvent{
+		TokenID: big.NewInt(8), Amount: big.NewInt(100), TotalRepaid: big.NewInt(100), Payer: payer,
+	}))
+	assert.Len(t, pendingMessages(t, relayer), 2)
+
+	// Unallocated loans are reported so the event is retried
+	assert.Error(t, relayer.handleHederaEvent(&RepaymentEvent{
+		TokenID: big.NewInt(9), Amount: big.NewInt(100), TotalRepaid: big.NewInt(100), Payer: payer,
+	}))
+}
+
+func TestTrustedRelayer_HandleLoanDisbursement(t *testing.T) {
+	relayer := newTestRelayer(t)
+	loans := relayer.loans.(*fakeLoanStore)
+	loans.fundingChains["7"] = "ethereum"
+
+	disbursedAt := time.Unix(1700000000, 0)
+	assert.NoError(t, relayer.handleHederaEvent(&LoanDisbursementEvent{
+		TokenID:   big.NewInt(7),
+		Amount:    big.NewInt(1000),
+		Merchant:  common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Timestamp: disbursedAt,
+	}))
+	assert.Equal(t, disbursedAt, loans.disbursed["7"])
+	assert.Len(t, pendingMessages(t, relayer), 0)
+
+	assert.Error(t, relayer.handleHederaEvent(&LoanDisbursementEvent{TokenID: big.NewInt(9), Amount: big.NewInt(1)}))
 }
@@ -0,0 +1,250 @@
+package relayer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Signer types, selected by RELAYER_SIGNER
+const (
+	SignerTypeLocal    = "local"
+	SignerTypeKeystore = "keystore"
+	SignerTypeRemote   = "remote"
+)
+
+// Remote signer APIs, selected by RELAYER_REMOTE_SIGNER_API
+const (
+	RemoteSignerWeb3Signer = "web3signer"
+	RemoteSignerClef       = "clef"
+)
+
+// Signer holds the relayer's EVM key. It signs source chain transactions and relayer
+// messages, so the key itself can stay in an encrypted keystore or a remote signer.
+type Signer interface {
+	blockchain.EVMSigner
+	// SignTypedData signs the EIP-712 hash of the data and returns a 65-byte signature
+	// with v = 27 or 28.
+	SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error)
+}
+
+// NewSigner creates the signer selected by RELAYER_SIGNER
+func NewSigner(cfg *config.Config) (Signer, error) {
+	switch cfg.RelayerSigner {
+	case "", SignerTypeLocal:
+		key, err := crypto.HexToECDSA(cfg.RelayerPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return NewLocalSigner(key), nil
+	case SignerTypeKeystore:
+		return LoadKeystoreSigner(cfg.RelayerKeystorePath, cfg.RelayerKeystorePasswordFile)
+	case SignerTypeRemote:
+		return NewRemoteSigner(cfg.RelayerRemoteSignerURL, cfg.RelayerRemoteSignerAPI, cfg.RelayerAddress)
+	default:
+		return nil, fmt.Errorf("unknown relayer signer: %s", cfg.RelayerSigner)
+	}
+}
+
+// LocalSigner signs with a key held in memory. It backs the keystore signer and is
+// used directly in development and tests.
+type LocalSigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewLocalSigner creates a signer for the key
+func NewLocalSigner(key *ecdsa.PrivateKey) *LocalSigner {
+	return &LocalSigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// GenerateLocalSigner creates a signer with a new random key
+func GenerateLocalSigner() (*LocalSigner, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return NewLocalSigner(key), nil
+}
+
+func (s *LocalSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *LocalSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+func (s *LocalSigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+	signature, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign typed data: %w", err)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// LoadKeystoreSigner decrypts a geth keystore JSON file with the password in
+// passwordFile. The key is only decrypted in memory; neither it nor the password has to
+// be set in the environment.
+func LoadKeystoreSigner(path, passwordFile string) (*LocalSigner, error) {
+	if path == "" || passwordFile == "" {
+		return nil, fmt.Errorf("keystore signer requires a keystore file and a password file")
+	}
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore password: %w", err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, strings.TrimRight(string(password), "\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore: %w", err)
+	}
+	return NewLocalSigner(key.PrivateKey), nil
+}
+
+// RemoteSigner signs over JSON-RPC with a Web3Signer or Clef instance that holds the
+// key. Every signature it returns is checked against the configured address.
+type RemoteSigner struct {
+	client              *rpc.Client
+	address             common.Address
+	signTxMethod        string
+	signTypedDataMethod string
+}
+
+// NewRemoteSigner creates a signer for the account at address on the remote signer.
+// api is RemoteSignerWeb3Signer (the default) or RemoteSignerClef.
+func NewRemoteSigner(url, api, address string) (*RemoteSigner, error) {
+	if url == "" {
+		return nil, fmt.Errorf("remote signer URL is not configured")
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("remote signer requires the relayer address, got %q", address)
+	}
+
+	signer := &RemoteSigner{address: common.HexToAddress(address)}
+	switch api {
+	case "", RemoteSignerWeb3Signer:
+		signer.signTxMethod = "eth_signTransaction"
+		signer.signTypedDataMethod = "eth_signTypedData"
+	case RemoteSignerClef:
+		signer.signTxMethod = "account_signTransaction"
+		signer.signTypedDataMethod = "account_signTypedData"
+	default:
+		return nil, fmt.Errorf("unknown remote signer API: %s", api)
+	}
+
+	client, err := rpc.DialHTTP(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+	signer.client = client
+	return signer, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := map[string]interface{}{
+		"from":    s.address,
+		"to":      tx.To(),
+		"gas":     hexutil.Uint64(tx.Gas()),
+		"value":   (*hexutil.Big)(tx.Value()),
+		"nonce":   hexutil.Uint64(tx.Nonce()),
+		"data":    hexutil.Bytes(tx.Data()),
+		"chainId": (*hexutil.Big)(chainID),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	}
+
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, s.signTxMethod, args); err != nil {
+		return nil, fmt.Errorf("remote signer failed to sign transaction: %w", err)
+	}
+
+	// Web3Signer returns the raw transaction, Clef an object holding it
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		var signed struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &signed); err != nil {
+			return nil, fmt.Errorf("unexpected remote signer response: %w", err)
+		}
+		raw = signed.Raw
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("failed to decode signed transaction: %w", err)
+	}
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, fmt.Errorf("remote signer returned a different transaction")
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signature: %w", err)
+	}
+	if sender != s.address {
+		return nil, fmt.Errorf("remote signer signed with %s, want %s", sender.Hex(), s.address.Hex())
+	}
+	return signed, nil
+}
+
+func (s *RemoteSigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := s.client.CallContext(ctx, &signature, s.signTypedDataMethod, s.address, data); err != nil {
+		return nil, fmt.Errorf("remote signer failed to sign typed data: %w", err)
+	}
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("remote signature is %d bytes", len(signature))
+	}
+	if signature[crypto.RecoveryIDOffset] < 27 {
+		signature[crypto.RecoveryIDOffset] += 27
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+	recoverable := append([]byte(nil), signature...)
+	recoverable[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(hash, recoverable)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != s.address {
+		return nil, fmt.Errorf("remote signer signed with %s, want %s", signer.Hex(), s.address.Hex())
+	}
+	return signature, nil
+}
@@ -0,0 +1,189 @@
+package relayer
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSignTxArgs are the transaction fields remote signers accept
+type fakeSignTxArgs struct {
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// fakeRemoteSigner answers the signing calls of Web3Signer (eth namespace) and Clef
+// (account namespace) with a local key.
+type fakeRemoteSigner struct {
+	key  *LocalSigner
+	clef bool
+}
+
+func (f *fakeRemoteSigner) SignTransaction(args fakeSignTxArgs) (interface{}, error) {
+	var inner types.TxData
+	if args.MaxFeePerGas != nil {
+		inner = &types.DynamicFeeTx{
+			ChainID:   args.ChainID.ToInt(),
+			Nonce:     uint64(args.Nonce),
+			GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			Gas:       uint64(args.Gas),
+			To:        args.To,
+			Value:     args.Value.ToInt(),
+			Data:      args.Data,
+		}
+	} else {
+		inner = &types.LegacyTx{
+			Nonce:    uint64(args.Nonce),
+			GasPrice: args.GasPrice.ToInt(),
+			Gas:      uint64(args.Gas),
+			To:       args.To,
+			Value:    args.Value.ToInt(),
+			Data:     args.Data,
+		}
+	}
+	signed, err := f.key.SignTx(context.Background(), types.NewTx(inner), args.ChainID.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if f.clef {
+		return map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signed}, nil
+	}
+	return hexutil.Bytes(raw), nil
+}
+
+func (f *fakeRemoteSigner) SignTypedData(address common.Address, data apitypes.TypedData) (hexutil.Bytes, error) {
+	return f.key.SignTypedData(context.Background(), data)
+}
+
+func newFakeRemoteSigner(t *testing.T, key *LocalSigner, api string) string {
+	server := rpc.NewServer()
+	namespace := "eth"
+	if api == RemoteSignerClef {
+		namespace = "account"
+	}
+	require.NoError(t, server.RegisterName(namespace, &fakeRemoteSigner{key: key, clef: api == RemoteSignerClef}))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+func TestRemoteSigner_SignsTransactionsAndMessages(t *testing.T) {
+	key, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	adapter := blockchain.NewEVMAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453}, nil)
+
+	for _, api := range []string{RemoteSignerWeb3Signer, RemoteSignerClef} {
+		signer, err := NewRemoteSigner(newFakeRemoteSigner(t, key, api), api, key.Address().Hex())
+		require.NoError(t, err)
+
+		// Source chain transactions are signed through the adapter
+		nonce := uint64(3)
+		tx, err := adapter.BuildTransaction(context.Background(), &blockchain.TxRequest{
+			To:       "0x0987654321098765432109876543210987654321",
+			Nonce:    &nonce,
+			GasLimit: 100000,
+			Data:     []byte{0x01, 0x02},
+			Fee:      &blockchain.FeeEstimate{GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)},
+		})
+		require.NoError(t, err)
+		signed, err := adapter.SignTransaction(context.Background(), tx, signer)
+		require.NoError(t, err, api)
+		native := signed.Native.(*types.Transaction)
+		sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(8453)), native)
+		require.NoError(t, err)
+		assert.Equal(t, key.Address(), sender)
+		assert.Equal(t, uint64(3), signed.Nonce)
+
+		// Relayer messages verify like locally signed ones
+		message := newTestSignedMessage(t, signer, 1, time.Now().Add(time.Hour))
+		verifier := NewMessageVerifier(testSigningDomain, 30101, SignerSet{Version: 1, Signers: []common.Address{key.Address()}})
+		_, err = verifier.VerifyMessage(message)
+		assert.NoError(t, err, api)
+	}
+}
+
+func TestRemoteSigner_RejectsSignaturesFromAnotherKey(t *testing.T) {
+	key, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	other, err := GenerateLocalSigner()
+	require.NoError(t, err)
+
+	signer, err := NewRemoteSigner(newFakeRemoteSigner(t, other, RemoteSignerWeb3Signer), "", key.Address().Hex())
+	require.NoError(t, err)
+
+	message := &SignedMessage{MessageType: MessageTypeLoanDisbursement, Payload: []byte{1}, Nonce: big.NewInt(1)}
+	assert.Error(t, message.Sign(context.Background(), testSigningDomain, signer))
+
+	to := common.HexToAddress("0x0987654321098765432109876543210987654321")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000, To: &to, Value: big.NewInt(0)})
+	_, err = signer.SignTx(context.Background(), tx, big.NewInt(1))
+	assert.Error(t, err)
+
+	_, err = NewRemoteSigner("http://localhost:9000", "", "")
+	assert.Error(t, err)
+	_, err = NewRemoteSigner("http://localhost:9000", "vault", key.Address().Hex())
+	assert.Error(t, err)
+}
+
+func TestNewSigner_Keystore(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	id, err := uuid.NewRandom()
+	require.NoError(t, err)
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "correct horse", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keystorePath := filepath.Join(dir, "relayer.json")
+	passwordPath := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(keystorePath, keyJSON, 0o600))
+	require.NoError(t, os.WriteFile(passwordPath, []byte("correct horse\n"), 0o600))
+
+	cfg := &config.Config{RelayerSigner: SignerTypeKeystore, RelayerKeystorePath: keystorePath, RelayerKeystorePasswordFile: passwordPath}
+	signer, err := NewSigner(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), signer.Address())
+
+	require.NoError(t, os.WriteFile(passwordPath, []byte("wrong"), 0o600))
+	_, err = NewSigner(cfg)
+	assert.Error(t, err)
+
+	_, err = NewSigner(&config.Config{RelayerSigner: SignerTypeKeystore})
+	assert.Error(t, err)
+	_, err = NewSigner(&config.Config{RelayerSigner: "hsm"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,716 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+// Submission limits enforced per chain
+const (
+	LimitMessagesPerMinute = "messages_per_minute"
+	LimitDisbursedPerHour  = "disbursed_per_hour"
+	LimitDisbursedPerDay   = "disbursed_per_day"
+	LimitGasSpendPerDay    = "gas_spend_per_day"
+	// LimitUnreadableDisbursement holds a disbursement whose amount cannot be read
+	LimitUnreadableDisbursement = "unreadable_disbursement"
+	// LimitUsageUnavailable holds sends while the chain's usage cannot be loaded
+	LimitUsageUnavailable = "usage_unavailable"
+)
+
+// LimitExceeded describes a chain limit that held a send back. Sends to the chain are
+// paused until RetryAt, when enough of the usage counted against the limit has expired.
+// Reason explains holds that are not about usage, such as an unreadable amount.
+type LimitExceeded struct {
+	ChainID string    `json:"chain_id"`
+	Limit   string    `json:"limit"`
+	Used    float64   `json:"used"`
+	Max     float64   `json:"max"`
+	Reason  string    `json:"reason,omitempty"`
+	RetryAt time.Time `json:"retry_at"`
+}
+
+func (e *LimitExceeded) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s send held by %s: %s", e.ChainID, e.Limit, e.Reason)
+	}
+	return fmt.Sprintf("%s limit of %s reached: %g of %g", e.ChainID, e.Limit, e.Used, e.Max)
+}
+
+// ChainLimitUsage is a chain's usage of its submission limits
+type ChainLimitUsage struct {
+	ChainID            string             `json:"chain_id"`
+	Limits             config.ChainLimits `json:"limits"`
+	MessagesLastMinute int                `json:"messages_last_minute"`
+	DisbursedLastHour  float64            `json:"disbursed_last_hour"`
+	DisbursedLastDay   float64            `json:"disbursed_last_day"`
+	GasSpentLastDay    float64            `json:"gas_spent_last_day"`
+	// Paused is the limit sends to the chain are paused by, if any
+	Paused *LimitExceeded `json:"paused,omitempty"`
+}
+
+// Usage kinds counted against the limits
+const (
+	usageMessages  = "messages"
+	usageDisbursed = "disbursed"
+	usageGas       = "gas"
+)
+
+// usageWindow is the longest limit window
+const usageWindow = 24 * time.Hour
+
+// usageRetryDelay is how long a send waits when its chain's usage cannot be loaded
+const usageRetryDelay = time.Minute
+
+// UsageRecord is an amount counted against a chain's limits at a point in time
+type UsageRecord struct {
+	ID         int64     `json:"id,omitempty"`
+	ChainID    string    `json:"chain_id"`
+	Kind       string    `json:"kind"`
+	Amount     float64   `json:"amount"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// UsageCap is a limit on the usage of one kind within a window
+type UsageCap struct {
+	Limit  string
+	Kind   string
+	Window time.Duration
+	Max    float64
+}
+
+// LimitUsageStore keeps the usage counted against the submission limits, so every
+// relayer instance enforces them against the same totals.
+type LimitUsageStore interface {
+	// Add records usage and may drop records older than the longest limit window
+	Add(ctx context.Context, record *UsageRecord) error
+	// Reserve atomically adds records of one chain, recorded at the same time, if the
+	// chain's usage stays within every cap with them, and returns their IDs. Otherwise
+	// it adds nothing and returns the first cap they would exceed.
+	Reserve(ctx context.Context, records []*UsageRecord, caps []UsageCap) ([]int64, *UsageCap, error)
+	// Remove deletes reserved records
+	Remove(ctx context.Context, ids []int64) error
+	// Since returns the chain's usage recorded after since, oldest first
+	Since(ctx context.Context, chainID string, since time.Time) ([]*UsageRecord, error)
+}
+
+// UsageReservation is the usage a send was counted with before it was sent. It is
+// released if the send fails.
+type UsageReservation struct {
+	chainID string
+	ids     []int64
+}
+
+// chainUsage is a chain's usage over the longest limit window, oldest first
+type chainUsage struct {
+	messages  []*UsageRecord
+	disbursed []*UsageRecord
+	gas       []*UsageRecord
+}
+
+// records returns the usage of the kind
+func (u *chainUsage) records(kind string) []*UsageRecord {
+	switch kind {
+	case usageMessages:
+		return u.messages
+	case usageDisbursed:
+		return u.disbursed
+	default:
+		return u.gas
+	}
+}
+
+// SubmissionLimiter enforces the per-chain limits on messages sent, loan asset disbursed
+// and gas spent. Usage is kept in the shared usage store; which chains are paused is
+// known to each instance from its own checks.
+type SubmissionLimiter struct {
+	mu     sync.Mutex
+	limits map[string]config.ChainLimits
+	store  LimitUsageStore
+	paused map[string]*LimitExceeded
+	now    func() time.Time
+}
+
+// NewSubmissionLimiter creates a limiter for the limits of the configured chains
+func NewSubmissionLimiter(chains map[string]*ChainConfig, store LimitUsageStore) *SubmissionLimiter {
+	limits := make(map[string]config.ChainLimits, len(chains))
+	for chainID, chain := range chains {
+		limits[chainID] = chain.Limits
+	}
+	return &SubmissionLimiter{
+		limits: limits,
+		store:  store,
+		paused: make(map[string]*LimitExceeded),
+		now:    time.Now,
+	}
+}
+
+// Reserve counts sending a message to chainID, submitted on txChainID and disbursing the
+// given amount, against the limits before it is sent. The destination's usage is checked
+// and recorded in one step in the usage store, so concurrent instances cannot both fit
+// under the same limit. It returns the first limit the send would exceed instead. Both
+// chains are paused by the limits they exceed until a reservation for them succeeds. A
+// chain whose usage cannot be loaded is held back rather than sent to uncounted.
+func (l *SubmissionLimiter) Reserve(ctx context.Context, chainID, txChainID string, disbursed float64) (*UsageReservation, *LimitExceeded) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var reservation *UsageReservation
+	var exceeded *LimitExceeded
+	if txChainID != "" {
+		exceeded = l.checkGas(ctx, txChainID, now)
+	}
+	if exceeded == nil {
+		reservation, exceeded = l.reserveDestination(ctx, chainID, disbursed, now)
+	}
+	if exceeded != nil {
+		l.paused[exceeded.ChainID] = exceeded
+		return nil, exceeded
+	}
+	delete(l.paused, chainID)
+	if txChainID != "" {
+		delete(l.paused, txChainID)
+	}
+	return reservation, nil
+}
+
+// Release removes the usage reserved for a send that failed
+func (l *SubmissionLimiter) Release(ctx context.Context, reservation *UsageReservation) {
+	if reservation == nil || len(reservation.ids) == 0 {
+		return
+	}
+	if err := l.store.Remove(ctx, reservation.ids); err != nil {
+		log.Error().Err(err).Str("chain_id", reservation.chainID).Msg("Failed to release submission limit usage")
+	}
+}
+
+// HoldUnreadable holds back a disbursement to a chain with disbursement limits whose
+// amount cannot be read, so it is never sent without being counted. It returns nil for
+// chains without disbursement limits.
+func (l *SubmissionLimiter) HoldUnreadable(chainID string, cause error) *LimitExceeded {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := l.limits[chainID]
+	if limits.MaxDisbursedPerHour <= 0 && limits.MaxDisbursedPerDay <= 0 {
+		return nil
+	}
+	exceeded := &LimitExceeded{
+		ChainID: chainID,
+		Limit:   LimitUnreadableDisbursement,
+		Reason:  cause.Error(),
+		RetryAt: l.now().Add(time.Hour),
+	}
+	l.paused[chainID] = exceeded
+	return exceeded
+}
+
+// load returns the chain's usage over the longest limit window, or the limit hold to
+// report when it cannot be loaded
+func (l *SubmissionLimiter) load(ctx context.Context, chainID string, now time.Time) (*chainUsage, *LimitExceeded) {
+	records, err := l.store.Since(ctx, chainID, now.Add(-usageWindow))
+	if err != nil {
+		return nil, &LimitExceeded{
+			ChainID: chainID,
+			Limit:   LimitUsageUnavailable,
+			Reason:  err.Error(),
+			RetryAt: now.Add(usageRetryDelay),
+		}
+	}
+
+	usage := &chainUsage{}
+	for _, record := range records {
+		switch record.Kind {
+		case usageMessages:
+			usage.messages = append(usage.messages, record)
+		case usageDisbursed:
+			usage.disbursed = append(usage.disbursed, record)
+		case usageGas:
+			usage.gas = append(usage.gas, record)
+		}
+	}
+	return usage, nil
+}
+
+// reserveDestination records the message and the loan asset it disburses against the
+// message and disbursement limits of the destination chain
+func (l *SubmissionLimiter) reserveDestination(ctx context.Context, chainID string, disbursed float64, now time.Time) (*UsageReservation, *LimitExceeded) {
+	limits := l.limits[chainID]
+	records := []*UsageRecord{{ChainID: chainID, Kind: usageMessages, Amount: 1, RecordedAt: now}}
+	var caps []UsageCap
+	if max := limits.MaxMessagesPerMinute; max > 0 {
+		caps = append(caps, UsageCap{Limit: LimitMessagesPerMinute, Kind: usageMessages, Window: time.Minute, Max: float64(max)})
+	}
+	if disbursed > 0 {
+		records = append(records, &UsageRecord{ChainID: chainID, Kind: usageDisbursed, Amount: disbursed, RecordedAt: now})
+		if max := limits.MaxDisbursedPerHour; max > 0 {
+			caps = append(caps, UsageCap{Limit: LimitDisbursedPerHour, Kind: usageDisbursed, Window: time.Hour, Max: max})
+		}
+		if max := limits.MaxDisbursedPerDay; max > 0 {
+			caps = append(caps, UsageCap{Limit: LimitDisbursedPerDay, Kind: usageDisbursed, Window: 24 * time.Hour, Max: max})
+		}
+	}
+
+	ids, exceededCap, err := l.store.Reserve(ctx, records, caps)
+	switch {
+	case err != nil && len(caps) == 0:
+		// The usage of a chain without limits is only reported, so the send goes ahead
+		log.Error().Err(err).Str("chain_id", chainID).Msg("Failed to record submission limit usage")
+		return nil, nil
+	case err != nil:
+		return nil, &LimitExceeded{
+			ChainID: chainID,
+			Limit:   LimitUsageUnavailable,
+			Reason:  err.Error(),
+			RetryAt: now.Add(usageRetryDelay),
+		}
+	case exceededCap != nil:
+		amount := 1.0
+		if exceededCap.Kind == usageDisbursed {
+			amount = disbursed
+		}
+		return nil, l.capExceeded(ctx, chainID, exceededCap, amount, now)
+	}
+	return &UsageReservation{chainID: chainID, ids: ids}, nil
+}
+
+// capExceeded describes a cap that amount more did not fit under and when it will
+func (l *SubmissionLimiter) capExceeded(ctx context.Context, chainID string, limit *UsageCap, amount float64, now time.Time) *LimitExceeded {
+	usage, exceeded := l.load(ctx, chainID, now)
+	if exceeded != nil {
+		return exceeded
+	}
+	used, retryAt, ok := checkWindow(usage.records(limit.Kind), limit.Window, now, fitsUnder(amount, limit.Max))
+	if ok {
+		// Usage expired since the reservation was refused
+		retryAt = now
+	}
+	return &LimitExceeded{ChainID: chainID, Limit: limit.Limit, Used: used, Max: limit.Max, RetryAt: retryAt}
+}
+
+// checkGas checks the gas spend of the chain a transaction is submitted on. The fee of
+// the next transaction is not known until it is mined, so sends stop once the spend
+// reaches the limit.
+func (l *SubmissionLimiter) checkGas(ctx context.Context, chainID string, now time.Time) *LimitExceeded {
+	max := l.limits[chainID].MaxGasSpendPerDay
+	if max <= 0 {
+		return nil
+	}
+	usage, exceeded := l.load(ctx, chainID, now)
+	if exceeded != nil {
+		return exceeded
+	}
+	below := func(used float64) bool { return used < max }
+	if used, retryAt, ok := checkWindow(usage.gas, 24*time.Hour, now, below); !ok {
+		return &LimitExceeded{ChainID: chainID, Limit: LimitGasSpendPerDay, Used: used, Max: max, RetryAt: retryAt}
+	}
+	return nil
+}
+
+// checkWindow sums the records within the window and reports whether the sum fits. If
+// it does not, retryAt is when enough of the records expire for it to fit, or a full
+// window from now if nothing would.
+func checkWindow(records []*UsageRecord, window time.Duration, now time.Time, fits func(used float64) bool) (used float64, retryAt time.Time, ok bool) {
+	used = windowSum(records, window, now)
+	if fits(used) {
+		return used, time.Time{}, true
+	}
+
+	start := now.Add(-window)
+	remaining := used
+	for _, record := range records {
+		if !record.RecordedAt.After(start) {
+			continue
+		}
+		remaining -= record.Amount
+		if fits(remaining) {
+			return used, record.RecordedAt.Add(window), false
+		}
+	}
+	return used, now.Add(window), false
+}
+
+// fitsUnder reports whether amount more fits under max
+func fitsUnder(amount, max float64) func(used float64) bool {
+	return func(used float64) bool { return used+amount <= max }
+}
+
+// windowSum sums the records within the window
+func windowSum(records []*UsageRecord, window time.Duration, now time.Time) float64 {
+	start := now.Add(-window)
+	var sum float64
+	for _, record := range records {
+		if record.RecordedAt.After(start) {
+			sum += record.Amount
+		}
+	}
+	return sum
+}
+
+// RecordGasSpend counts a fee paid by a relayer transaction on the chain, in its
+// native coin
+func (l *SubmissionLimiter) RecordGasSpend(ctx context.Context, chainID string, fee float64) {
+	if fee <= 0 {
+		return
+	}
+	l.add(ctx, &UsageRecord{ChainID: chainID, Kind: usageGas, Amount: fee, RecordedAt: l.now()})
+}
+
+func (l *SubmissionLimiter) add(ctx context.Context, record *UsageRecord) {
+	if err := l.store.Add(ctx, record); err != nil {
+		log.Error().
+			Err(err).
+			Str("chain_id", record.ChainID).
+			Str("kind", record.Kind).
+			Float64("amount", record.Amount).
+			Msg("Failed to record submission limit usage")
+	}
+}
+
+// PausedChains returns the chains whose sends are paused by a limit, ordered by chain ID
+func (l *SubmissionLimiter) PausedChains() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paused := make([]string, 0, len(l.paused))
+	for chainID := range l.paused {
+		paused = append(paused, chainID)
+	}
+	sort.Strings(paused)
+	return paused
+}
+
+// Usage returns every chain's usage of its limits, ordered by chain ID. Chains whose
+// usage cannot be loaded report none.
+func (l *SubmissionLimiter) Usage(ctx context.Context) []ChainLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	chainIDs := make(map[string]bool, len(l.limits))
+	for chainID := range l.limits {
+		chainIDs[chainID] = true
+	}
+	for chainID := range l.paused {
+		chainIDs[chainID] = true
+	}
+
+	now := l.now()
+	result := make([]ChainLimitUsage, 0, len(chainIDs))
+	for chainID := range chainIDs {
+		entry := ChainLimitUsage{
+			ChainID: chainID,
+			Limits:  l.limits[chainID],
+			Paused:  l.paused[chainID],
+		}
+		if usage, exceeded := l.load(ctx, chainID, now); exceeded != nil {
+			log.Warn().Str("chain_id", chainID).Str("error", exceeded.Reason).Msg("Failed to load submission limit usage")
+		} else {
+			entry.MessagesLastMinute = int(windowSum(usage.messages, time.Minute, now))
+			entry.DisbursedLastHour = windowSum(usage.disbursed, time.Hour, now)
+			entry.DisbursedLastDay = windowSum(usage.disbursed, 24*time.Hour, now)
+			entry.GasSpentLastDay = windowSum(usage.gas, 24*time.Hour, now)
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ChainID < result[j].ChainID })
+	return result
+}
+
+// MemoryLimitUsageStore is an in-process LimitUsageStore for tests and development.
+type MemoryLimitUsageStore struct {
+	mu      sync.Mutex
+	records []*UsageRecord
+	lastID  int64
+}
+
+// NewMemoryLimitUsageStore creates an empty in-memory usage store
+func NewMemoryLimitUsageStore() *MemoryLimitUsageStore {
+	return &MemoryLimitUsageStore{}
+}
+
+func (s *MemoryLimitUsageStore) Add(ctx context.Context, record *UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(record)
+	return nil
+}
+
+func (s *MemoryLimitUsageStore) Reserve(ctx context.Context, records []*UsageRecord, caps []UsageCap) ([]int64, *UsageCap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+	chainID, now := records[0].ChainID, records[0].RecordedAt
+	for i, limit := range caps {
+		var used float64
+		for _, r := range s.records {
+			if r.ChainID == chainID && r.Kind == limit.Kind && r.RecordedAt.After(now.Add(-limit.Window)) {
+				used += r.Amount
+			}
+		}
+		for _, r := range records {
+			if r.Kind == limit.Kind {
+				used += r.Amount
+			}
+		}
+		if used > limit.Max {
+			return nil, &caps[i], nil
+		}
+	}
+
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, s.add(record))
+	}
+	return ids, nil, nil
+}
+
+func (s *MemoryLimitUsageStore) Remove(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	kept := s.records[:0]
+	for _, r := range s.records {
+		if !removed[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	s.records = kept
+	return nil
+}
+
+// add stores a copy of the record, drops the records past the longest window and
+// returns the new record's ID
+func (s *MemoryLimitUsageStore) add(record *UsageRecord) int64 {
+	s.lastID++
+	copied := *record
+	copied.ID = s.lastID
+	s.records = append(s.records, &copied)
+
+	start := record.RecordedAt.Add(-usageWindow)
+	kept := s.records[:0]
+	for _, r := range s.records {
+		if r.RecordedAt.After(start) {
+			kept = append(kept, r)
+		}
+	}
+	s.records = kept
+	return copied.ID
+}
+
+func (s *MemoryLimitUsageStore) Since(ctx context.Context, chainID string, since time.Time) ([]*UsageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*UsageRecord
+	for _, r := range s.records {
+		if r.ChainID == chainID && r.RecordedAt.After(since) {
+			copied := *r
+			records = append(records, &copied)
+		}
+	}
+	return records, nil
+}
+
+// SupabaseLimitUsageStore stores limit usage in the relayer_limit_usage table
+type SupabaseLimitUsageStore struct {
+	db *supabase.Client
+}
+
+// NewSupabaseLimitUsageStore creates a new Supabase-backed usage store
+func NewSupabaseLimitUsageStore(db *supabase.Client) *SupabaseLimitUsageStore {
+	return &SupabaseLimitUsageStore{db: db}
+}
+
+func (s *SupabaseLimitUsageStore) Add(ctx context.Context, record *UsageRecord) error {
+	row := map[string]interface{}{
+		"chain_id":    record.ChainID,
+		"kind":        record.Kind,
+		"amount":      record.Amount,
+		"recorded_at": record.RecordedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if _, _, err := s.db.From("relayer_limit_usage").Insert(row, false, "", "", "").Execute(); err != nil {
+		return fmt.Errorf("failed to record limit usage: %w", err)
+	}
+	s.prune(record.ChainID, record.RecordedAt)
+	return nil
+}
+
+// Reserve checks and records the usage in one transaction with the
+// reserve_relayer_limit_usage function, which serializes reservations per chain.
+func (s *SupabaseLimitUsageStore) Reserve(ctx context.Context, records []*UsageRecord, caps []UsageCap) ([]int64, *UsageCap, error) {
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+	chainID, now := records[0].ChainID, records[0].RecordedAt
+	usage := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		usage = append(usage, map[string]interface{}{"kind": record.Kind, "amount": record.Amount})
+	}
+	limits := make([]map[string]interface{}, 0, len(caps))
+	for _, limit := range caps {
+		limits = append(limits, map[string]interface{}{
+			"limit":          limit.Limit,
+			"kind":           limit.Kind,
+			"window_seconds": limit.Window.Seconds(),
+			"max":            limit.Max,
+		})
+	}
+
+	// Note: The Rpc method in this library version returns only a string.
+	// Errors are handled by returning an empty string, which will then fail to unmarshal.
+	result := s.db.Rpc("reserve_relayer_limit_usage", "", map[string]interface{}{
+		"p_chain_id":    chainID,
+		"p_recorded_at": now.UTC().Format(time.RFC3339Nano),
+		"p_records":     usage,
+		"p_caps":        limits,
+	})
+
+	var reserved struct {
+		IDs      []int64 `json:"ids"`
+		Exceeded string  `json:"exceeded"`
+	}
+	if err := json.Unmarshal([]byte(result), &reserved); err != nil {
+		return nil, nil, fmt.Errorf("failed to reserve limit usage: %s", result)
+	}
+	if reserved.Exceeded != "" {
+		for i := range caps {
+			if caps[i].Limit == reserved.Exceeded {
+				return nil, &caps[i], nil
+			}
+		}
+		return nil, nil, fmt.Errorf("failed to reserve limit usage: unknown limit %s", reserved.Exceeded)
+	}
+	s.prune(chainID, now)
+	return reserved.IDs, nil, nil
+}
+
+func (s *SupabaseLimitUsageStore) Remove(ctx context.Context, ids []int64) error {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, strconv.FormatInt(id, 10))
+	}
+	if _, _, err := s.db.From("relayer_limit_usage").Delete("", "").In("id", values).Execute(); err != nil {
+		return fmt.Errorf("failed to remove limit usage: %w", err)
+	}
+	return nil
+}
+
+// prune drops the chain's usage past the longest window, which no longer counts
+// against any limit
+func (s *SupabaseLimitUsageStore) prune(chainID string, now time.Time) {
+	cutoff := now.Add(-usageWindow).UTC().Format(time.RFC3339Nano)
+	if _, _, err := s.db.From("relayer_limit_usage").Delete("", "").Eq("chain_id", chainID).Lt("recorded_at", cutoff).Execute(); err != nil {
+		log.Warn().Err(err).Str("chain_id", chainID).Msg("Failed to prune limit usage")
+	}
+}
+
+func (s *SupabaseLimitUsageStore) Since(ctx context.Context, chainID string, since time.Time) ([]*UsageRecord, error) {
+	data, _, err := s.db.From("relayer_limit_usage").Select("chain_id,kind,amount,recorded_at", "", false).
+		Eq("chain_id", chainID).
+		Gt("recorded_at", since.UTC().Format(time.RFC3339Nano)).
+		Order("recorded_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load limit usage: %w", err)
+	}
+
+	var records []*UsageRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal limit usage: %w", err)
+	}
+	return records, nil
+}
+
+// nativeDecimals returns the decimals of a chain type's native coin
+func nativeDecimals(chainType string) int {
+	switch chainType {
+	case config.ChainTypeSolana:
+		return 9
+	case config.ChainTypeAptos:
+		return 8
+	default:
+		return 18
+	}
+}
+
+// reserveLimits counts the message against the submission limits before it is sent, or
+// returns the limit that sending it would exceed. The reservation must be released if
+// the message is not sent. A disbursement whose amount cannot be read is held back.
+func (tr *TrustedRelayer) reserveLimits(message *Message) (*UsageReservation, *LimitExceeded) {
+	chain, ok := tr.chainConfigs[message.ChainID]
+	if !ok {
+		return nil, nil
+	}
+	disbursed, err := tr.disbursedAmount(message)
+	var reservation *UsageReservation
+	var exceeded *LimitExceeded
+	if err != nil {
+		exceeded = tr.limits.HoldUnreadable(message.ChainID, err)
+	}
+	if exceeded == nil {
+		reservation, exceeded = tr.limits.Reserve(tr.ctx, message.ChainID, tr.txChainID(message.ChainID, chain), disbursed)
+	}
+	if exceeded != nil {
+		log.Warn().
+			Str("message_id", message.ID).
+			Str("chain_id", exceeded.ChainID).
+			Str("limit", exceeded.Limit).
+			Float64("used", exceeded.Used).
+			Float64("max", exceeded.Max).
+			Str("reason", exceeded.Reason).
+			Time("retry_at", exceeded.RetryAt).
+			Msg("Submission limit reached, pausing sends")
+		tr.recordLimitEvent(message, exceeded)
+	}
+	return reservation, exceeded
+}
+
+// disbursedAmount returns the loan asset a message disburses, in whole tokens
+func (tr *TrustedRelayer) disbursedAmount(message *Message) (float64, error) {
+	if message.Type != MessageTypeLoanDisbursement {
+		return 0, nil
+	}
+	payload, err := decodeDisbursement(message)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read disbursed amount: %w", err)
+	}
+	return tokenAmount(payload.Amount, tr.config.LoanAssetDecimals), nil
+}
+
+// recordGasSpend counts the fee of a mined relayer transaction against its chain's gas
+// limit
+func (tr *TrustedRelayer) recordGasSpend(chainID string, receipt *blockchain.Receipt) {
+	if receipt.Fee == nil {
+		return
+	}
+	decimals := nativeDecimals(config.ChainTypeEVM)
+	if chain, ok := tr.chainConfigs[chainID]; ok {
+		decimals = nativeDecimals(chain.Type)
+	}
+	tr.limits.RecordGasSpend(tr.ctx, chainID, tokenAmount(receipt.Fee, decimals))
+}
+
+// GetLimitUsage returns every chain's usage of its submission limits
+func (tr *TrustedRelayer) GetLimitUsage() []ChainLimitUsage {
+	return tr.limits.Usage(tr.ctx)
+}
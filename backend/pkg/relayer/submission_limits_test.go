@@ -0,0 +1,311 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(limits map[string]config.ChainLimits) (*SubmissionLimiter, *time.Time) {
+	chains := make(map[string]*ChainConfig, len(limits))
+	for chainID, chainLimits := range limits {
+		chains[chainID] = &ChainConfig{Limits: chainLimits}
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewSubmissionLimiter(chains, NewMemoryLimitUsageStore())
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+// reserveUsage reserves the usage of a send and returns the limit it exceeds, if any
+func reserveUsage(limiter *SubmissionLimiter, chainID, txChainID string, disbursed float64) *LimitExceeded {
+	_, exceeded := limiter.Reserve(context.Background(), chainID, txChainID, disbursed)
+	return exceeded
+}
+
+func TestSubmissionLimiter_MessagesAndDisbursements(t *testing.T) {
+	limiter, now := newTestLimiter(map[string]config.ChainLimits{
+		"base": {MaxMessagesPerMinute: 2, MaxDisbursedPerHour: 100, MaxDisbursedPerDay: 150},
+	})
+	ctx := context.Background()
+
+	require.Nil(t, reserveUsage(limiter, "base", "", 60))
+	*now = now.Add(10 * time.Second)
+	require.Nil(t, reserveUsage(limiter, "base", "", 0))
+
+	// A third message within the minute waits for the first to age out
+	exceeded := reserveUsage(limiter, "base", "", 0)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitMessagesPerMinute, exceeded.Limit)
+	assert.Equal(t, now.Add(50*time.Second), exceeded.RetryAt)
+	assert.Equal(t, []string{"base"}, limiter.PausedChains())
+
+	// So does a disbursement that would take the hour over its limit
+	*now = now.Add(time.Minute)
+	exceeded = reserveUsage(limiter, "base", "", 50)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitDisbursedPerHour, exceeded.Limit)
+	assert.Equal(t, 60.0, exceeded.Used)
+	assert.Equal(t, now.Add(-70*time.Second).Add(time.Hour), exceeded.RetryAt)
+
+	// A smaller one fits and lifts the pause
+	require.Nil(t, reserveUsage(limiter, "base", "", 40))
+	assert.Empty(t, limiter.PausedChains())
+
+	// An hour later the day's limit still applies
+	*now = now.Add(time.Hour)
+	exceeded = reserveUsage(limiter, "base", "", 60)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitDisbursedPerDay, exceeded.Limit)
+
+	usage := limiter.Usage(ctx)
+	require.Len(t, usage, 1)
+	assert.Equal(t, "base", usage[0].ChainID)
+	assert.Equal(t, 0, usage[0].MessagesLastMinute)
+	assert.Equal(t, 0.0, usage[0].DisbursedLastHour)
+	assert.Equal(t, 100.0, usage[0].DisbursedLastDay)
+	require.NotNil(t, usage[0].Paused)
+	assert.Equal(t, LimitDisbursedPerDay, usage[0].Paused.Limit)
+
+	// Usage older than a day no longer counts
+	*now = now.Add(24 * time.Hour)
+	assert.Nil(t, reserveUsage(limiter, "base", "", 60))
+}
+
+func TestSubmissionLimiter_GasSpendPausesTransactionChain(t *testing.T) {
+	limiter, now := newTestLimiter(map[string]config.ChainLimits{
+		"ethereum": {MaxGasSpendPerDay: 0.5},
+		"base":     {},
+	})
+	ctx := context.Background()
+
+	limiter.RecordGasSpend(ctx, "ethereum", 0.3)
+	require.Nil(t, reserveUsage(limiter, "base", "ethereum", 0))
+	*now = now.Add(time.Hour)
+	limiter.RecordGasSpend(ctx, "ethereum", 0.2)
+
+	// LayerZero messages to any chain pay gas on the source chain
+	exceeded := reserveUsage(limiter, "base", "ethereum", 0)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, "ethereum", exceeded.ChainID)
+	assert.Equal(t, LimitGasSpendPerDay, exceeded.Limit)
+	assert.InDelta(t, 0.5, exceeded.Used, 1e-9)
+	assert.Equal(t, now.Add(23*time.Hour), exceeded.RetryAt)
+	assert.Equal(t, []string{"ethereum"}, limiter.PausedChains())
+
+	*now = now.Add(23 * time.Hour)
+	assert.Nil(t, reserveUsage(limiter, "base", "ethereum", 0))
+	assert.Empty(t, limiter.PausedChains())
+}
+
+// failingUsageStore is a LimitUsageStore whose database is down
+type failingUsageStore struct{}
+
+func (failingUsageStore) Add(ctx context.Context, record *UsageRecord) error {
+	return errors.New("connection refused")
+}
+
+func (failingUsageStore) Reserve(ctx context.Context, records []*UsageRecord, caps []UsageCap) ([]int64, *UsageCap, error) {
+	return nil, nil, errors.New("connection refused")
+}
+
+func (failingUsageStore) Remove(ctx context.Context, ids []int64) error {
+	return errors.New("connection refused")
+}
+
+func (failingUsageStore) Since(ctx context.Context, chainID string, since time.Time) ([]*UsageRecord, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSubmissionLimiter_SharedAcrossInstances(t *testing.T) {
+	chains := map[string]*ChainConfig{"base": {Limits: config.ChainLimits{MaxDisbursedPerHour: 100}}}
+	store := NewMemoryLimitUsageStore()
+	first := NewSubmissionLimiter(chains, store)
+	second := NewSubmissionLimiter(chains, store)
+
+	require.Nil(t, reserveUsage(first, "base", "", 80))
+
+	// The other instance counts the first one's disbursement
+	exceeded := reserveUsage(second, "base", "", 30)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitDisbursedPerHour, exceeded.Limit)
+	assert.Equal(t, 80.0, exceeded.Used)
+}
+
+func TestSubmissionLimiter_ConcurrentInstancesStayWithinLimits(t *testing.T) {
+	chains := map[string]*ChainConfig{"base": {Limits: config.ChainLimits{MaxDisbursedPerHour: 100}}}
+	store := NewMemoryLimitUsageStore()
+
+	// Each instance checks and records in one step, so only ten sends of 10 fit
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 4; i++ {
+		limiter := NewSubmissionLimiter(chains, store)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if reserveUsage(limiter, "base", "", 10) == nil {
+					mu.Lock()
+					reserved++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, reserved)
+}
+
+func TestSubmissionLimiter_ReleasesFailedSend(t *testing.T) {
+	limiter, _ := newTestLimiter(map[string]config.ChainLimits{"base": {MaxDisbursedPerHour: 100}})
+	ctx := context.Background()
+
+	reservation, exceeded := limiter.Reserve(ctx, "base", "", 80)
+	require.Nil(t, exceeded)
+	require.NotNil(t, reservation)
+	require.NotNil(t, reserveUsage(limiter, "base", "", 30))
+
+	// A send that failed gives its usage back
+	limiter.Release(ctx, reservation)
+	assert.Nil(t, reserveUsage(limiter, "base", "", 30))
+	usage := limiter.Usage(ctx)
+	require.Len(t, usage, 1)
+	assert.Equal(t, 30.0, usage[0].DisbursedLastHour)
+	assert.Equal(t, 1, usage[0].MessagesLastMinute)
+}
+
+func TestSubmissionLimiter_FailsClosed(t *testing.T) {
+	chains := map[string]*ChainConfig{
+		"base":     {Limits: config.ChainLimits{MaxDisbursedPerHour: 100}},
+		"ethereum": {},
+	}
+	limiter := NewSubmissionLimiter(chains, failingUsageStore{})
+
+	// Usage that cannot be loaded holds sends to chains with limits
+	exceeded := reserveUsage(limiter, "base", "", 10)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitUsageUnavailable, exceeded.Limit)
+	assert.Contains(t, exceeded.Error(), "connection refused")
+	assert.Nil(t, reserveUsage(limiter, "ethereum", "", 10))
+
+	// So does a disbursement whose amount cannot be read, on chains with disbursement limits
+	exceeded = limiter.HoldUnreadable("base", errors.New("bad payload"))
+	require.NotNil(t, exceeded)
+	assert.Equal(t, LimitUnreadableDisbursement, exceeded.Limit)
+	assert.Nil(t, limiter.HoldUnreadable("ethereum", errors.New("bad payload")))
+	assert.Equal(t, []string{"base"}, limiter.PausedChains())
+}
+
+func TestTrustedRelayer_HoldsUnreadableDisbursement(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["ethereum"].Limits = config.ChainLimits{MaxDisbursedPerDay: 1000}
+	relayer.limits = NewSubmissionLimiter(relayer.chainConfigs, NewMemoryLimitUsageStore())
+	relayer.registerAlertRules()
+
+	message := newPendingMessage(MessageTypeLoanDisbursement, "62", "ethereum", []byte{0x01, 0x02})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+
+	held, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, held.Status)
+	assert.Equal(t, 0, held.RetryCount)
+	assert.True(t, held.NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+	assert.Equal(t, []string{"ethereum"}, relayer.limits.PausedChains())
+
+	status := relayer.GetStatus()
+	require.Len(t, status.Alerts, 1)
+	assert.Equal(t, "submission_limit_reached", status.Alerts[0].Title)
+}
+
+func TestTrustedRelayer_ReleasesLimitUsageOfFailedSend(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.chainConfigs["ethereum"].Limits = config.ChainLimits{MaxMessagesPerMinute: 5}
+	relayer.limits = NewSubmissionLimiter(relayer.chainConfigs, NewMemoryLimitUsageStore())
+
+	// No LayerZero source chain is configured, so the send fails before broadcasting
+	message := newPendingMessage(MessageTypeLoanDisbursement, "63", "ethereum", []byte{1})
+	require.NoError(t, relayer.enqueue(message))
+	relayer.processOutbox()
+	failed, err := relayer.outbox.Get(relayer.ctx, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed.RetryCount)
+
+	usage := relayer.GetLimitUsage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, 0, usage[0].MessagesLastMinute)
+}
+
+func TestTrustedRelayer_PausesSendsOverLimit(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.LayerZeroSourceChain = "ethereum"
+	relayer.chainConfigs["ethereum"].LayerZeroEID = 30101
+	relayer.chainConfigs["ethereum"].LayerZeroReceiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	relayer.chainConfigs["ethereum"].Limits = config.ChainLimits{MaxDisbursedPerHour: 1000, MaxGasSpendPerDay: 10}
+	relayer.limits = NewSubmissionLimiter(relayer.chainConfigs, NewMemoryLimitUsageStore())
+	relayer.registerAlertRules()
+
+	source := blockchain.NewFakeAdapter(config.ChainSpec{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101})
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	relayer.chains = blockchain.NewRegistry()
+	require.NoError(t, relayer.chains.Register(source))
+	lzClient, err := NewLayerZeroClient(source, relayer.signer, relayer.config)
+	require.NoError(t, err)
+	relayer.layerZeroClient = lzClient
+
+	disbursement := func(key string, amount int64) *Message {
+		payload, err := EncodePayload(MessageTypeLoanDisbursement, &LoanDisbursementPayload{
+			Token:    common.HexToAddress("0x3333333333333333333333333333333333333333"),
+			Merchant: common.HexToAddress("0x4444444444444444444444444444444444444444"),
+			Amount:   big.NewInt(amount),
+		})
+		require.NoError(t, err)
+		return newPendingMessage(MessageTypeLoanDisbursement, key, "ethereum", payload)
+	}
+
+	first := disbursement("60", 700)
+	require.NoError(t, relayer.enqueue(first))
+	relayer.processOutbox()
+	sent, err := relayer.outbox.Get(relayer.ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, sent.Status)
+
+	// The next disbursement would go over the hour's limit and waits for it instead
+	held := disbursement("61", 400)
+	require.NoError(t, relayer.enqueue(held))
+	relayer.processOutbox()
+	deferred, err := relayer.outbox.Get(relayer.ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, deferred.Status)
+	assert.Equal(t, 0, deferred.RetryCount)
+	assert.True(t, deferred.NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+	assert.Len(t, source.Sent(), 1)
+
+	status := relayer.GetStatus()
+	assert.Equal(t, HealthStatusDegraded, status.Status)
+	require.Len(t, status.Alerts, 1)
+	assert.Equal(t, "submission_limit_reached", status.Alerts[0].Title)
+	assert.Equal(t, SeverityHigh, status.Alerts[0].Severity)
+
+	// The fee of the confirmed transaction counts against the gas limit
+	relayer.reconcileSent()
+	usage := relayer.GetLimitUsage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, 1000.0, usage[0].Limits.MaxDisbursedPerHour)
+	assert.Equal(t, 700.0, usage[0].DisbursedLastHour)
+	assert.Greater(t, usage[0].GasSpentLastDay, 0.0)
+	require.NotNil(t, usage[0].Paused)
+	assert.Equal(t, LimitDisbursedPerHour, usage[0].Paused.Limit)
+}
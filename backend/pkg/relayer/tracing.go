@@ -0,0 +1,42 @@
+package relayer
+
+import (
+	"context"
+
+	"kelo-backend/pkg/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of the relayer's spans from the current tracer provider. A
+// message carries the trace context of the event that queued it, so its delivery joins
+// the trace of the request behind the event.
+func tracer() trace.Tracer {
+	return otel.Tracer("kelo-backend/pkg/relayer")
+}
+
+// startEventSpan starts the span that handles a Hedera event, continuing the trace the
+// event carries, and stores the span's context back on the event for the messages it
+// queues
+func (tr *TrustedRelayer) startEventSpan(event interface{}) (context.Context, trace.Span) {
+	var traceContext *map[string]string
+	var name, tokenID string
+	switch e := event.(type) {
+	case *LoanApprovalEvent:
+		traceContext, name, tokenID = &e.TraceContext, "loan_approval", e.TokenID.String()
+	case *RepaymentEvent:
+		traceContext, name, tokenID = &e.TraceContext, "repayment", e.TokenID.String()
+	case *LoanDisbursementEvent:
+		traceContext, name, tokenID = &e.TraceContext, "loan_disbursement", e.TokenID.String()
+	default:
+		return tracer().Start(tr.ctx, "relayer.handleHederaEvent")
+	}
+
+	ctx, span := tracer().Start(tracing.Extract(tr.ctx, *traceContext), "relayer.handleHederaEvent",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("event", name), attribute.String("token_id", tokenID)))
+	*traceContext = tracing.Inject(ctx)
+	return ctx, span
+}
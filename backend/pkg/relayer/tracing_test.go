@@ -0,0 +1,74 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"kelo-backend/pkg/tracing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTrustedRelayer_MessagesContinueEventTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	// The API request that recorded the repayment on the loan topic
+	ctx, request := otel.Tracer("test").Start(context.Background(), "POST /v1/loans/:id/repay")
+	request.End()
+	traceID := request.SpanContext().TraceID()
+	traceContext, err := json.Marshal(tracing.Inject(ctx))
+	require.NoError(t, err)
+
+	payer := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	decoded, err := decodeLoanTopicMessage(topicMessage("1700000012.000000000", 1,
+		`{"event":"loan_repaid","token_id":"7","amount":"400","total_repaid":"400","payer":"`+payer.Hex()+`","trace_context":`+string(traceContext)+`}`))
+	require.NoError(t, err)
+
+	relayer := newTestRelayer(t)
+	relayer.loans.(*fakeLoanStore).fundingChains["7"] = "ethereum"
+	require.NoError(t, relayer.handleHederaEvent(decoded))
+
+	// The queued message carries the trace through the outbox
+	pending := pendingMessages(t, relayer)
+	require.Len(t, pending, 1)
+	messages, err := decodeOutboxRows(mustMarshal(t, []*outboxRow{toOutboxRow(pending[0])}))
+	require.NoError(t, err)
+	assert.Equal(t, pending[0].TraceContext, messages[0].TraceContext)
+
+	relayer.processOutbox()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	handled, processed := spans["relayer.handleHederaEvent"], spans["relayer.processMessage"]
+	require.NotNil(t, handled)
+	require.NotNil(t, processed)
+	assert.Equal(t, traceID, handled.SpanContext().TraceID())
+	assert.Equal(t, request.SpanContext().SpanID(), handled.Parent().SpanID())
+	assert.Equal(t, traceID, processed.SpanContext().TraceID())
+	assert.Equal(t, handled.SpanContext().SpanID(), processed.Parent().SpanID())
+	// The test relayer cannot reach ethereum, so the dispatch fails on the span
+	assert.Equal(t, codes.Error, processed.Status().Code)
+}
+
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return data
+}
@@ -2,31 +2,28 @@ package relayer
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 	"time"
 
 	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/rs/zerolog/log"
 )
 
 // TransactionManager handles secure transaction submission to liquidity chains
 type TransactionManager struct {
-	blockchain      *blockchain.Clients
-	privateKey      *ecdsa.PrivateKey
+	chains          *blockchain.Registry
+	signer          Signer
 	publicAddress   common.Address
 	
-	// Transaction nonce management
-	nonces          map[string]uint64
-	nonceMutex      sync.RWMutex
+	// Transaction nonce management, shared with every other signer of the relayer key
+	nonces          *NonceManager
 	
 	// Gas price oracle
 	gasPriceOracle  *GasPriceOracle
@@ -39,20 +36,39 @@ type TransactionManager struct {
 	maxGasPrice     *big.Int
 	maxGasLimit     uint64
 	
+	// Stuck transaction replacement
+	stuckAfter       time.Duration
+	watchdogInterval time.Duration
+	feeBumpPercent   int64
+	// OnReplaced is called after a stuck transaction has been replaced
+	OnReplaced func(pendingTx *PendingTransaction, oldHash string)
+	
 	// Context
 	ctx            context.Context
 }
 
-// PendingTransaction represents a transaction that is waiting for confirmation
+// PendingTransaction represents a transaction that is waiting for confirmation. A
+// stuck transaction is replaced at the same nonce; TxHash is the latest replacement
+// until one of the hashes is mined, and then the mined one.
 type PendingTransaction struct {
 	ChainID       string          `json:"chain_id"`
-	TxHash        common.Hash     `json:"tx_hash"`
+	TxHash        string          `json:"tx_hash"`
 	Message       *Message        `json:"message"`
 	SubmittedAt   time.Time       `json:"submitted_at"`
 	Confirmations uint64          `json:"confirmations"`
 	RequiredConfs uint64          `json:"required_confirmations"`
 	Status        TxStatus        `json:"status"`
 	RetryCount    int             `json:"retry_count"`
+
+	Nonce           uint64                  `json:"nonce"`
+	Fee             *blockchain.FeeEstimate `json:"fee"`
+	Hashes          []string                `json:"hashes"` // every hash broadcast for this nonce, oldest first
+	ReplacedBy      string                  `json:"replaced_by,omitempty"`
+	LastSubmittedAt time.Time               `json:"last_submitted_at"`
+	Mined           bool                    `json:"mined"`
+	BlockHash       string                  `json:"block_hash,omitempty"` // block of the mined hash, re-checked until final
+	BlockNumber     uint64                  `json:"block_number,omitempty"`
+	request         *blockchain.TxRequest
 }
 
 // TxStatus represents the status of a transaction
@@ -63,11 +79,22 @@ const (
 	TxStatusConfirmed
 	TxStatusFailed
 	TxStatusReplaced
+	TxStatusReorged
+)
+
+const (
+	// defaultStuckAfter is how long a transaction may stay unmined before it is replaced.
+	defaultStuckAfter = 3 * time.Minute
+	// defaultWatchdogInterval is how often pending transactions are checked.
+	defaultWatchdogInterval = 30 * time.Second
+	// defaultFeeBumpPercent is the fee increase of a replacement. Nodes require at
+	// least 10% to accept a replacement at the same nonce.
+	defaultFeeBumpPercent = 20
 )
 
 // GasPriceOracle provides gas price estimates for different chains
 type GasPriceOracle struct {
-	blockchain *blockchain.Clients
+	chains     *blockchain.Registry
 	cache      map[string]*GasPriceCache
 	cacheMutex sync.RWMutex
 }
@@ -75,164 +102,182 @@ type GasPriceOracle struct {
 // GasPriceCache stores cached gas prices
 type GasPriceCache struct {
 	GasPrice    *big.Int
+	Fee         *blockchain.FeeEstimate
 	LastUpdated time.Time
 	TTL         time.Duration
 }
 
 // NewTransactionManager creates a new transaction manager
-func NewTransactionManager(bc *blockchain.Clients, privateKey *ecdsa.PrivateKey, ctx context.Context) *TransactionManager {
-	publicAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
+func NewTransactionManager(chains *blockchain.Registry, signer Signer, nonces *NonceManager, ctx context.Context) *TransactionManager {
+	publicAddress := signer.Address()
 	
 	return &TransactionManager{
-		blockchain:     bc,
-		privateKey:     privateKey,
+		chains:         chains,
+		signer:         signer,
 		publicAddress:  publicAddress,
-		nonces:         make(map[string]uint64),
+		nonces:         nonces,
 		pendingTxs:     make(map[string]*PendingTransaction),
-		gasPriceOracle: NewGasPriceOracle(bc),
+		gasPriceOracle: NewGasPriceOracle(chains),
 		maxGasPrice:    big.NewInt(500000000000), // 500 Gwei
 		maxGasLimit:    2000000, // 2M gas
+		stuckAfter:       defaultStuckAfter,
+		watchdogInterval: defaultWatchdogInterval,
+		feeBumpPercent:   defaultFeeBumpPercent,
 		ctx:           ctx,
 	}
 }
 
 // NewGasPriceOracle creates a new gas price oracle
-func NewGasPriceOracle(bc *blockchain.Clients) *GasPriceOracle {
+func NewGasPriceOracle(chains *blockchain.Registry) *GasPriceOracle {
 	return &GasPriceOracle{
-		blockchain: bc,
+		chains:     chains,
 		cache:      make(map[string]*GasPriceCache),
 	}
 }
 
 // SubmitTransaction submits a transaction to the specified chain
-func (tm *TransactionManager) SubmitTransaction(ctx context.Context, chainID string, message *Message) (*types.Transaction, error) {
+func (tm *TransactionManager) SubmitTransaction(ctx context.Context, chainID string, message *Message) (*blockchain.Transaction, error) {
 	log.Info().
 		Str("chain_id", chainID).
 		Str("message_type", message.Type.String()).
 		Msg("Submitting transaction")
 	
-	// Get chain client
-	client, err := tm.getChainClient(chainID)
+	// Get chain adapter
+	adapter, err := tm.chains.Adapter(chainID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chain client: %w", err)
+		return nil, fmt.Errorf("failed to get chain adapter: %w", err)
 	}
 	
-	// Get current nonce
-	nonce, err := tm.getCurrentNonce(ctx, chainID)
+	// Estimate gas limit
+	gasLimit, err := tm.estimateGasLimit(ctx, chainID, message)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current nonce: %w", err)
+		return nil, fmt.Errorf("failed to estimate gas limit: %w", err)
 	}
 	
-	// Get gas price
-	gasPrice, err := tm.gasPriceOracle.GetGasPrice(ctx, chainID)
+	// Create transaction request
+	req, err := tm.createTransaction(adapter, message, gasLimit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get gas price: %w", err)
-	}
-	
-	// Check gas price against maximum
-	if gasPrice.Cmp(tm.maxGasPrice) > 0 {
-		return nil, fmt.Errorf("gas price %s exceeds maximum %s", gasPrice.String(), tm.maxGasPrice.String())
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
 	}
 	
-	// Estimate gas limit
-	gasLimit, err := tm.estimateGasLimit(ctx, chainID, message)
+	return tm.Submit(ctx, chainID, req, message)
+}
+
+// Submit signs and sends a transaction request on the chain with the relayer key. The
+// nonce and fees are filled in, fees are capped at the maximum gas price, and the
+// transaction is watched until it confirms or is replaced.
+func (tm *TransactionManager) Submit(ctx context.Context, chainID string, req *blockchain.TxRequest, message *Message) (*blockchain.Transaction, error) {
+	adapter, err := tm.chains.Adapter(chainID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to estimate gas limit: %w", err)
+		return nil, fmt.Errorf("failed to get chain adapter: %w", err)
 	}
 	
 	// Check gas limit against maximum
-	if gasLimit > tm.maxGasLimit {
-		return nil, fmt.Errorf("gas limit %d exceeds maximum %d", gasLimit, tm.maxGasLimit)
+	if req.GasLimit > tm.maxGasLimit {
+		return nil, fmt.Errorf("gas limit %d exceeds maximum %d", req.GasLimit, tm.maxGasLimit)
 	}
 	
-	// Create transaction
-	tx, err := tm.createTransaction(ctx, chainID, message, nonce, gasPrice, gasLimit)
+	// Get fees, dynamic where the chain supports them
+	fee, err := tm.gasPriceOracle.GetFee(ctx, chainID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %w", err)
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
 	}
-	
-	// Sign transaction
-	signedTx, err := tm.signTransaction(tx)
+	fee, err = tm.capFee(fee)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, err
 	}
 	
-	// Submit transaction
-	err = client.SendTransaction(ctx, signedTx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	// Allocate a nonce; when the chain has already used it, resync and try once more
+	signer := tm.publicAddress.Hex()
+	var nonce uint64
+	var built blockchain.TxRequest
+	var signedTx *blockchain.Transaction
+	for attempt := 0; ; attempt++ {
+		nonce, err = tm.nonces.Allocate(ctx, chainID, signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate nonce: %w", err)
+		}
+		
+		built = *req
+		built.From = signer
+		built.Nonce = &nonce
+		built.Fee = fee
+		
+		signedTx, err = tm.signAndSend(ctx, adapter, &built)
+		if err == nil {
+			tm.nonces.Confirm(chainID, signer, nonce)
+			break
+		}
+		if !tm.nonces.Fail(ctx, chainID, signer, nonce, err) || attempt > 0 {
+			return nil, err
+		}
 	}
 	
-	// Update nonce
-	tm.incrementNonce(chainID)
-	
 	// Monitor transaction
-	go tm.monitorTransaction(chainID, signedTx.Hash(), message)
+	tm.monitorTransaction(adapter, signedTx.Hash, message, &built)
 	
 	log.Info().
 		Str("chain_id", chainID).
-		Str("tx_hash", signedTx.Hash().Hex()).
+		Str("tx_hash", signedTx.Hash).
 		Str("nonce", fmt.Sprintf("%d", nonce)).
-		Str("gas_price", gasPrice.String()).
-		Str("gas_limit", fmt.Sprintf("%d", gasLimit)).
+		Str("gas_price", fee.GasPrice.String()).
+		Str("gas_limit", fmt.Sprintf("%d", req.GasLimit)).
+		Bool("dynamic_fee", fee.GasFeeCap != nil).
 		Msg("Transaction submitted successfully")
 	
 	return signedTx, nil
 }
 
-// getChainClient gets the client for the specified chain
-func (tm *TransactionManager) getChainClient(chainID string) (*ethclient.Client, error) {
-	switch chainID {
-	case "ethereum":
-		return tm.blockchain.GetEthereumClient(), nil
-	case "base":
-		return tm.blockchain.GetBaseClient(), nil
-	default:
-		return nil, fmt.Errorf("unsupported chain ID: %s", chainID)
-	}
-}
-
-// getCurrentNonce gets the current nonce for the specified chain
-func (tm *TransactionManager) getCurrentNonce(ctx context.Context, chainID string) (uint64, error) {
-	tm.nonceMutex.RLock()
-	nonce, exists := tm.nonces[chainID]
-	tm.nonceMutex.RUnlock()
-	
-	if exists {
-		return nonce, nil
-	}
-	
-	// Get nonce from blockchain
-	client, err := tm.getChainClient(chainID)
+// signAndSend builds, signs and sends a fully specified transaction request
+func (tm *TransactionManager) signAndSend(ctx context.Context, adapter blockchain.ChainAdapter, req *blockchain.TxRequest) (*blockchain.Transaction, error) {
+	tx, err := adapter.BuildTransaction(ctx, req)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
 	}
-	
-	nonce, err = client.PendingNonceAt(ctx, tm.publicAddress)
+	signedTx, err := adapter.SignTransaction(ctx, tx, tm.signer)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
-	
-	// Cache nonce
-	tm.nonceMutex.Lock()
-	tm.nonces[chainID] = nonce
-	tm.nonceMutex.Unlock()
-	
-	return nonce, nil
+	if _, err := adapter.SendTransaction(ctx, signedTx); err != nil {
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	}
+	return signedTx, nil
 }
 
-// incrementNonce increments the nonce for the specified chain
-func (tm *TransactionManager) incrementNonce(chainID string) {
-	tm.nonceMutex.Lock()
-	defer tm.nonceMutex.Unlock()
+// capFee keeps fees within the maximum gas price. A dynamic fee cap above the maximum
+// is lowered to it, since the cap only bounds what may be paid; a legacy gas price or
+// priority fee above the maximum is rejected.
+func (tm *TransactionManager) capFee(fee *blockchain.FeeEstimate) (*blockchain.FeeEstimate, error) {
+	capped := *fee
+	if fee.GasTipCap != nil && fee.GasFeeCap != nil {
+		if fee.GasTipCap.Cmp(tm.maxGasPrice) > 0 {
+			return nil, fmt.Errorf("priority fee %s exceeds maximum %s", fee.GasTipCap.String(), tm.maxGasPrice.String())
+		}
+		if fee.GasFeeCap.Cmp(tm.maxGasPrice) > 0 {
+			capped.GasFeeCap = new(big.Int).Set(tm.maxGasPrice)
+		}
+		if capped.GasPrice == nil || capped.GasPrice.Cmp(capped.GasFeeCap) > 0 {
+			capped.GasPrice = capped.GasFeeCap
+		}
+		return &capped, nil
+	}
 	
-	tm.nonces[chainID]++
+	if fee.GasPrice == nil {
+		return nil, fmt.Errorf("no gas price available")
+	}
+	if fee.GasPrice.Cmp(tm.maxGasPrice) > 0 {
+		return nil, fmt.Errorf("gas price %s exceeds maximum %s", fee.GasPrice.String(), tm.maxGasPrice.String())
+	}
+	return &capped, nil
 }
 
 // estimateGasLimit estimates the gas limit for a transaction
 func (tm *TransactionManager) estimateGasLimit(ctx context.Context, chainID string, message *Message) (uint64, error) {
 	// This is a placeholder implementation
 	// In a real implementation, you would estimate gas based on the message type and chain
+	if message.GasLimit != 0 {
+		return message.GasLimit, nil
+	}
 	
 	switch message.Type {
 	case MessageTypeLoanApproval:
@@ -250,12 +295,12 @@ func (tm *TransactionManager) estimateGasLimit(ctx context.Context, chainID stri
 	}
 }
 
-// createTransaction creates a transaction for the specified message
-func (tm *TransactionManager) createTransaction(ctx context.Context, chainID string, message *Message, nonce uint64, gasPrice *big.Int, gasLimit uint64) (*types.Transaction, error) {
+// createTransaction creates the transaction request for the specified message
+func (tm *TransactionManager) createTransaction(adapter blockchain.ChainAdapter, message *Message, gasLimit uint64) (*blockchain.TxRequest, error) {
 	// Get contract address for the chain
-	contractAddr, err := tm.getContractAddress(chainID)
-	if err != nil {
-		return nil, err
+	contractAddr := adapter.Spec().ContractAddress
+	if contractAddr == "" {
+		return nil, fmt.Errorf("no contract address configured for chain %s", adapter.ChainID())
 	}
 	
 	// Create transaction data
@@ -264,45 +309,12 @@ func (tm *TransactionManager) createTransaction(ctx context.Context, chainID str
 		return nil, err
 	}
 	
-	// Create transaction
-	tx := &types.DynamicFeeTx{
-		ChainID:   tm.getChainID(chainID),
-		Nonce:     nonce,
-		GasTipCap: gasPrice,
-		GasFeeCap: new(big.Int).Mul(gasPrice, big.NewInt(2)), // 2x gas price for fee cap
-		Gas:       gasLimit,
-		To:        &contractAddr,
-		Value:     big.NewInt(0), // No ETH value
-		Data:      data,
-	}
-	
-	return types.NewTx(tx), nil
-}
-
-// getChainID returns the chain ID for the specified chain
-func (tm *TransactionManager) getChainID(chainID string) *big.Int {
-	switch chainID {
-	case "ethereum":
-		return big.NewInt(1)
-	case "base":
-		return big.NewInt(8453)
-	default:
-		return big.NewInt(1) // Default to Ethereum
-	}
-}
-
-// getContractAddress returns the contract address for the specified chain
-func (tm *TransactionManager) getContractAddress(chainID string) (common.Address, error) {
-	// This would be configured based on your deployment
-	// For now, return a placeholder address
-	switch chainID {
-	case "ethereum":
-		return common.HexToAddress("0x1234567890123456789012345678901234567890"), nil
-	case "base":
-		return common.HexToAddress("0x0987654321098765432109876543210987654321"), nil
-	default:
-		return common.Address{}, fmt.Errorf("unsupported chain ID: %s", chainID)
-	}
+	return &blockchain.TxRequest{
+		To:       contractAddr,
+		Value:    big.NewInt(0), // No ETH value
+		Data:     data,
+		GasLimit: gasLimit,
+	}, nil
 }
 
 // createTransactionData creates the transaction data for the specified message
@@ -314,61 +326,46 @@ func (tm *TransactionManager) createTransactionData(message *Message) ([]byte, e
 	return message.Payload, nil
 }
 
-// signTransaction signs a transaction
-func (tm *TransactionManager) signTransaction(tx *types.Transaction) (*types.Transaction, error) {
-	// Sign the transaction
-	signer := types.NewLondonSigner(tx.ChainId())
-	signedTx, err := types.SignTx(tx, signer, tm.privateKey)
-	if err != nil {
-		return nil, err
-	}
+// monitorTransaction tracks a transaction and watches it for confirmation
+func (tm *TransactionManager) monitorTransaction(adapter blockchain.ChainAdapter, txHash string, message *Message, req *blockchain.TxRequest) {
+	now := time.Now()
 	
-	return signedTx, nil
-}
-
-// monitorTransaction monitors a transaction for confirmation
-func (tm *TransactionManager) monitorTransaction(chainID string, txHash common.Hash, message *Message) {
 	// Create pending transaction
 	pendingTx := &PendingTransaction{
-		ChainID:       chainID,
-		TxHash:        txHash,
-		Message:       message,
-		SubmittedAt:   time.Now(),
-		Confirmations: 0,
-		RequiredConfs: tm.getRequiredConfirmations(chainID),
-		Status:        TxStatusPending,
-		RetryCount:    0,
+		ChainID:         adapter.ChainID(),
+		TxHash:          txHash,
+		Message:         message,
+		SubmittedAt:     now,
+		Confirmations:   0,
+		RequiredConfs:   getRequiredConfirmations(adapter),
+		Status:          TxStatusPending,
+		RetryCount:      0,
+		Nonce:           *req.Nonce,
+		Fee:             req.Fee,
+		Hashes:          []string{txHash},
+		LastSubmittedAt: now,
+		request:         req,
 	}
 	
 	// Store pending transaction
 	tm.txMutex.Lock()
-	tm.pendingTxs[txHash.Hex()] = pendingTx
+	tm.pendingTxs[txHash] = pendingTx
 	tm.txMutex.Unlock()
 	
 	// Start monitoring
-	go tm.waitForConfirmation(chainID, txHash, pendingTx)
+	go tm.waitForConfirmation(adapter, pendingTx)
 }
 
-// getRequiredConfirmations returns the required confirmations for the specified chain
-func (tm *TransactionManager) getRequiredConfirmations(chainID string) uint64 {
-	switch chainID {
-	case "ethereum":
-		return 12
-	case "base":
-		return 5
-	default:
-		return 1
+// getRequiredConfirmations returns the configured confirmations for the chain
+func getRequiredConfirmations(adapter blockchain.ChainAdapter) uint64 {
+	if confirmations := adapter.Spec().Confirmations; confirmations > 0 {
+		return confirmations
 	}
+	return 1
 }
 
-// waitForConfirmation waits for a transaction to be confirmed
-func (tm *TransactionManager) waitForConfirmation(chainID string, txHash common.Hash, pendingTx *PendingTransaction) {
-	client, err := tm.getChainClient(chainID)
-	if err != nil {
-		log.Error().Err(err).Str("chain_id", chainID).Msg("Failed to get chain client for confirmation")
-		return
-	}
-	
+// waitForConfirmation waits for a transaction, or one of its replacements, to be confirmed
+func (tm *TransactionManager) waitForConfirmation(adapter blockchain.ChainAdapter, pendingTx *PendingTransaction) {
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
 	
@@ -377,98 +374,346 @@ func (tm *TransactionManager) waitForConfirmation(chainID string, txHash common.
 		case <-tm.ctx.Done():
 			return
 		case <-ticker.C:
-			// Get transaction receipt
-			receipt, err := client.TransactionReceipt(tm.ctx, txHash)
-			if err != nil {
-				if err == ethereum.NotFound {
-					// Transaction not yet mined
-					continue
-				}
-				log.Error().Err(err).Str("tx_hash", txHash.Hex()).Msg("Failed to get transaction receipt")
-				continue
+			if tm.checkConfirmation(adapter, pendingTx) {
+				return
 			}
-			
-			// Update transaction status
-			tm.txMutex.Lock()
-			pendingTx.Confirmations++
-			
-			if receipt.Status == 1 {
-				pendingTx.Status = TxStatusConfirmed
-				log.Info().
-					Str("chain_id", chainID).
-					Str("tx_hash", txHash.Hex()).
-					Uint64("confirmations", pendingTx.Confirmations).
-					Msg("Transaction confirmed")
-			} else {
-				pendingTx.Status = TxStatusFailed
-				log.Error().
-					Str("chain_id", chainID).
-					Str("tx_hash", txHash.Hex()).
-					Msg("Transaction failed")
+		}
+	}
+}
+
+// checkConfirmation looks up the receipts of every hash broadcast for the transaction's
+// nonce. It reports whether the transaction reached a final status. Until the mined
+// hash is deep enough its block is re-checked, since a reorg can move or drop it.
+func (tm *TransactionManager) checkConfirmation(adapter blockchain.ChainAdapter, pendingTx *PendingTransaction) bool {
+	chainID := adapter.ChainID()
+	
+	tm.txMutex.RLock()
+	hashes := append([]string(nil), pendingTx.Hashes...)
+	tm.txMutex.RUnlock()
+	
+	// At most one of the hashes can be mined; check the newest first
+	var receipt *blockchain.Receipt
+	lookupFailed := false
+	for i := len(hashes) - 1; i >= 0 && receipt == nil; i-- {
+		r, err := adapter.TransactionReceipt(tm.ctx, hashes[i])
+		if err != nil {
+			if !errors.Is(err, blockchain.ErrTxNotFound) {
+				log.Error().Err(err).Str("tx_hash", hashes[i]).Msg("Failed to get transaction receipt")
+				lookupFailed = true
 			}
-			
-			// Remove from pending transactions if confirmed or failed
-			if pendingTx.Status == TxStatusConfirmed || pendingTx.Status == TxStatusFailed {
-				delete(tm.pendingTxs, txHash.Hex())
+			continue
+		}
+		receipt = r
+	}
+	
+	// Update transaction status
+	tm.txMutex.Lock()
+	defer tm.txMutex.Unlock()
+	
+	if receipt == nil {
+		if !pendingTx.Mined || lookupFailed {
+			// Transaction not yet mined, or not known to be gone
+			return false
+		}
+		tm.dropReorged(pendingTx, hashes)
+		return true
+	}
+	
+	if pendingTx.Mined && pendingTx.BlockHash != receipt.BlockHash {
+		log.Warn().
+			Str("chain_id", chainID).
+			Str("tx_hash", receipt.TxHash).
+			Str("old_block_hash", pendingTx.BlockHash).
+			Str("block_hash", receipt.BlockHash).
+			Uint64("block_number", receipt.BlockNumber).
+			Msg("Transaction moved to another block by a reorg")
+	}
+	pendingTx.Mined = true
+	pendingTx.BlockHash = receipt.BlockHash
+	pendingTx.BlockNumber = receipt.BlockNumber
+	pendingTx.TxHash = receipt.TxHash
+	pendingTx.Confirmations = receipt.Confirmations
+	if pendingTx.Message != nil {
+		pendingTx.Message.TxHash = receipt.TxHash
+	}
+	
+	if receipt.Success && receipt.Confirmations < pendingTx.RequiredConfs {
+		// Mined but not yet deep enough
+		return false
+	}
+	
+	if receipt.Success {
+		pendingTx.Status = TxStatusConfirmed
+		log.Info().
+			Str("chain_id", chainID).
+			Str("tx_hash", receipt.TxHash).
+			Int("replacements", len(hashes)-1).
+			Uint64("confirmations", pendingTx.Confirmations).
+			Msg("Transaction confirmed")
+	} else {
+		pendingTx.Status = TxStatusFailed
+		log.Error().
+			Str("chain_id", chainID).
+			Str("tx_hash", receipt.TxHash).
+			Msg("Transaction failed")
+	}
+	
+	// Remove every hash of the nonce from pending transactions
+	for _, hash := range hashes {
+		delete(tm.pendingTxs, hash)
+	}
+	return true
+}
+
+// dropReorged stops watching a mined transaction whose block was orphaned and whose
+// hashes are no longer on chain. The relayer resubmits its message; the nonce is
+// released so the resubmission, or a gap filler, takes it over from the dropped
+// transaction. Callers hold txMutex.
+func (tm *TransactionManager) dropReorged(pendingTx *PendingTransaction, hashes []string) {
+	log.Warn().
+		Str("chain_id", pendingTx.ChainID).
+		Str("tx_hash", pendingTx.TxHash).
+		Str("block_hash", pendingTx.BlockHash).
+		Uint64("block_number", pendingTx.BlockNumber).
+		Uint64("nonce", pendingTx.Nonce).
+		Msg("Transaction dropped by a reorg")
+	
+	pendingTx.Status = TxStatusReorged
+	pendingTx.Mined = false
+	pendingTx.Confirmations = 0
+	for _, hash := range hashes {
+		delete(tm.pendingTxs, hash)
+	}
+	tm.nonces.Release(pendingTx.ChainID, tm.publicAddress.Hex(), pendingTx.Nonce)
+}
+
+// StartWatchdog periodically replaces transactions that have been pending too long and
+// fills nonce gaps left by transactions that were never sent
+func (tm *TransactionManager) StartWatchdog() {
+	go func() {
+		ticker := time.NewTicker(tm.watchdogInterval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-tm.ctx.Done():
+				return
+			case <-ticker.C:
+				tm.replaceStuckTransactions()
+				tm.nonces.FillGaps(tm.ctx, tm.fillNonceGap)
 			}
-			tm.txMutex.Unlock()
-			
-			return
 		}
+	}()
+}
+
+// replaceStuckTransactions replaces every unmined transaction last submitted more than
+// stuckAfter ago
+func (tm *TransactionManager) replaceStuckTransactions() {
+	tm.txMutex.RLock()
+	var stuck []*PendingTransaction
+	for hash, pendingTx := range tm.pendingTxs {
+		if hash == pendingTx.TxHash && pendingTx.Status == TxStatusPending && !pendingTx.Mined &&
+			time.Since(pendingTx.LastSubmittedAt) >= tm.stuckAfter {
+			stuck = append(stuck, pendingTx)
+		}
+	}
+	tm.txMutex.RUnlock()
+	
+	for _, pendingTx := range stuck {
+		if err := tm.replaceTransaction(pendingTx); err != nil {
+			log.Warn().
+				Err(err).
+				Str("chain_id", pendingTx.ChainID).
+				Str("tx_hash", pendingTx.TxHash).
+				Uint64("nonce", pendingTx.Nonce).
+				Msg("Failed to replace stuck transaction")
+		}
+	}
+}
+
+// replaceTransaction resends a pending transaction at the same nonce with bumped fees
+func (tm *TransactionManager) replaceTransaction(pendingTx *PendingTransaction) error {
+	adapter, err := tm.chains.Adapter(pendingTx.ChainID)
+	if err != nil {
+		return err
+	}
+	
+	tm.txMutex.RLock()
+	oldHash := pendingTx.TxHash
+	oldFee := pendingTx.Fee
+	req := *pendingTx.request
+	tm.txMutex.RUnlock()
+	
+	// Use the market fee if it has risen past the bump
+	market, err := tm.gasPriceOracle.fetchFee(tm.ctx, pendingTx.ChainID)
+	if err != nil {
+		market = nil
+	}
+	fee, err := tm.bumpFee(oldFee, market)
+	if err != nil {
+		return err
+	}
+	req.Fee = fee
+	
+	signedTx, err := tm.signAndSend(tm.ctx, adapter, &req)
+	if err != nil {
+		return err
+	}
+	
+	tm.txMutex.Lock()
+	if pendingTx.Mined || pendingTx.Status != TxStatusPending {
+		tm.txMutex.Unlock()
+		return nil
+	}
+	pendingTx.TxHash = signedTx.Hash
+	pendingTx.Fee = fee
+	pendingTx.Hashes = append(pendingTx.Hashes, signedTx.Hash)
+	pendingTx.LastSubmittedAt = time.Now()
+	pendingTx.RetryCount++
+	pendingTx.request = &req
+	tm.pendingTxs[signedTx.Hash] = pendingTx
+	tm.txMutex.Unlock()
+	
+	log.Info().
+		Str("chain_id", pendingTx.ChainID).
+		Str("old_tx_hash", oldHash).
+		Str("tx_hash", signedTx.Hash).
+		Uint64("nonce", pendingTx.Nonce).
+		Str("gas_price", fee.GasPrice.String()).
+		Msg("Replaced stuck transaction")
+	
+	if tm.OnReplaced != nil {
+		tm.OnReplaced(pendingTx, oldHash)
+	}
+	return nil
+}
+
+// fillNonceGap sends a zero-value transfer to the relayer itself at the nonce, so the
+// transactions queued behind it can be mined
+func (tm *TransactionManager) fillNonceGap(ctx context.Context, chainID, signer string, nonce uint64) error {
+	adapter, err := tm.chains.Adapter(chainID)
+	if err != nil {
+		return err
+	}
+	if adapter.Spec().Type != config.ChainTypeEVM || !strings.EqualFold(signer, tm.publicAddress.Hex()) {
+		return blockchain.ErrNotSupported
+	}
+	
+	fee, err := tm.gasPriceOracle.GetFee(ctx, chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get gas price: %w", err)
+	}
+	fee, err = tm.capFee(fee)
+	if err != nil {
+		return err
+	}
+	
+	signedTx, err := tm.signAndSend(ctx, adapter, &blockchain.TxRequest{
+		From:     tm.publicAddress.Hex(),
+		To:       tm.publicAddress.Hex(),
+		Value:    big.NewInt(0),
+		GasLimit: 21000,
+		Nonce:    &nonce,
+		Fee:      fee,
+	})
+	if err != nil {
+		return err
+	}
+	
+	log.Info().
+		Str("chain_id", chainID).
+		Str("tx_hash", signedTx.Hash).
+		Uint64("nonce", nonce).
+		Msg("Sent nonce gap filler")
+	return nil
+}
+
+// bumpFee raises each fee field by feeBumpPercent, or to the market fee when that is
+// higher, within the maximum gas price
+func (tm *TransactionManager) bumpFee(fee, market *blockchain.FeeEstimate) (*blockchain.FeeEstimate, error) {
+	bump := func(old, current *big.Int) *big.Int {
+		if old == nil {
+			return nil
+		}
+		bumped := new(big.Int).Mul(old, big.NewInt(100+tm.feeBumpPercent))
+		bumped.Add(bumped, big.NewInt(99))
+		bumped.Div(bumped, big.NewInt(100))
+		if current != nil && current.Cmp(bumped) > 0 {
+			return new(big.Int).Set(current)
+		}
+		return bumped
 	}
+	if market == nil {
+		market = &blockchain.FeeEstimate{}
+	}
+	
+	bumped := &blockchain.FeeEstimate{
+		GasPrice:  bump(fee.GasPrice, market.GasPrice),
+		GasTipCap: bump(fee.GasTipCap, market.GasTipCap),
+		GasFeeCap: bump(fee.GasFeeCap, market.GasFeeCap),
+	}
+	
+	// The replacement must outbid the original, so it cannot be capped below the bump
+	for _, value := range []*big.Int{bumped.GasPrice, bumped.GasTipCap, bumped.GasFeeCap} {
+		if value != nil && value.Cmp(tm.maxGasPrice) > 0 {
+			return nil, fmt.Errorf("bumped fee %s exceeds maximum %s", value.String(), tm.maxGasPrice.String())
+		}
+	}
+	return bumped, nil
 }
 
 // GetGasPrice gets the current gas price for the specified chain
 func (gpo *GasPriceOracle) GetGasPrice(ctx context.Context, chainID string) (*big.Int, error) {
+	fee, err := gpo.GetFee(ctx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	return fee.GasPrice, nil
+}
+
+// GetFee gets the current fees for the specified chain, including EIP-1559 caps on
+// chains that support them
+func (gpo *GasPriceOracle) GetFee(ctx context.Context, chainID string) (*blockchain.FeeEstimate, error) {
 	gpo.cacheMutex.RLock()
 	cache, exists := gpo.cache[chainID]
 	gpo.cacheMutex.RUnlock()
 	
-	// Check if cached gas price is still valid
+	// Check if cached fee is still valid
 	if exists && time.Since(cache.LastUpdated) < cache.TTL {
-		return cache.GasPrice, nil
+		return cache.Fee, nil
 	}
 	
-	// Get fresh gas price
-	gasPrice, err := gpo.fetchGasPrice(ctx, chainID)
+	// Get fresh fee
+	fee, err := gpo.fetchFee(ctx, chainID)
 	if err != nil {
 		if exists {
-			// Return cached gas price if fetch fails
-			return cache.GasPrice, nil
+			// Return cached fee if fetch fails
+			return cache.Fee, nil
 		}
 		return nil, err
 	}
 	
-	// Cache the gas price
+	// Cache the fee
 	gpo.cacheMutex.Lock()
 	gpo.cache[chainID] = &GasPriceCache{
-		GasPrice:    gasPrice,
+		GasPrice:    fee.GasPrice,
+		Fee:         fee,
 		LastUpdated: time.Now(),
 		TTL:         5 * time.Minute, // Cache for 5 minutes
 	}
 	gpo.cacheMutex.Unlock()
 	
-	return gasPrice, nil
+	return fee, nil
 }
 
-// fetchGasPrice fetches the current gas price from the blockchain
-func (gpo *GasPriceOracle) fetchGasPrice(ctx context.Context, chainID string) (*big.Int, error) {
-	var client *ethclient.Client
-	
-	switch chainID {
-	case "ethereum":
-		client = gpo.blockchain.GetEthereumClient()
-	case "base":
-		client = gpo.blockchain.GetBaseClient()
-	default:
-		return nil, fmt.Errorf("unsupported chain ID: %s", chainID)
-	}
-	
-	if client == nil {
-		return nil, fmt.Errorf("client not available for chain ID: %s", chainID)
+// fetchFee fetches the current fees from the blockchain
+func (gpo *GasPriceOracle) fetchFee(ctx context.Context, chainID string) (*blockchain.FeeEstimate, error) {
+	adapter, err := gpo.chains.Adapter(chainID)
+	if err != nil {
+		return nil, err
 	}
 	
-	return client.SuggestGasPrice(ctx)
+	return adapter.EstimateFee(ctx)
 }
 
 // GetPendingTransactions returns all pending transactions
@@ -495,5 +740,14 @@ func (tm *TransactionManager) GetTransactionStatus(txHash string) (*PendingTrans
 		return nil, fmt.Errorf("transaction not found: %s", txHash)
 	}
 	
+	// An earlier hash of a replaced transaction reports the hash that replaced it
+	if txHash != pendingTx.TxHash {
+		replaced := *pendingTx
+		replaced.TxHash = txHash
+		replaced.Status = TxStatusReplaced
+		replaced.ReplacedBy = pendingTx.TxHash
+		return &replaced, nil
+	}
+	
 	return pendingTx, nil
 }
\ No newline at end of file
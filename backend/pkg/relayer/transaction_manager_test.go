@@ -0,0 +1,320 @@
+package relayer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"kelo-backend/pkg/blockchain"
+	"kelo-backend/pkg/config"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionManager_SubmitTransaction(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{
+		Key:             "base",
+		Type:            config.ChainTypeEVM,
+		EVMChainID:      8453,
+		ContractAddress: "0x0987654321098765432109876543210987654321",
+		Confirmations:   5,
+	})
+	registry := blockchain.NewRegistry()
+	require.NoError(t, registry.Register(adapter))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tm := NewTransactionManager(registry, signer, NewNonceManager(registry, NewMemoryNonceStore()), ctx)
+
+	message := &Message{ChainID: "base", Type: MessageTypeLoanDisbursement, Payload: []byte{0x01}}
+	tx, err := tm.SubmitTransaction(ctx, "base", message)
+	require.NoError(t, err)
+
+	sent := adapter.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, tx.Hash, sent[0].Hash)
+	assert.Equal(t, uint64(0), sent[0].Nonce)
+
+	req := sent[0].Native.(*blockchain.TxRequest)
+	assert.Equal(t, "0x0987654321098765432109876543210987654321", req.To)
+	assert.Equal(t, uint64(150000), req.GasLimit)
+
+	// The next submission takes the next allocated nonce
+	tx, err = tm.SubmitTransaction(ctx, "base", message)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), tx.Nonce)
+
+	_, err = tm.SubmitTransaction(ctx, "polygon", message)
+	assert.Error(t, err)
+}
+
+func TestTrustedRelayer_DispatchToConfiguredChain(t *testing.T) {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+
+	// A chain that exists only in config
+	cfg := &config.Config{
+		LayerZeroEndpoint:       "0x1111111111111111111111111111111111111111",
+		LayerZeroSourceChain:    "ethereum",
+		RelayerSignerSetVersion: 2,
+		Chains: []config.ChainSpec{
+			{Key: "ethereum", Type: config.ChainTypeEVM, EVMChainID: 1, LayerZeroEID: 30101, Confirmations: 1, Enabled: true},
+			{Key: "optimism", Type: config.ChainTypeEVM, EVMChainID: 10, LayerZeroEID: 30111, LayerZeroReceiver: "0x2222222222222222222222222222222222222222", Confirmations: 1, Enabled: true},
+		},
+	}
+	chainConfigs := initializeChainConfigs(cfg)
+	require.Contains(t, chainConfigs, "optimism")
+	assert.Equal(t, "10", chainConfigs["optimism"].ChainID)
+
+	source := blockchain.NewFakeAdapter(cfg.Chains[0])
+	fakeLayerZeroEndpoint(t, source, big.NewInt(1000), 0)
+	lzClient, err := NewLayerZeroClient(source, signer, cfg)
+	require.NoError(t, err)
+
+	tr := &TrustedRelayer{
+		config:          cfg,
+		signer:          signer,
+		chainConfigs:    chainConfigs,
+		layerZeroClient: lzClient,
+		ctx:             context.Background(),
+	}
+
+	message := &Message{ChainID: "optimism", Type: MessageTypeLoanDisbursement, Payload: []byte{0x01}}
+	hash, err := tr.dispatchMessage(tr.ctx, message)
+	require.NoError(t, err)
+	assert.NotEmpty(t, message.LayerZeroGUID)
+
+	sent := source.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, hash, sent[0].Hash)
+	assert.Equal(t, cfg.LayerZeroEndpoint, sent[0].Native.(*blockchain.TxRequest).To)
+
+	// The receiver gets the payload signed by the relayer for its own domain
+	values, err := layerZeroEndpointABI.Methods["send"].Inputs.Unpack(sent[0].Native.(*blockchain.TxRequest).Data[4:])
+	require.NoError(t, err)
+	params := abi.ConvertType(values[0], new(layerZeroMessagingParams)).(*layerZeroMessagingParams)
+	verifier := NewMessageVerifier(
+		SigningDomain{ChainID: big.NewInt(10), VerifyingContract: common.HexToAddress("0x2222222222222222222222222222222222222222")},
+		30101,
+		SignerSet{Version: 2, Signers: []common.Address{signer.Address()}},
+	)
+	signed, _, err := verifier.Verify(params.Message)
+	require.NoError(t, err)
+	assert.Equal(t, message.Payload, signed.Payload)
+	assert.Equal(t, message.Signature, signed.Signature)
+	assert.Equal(t, uint32(2), message.SignerSetVersion)
+	require.NotNil(t, message.SignatureExpiry)
+
+	_, err = tr.dispatchMessage(tr.ctx, &Message{ChainID: "fantom"})
+	assert.Error(t, err)
+}
+
+func TestTrustedRelayer_AptosDisbursementDecimals(t *testing.T) {
+	relayer := newTestRelayer(t)
+	relayer.config.LoanAssetDecimals = 6
+	relayer.config.AptosCoinType = "0xf22bede237a07e121b56d91a491eb7bcdfd1f5907926a9e58338f964a01b17fa::asset::USDC"
+	relayer.recipients = fakeRecipients{"aptos": "0xbeef"}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	relayer.aptosKey = key
+	chain := &ChainConfig{ChainID: "aptos", Type: config.ChainTypeAptos, ProgramID: "0xcafe", Enabled: true}
+
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{
+		TokenID:  big.NewInt(16),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(2500000), // 2.5 USDC
+	}, common.Address{})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "16", "aptos", payload)
+
+	amount := func() uint64 {
+		req, err := relayer.aptosDisbursementRequest(message, chain)
+		require.NoError(t, err)
+		function := req.Call.(blockchain.AptosEntryFunction)
+		assert.Equal(t, []string{relayer.config.AptosCoinType}, function.TypeArgs)
+		return binary.LittleEndian.Uint64(function.Args[1])
+	}
+	relayer.config.AptosCoinDecimals = 6
+	assert.Equal(t, uint64(2500000), amount())
+	relayer.config.AptosCoinDecimals = 8
+	assert.Equal(t, uint64(250000000), amount())
+
+	// A coin with fewer decimals cannot carry the whole amount
+	relayer.config.AptosCoinDecimals = 0
+	_, err = relayer.aptosDisbursementRequest(message, chain)
+	assert.Error(t, err)
+}
+
+func TestTrustedRelayer_SolanaDisbursementDecimals(t *testing.T) {
+	relayer, _ := newSolanaTestRelayer(t)
+	relayer.config.LoanAssetDecimals = 6
+	chain := relayer.chainConfigs["solana"]
+
+	payload, err := NewMessageFactory().CreateLoanDisbursementPayload(&LoanApprovalEvent{
+		TokenID:  big.NewInt(17),
+		Merchant: common.HexToAddress("0x0987654321098765432109876543210987654321"),
+		Amount:   big.NewInt(2500000), // 2.5 USDC
+	}, common.Address{})
+	require.NoError(t, err)
+	message := newPendingMessage(MessageTypeLoanDisbursement, "17", "solana", payload)
+
+	amount := func() uint64 {
+		req, err := relayer.solanaDisbursementRequest(message, chain)
+		require.NoError(t, err)
+		instructions := req.Call.([]blockchain.SolanaInstruction)
+		disburse := instructions[len(instructions)-1]
+		return binary.LittleEndian.Uint64(disburse.Data[1:])
+	}
+	relayer.config.SolanaTokenDecimals = 6
+	assert.Equal(t, uint64(2500000), amount())
+	relayer.config.SolanaTokenDecimals = 9
+	assert.Equal(t, uint64(2500000000), amount())
+
+	// A mint with fewer decimals cannot carry the whole amount
+	relayer.config.SolanaTokenDecimals = 0
+	_, err = relayer.solanaDisbursementRequest(message, chain)
+	assert.Error(t, err)
+}
+
+func newTestTransactionManager(t *testing.T, adapter *blockchain.FakeAdapter) *TransactionManager {
+	signer, err := GenerateLocalSigner()
+	require.NoError(t, err)
+	registry := blockchain.NewRegistry()
+	require.NoError(t, registry.Register(adapter))
+	return NewTransactionManager(registry, signer, NewNonceManager(registry, NewMemoryNonceStore()), context.Background())
+}
+
+func TestTransactionManager_DynamicFeeWithinMaximum(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321"})
+	tm := newTestTransactionManager(t, adapter)
+	tm.maxGasPrice = big.NewInt(100)
+
+	// The fee cap is lowered to the maximum
+	adapter.SetFee(&blockchain.FeeEstimate{GasPrice: big.NewInt(90), GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(150)})
+	tx, err := tm.SubmitTransaction(context.Background(), "base", &Message{Type: MessageTypeLoanDisbursement})
+	require.NoError(t, err)
+	fee := tx.Native.(*blockchain.TxRequest).Fee
+	assert.Equal(t, big.NewInt(10), fee.GasTipCap)
+	assert.Equal(t, big.NewInt(100), fee.GasFeeCap)
+
+	// A priority fee above the maximum is rejected
+	tm.gasPriceOracle = NewGasPriceOracle(tm.chains)
+	adapter.SetFee(&blockchain.FeeEstimate{GasPrice: big.NewInt(90), GasTipCap: big.NewInt(120), GasFeeCap: big.NewInt(150)})
+	_, err = tm.SubmitTransaction(context.Background(), "base", &Message{Type: MessageTypeLoanDisbursement})
+	assert.Error(t, err)
+
+	// Legacy chains keep the hard limit on the gas price
+	tm.gasPriceOracle = NewGasPriceOracle(tm.chains)
+	adapter.SetFee(&blockchain.FeeEstimate{GasPrice: big.NewInt(120)})
+	_, err = tm.SubmitTransaction(context.Background(), "base", &Message{Type: MessageTypeLoanDisbursement})
+	assert.Error(t, err)
+}
+
+func TestTransactionManager_ReplacesStuckTransaction(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321", Confirmations: 2})
+	adapter.Hold = true
+	adapter.SetFee(&blockchain.FeeEstimate{GasPrice: big.NewInt(100), GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(200)})
+	tm := newTestTransactionManager(t, adapter)
+	tm.stuckAfter = 0
+
+	var replaced []string
+	tm.OnReplaced = func(pendingTx *PendingTransaction, oldHash string) { replaced = append(replaced, oldHash) }
+
+	message := &Message{ID: "m1", Type: MessageTypeLoanDisbursement}
+	original, err := tm.SubmitTransaction(context.Background(), "base", message)
+	require.NoError(t, err)
+
+	tm.replaceStuckTransactions()
+
+	sent := adapter.Sent()
+	require.Len(t, sent, 2)
+	replacement := sent[1]
+	assert.Equal(t, original.Nonce, replacement.Nonce)
+	fee := replacement.Native.(*blockchain.TxRequest).Fee
+	assert.Equal(t, big.NewInt(12), fee.GasTipCap)
+	assert.Equal(t, big.NewInt(240), fee.GasFeeCap)
+	assert.Equal(t, []string{original.Hash}, replaced)
+
+	status, err := tm.GetTransactionStatus(original.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, TxStatusReplaced, status.Status)
+	assert.Equal(t, replacement.Hash, status.ReplacedBy)
+
+	// Only the replacement is mined
+	adapter.Mine(1)
+	_, err = adapter.TransactionReceipt(context.Background(), original.Hash)
+	assert.ErrorIs(t, err, blockchain.ErrTxNotFound)
+
+	pendingTx, err := tm.GetTransactionStatus(replacement.Hash)
+	require.NoError(t, err)
+	assert.False(t, tm.checkConfirmation(adapter, pendingTx))
+	assert.True(t, pendingTx.Mined)
+
+	// A mined transaction is never replaced
+	tm.replaceStuckTransactions()
+	assert.Len(t, adapter.Sent(), 2)
+
+	adapter.Mine(1)
+	assert.True(t, tm.checkConfirmation(adapter, pendingTx))
+	assert.Equal(t, TxStatusConfirmed, pendingTx.Status)
+	assert.Equal(t, replacement.Hash, message.TxHash)
+	assert.Empty(t, tm.GetPendingTransactions())
+}
+
+func TestTransactionManager_ReorgedTransaction(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321", Confirmations: 3})
+	tm := newTestTransactionManager(t, adapter)
+
+	tx, err := tm.SubmitTransaction(context.Background(), "base", &Message{ID: "m1", Type: MessageTypeLoanDisbursement})
+	require.NoError(t, err)
+	pendingTx, err := tm.GetTransactionStatus(tx.Hash)
+	require.NoError(t, err)
+	assert.False(t, tm.checkConfirmation(adapter, pendingTx))
+	minedIn := pendingTx.BlockHash
+	require.NotEmpty(t, minedIn)
+
+	// Re-included in the fork's block at the same height
+	adapter.Reorg(1, true)
+	assert.False(t, tm.checkConfirmation(adapter, pendingTx))
+	assert.True(t, pendingTx.Mined)
+	assert.NotEqual(t, minedIn, pendingTx.BlockHash)
+
+	// Dropped before reaching three confirmations
+	adapter.Mine(1)
+	adapter.Reorg(2, false)
+	assert.True(t, tm.checkConfirmation(adapter, pendingTx))
+	assert.Equal(t, TxStatusReorged, pendingTx.Status)
+	assert.False(t, pendingTx.Mined)
+	assert.Empty(t, tm.GetPendingTransactions())
+
+	// The resubmission takes over the nonce
+	assert.Equal(t, []uint64{tx.Nonce}, tm.nonces.Gaps("base", tm.publicAddress.Hex()))
+	resubmitted, err := tm.SubmitTransaction(context.Background(), "base", &Message{ID: "m1", Type: MessageTypeLoanDisbursement})
+	require.NoError(t, err)
+	assert.Equal(t, tx.Nonce, resubmitted.Nonce)
+}
+
+func TestTransactionManager_ReplacementWithinMaximum(t *testing.T) {
+	adapter := blockchain.NewFakeAdapter(config.ChainSpec{Key: "base", Type: config.ChainTypeEVM, EVMChainID: 8453, ContractAddress: "0x0987654321098765432109876543210987654321"})
+	adapter.Hold = true
+	adapter.SetFee(&blockchain.FeeEstimate{GasPrice: big.NewInt(100), GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100)})
+	tm := newTestTransactionManager(t, adapter)
+	tm.stuckAfter = 0
+	tm.maxGasPrice = big.NewInt(100)
+
+	_, err := tm.SubmitTransaction(context.Background(), "base", &Message{Type: MessageTypeLoanDisbursement})
+	require.NoError(t, err)
+
+	// The fee is already at the maximum, so no replacement can outbid it
+	tm.replaceStuckTransactions()
+	assert.Len(t, adapter.Sent(), 1)
+}
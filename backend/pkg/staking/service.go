@@ -4,8 +4,9 @@ import (
 	"context"
 	"time"
 
+	"kelo-backend/pkg/logger"
+
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 )
 
 // Service handles business logic for Staking
@@ -27,7 +28,7 @@ type Deposit struct {
 
 // DepositLiquidity simulates a staking deposit
 func (s *Service) DepositLiquidity(ctx context.Context, amount float64) (*Deposit, error) {
-	log.Info().Float64("amount", amount).Msg("Simulating staking deposit")
+	logger.FromContext(ctx).Info().Float64("amount", amount).Msg("Simulating staking deposit")
 
 	// In a real implementation, you would save the deposit to the database
 	return &Deposit{
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that records every span for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestInjectExtract_RoundTrip(t *testing.T) {
+	recordSpans(t)
+	assert.Nil(t, Inject(context.Background()))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+
+	background := context.Background()
+	assert.Equal(t, background, Extract(background, nil))
+}
+
+func TestGinMiddleware_ContinuesCallerTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware("test"))
+	var handlerSpan trace.SpanContext
+	router.GET("/loans/:id", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	ctx, caller := otel.Tracer("test").Start(context.Background(), "caller")
+	caller.End()
+	req := httptest.NewRequest(http.MethodGet, "/loans/42", nil)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	server := spans[1]
+	assert.Equal(t, "GET /loans/:id", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, caller.SpanContext().TraceID(), server.SpanContext().TraceID())
+	assert.Equal(t, caller.SpanContext().SpanID(), server.Parent().SpanID())
+	assert.Equal(t, server.SpanContext().SpanID(), handlerSpan.SpanID())
+	assert.Equal(t, codes.Error, server.Status().Code)
+}
+
+func TestInit_Exporters(t *testing.T) {
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout, ExporterOTLP} {
+		shutdown, err := Init(context.Background(), "kelo-test", exporter, 1)
+		require.NoError(t, err, exporter)
+		assert.NoError(t, shutdown(context.Background()), exporter)
+	}
+
+	_, err := Init(context.Background(), "kelo-test", "jaeger", 1)
+	assert.Error(t, err)
+}
@@ -7,10 +7,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"kelo-backend/pkg/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// MakeRequest performs an HTTP request and returns the response body.
-func MakeRequest(ctx context.Context, client *http.Client, method, url string, headers map[string]string, bodyData interface{}) ([]byte, error) {
+// MakeRequest performs an HTTP request and returns the response body. The request
+// runs in a client span and carries its trace context to the server.
+func MakeRequest(ctx context.Context, client *http.Client, method, url string, headers map[string]string, bodyData interface{}) (_ []byte, err error) {
+	ctx, span := otel.Tracer("kelo-backend/pkg/utils").Start(ctx, "HTTP "+method, trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	var body io.Reader
 	if bodyData != nil {
 		jsonData, err := json.Marshal(bodyData)
@@ -28,12 +42,19 @@ func MakeRequest(ctx context.Context, client *http.Client, method, url string, h
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	span.SetAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
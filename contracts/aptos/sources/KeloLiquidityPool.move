@@ -92,7 +92,7 @@ module KeloLiquidityPool::KeloLiquidityPool {
         Coin::deposit(account_addr, coin);
     }
 
-    public fun disburse<CoinType>(admin: &signer, to: address, amount: u64) acquires Pool {
+    public entry fun disburse<CoinType>(admin: &signer, to: address, amount: u64) acquires Pool {
         assert!(exists<AdminCap>(Signer::address_of(admin)), E_NOT_AUTHORIZED);
         let contract_addr = @KeloLiquidityPool;
         assert!(exists<Pool<CoinType>>(contract_addr), E_POOL_NOT_INITIALIZED);
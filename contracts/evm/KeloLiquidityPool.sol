@@ -12,9 +12,14 @@ contract KeloLiquidityPool is Ownable, ReentrancyGuard {
 
     mapping(address => mapping(address => uint256)) public deposits;
 
+    // Repayments confirmed by the relayer, per loan and in total
+    mapping(uint256 => uint256) public repaidByLoan;
+    uint256 public totalRepaid;
+
     event Deposit(address indexed token, address indexed user, uint256 amount);
     event Withdrawal(address indexed token, address indexed user, uint256 amount);
     event Disbursement(address indexed token, address indexed merchant, uint256 amount);
+    event RepaymentRecorded(uint256 indexed loanId, address indexed payer, uint256 amount, uint256 totalRepaid, uint64 timestamp);
     event RelayerUpdated(address indexed newRelayer);
 
     modifier onlyRelayer() {
@@ -69,6 +74,18 @@ contract KeloLiquidityPool is Ownable, ReentrancyGuard {
         emit Disbursement(_token, _merchant, _amount);
     }
 
+    function recordRepayment(uint256 _loanId, address _payer, uint256 _amount, uint256 _totalRepaid, uint64 _timestamp) public onlyRelayer {
+        // A confirmation may be delivered twice or out of order; a loan's total only grows
+        if (_totalRepaid <= repaidByLoan[_loanId]) {
+            return;
+        }
+
+        totalRepaid += _totalRepaid - repaidByLoan[_loanId];
+        repaidByLoan[_loanId] = _totalRepaid;
+
+        emit RepaymentRecorded(_loanId, _payer, _amount, _totalRepaid, _timestamp);
+    }
+
     function getDeposit(address _token, address _user) public view isSupportedToken(_token) returns (uint256) {
         return deposits[_token][_user];
     }
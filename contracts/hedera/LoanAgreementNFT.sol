@@ -17,6 +17,7 @@ contract LoanAgreementNFT is ERC721, Ownable {
     address public htsTokenAddress;
 
     struct LoanMetadata {
+        address merchant;
         uint256 loanAmount;
         uint256 interestRate;
         uint256 repaymentDeadline;
@@ -28,6 +29,8 @@ contract LoanAgreementNFT is ERC721, Ownable {
 
     event LoanAgreementCreated(
         uint256 indexed tokenId,
+        address indexed borrower,
+        address indexed merchant,
         uint256 loanAmount,
         uint256 interestRate,
         uint256 repaymentDeadline
@@ -43,6 +46,7 @@ contract LoanAgreementNFT is ERC721, Ownable {
     /**
      * @notice Mints a new Loan Agreement NFT associated with an HTS token.
      * @param to The address to mint the NFT to.
+     * @param merchant The merchant's EVM wallet, paid when the loan is disbursed.
      * @param loanAmount The principal amount of the loan.
      * @param interestRate The interest rate of the loan (e.g., in basis points).
      * @param repaymentDeadline The timestamp by which the loan must be repaid.
@@ -50,11 +54,14 @@ contract LoanAgreementNFT is ERC721, Ownable {
      */
     function mint(
         address to,
+        address merchant,
         uint256 loanAmount,
         uint256 interestRate,
         uint256 repaymentDeadline,
         bytes calldata metadata
     ) public onlyOwner returns (uint256) {
+        require(merchant != address(0), "INVALID_MERCHANT");
+
         // Prepare the metadata for the HTS mint call. HTS expects an array of metadata.
         bytes[] memory metadataArray = new bytes[](1);
         metadataArray[0] = metadata;
@@ -78,6 +85,7 @@ contract LoanAgreementNFT is ERC721, Ownable {
 
         // Store the loan metadata associated with the new serial number
         LoanMetadata memory newLoan = LoanMetadata({
+            merchant: merchant,
             loanAmount: loanAmount,
             interestRate: interestRate,
             repaymentDeadline: repaymentDeadline,
@@ -85,7 +93,7 @@ contract LoanAgreementNFT is ERC721, Ownable {
         });
         loanAgreements[newSerialNumber] = newLoan;
 
-        emit LoanAgreementCreated(newSerialNumber, loanAmount, interestRate, repaymentDeadline);
+        emit LoanAgreementCreated(newSerialNumber, to, merchant, loanAmount, interestRate, repaymentDeadline);
 
         return newSerialNumber;
     }
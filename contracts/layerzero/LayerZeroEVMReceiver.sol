@@ -2,58 +2,165 @@
 pragma solidity >=0.8.0 <0.9.0;
 
 import "@openzeppelin/contracts/access/Ownable.sol";
+import "@openzeppelin/contracts/utils/cryptography/ECDSA.sol";
+import "@openzeppelin/contracts/utils/cryptography/EIP712.sol";
 
 // Interface for the Kelo Liquidity Pool
 interface IKeloLiquidityPool {
     function disburse(address _token, address _merchant, uint256 _amount) external;
+    function recordRepayment(uint256 _loanId, address _payer, uint256 _amount, uint256 _totalRepaid, uint64 _timestamp) external;
 }
 
-// Interface for the LayerZero Endpoint
+// Where a LayerZero V2 message came from
+struct Origin {
+    uint32 srcEid;
+    bytes32 sender;
+    uint64 nonce;
+}
+
+// The receiver interface the LayerZero V2 endpoint calls (OApp)
 interface ILayerZeroReceiver {
-    function lzReceive(uint16 _srcChainId, bytes calldata _srcAddress, uint64 _nonce, bytes calldata _payload) external;
+    function allowInitializePath(Origin calldata _origin) external view returns (bool);
+
+    function nextNonce(uint32 _eid, bytes32 _sender) external view returns (uint64);
+
+    function lzReceive(
+        Origin calldata _origin,
+        bytes32 _guid,
+        bytes calldata _message,
+        address _executor,
+        bytes calldata _extraData
+    ) external payable;
 }
 
-contract LayerZeroEVMReceiver is Ownable, ILayerZeroReceiver {
-    address public layerZeroEndpoint;
+contract LayerZeroEVMReceiver is Ownable, EIP712, ILayerZeroReceiver {
+    address public immutable endpoint;
     IKeloLiquidityPool public keloLiquidityPool;
 
-    mapping(uint16 => bytes) public trustedRemotes;
+    // The trusted sender on each source endpoint ID
+    mapping(uint32 => bytes32) public peers;
+
+    // A relayer payload and the fields the relayer key signs over it (EIP-712)
+    struct RelayerMessage {
+        uint8 messageType;
+        bytes payload;
+        uint32 srcChainId;
+        uint256 nonce;
+        uint64 expiry;
+        uint32 signerSetVersion;
+    }
+
+    bytes32 private constant RELAYER_MESSAGE_TYPEHASH = keccak256(
+        "RelayerMessage(uint8 messageType,bytes payload,uint32 srcChainId,uint256 nonce,uint64 expiry,uint32 signerSetVersion)"
+    );
+
+    // Message types, numbered as in the relayer
+    uint8 private constant MESSAGE_TYPE_LOAN_DISBURSEMENT = 1;
+    uint8 private constant MESSAGE_TYPE_REPAYMENT_CONFIRMATION = 2;
+
+    // Relayer keys allowed to sign, by signer set version. Keys are rotated by adding a
+    // new version and raising minSignerSetVersion once the old one has drained.
+    mapping(uint32 => mapping(address => bool)) public signers;
+    uint32 public minSignerSetVersion;
+
+    mapping(uint256 => bool) public usedNonces;
 
-    event MessageReceived(uint16 indexed srcChainId, bytes srcAddress, uint64 nonce, bytes payload);
-    event TrustedRemoteSet(uint16 indexed srcChainId, bytes srcAddress);
+    event MessageReceived(uint32 indexed srcEid, bytes32 sender, uint64 nonce, bytes32 guid, bytes payload);
+    event PeerSet(uint32 indexed eid, bytes32 peer);
     event LiquidityPoolSet(address indexed poolAddress);
+    event SignerSet(uint32 indexed version, address indexed signer, bool allowed);
+    event MinSignerSetVersionSet(uint32 version);
 
-    constructor(address _layerZeroEndpoint, address _keloLiquidityPool) {
-        layerZeroEndpoint = _layerZeroEndpoint;
+    constructor(address _endpoint, address _keloLiquidityPool) EIP712("KeloRelayer", "1") {
+        require(_endpoint != address(0), "Invalid endpoint address");
+        endpoint = _endpoint;
         keloLiquidityPool = IKeloLiquidityPool(_keloLiquidityPool);
     }
 
+    // The endpoint opens a path only for a configured peer
+    function allowInitializePath(Origin calldata _origin) external view override returns (bool) {
+        return peers[_origin.srcEid] == _origin.sender;
+    }
+
+    // Messages may arrive in any order; replay is stopped by the relayer nonce instead
+    function nextNonce(uint32, bytes32) external pure override returns (uint64) {
+        return 0;
+    }
+
     function lzReceive(
-        uint16 _srcChainId,
-        bytes calldata _srcAddress,
-        uint64 _nonce,
-        bytes calldata _payload
-    ) external override {
+        Origin calldata _origin,
+        bytes32 _guid,
+        bytes calldata _message,
+        address,
+        bytes calldata
+    ) external payable override {
+        require(msg.sender == endpoint, "LayerZeroEVMReceiver: Caller is not the endpoint");
         require(
-            keccak256(trustedRemotes[_srcChainId]) == keccak256(_srcAddress),
+            peers[_origin.srcEid] != bytes32(0) && peers[_origin.srcEid] == _origin.sender,
             "LayerZeroEVMReceiver: Invalid source address"
         );
 
-        // Decode the payload to get disbursement details
-        (address token, address merchant, uint256 amount) = abi.decode(
-            _payload,
-            (address, address, uint256)
+        (RelayerMessage memory message, bytes memory signature) = abi.decode(_message, (RelayerMessage, bytes));
+        _verify(message, signature, _origin.srcEid);
+        _deliver(message.messageType, message.payload);
+
+        emit MessageReceived(_origin.srcEid, _origin.sender, _origin.nonce, _guid, message.payload);
+    }
+
+    // Checks the relayer's signature and uses up the message's nonce
+    function _verify(RelayerMessage memory _relayerMessage, bytes memory _signature, uint32 _srcEid) internal {
+        require(block.timestamp <= _relayerMessage.expiry, "LayerZeroEVMReceiver: Signature expired");
+        require(_relayerMessage.srcChainId == _srcEid, "LayerZeroEVMReceiver: Source chain mismatch");
+        require(
+            _relayerMessage.signerSetVersion >= minSignerSetVersion,
+            "LayerZeroEVMReceiver: Signer set retired"
+        );
+        require(!usedNonces[_relayerMessage.nonce], "LayerZeroEVMReceiver: Nonce already used");
+
+        bytes32 structHash = keccak256(
+            abi.encode(
+                RELAYER_MESSAGE_TYPEHASH,
+                _relayerMessage.messageType,
+                keccak256(_relayerMessage.payload),
+                _relayerMessage.srcChainId,
+                _relayerMessage.nonce,
+                _relayerMessage.expiry,
+                _relayerMessage.signerSetVersion
+            )
         );
+        address signer = ECDSA.recover(_hashTypedDataV4(structHash), _signature);
+        require(signers[_relayerMessage.signerSetVersion][signer], "LayerZeroEVMReceiver: Invalid signer");
 
-        // Call the disburse function on the liquidity pool
-        keloLiquidityPool.disburse(token, merchant, amount);
+        usedNonces[_relayerMessage.nonce] = true;
+    }
+
+    function _deliver(uint8 _messageType, bytes memory _payload) internal {
+        if (_messageType == MESSAGE_TYPE_LOAN_DISBURSEMENT) {
+            // Decode the payload to get disbursement details
+            (address token, address merchant, uint256 amount) = abi.decode(
+                _payload,
+                (address, address, uint256)
+            );
 
-        emit MessageReceived(_srcChainId, _srcAddress, _nonce, _payload);
+            // Call the disburse function on the liquidity pool
+            keloLiquidityPool.disburse(token, merchant, amount);
+        } else if (_messageType == MESSAGE_TYPE_REPAYMENT_CONFIRMATION) {
+            // Decode the payload to get repayment details
+            (uint256 loanId, address payer, uint256 amount, uint256 totalRepaid, uint64 timestamp) = abi.decode(
+                _payload,
+                (uint256, address, uint256, uint256, uint64)
+            );
+
+            // Record the repayment in the pool's accounting
+            keloLiquidityPool.recordRepayment(loanId, payer, amount, totalRepaid, timestamp);
+        } else {
+            revert("LayerZeroEVMReceiver: Unknown message type");
+        }
     }
 
-    function setTrustedRemote(uint16 _srcChainId, bytes calldata _srcAddress) public onlyOwner {
-        trustedRemotes[_srcChainId] = _srcAddress;
-        emit TrustedRemoteSet(_srcChainId, _srcAddress);
+    function setPeer(uint32 _eid, bytes32 _peer) public onlyOwner {
+        peers[_eid] = _peer;
+        emit PeerSet(_eid, _peer);
     }
 
     function setKeloLiquidityPool(address _keloLiquidityPool) public onlyOwner {
@@ -61,4 +168,15 @@ contract LayerZeroEVMReceiver is Ownable, ILayerZeroReceiver {
         keloLiquidityPool = IKeloLiquidityPool(_keloLiquidityPool);
         emit LiquidityPoolSet(_keloLiquidityPool);
     }
+
+    function setSigner(uint32 _version, address _signer, bool _allowed) public onlyOwner {
+        require(_signer != address(0), "Invalid signer address");
+        signers[_version][_signer] = _allowed;
+        emit SignerSet(_version, _signer, _allowed);
+    }
+
+    function setMinSignerSetVersion(uint32 _version) public onlyOwner {
+        minSignerSetVersion = _version;
+        emit MinSignerSetVersionSet(_version);
+    }
 }
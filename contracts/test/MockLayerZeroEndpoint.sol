@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: MIT
+pragma solidity >=0.8.0 <0.9.0;
+
+import "../layerzero/LayerZeroEVMReceiver.sol";
+
+// Stands in for the LayerZero V2 endpoint in the relayer's end-to-end tests. It charges
+// a fixed fee and delivers every message at once by calling lzReceive on the receiver,
+// as if the source and destination chains were the same chain.
+contract MockLayerZeroEndpoint {
+    struct MessagingParams {
+        uint32 dstEid;
+        bytes32 receiver;
+        bytes message;
+        bytes options;
+        bool payInLzToken;
+    }
+
+    struct MessagingFee {
+        uint256 nativeFee;
+        uint256 lzTokenFee;
+    }
+
+    struct MessagingReceipt {
+        bytes32 guid;
+        uint64 nonce;
+        MessagingFee fee;
+    }
+
+    uint32 public immutable eid;
+    uint256 public nativeFee;
+
+    mapping(address => mapping(uint32 => mapping(bytes32 => uint64))) public outboundNonce;
+
+    // Emitted by EndpointV2 with the encoded packet: version, nonce, source EID, sender,
+    // destination EID, receiver, GUID and message
+    event PacketSent(bytes encodedPayload, bytes options, address sendLibrary);
+    event PacketDelivered(bytes32 indexed guid, uint32 dstEid, bytes32 receiver);
+
+    constructor(uint32 _eid, uint256 _nativeFee) {
+        eid = _eid;
+        nativeFee = _nativeFee;
+    }
+
+    function quote(MessagingParams calldata, address) external view returns (MessagingFee memory) {
+        return MessagingFee(nativeFee, 0);
+    }
+
+    function send(
+        MessagingParams calldata _params,
+        address _refundAddress
+    ) external payable returns (MessagingReceipt memory) {
+        require(msg.value >= nativeFee, "MockLayerZeroEndpoint: Insufficient fee");
+
+        uint64 nonce = ++outboundNonce[msg.sender][_params.dstEid][_params.receiver];
+        bytes32 sender = bytes32(uint256(uint160(msg.sender)));
+        bytes32 guid = keccak256(abi.encodePacked(nonce, eid, sender, _params.dstEid, _params.receiver));
+
+        ILayerZeroReceiver(address(uint160(uint256(_params.receiver)))).lzReceive(
+            Origin(eid, sender, nonce),
+            guid,
+            _params.message,
+            address(this),
+            ""
+        );
+
+        if (msg.value > nativeFee) {
+            payable(_refundAddress).transfer(msg.value - nativeFee);
+        }
+        emit PacketSent(
+            abi.encodePacked(uint8(1), nonce, eid, sender, _params.dstEid, _params.receiver, guid, _params.message),
+            _params.options,
+            address(this)
+        );
+        emit PacketDelivered(guid, _params.dstEid, _params.receiver);
+        return MessagingReceipt(guid, nonce, MessagingFee(nativeFee, 0));
+    }
+}
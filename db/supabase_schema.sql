@@ -301,4 +301,563 @@ USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
 -- RLS Policies for Users
 CREATE POLICY "Users can view their own transactions" ON public.transactions FOR SELECT
 TO authenticated
-USING (user_id = auth.uid());
\ No newline at end of file
+USING (user_id = auth.uid());
+
+--
+-- 6. Loan Provisions Table
+--
+CREATE TABLE public.loan_provisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    as_of TIMESTAMPTZ NOT NULL,
+    loan_id UUID NOT NULL REFERENCES public.loans(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES public.profiles(id) ON DELETE SET NULL,
+    vintage TEXT NOT NULL,
+    days_past_due INTEGER NOT NULL DEFAULT 0,
+    dpd_bucket TEXT NOT NULL,
+    score_band TEXT NOT NULL,
+    exposure NUMERIC(20, 8) NOT NULL,
+    pd NUMERIC(10, 6) NOT NULL,
+    lgd NUMERIC(10, 6) NOT NULL,
+    ecl NUMERIC(20, 8) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.loan_provisions IS 'Expected credit loss provisions posted per loan for each provisioning run.';
+CREATE INDEX idx_loan_provisions_as_of ON public.loan_provisions(as_of);
+CREATE INDEX idx_loan_provisions_loan_id ON public.loan_provisions(loan_id);
+
+-- Enable RLS for the new table
+ALTER TABLE public.loan_provisions ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all loan provisions" ON public.loan_provisions FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+
+--
+-- 7. Loan Funding Allocation
+--
+-- Each liquidity pool is deployed on one chain and holds one asset. The relayer's
+-- allocation engine picks a single pool per loan and records it on the loan.
+ALTER TABLE public.liquidity_pools
+    ADD COLUMN chain_id TEXT,
+    ADD COLUMN asset TEXT,
+    ADD COLUMN total_borrowed NUMERIC(15, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN utilization_cap NUMERIC(5, 4) NOT NULL DEFAULT 0.9,
+    ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE public.loans
+    ADD COLUMN onchain_id TEXT UNIQUE,
+    ADD COLUMN funding_pool_id UUID REFERENCES public.liquidity_pools(id) ON DELETE SET NULL,
+    ADD COLUMN funding_chain TEXT,
+    ADD COLUMN allocated_at TIMESTAMPTZ;
+
+CREATE INDEX idx_loans_funding_pool_id ON public.loans(funding_pool_id);
+
+
+--
+-- 8. LP Statements
+--
+-- Pool deposits, withdrawals, interest and absorbed losses are recorded as transactions
+-- against the pool so investor statements can be generated from them.
+ALTER TABLE public.transactions
+    ADD COLUMN pool_id UUID REFERENCES public.liquidity_pools(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_pool_id ON public.transactions(pool_id);
+
+CREATE TABLE public.pool_share_prices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pool_id UUID NOT NULL REFERENCES public.liquidity_pools(id) ON DELETE CASCADE,
+    share_price NUMERIC(20, 8) NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.pool_share_prices IS 'Share price history for each liquidity pool, shown on LP statements.';
+CREATE INDEX idx_pool_share_prices_pool_id_recorded_at ON public.pool_share_prices(pool_id, recorded_at);
+
+-- Enable RLS for the new table
+ALTER TABLE public.pool_share_prices ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all pool share prices" ON public.pool_share_prices FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- RLS Policies for Public/Anonymous Access
+CREATE POLICY "Public can view pool share prices" ON public.pool_share_prices FOR SELECT TO anon, authenticated USING (true);
+
+
+--
+-- 9. Merchant Wallets Table
+--
+-- Loan events identify merchants by their EVM wallet. Disbursements to Solana and Aptos
+-- pools need the merchant's wallet on that chain.
+CREATE TABLE public.merchant_wallets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES public.merchants(id) ON DELETE CASCADE,
+    chain_id TEXT NOT NULL,
+    address TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (merchant_id, chain_id)
+);
+
+COMMENT ON TABLE public.merchant_wallets IS 'Merchant payout wallets on non-EVM chains, keyed by relayer chain ID.';
+
+-- Enable RLS for the new table
+ALTER TABLE public.merchant_wallets ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all merchant wallets" ON public.merchant_wallets FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- RLS Policies for Merchants
+CREATE POLICY "Merchants can manage their own wallets" ON public.merchant_wallets FOR ALL
+TO authenticated
+USING (merchant_id = auth.uid());
+
+
+--
+-- 10. Relayer Outbox
+--
+-- Durable queue of cross-chain messages. Relayer instances lease messages through
+-- claim_relayer_messages so that each message is processed by one instance at a time.
+CREATE TABLE public.relayer_messages (
+    id TEXT PRIMARY KEY,
+    type SMALLINT NOT NULL,
+    chain_id TEXT NOT NULL,
+    payload TEXT NOT NULL, -- base64
+    payload_version SMALLINT NOT NULL DEFAULT 1, -- ABI layout of the payload
+    signature TEXT,
+    status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'PROCESSING', 'SENT', 'CONFIRMED', 'FAILED', 'RETRYING')),
+    retry_count INTEGER NOT NULL DEFAULT 0,
+    tx_hash TEXT,
+    tx_chain_id TEXT,
+    tx_hashes JSONB, -- all hashes broadcast for tx_hash's nonce once it has been replaced
+    layerzero_guid TEXT, -- LayerZero V2 message GUID, for delivery tracking
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    lease_owner TEXT,
+    lease_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_messages IS 'Relayer outbox. IDs are derived from the source event so replays are idempotent.';
+
+CREATE INDEX relayer_messages_due_idx ON public.relayer_messages (status, next_attempt_at);
+
+-- Leases up to p_limit due messages, including those whose previous lease expired.
+CREATE OR REPLACE FUNCTION public.claim_relayer_messages(p_owner TEXT, p_limit INTEGER, p_lease_seconds INTEGER)
+RETURNS SETOF public.relayer_messages
+LANGUAGE sql
+AS $$
+    UPDATE public.relayer_messages m
+    SET status = 'PROCESSING',
+        lease_owner = p_owner,
+        lease_expires_at = NOW() + make_interval(secs => p_lease_seconds),
+        updated_at = NOW()
+    WHERE m.id IN (
+        SELECT id FROM public.relayer_messages
+        WHERE (status IN ('PENDING', 'RETRYING') AND next_attempt_at <= NOW())
+           OR (status = 'PROCESSING' AND lease_expires_at < NOW())
+        ORDER BY next_attempt_at
+        LIMIT p_limit
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING m.*;
+$$;
+
+-- Enable RLS for the new table
+ALTER TABLE public.relayer_messages ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer messages" ON public.relayer_messages FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- 11. Relayer Nonces
+--
+-- Next unreserved transaction nonce per chain and signing account. Reservations go
+-- through reserve_relayer_nonce so a restarted or concurrent relayer never reuses a
+-- nonce it may already have signed with.
+CREATE TABLE public.relayer_nonces (
+    chain_id TEXT NOT NULL,
+    signer TEXT NOT NULL, -- lowercase account address
+    next_nonce BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, signer)
+);
+
+COMMENT ON TABLE public.relayer_nonces IS 'Transaction nonces reserved by the relayer, per chain and signer.';
+
+-- Reserves the larger of the stored next nonce and p_floor, the chain's pending nonce.
+CREATE OR REPLACE FUNCTION public.reserve_relayer_nonce(p_chain_id TEXT, p_signer TEXT, p_floor BIGINT)
+RETURNS BIGINT
+LANGUAGE sql
+AS $$
+    INSERT INTO public.relayer_nonces AS n (chain_id, signer, next_nonce)
+    VALUES (p_chain_id, p_signer, p_floor + 1)
+    ON CONFLICT (chain_id, signer) DO UPDATE
+    SET next_nonce = GREATEST(n.next_nonce, p_floor) + 1,
+        updated_at = NOW()
+    RETURNING n.next_nonce - 1;
+$$;
+
+-- Enable RLS for the new table
+ALTER TABLE public.relayer_nonces ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer nonces" ON public.relayer_nonces FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- 12. Relayer Cursors
+--
+-- How far the relayer has read each Hedera mirror node stream, as the consensus
+-- timestamp of the last record handled.
+CREATE TABLE public.relayer_cursors (
+    stream TEXT PRIMARY KEY, -- e.g. contract_logs:0.0.1234 or topic_messages:0.0.5678
+    consensus_timestamp TEXT NOT NULL, -- seconds.nanoseconds
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_cursors IS 'Mirror node read positions of the relayer''s Hedera listener.';
+
+-- Enable RLS for the new table
+ALTER TABLE public.relayer_cursors ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer cursors" ON public.relayer_cursors FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- 13. Loan Disbursements
+--
+-- Set by the relayer when Hedera confirms that the merchant has been paid.
+ALTER TABLE public.loans
+    ADD COLUMN disbursed_at TIMESTAMPTZ;
+
+-- 14. Relayer Dead Letters
+--
+-- Messages that used up their retries, kept for operators to inspect, edit and replay
+-- or discard. Every operator action is recorded in relayer_audit_log.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN attempts JSONB, -- failed delivery attempts, oldest first
+    ADD COLUMN gas_limit BIGINT; -- operator override of the destination gas limit
+
+CREATE TABLE public.relayer_dead_letters (
+    id UUID PRIMARY KEY,
+    message_id TEXT NOT NULL REFERENCES public.relayer_messages(id) ON DELETE CASCADE,
+    message_type SMALLINT NOT NULL,
+    chain_id TEXT NOT NULL, -- chain the message is replayed to
+    gas_limit BIGINT, -- gas limit the message is replayed with; NULL or 0 uses the chain's
+    reason TEXT NOT NULL,
+    attempts JSONB NOT NULL DEFAULT '[]',
+    last_tx_hash TEXT,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'replayed', 'discarded')),
+    resolved_by TEXT, -- user ID of the admin who replayed or discarded it
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_dead_letters IS 'Failed relayer messages awaiting operator action.';
+
+CREATE INDEX relayer_dead_letters_status_idx ON public.relayer_dead_letters (status, created_at DESC);
+
+CREATE TABLE public.relayer_audit_log (
+    id UUID PRIMARY KEY,
+    actor TEXT NOT NULL, -- user ID of the admin
+    action TEXT NOT NULL, -- e.g. dead_letter.replay
+    resource_type TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    details JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_audit_log IS 'Append-only record of operator actions on the relayer.';
+
+CREATE INDEX relayer_audit_log_resource_idx ON public.relayer_audit_log (resource_type, resource_id);
+
+-- Enable RLS for the new tables
+ALTER TABLE public.relayer_dead_letters ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.relayer_audit_log ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer dead letters" ON public.relayer_dead_letters FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- The audit log can be read but not changed
+CREATE POLICY "Admins can view the relayer audit log" ON public.relayer_audit_log FOR SELECT
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- 15. Relayer Message Signatures
+--
+-- Every message is signed (EIP-712) by the relayer key for its destination receiver
+-- and signed again on each attempt. signature holds the latest signature.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN signer_set_version INTEGER, -- signer set version of the key that signed it
+    ADD COLUMN signature_expiry TIMESTAMPTZ;
+
+-- 16. Relayer Reorg Tracking
+--
+-- The block a sent message's transaction was mined in. It is re-checked until the
+-- transaction is final; if a reorg drops the block the message returns to PENDING.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN block_hash TEXT,
+    ADD COLUMN block_number BIGINT;
+
+-- 17. Relayer Trace Context
+--
+-- The W3C trace context (traceparent, tracestate) of the event that queued a message,
+-- so its delivery is traced as part of the request that caused it.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN trace_context JSONB;
+
+-- 18. Relayer Transaction Expiry
+--
+-- Solana and Aptos transactions expire: tx_expiry is the last valid block height on
+-- Solana and the expiration timestamp on Aptos. A sent message whose transaction is
+-- still not found after it expired returns to PENDING. tx_nonce is the Aptos sequence
+-- number, reused once the transaction expired.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN tx_expiry BIGINT,
+    ADD COLUMN tx_nonce BIGINT;
+
+-- 19. Atomic Loan Allocation
+--
+-- Records the relayer's pool choice on a loan and reserves the amount in the pool in
+-- one transaction. A loan that already has a funding pool keeps it and nothing is
+-- reserved again, so a replayed approval is harmless. The reservation fails with
+-- 'insufficient liquidity' when the pool no longer has the free liquidity or headroom
+-- under its utilization cap, so the relayer allocates again. Returns the loan's pool and
+-- chain.
+CREATE OR REPLACE FUNCTION public.record_loan_allocation(
+    p_loan_id TEXT,
+    p_pool_id UUID,
+    p_chain_id TEXT,
+    p_amount NUMERIC,
+    p_allocated_at TIMESTAMPTZ
+)
+RETURNS TABLE (recorded_pool_id UUID, recorded_chain_id TEXT)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    -- Lock the loan so concurrent allocations of it are serialized
+    SELECT l.funding_pool_id, l.funding_chain INTO recorded_pool_id, recorded_chain_id
+    FROM public.loans l
+    WHERE l.onchain_id = p_loan_id
+    FOR UPDATE;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'loan not found: %', p_loan_id;
+    END IF;
+    IF recorded_pool_id IS NOT NULL THEN
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    -- The pool row is locked by the update, so concurrent allocations cannot overcommit it
+    UPDATE public.liquidity_pools p
+    SET total_borrowed = p.total_borrowed + p_amount
+    WHERE p.id = p_pool_id
+      AND p.total_staked - p.total_borrowed >= p_amount
+      AND p.total_borrowed + p_amount <= p.total_staked * COALESCE(NULLIF(p.utilization_cap, 0), 0.9);
+    IF NOT FOUND THEN
+        IF NOT EXISTS (SELECT 1 FROM public.liquidity_pools WHERE id = p_pool_id) THEN
+            RAISE EXCEPTION 'liquidity pool not found: %', p_pool_id;
+        END IF;
+        RAISE EXCEPTION 'insufficient liquidity in pool %', p_pool_id;
+    END IF;
+
+    UPDATE public.loans l
+    SET funding_pool_id = p_pool_id,
+        funding_chain = p_chain_id,
+        allocated_at = p_allocated_at
+    WHERE l.onchain_id = p_loan_id;
+
+    recorded_pool_id := p_pool_id;
+    recorded_chain_id := p_chain_id;
+    RETURN NEXT;
+END;
+$$;
+
+-- 20. Relayer Limit Usage
+--
+-- Messages sent, loan asset disbursed and gas spent per chain, counted against the
+-- relayer's submission limits. Every relayer instance checks and records usage here, so
+-- the limits hold across instances and restarts. Rows older than a day are pruned.
+-- A send reserves its usage with reserve_relayer_limit_usage before it is sent, and
+-- the reserved rows are deleted again if the send fails.
+CREATE TABLE public.relayer_limit_usage (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('messages', 'disbursed', 'gas')),
+    amount NUMERIC NOT NULL, -- messages, whole loan asset tokens, or native coin
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_limit_usage IS 'Usage counted against the relayer''s per-chain submission limits.';
+
+CREATE INDEX relayer_limit_usage_chain_idx ON public.relayer_limit_usage (chain_id, recorded_at);
+
+-- Enable RLS for the new table
+ALTER TABLE public.relayer_limit_usage ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer limit usage" ON public.relayer_limit_usage FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+-- Checks a send's usage against the chain's limits and records it in one transaction.
+-- Reservations for a chain are serialized, so concurrent relayer instances cannot both
+-- fit under the same limit. p_records is [{kind, amount}] and p_caps is
+-- [{limit, kind, window_seconds, max}]. Returns {"ids": [...]} of the recorded rows, or
+-- {"exceeded": limit} with nothing recorded.
+CREATE OR REPLACE FUNCTION public.reserve_relayer_limit_usage(
+    p_chain_id TEXT,
+    p_recorded_at TIMESTAMPTZ,
+    p_records JSONB,
+    p_caps JSONB
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    cap JSONB;
+    used NUMERIC;
+    ids BIGINT[];
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('relayer_limit_usage:' || p_chain_id));
+
+    FOR cap IN SELECT value FROM jsonb_array_elements(p_caps) LOOP
+        SELECT COALESCE(SUM(u.amount), 0) INTO used
+        FROM public.relayer_limit_usage u
+        WHERE u.chain_id = p_chain_id
+          AND u.kind = cap ->> 'kind'
+          AND u.recorded_at > p_recorded_at - make_interval(secs => (cap ->> 'window_seconds')::DOUBLE PRECISION);
+
+        SELECT used + COALESCE(SUM((r ->> 'amount')::NUMERIC), 0) INTO used
+        FROM jsonb_array_elements(p_records) r
+        WHERE r ->> 'kind' = cap ->> 'kind';
+
+        IF used > (cap ->> 'max')::NUMERIC THEN
+            RETURN jsonb_build_object('exceeded', cap ->> 'limit');
+        END IF;
+    END LOOP;
+
+    WITH inserted AS (
+        INSERT INTO public.relayer_limit_usage (chain_id, kind, amount, recorded_at)
+        SELECT p_chain_id, r ->> 'kind', (r ->> 'amount')::NUMERIC, p_recorded_at
+        FROM jsonb_array_elements(p_records) r
+        RETURNING id
+    )
+    SELECT array_agg(id) INTO ids FROM inserted;
+
+    RETURN jsonb_build_object('ids', COALESCE(to_jsonb(ids), '[]'::JSONB));
+END;
+$$;
+
+-- 21. Relayer Message Loans and Allocation Moves
+--
+-- loan_id ties a disbursement or repayment confirmation to its loan. A dead-lettered
+-- disbursement replayed to another chain moves the loan's reservation with
+-- move_loan_allocation: the old pool is released and the new one reserved in one
+-- transaction. A loan already funded from the target chain keeps its pool.
+ALTER TABLE public.relayer_messages
+    ADD COLUMN loan_id TEXT;
+
+CREATE OR REPLACE FUNCTION public.move_loan_allocation(
+    p_loan_id TEXT,
+    p_pool_id UUID,
+    p_chain_id TEXT,
+    p_amount NUMERIC,
+    p_allocated_at TIMESTAMPTZ
+)
+RETURNS TABLE (recorded_pool_id UUID, recorded_chain_id TEXT)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    SELECT l.funding_pool_id, l.funding_chain INTO recorded_pool_id, recorded_chain_id
+    FROM public.loans l
+    WHERE l.onchain_id = p_loan_id
+    FOR UPDATE;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'loan not found: %', p_loan_id;
+    END IF;
+    IF recorded_pool_id IS NULL THEN
+        RAISE EXCEPTION 'loan has no allocation: %', p_loan_id;
+    END IF;
+    IF recorded_chain_id = p_chain_id THEN
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    UPDATE public.liquidity_pools p
+    SET total_borrowed = GREATEST(p.total_borrowed - p_amount, 0)
+    WHERE p.id = recorded_pool_id;
+
+    -- The pool row is locked by the update, so concurrent allocations cannot overcommit it
+    UPDATE public.liquidity_pools p
+    SET total_borrowed = p.total_borrowed + p_amount
+    WHERE p.id = p_pool_id
+      AND p.total_staked - p.total_borrowed >= p_amount
+      AND p.total_borrowed + p_amount <= p.total_staked * COALESCE(NULLIF(p.utilization_cap, 0), 0.9);
+    IF NOT FOUND THEN
+        IF NOT EXISTS (SELECT 1 FROM public.liquidity_pools WHERE id = p_pool_id) THEN
+            RAISE EXCEPTION 'liquidity pool not found: %', p_pool_id;
+        END IF;
+        RAISE EXCEPTION 'insufficient liquidity in pool %', p_pool_id;
+    END IF;
+
+    UPDATE public.loans l
+    SET funding_pool_id = p_pool_id,
+        funding_chain = p_chain_id,
+        allocated_at = p_allocated_at
+    WHERE l.onchain_id = p_loan_id;
+
+    recorded_pool_id := p_pool_id;
+    recorded_chain_id := p_chain_id;
+    RETURN NEXT;
+END;
+$$;
+
+-- 22. Relayer Controls
+--
+-- Operator overrides of each chain and the relayer-wide dry-run mode. Every relayer
+-- instance reloads them on each outbox poll, so a chain paused through one instance is
+-- paused on all of them. NULL enabled and gas_limit keep the chain's configured value.
+CREATE TABLE public.relayer_chain_controls (
+    chain_id TEXT PRIMARY KEY,
+    state TEXT NOT NULL DEFAULT 'active' CHECK (state IN ('active', 'paused', 'draining')),
+    reason TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN,
+    gas_limit BIGINT CHECK (gas_limit > 0),
+    updated_by TEXT NOT NULL, -- user ID of the admin who last changed it
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE public.relayer_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id), -- a single row
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE public.relayer_chain_controls IS 'Operator overrides of each chain the relayer sends to.';
+COMMENT ON TABLE public.relayer_settings IS 'Relayer-wide operator settings.';
+
+-- Enable RLS for the new tables
+ALTER TABLE public.relayer_chain_controls ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.relayer_settings ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for Admins
+CREATE POLICY "Admins can manage all relayer chain controls" ON public.relayer_chain_controls FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');
+
+CREATE POLICY "Admins can manage relayer settings" ON public.relayer_settings FOR ALL
+TO authenticated
+USING ((auth.jwt() -> 'app_metadata' ->> 'role') = 'admin');